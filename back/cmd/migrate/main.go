@@ -22,6 +22,7 @@ func main() {
 		Net:                  "tcp",
 		AllowNativePasswords: true,
 		ParseTime:            true,
+		MultiStatements:      true,
 	})
 	if err != nil {
 		log.Fatal(err)
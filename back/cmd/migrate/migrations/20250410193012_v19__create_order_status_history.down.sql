@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS order_status_history;
+
+UPDATE order_history SET `status` = 'PENDING' WHERE `status` = 'PAID';
+UPDATE order_history SET `status` = 'COMPLETED' WHERE `status` = 'DELIVERED';
+
+ALTER TABLE order_history
+    MODIFY COLUMN `status` ENUM('PENDING', 'COMPLETED', 'CANCELLED', 'SHIPPED') NOT NULL DEFAULT 'PENDING';
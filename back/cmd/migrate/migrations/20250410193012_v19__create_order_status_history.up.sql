@@ -0,0 +1,16 @@
+ALTER TABLE order_history
+    MODIFY COLUMN `status` ENUM('PENDING', 'PAID', 'SHIPPED', 'DELIVERED', 'COMPLETED', 'CANCELLED') NOT NULL DEFAULT 'PENDING';
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `fromStatus` ENUM('PENDING', 'PAID', 'SHIPPED', 'DELIVERED', 'COMPLETED', 'CANCELLED'),
+    `toStatus` ENUM('PENDING', 'PAID', 'SHIPPED', 'DELIVERED', 'COMPLETED', 'CANCELLED') NOT NULL,
+    `changedBy` INT UNSIGNED,
+    `note` VARCHAR(255),
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`changedBy`) REFERENCES users(`id`) ON DELETE SET NULL,
+    INDEX `idx_status_history_order` (`orderId`)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pix_charges;
\ No newline at end of file
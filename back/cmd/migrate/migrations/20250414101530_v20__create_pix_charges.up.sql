@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS pix_charges (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `txid` VARCHAR(35) NOT NULL,
+    `brCode` TEXT NOT NULL,
+    `amount` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `expiresAt` TIMESTAMP NOT NULL,
+    `paidAt` TIMESTAMP NULL DEFAULT NULL,
+    `endToEndId` VARCHAR(32),
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_pix_txid` (`txid`),
+    UNIQUE KEY `uq_pix_order` (`orderId`),
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS refunds (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `amount` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `reason` VARCHAR(255),
+    `restocked` BOOLEAN NOT NULL DEFAULT FALSE,
+    `createdBy` INT UNSIGNED,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`createdBy`) REFERENCES users(`id`) ON DELETE SET NULL,
+    INDEX `idx_refund_order` (`orderId`)
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    `refundId` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `quantity` INT UNSIGNED NOT NULL,
+    `amount` DECIMAL(10,2) UNSIGNED NOT NULL,
+    PRIMARY KEY (`refundId`, `productId`),
+    FOREIGN KEY (`refundId`) REFERENCES refunds(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderId`, `productId`) REFERENCES order_items(`orderId`, `productId`) ON DELETE CASCADE,
+    INDEX `idx_refund_item_order` (`orderId`, `productId`)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE inventory
+DROP COLUMN max_purchase_quantity;
@@ -0,0 +1,2 @@
+ALTER TABLE inventory
+ADD COLUMN max_purchase_quantity INT UNSIGNED NULL DEFAULT NULL AFTER stock_quantity;
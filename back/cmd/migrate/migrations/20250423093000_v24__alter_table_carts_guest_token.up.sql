@@ -0,0 +1,4 @@
+ALTER TABLE carts
+MODIFY COLUMN `userId` INT UNSIGNED NULL,
+ADD COLUMN `guestToken` CHAR(43) NULL DEFAULT NULL AFTER `userId`,
+ADD UNIQUE KEY `uq_carts_guest_token` (`guestToken`);
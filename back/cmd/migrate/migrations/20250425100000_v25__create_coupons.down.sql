@@ -0,0 +1,8 @@
+ALTER TABLE carts
+DROP FOREIGN KEY `fk_carts_coupon`,
+DROP COLUMN `couponId`;
+
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupon_categories;
+DROP TABLE IF EXISTS coupon_products;
+DROP TABLE IF EXISTS coupons;
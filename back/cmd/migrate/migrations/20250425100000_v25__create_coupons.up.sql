@@ -0,0 +1,49 @@
+CREATE TABLE IF NOT EXISTS coupons (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `code` VARCHAR(50) NOT NULL,
+    `discountType` ENUM('PERCENTAGE', 'FIXED') NOT NULL,
+    `value` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `minCartValue` DECIMAL(10,2) UNSIGNED NOT NULL DEFAULT 0,
+    `maxUses` INT UNSIGNED NULL DEFAULT NULL,
+    `maxUsesPerUser` INT UNSIGNED NULL DEFAULT NULL,
+    `expiresAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_coupons_code` (`code`)
+);
+
+CREATE TABLE IF NOT EXISTS coupon_products (
+    `couponId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`couponId`, `productId`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS coupon_categories (
+    `couponId` INT UNSIGNED NOT NULL,
+    `categoryId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`couponId`, `categoryId`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`categoryId`) REFERENCES categories(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `couponId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `amount` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_coupon_redemptions_order` (`orderId`),
+    FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE,
+    INDEX `idx_coupon_redemptions_user` (`couponId`, `userId`)
+);
+
+ALTER TABLE carts
+ADD COLUMN `couponId` INT UNSIGNED NULL DEFAULT NULL AFTER `guestToken`,
+ADD CONSTRAINT `fk_carts_coupon` FOREIGN KEY (`couponId`) REFERENCES coupons(`id`) ON DELETE SET NULL;
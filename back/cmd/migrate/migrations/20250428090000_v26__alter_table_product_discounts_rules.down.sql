@@ -0,0 +1,10 @@
+DELETE FROM product_discounts WHERE discountType <> 'PERCENTAGE';
+
+ALTER TABLE product_discounts
+DROP COLUMN `priority`,
+DROP COLUMN `stackable`,
+DROP COLUMN `getQuantity`,
+DROP COLUMN `buyQuantity`,
+DROP COLUMN `fixedPrice`,
+DROP COLUMN `discountType`,
+MODIFY COLUMN `discountPercent` DECIMAL(5,2) UNSIGNED NOT NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE product_discounts
+ADD COLUMN `discountType` ENUM('PERCENTAGE', 'FIXED_PRICE', 'BUY_X_GET_Y') NOT NULL DEFAULT 'PERCENTAGE' AFTER `productId`,
+MODIFY COLUMN `discountPercent` DECIMAL(5,2) UNSIGNED NOT NULL DEFAULT 0,
+ADD COLUMN `fixedPrice` DECIMAL(10,2) UNSIGNED NULL DEFAULT NULL AFTER `discountPercent`,
+ADD COLUMN `buyQuantity` INT UNSIGNED NULL DEFAULT NULL AFTER `fixedPrice`,
+ADD COLUMN `getQuantity` INT UNSIGNED NULL DEFAULT NULL AFTER `buyQuantity`,
+ADD COLUMN `stackable` BOOLEAN NOT NULL DEFAULT FALSE AFTER `getQuantity`,
+ADD COLUMN `priority` INT NOT NULL DEFAULT 0 AFTER `stackable`;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `name` VARCHAR(100) NOT NULL,
+    `scope` ENUM('CATEGORY', 'PRODUCTS', 'STORE') NOT NULL,
+    `categoryId` INT UNSIGNED NULL DEFAULT NULL,
+    `discountType` ENUM('PERCENTAGE', 'BUY_X_GET_Y') NOT NULL DEFAULT 'PERCENTAGE',
+    `discountPercent` DECIMAL(5,2) UNSIGNED NOT NULL,
+    `buyQuantity` INT UNSIGNED NULL DEFAULT NULL,
+    `getQuantity` INT UNSIGNED NULL DEFAULT NULL,
+    `stackable` BOOLEAN NOT NULL DEFAULT FALSE,
+    `priority` INT NOT NULL DEFAULT 0,
+    `startDate` DATETIME NOT NULL,
+    `endDate` DATETIME NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`categoryId`) REFERENCES categories(`id`) ON DELETE CASCADE,
+    INDEX `idx_campaigns_schedule` (`startDate`, `endDate`)
+);
+
+CREATE TABLE IF NOT EXISTS campaign_products (
+    `campaignId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`campaignId`, `productId`),
+    FOREIGN KEY (`campaignId`) REFERENCES campaigns(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE products
+DROP INDEX `ft_products_title_description`,
+DROP INDEX `ft_products_title`;
@@ -0,0 +1,9 @@
+-- an accent insensitive collation lets "cafe" find "café"; the FULLTEXT
+-- indexes compare words with the collation of their columns
+ALTER TABLE products
+MODIFY COLUMN `title` VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL,
+MODIFY COLUMN `description` TEXT CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci;
+
+ALTER TABLE products
+ADD FULLTEXT INDEX `ft_products_title` (`title`),
+ADD FULLTEXT INDEX `ft_products_title_description` (`title`, `description`);
@@ -0,0 +1,41 @@
+DELETE FROM refund_items WHERE variantId <> 0;
+DELETE FROM order_items WHERE variantId <> 0;
+DELETE FROM inventory_reservations WHERE variantId <> 0;
+DELETE FROM cart_items WHERE variantId <> 0;
+
+ALTER TABLE refund_items
+DROP FOREIGN KEY `fk_refund_items_order_item`;
+
+ALTER TABLE refund_items
+DROP INDEX `idx_refund_item_order`,
+ADD INDEX `idx_refund_item_order` (`orderId`, `productId`),
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`refundId`, `productId`),
+DROP COLUMN `variantId`;
+
+ALTER TABLE order_items
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`orderId`, `productId`),
+DROP COLUMN `variantLabel`,
+DROP COLUMN `variantId`;
+
+ALTER TABLE refund_items
+ADD CONSTRAINT `refund_items_ibfk_2` FOREIGN KEY (`orderId`, `productId`)
+    REFERENCES order_items(`orderId`, `productId`) ON DELETE CASCADE;
+
+ALTER TABLE inventory_reservations
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`cartId`, `productId`),
+DROP COLUMN `variantId`;
+
+ALTER TABLE cart_items
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`cartId`, `productId`),
+DROP COLUMN `variantLabel`,
+DROP COLUMN `variantId`;
+
+DROP TABLE IF EXISTS product_variant_images;
+DROP TABLE IF EXISTS product_variant_values;
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_option_values;
+DROP TABLE IF EXISTS product_options;
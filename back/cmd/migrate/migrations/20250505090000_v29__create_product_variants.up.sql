@@ -0,0 +1,81 @@
+CREATE TABLE IF NOT EXISTS product_options (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `name` VARCHAR(50) NOT NULL,
+    `sortOrder` INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_product_options_name` (`productId`, `name`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_option_values (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `optionId` INT UNSIGNED NOT NULL,
+    `value` VARCHAR(50) NOT NULL,
+    `sortOrder` INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_product_option_values_value` (`optionId`, `value`),
+    FOREIGN KEY (`optionId`) REFERENCES product_options(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `productId` INT UNSIGNED NOT NULL,
+    `sku` VARCHAR(64) NOT NULL,
+    `price` DECIMAL(10,2) UNSIGNED NULL DEFAULT NULL,
+    `stock_quantity` INT UNSIGNED NOT NULL DEFAULT 0,
+    `version` INT NOT NULL DEFAULT 0,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_product_variants_sku` (`sku`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variant_values (
+    `variantId` INT UNSIGNED NOT NULL,
+    `optionValueId` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`variantId`, `optionValueId`),
+    FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`optionValueId`) REFERENCES product_option_values(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS product_variant_images (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `variantId` INT UNSIGNED NOT NULL,
+    `imageUrl` VARCHAR(512) NOT NULL,
+    `sortOrder` INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`variantId`) REFERENCES product_variants(`id`) ON DELETE CASCADE
+);
+
+-- lines of a product sold without variants have a variantId of 0, which
+-- keeps it usable in the primary keys
+ALTER TABLE cart_items
+ADD COLUMN `variantId` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `productId`,
+ADD COLUMN `variantLabel` VARCHAR(255) NOT NULL DEFAULT '' AFTER `productTitle`,
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`cartId`, `productId`, `variantId`);
+
+ALTER TABLE inventory_reservations
+ADD COLUMN `variantId` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `productId`,
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`cartId`, `productId`, `variantId`);
+
+ALTER TABLE refund_items
+DROP FOREIGN KEY `refund_items_ibfk_2`;
+
+ALTER TABLE order_items
+ADD COLUMN `variantId` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `productId`,
+ADD COLUMN `variantLabel` VARCHAR(255) NOT NULL DEFAULT '' AFTER `price`,
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`orderId`, `productId`, `variantId`);
+
+ALTER TABLE refund_items
+ADD COLUMN `variantId` INT UNSIGNED NOT NULL DEFAULT 0 AFTER `productId`,
+DROP PRIMARY KEY,
+ADD PRIMARY KEY (`refundId`, `productId`, `variantId`),
+DROP INDEX `idx_refund_item_order`,
+ADD INDEX `idx_refund_item_order` (`orderId`, `productId`, `variantId`),
+ADD CONSTRAINT `fk_refund_items_order_item` FOREIGN KEY (`orderId`, `productId`, `variantId`)
+    REFERENCES order_items(`orderId`, `productId`, `variantId`) ON DELETE CASCADE;
@@ -0,0 +1,15 @@
+ALTER TABLE order_history
+DROP COLUMN `shippingStreet`,
+DROP COLUMN `shippingCity`,
+DROP COLUMN `shippingState`,
+DROP COLUMN `shippingPostalCode`,
+DROP COLUMN `shippingCountry`;
+
+-- the old key only holds one default and one other address per user
+DELETE a FROM user_address a
+JOIN user_address b ON b.userId = a.userId AND b.isDefault = a.isDefault AND b.id < a.id;
+
+ALTER TABLE user_address
+DROP INDEX `uq_user_address_default`,
+DROP COLUMN `defaultUserId`,
+ADD UNIQUE KEY `unique_default_address` (`userId`, `isDefault`);
@@ -0,0 +1,16 @@
+-- the old key allowed a single non-default address per user. defaultUserId
+-- is only set on the default address, and NULLs never collide, so the key
+-- now allows one default and any number of other addresses
+ALTER TABLE user_address
+DROP INDEX `unique_default_address`,
+ADD COLUMN `defaultUserId` INT UNSIGNED AS (IF(`isDefault`, `userId`, NULL)) STORED,
+ADD UNIQUE KEY `uq_user_address_default` (`defaultUserId`);
+
+-- the address an order ships to, copied when it is placed so later edits of
+-- the address leave the order as it was
+ALTER TABLE order_history
+ADD COLUMN `shippingStreet` VARCHAR(255) NOT NULL DEFAULT '' AFTER `paymentId`,
+ADD COLUMN `shippingCity` VARCHAR(100) NOT NULL DEFAULT '' AFTER `shippingStreet`,
+ADD COLUMN `shippingState` VARCHAR(50) NOT NULL DEFAULT '' AFTER `shippingCity`,
+ADD COLUMN `shippingPostalCode` VARCHAR(20) NOT NULL DEFAULT '' AFTER `shippingState`,
+ADD COLUMN `shippingCountry` VARCHAR(50) NOT NULL DEFAULT '' AFTER `shippingPostalCode`;
@@ -0,0 +1,6 @@
+ALTER TABLE order_history
+DROP COLUMN `shippingCost`,
+DROP COLUMN `shippingService`;
+
+DROP TABLE IF EXISTS shipping_rates;
+DROP TABLE IF EXISTS product_dimensions;
@@ -0,0 +1,34 @@
+-- what a product weighs and measures packed, which shipping is priced on.
+-- a variant ships in the package of its product
+CREATE TABLE IF NOT EXISTS product_dimensions (
+    `productId` INT UNSIGNED NOT NULL,
+    `weightGrams` INT UNSIGNED NOT NULL,
+    `lengthCm` DECIMAL(6,1) UNSIGNED NOT NULL,
+    `widthCm` DECIMAL(6,1) UNSIGNED NOT NULL,
+    `heightCm` DECIMAL(6,1) UNSIGNED NOT NULL,
+    PRIMARY KEY (`productId`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE
+);
+
+-- each row prices a service for parcels up to maxWeightGrams sent to a zone:
+-- a CEP range, a state, or anywhere when both are NULL
+CREATE TABLE IF NOT EXISTS shipping_rates (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `service` VARCHAR(30) NOT NULL,
+    `serviceName` VARCHAR(100) NOT NULL,
+    `state` CHAR(2) NULL DEFAULT NULL,
+    `postalCodeFrom` CHAR(8) NULL DEFAULT NULL,
+    `postalCodeTo` CHAR(8) NULL DEFAULT NULL,
+    `maxWeightGrams` INT UNSIGNED NOT NULL,
+    `price` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `minDays` INT UNSIGNED NOT NULL,
+    `maxDays` INT UNSIGNED NOT NULL,
+    `freeAbove` DECIMAL(10,2) UNSIGNED NULL DEFAULT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    INDEX `idx_shipping_rates_service` (`service`)
+);
+
+ALTER TABLE order_history
+ADD COLUMN `shippingService` VARCHAR(30) NOT NULL DEFAULT '' AFTER `shippingCountry`,
+ADD COLUMN `shippingCost` DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER `shippingService`;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS shipments (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `orderId` INT UNSIGNED NOT NULL,
+    `carrier` VARCHAR(50) NOT NULL,
+    `trackingCode` VARCHAR(100) NOT NULL DEFAULT '',
+    `trackingUrl` VARCHAR(512) NOT NULL DEFAULT '',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'SHIPPED',
+    `shippedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `deliveredAt` TIMESTAMP NULL DEFAULT NULL,
+    `createdBy` INT UNSIGNED,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`createdBy`) REFERENCES users(`id`) ON DELETE SET NULL,
+    INDEX `idx_shipment_order` (`orderId`)
+);
+
+-- the units of each order line a shipment carries, so an order can go out
+-- in several shipments
+CREATE TABLE IF NOT EXISTS shipment_items (
+    `shipmentId` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `productId` INT UNSIGNED NOT NULL,
+    `variantId` INT UNSIGNED NOT NULL DEFAULT 0,
+    `quantity` INT UNSIGNED NOT NULL,
+    PRIMARY KEY (`shipmentId`, `productId`, `variantId`),
+    FOREIGN KEY (`shipmentId`) REFERENCES shipments(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`orderId`, `productId`, `variantId`)
+        REFERENCES order_items(`orderId`, `productId`, `variantId`) ON DELETE CASCADE,
+    INDEX `idx_shipment_item_order` (`orderId`, `productId`, `variantId`)
+);
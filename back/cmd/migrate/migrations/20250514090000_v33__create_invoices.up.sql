@@ -0,0 +1,15 @@
+-- an order gets one invoice, numbered right after the last one issued. The
+-- PDF is kept as it was rendered, so the document never changes once issued
+CREATE TABLE IF NOT EXISTS invoices (
+    `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `number` INT UNSIGNED NOT NULL,
+    `orderId` INT UNSIGNED NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `totalAmount` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `pdf` MEDIUMBLOB NOT NULL,
+    `issuedAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uq_invoice_number` (`number`),
+    UNIQUE KEY `uq_invoice_order` (`orderId`),
+    FOREIGN KEY (`orderId`) REFERENCES order_history(`id`) ON DELETE CASCADE
+);
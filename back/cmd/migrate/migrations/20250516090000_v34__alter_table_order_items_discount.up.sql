@@ -0,0 +1,4 @@
+-- the part of the order's coupon discount taken off the line, so refunds
+-- give back what was paid for each unit
+ALTER TABLE order_items
+ADD COLUMN `discount` DECIMAL(10,2) UNSIGNED NOT NULL DEFAULT 0 AFTER `price`;
@@ -0,0 +1,8 @@
+DELETE FROM product_variants
+WHERE `deletedAt` IS NOT NULL;
+
+ALTER TABLE product_variants
+DROP INDEX `uq_product_variants_sku`,
+DROP COLUMN `liveSku`,
+DROP COLUMN `deletedAt`,
+ADD UNIQUE KEY `uq_product_variants_sku` (`sku`);
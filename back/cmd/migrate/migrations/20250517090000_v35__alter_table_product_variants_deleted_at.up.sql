@@ -0,0 +1,7 @@
+-- deleted variants are kept so the orders that sold them can still put their
+-- units back in stock; only live variants need a unique SKU
+ALTER TABLE product_variants
+ADD COLUMN `deletedAt` TIMESTAMP NULL DEFAULT NULL AFTER `version`,
+ADD COLUMN `liveSku` VARCHAR(64) AS (IF(`deletedAt` IS NULL, `sku`, NULL)) STORED AFTER `deletedAt`,
+DROP INDEX `uq_product_variants_sku`,
+ADD UNIQUE KEY `uq_product_variants_sku` (`liveSku`);
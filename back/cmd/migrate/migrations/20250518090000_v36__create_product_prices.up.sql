@@ -0,0 +1,12 @@
+-- the price the pricing engine last worked out for each product, which the
+-- listing filters and sorts on; products missing here list at their base
+-- price until the next refresh
+CREATE TABLE IF NOT EXISTS product_prices (
+    `productId` INT UNSIGNED NOT NULL,
+    `price` DECIMAL(10,2) UNSIGNED NOT NULL,
+    `onSale` BOOLEAN NOT NULL DEFAULT FALSE,
+    `updatedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`productId`),
+    FOREIGN KEY (`productId`) REFERENCES products(`id`) ON DELETE CASCADE,
+    INDEX `idx_product_prices_price` (`price`)
+);
@@ -0,0 +1,3 @@
+-- the state names the addresses had before are lost once they hold their
+-- UF, so there is nothing to undo
+SELECT 1;
@@ -0,0 +1,34 @@
+-- addresses keep the UF of their state, which the shipping rates are kept
+-- by. The comparison with the names ignores case and accents under the
+-- default collation
+UPDATE user_address a
+JOIN (
+    SELECT 'AC' AS uf, 'Acre' AS name UNION ALL
+    SELECT 'AL', 'Alagoas' UNION ALL
+    SELECT 'AP', 'Amapá' UNION ALL
+    SELECT 'AM', 'Amazonas' UNION ALL
+    SELECT 'BA', 'Bahia' UNION ALL
+    SELECT 'CE', 'Ceará' UNION ALL
+    SELECT 'DF', 'Distrito Federal' UNION ALL
+    SELECT 'ES', 'Espírito Santo' UNION ALL
+    SELECT 'GO', 'Goiás' UNION ALL
+    SELECT 'MA', 'Maranhão' UNION ALL
+    SELECT 'MT', 'Mato Grosso' UNION ALL
+    SELECT 'MS', 'Mato Grosso do Sul' UNION ALL
+    SELECT 'MG', 'Minas Gerais' UNION ALL
+    SELECT 'PA', 'Pará' UNION ALL
+    SELECT 'PB', 'Paraíba' UNION ALL
+    SELECT 'PR', 'Paraná' UNION ALL
+    SELECT 'PE', 'Pernambuco' UNION ALL
+    SELECT 'PI', 'Piauí' UNION ALL
+    SELECT 'RJ', 'Rio de Janeiro' UNION ALL
+    SELECT 'RN', 'Rio Grande do Norte' UNION ALL
+    SELECT 'RS', 'Rio Grande do Sul' UNION ALL
+    SELECT 'RO', 'Rondônia' UNION ALL
+    SELECT 'RR', 'Roraima' UNION ALL
+    SELECT 'SC', 'Santa Catarina' UNION ALL
+    SELECT 'SP', 'São Paulo' UNION ALL
+    SELECT 'SE', 'Sergipe' UNION ALL
+    SELECT 'TO', 'Tocantins'
+) s ON TRIM(a.state) IN (s.uf, s.name)
+SET a.state = s.uf;
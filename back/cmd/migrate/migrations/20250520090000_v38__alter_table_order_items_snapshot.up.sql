@@ -0,0 +1,13 @@
+-- order items keep the title of the product and its price before discounts
+-- as they were when the order was placed, so later edits of the product
+-- leave the order and its invoice as they were
+ALTER TABLE order_items
+ADD COLUMN `productTitle` VARCHAR(255) NOT NULL DEFAULT '' AFTER `productId`,
+ADD COLUMN `basePrice` DECIMAL(10,2) UNSIGNED NOT NULL DEFAULT 0 AFTER `quantity`;
+
+UPDATE order_items oi
+JOIN products p ON p.id = oi.productId
+SET oi.productTitle = p.title;
+
+UPDATE order_items
+SET basePrice = price;
@@ -18,6 +18,28 @@ type Config struct {
 
 	JWTExpirationInSeconds int64
 	JWTSecret              string
+
+	PaymentGateway string
+
+	PixKey                    string
+	PixMerchantName           string
+	PixMerchantCity           string
+	PixExpirationInSeconds    int64
+	PixWebhookSecret          string
+	PixSweepIntervalInSeconds int64
+
+	InvoiceIssuerName             string
+	InvoiceIssuerCNPJ             string
+	InvoiceIssuerAddress          string
+	InvoiceSweepIntervalInSeconds int64
+
+	ReservationTTLInSeconds           int64
+	ReservationSweepIntervalInSeconds int64
+
+	GuestCartTTLInSeconds           int64
+	GuestCartSweepIntervalInSeconds int64
+
+	PriceRefreshIntervalInSeconds int64
 }
 
 var Envs = initConfig()
@@ -35,6 +57,28 @@ func initConfig() Config {
 
 		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP", 3600*24*7),
 		JWTSecret:              getEnv("JWT_SECRET", "secret"),
+
+		PaymentGateway: getEnv("PAYMENT_GATEWAY", "fake"),
+
+		PixKey:                    getEnv("PIX_KEY", "pix@ecommerce.local"),
+		PixMerchantName:           getEnv("PIX_MERCHANT_NAME", "ECOMMERCE MOBILE"),
+		PixMerchantCity:           getEnv("PIX_MERCHANT_CITY", "SAO PAULO"),
+		PixExpirationInSeconds:    getEnvAsInt("PIX_EXP", 3600),
+		PixWebhookSecret:          getEnv("PIX_WEBHOOK_SECRET", "secret"),
+		PixSweepIntervalInSeconds: getEnvAsInt("PIX_SWEEP_INTERVAL", 60),
+
+		InvoiceIssuerName:             getEnv("INVOICE_ISSUER_NAME", "Ecommerce Mobile"),
+		InvoiceIssuerCNPJ:             getEnv("INVOICE_ISSUER_CNPJ", ""),
+		InvoiceIssuerAddress:          getEnv("INVOICE_ISSUER_ADDRESS", ""),
+		InvoiceSweepIntervalInSeconds: getEnvAsInt("INVOICE_SWEEP_INTERVAL", 60),
+
+		ReservationTTLInSeconds:           getEnvAsInt("RESERVATION_TTL", 60*15),
+		ReservationSweepIntervalInSeconds: getEnvAsInt("RESERVATION_SWEEP_INTERVAL", 60),
+
+		GuestCartTTLInSeconds:           getEnvAsInt("GUEST_CART_TTL", 3600*24*30),
+		GuestCartSweepIntervalInSeconds: getEnvAsInt("GUEST_CART_SWEEP_INTERVAL", 3600),
+
+		PriceRefreshIntervalInSeconds: getEnvAsInt("PRICE_REFRESH_INTERVAL", 60),
 	}
 }
 
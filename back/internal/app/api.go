@@ -1,21 +1,34 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/address"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/campaign"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/cart"
 	category "github.com/nobregas/ecommerce-mobile-back/internal/domain/category"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/coupon"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/discount"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/favorite"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/invoice"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/notification"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/orders"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/payment"
 	product "github.com/nobregas/ecommerce-mobile-back/internal/domain/product"
 	"github.com/nobregas/ecommerce-mobile-back/internal/domain/rating"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/reservation"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/shipment"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/shipping"
 	user "github.com/nobregas/ecommerce-mobile-back/internal/domain/user"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/variant"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 
 	"github.com/gorilla/mux"
+	configs "github.com/nobregas/ecommerce-mobile-back/config"
 	"github.com/rs/cors"
 )
 
@@ -44,18 +57,69 @@ func (s *APIServer) Run() error {
 	favoriteStore := favorite.NewStore(s.db)
 	cartStore := cart.NewStore(s.db)
 	orderStore := orders.NewStore(s.db)
+	pixStore := payment.NewStore(s.db)
+	reservationStore := reservation.NewStore(s.db)
+	couponStore := coupon.NewStore(s.db)
+	campaignStore := campaign.NewStore(s.db)
+	variantStore := variant.NewStore(s.db)
+	addressStore := address.NewStore(s.db)
+	shippingStore := shipping.NewStore(s.db)
+	shipmentStore := shipment.NewStore(s.db)
+	invoiceStore := invoice.NewStore(s.db)
+
+	paymentGateway, err := payment.NewGateway(configs.Envs.PaymentGateway)
+	if err != nil {
+		return err
+	}
+
+	pixIssuer := payment.NewPixIssuer(
+		configs.Envs.PixKey,
+		configs.Envs.PixMerchantName,
+		configs.Envs.PixMerchantCity,
+		time.Duration(configs.Envs.PixExpirationInSeconds)*time.Second,
+	)
+
+	couponService := coupon.NewService(couponStore)
+
+	uow := orders.NewUnitOfWork(s.db)
 
 	cartService := cart.NewService(
 		cartStore,
 		productStore,
+		variantStore,
 		discountStore,
+		reservationStore,
+		couponService,
+		time.Duration(configs.Envs.ReservationTTLInSeconds)*time.Second,
+		uow,
+	)
+
+	shippingService := shipping.NewService(
+		shipping.NewTableRateCalculator(shippingStore),
+		shippingStore,
+		productStore,
+		addressStore,
+		cartService,
 	)
 
 	orderService := orders.NewService(
 		orderStore,
 		cartStore,
+		cartService,
 		productStore,
+		addressStore,
+		shippingService,
+		shipmentStore,
+		pixStore,
+		paymentGateway,
+		pixIssuer,
+		uow,
 	)
+	invoiceService := invoice.NewService(invoiceStore, orderService, types.InvoiceIssuer{
+		Name:    configs.Envs.InvoiceIssuerName,
+		CNPJ:    configs.Envs.InvoiceIssuerCNPJ,
+		Address: configs.Envs.InvoiceIssuerAddress,
+	})
 	userStore := user.NewStore(s.db, cartService)
 
 	productService := product.NewProductService(
@@ -63,6 +127,7 @@ func (s *APIServer) Run() error {
 		userStore,
 		discountStore,
 		ratingStore,
+		variantStore,
 	)
 
 	favoriteService := favorite.NewService(
@@ -73,21 +138,38 @@ func (s *APIServer) Run() error {
 	notificationService := notification.NewNotificationService(notificationStore, userStore)
 
 	// user
-	userHandler := user.NewHandler(userStore)
+	userHandler := user.NewHandler(userStore, cartService)
 	userHandler.RegisterRoutes(subrouter)
 
 	// product
 	productHandler := product.NewHandler(productStore, userStore, productService)
 	productHandler.RegisterRoutes(subrouter)
 
+	// address
+	addressService := address.NewService(addressStore)
+	addressHandler := address.NewHandler(addressService)
+	addressHandler.RegisterRoutes(subrouter, userStore)
+
+	// variant
+	variantService := variant.NewService(variantStore, productStore)
+	variantHandler := variant.NewHandler(variantService)
+	variantHandler.RegisterRoutes(subrouter, userStore)
+
 	// category
-	categoryHandler := category.NewHandler(categoryStore, userStore)
+	categoryService := category.NewService(categoryStore)
+	categoryHandler := category.NewHandler(categoryStore, categoryService, userStore)
 	categoryHandler.RegisterRoutes(subrouter)
 
 	// discount
-	discountHandler := discount.NewHandler(discountStore, productStore, userStore)
+	discountService := discount.NewService(discountStore, productStore, productService)
+	discountHandler := discount.NewHandler(discountService, userStore)
 	discountHandler.RegisterRoutes(subrouter)
 
+	// campaign
+	campaignService := campaign.NewService(campaignStore, categoryStore, productStore, productService)
+	campaignHandler := campaign.NewHandler(campaignService)
+	campaignHandler.RegisterRoutes(subrouter, userStore)
+
 	// rating
 	ratingHandler := rating.NewHandler(ratingStore, userStore, productStore)
 	ratingHandler.RegisterRoutes(subrouter)
@@ -104,10 +186,59 @@ func (s *APIServer) Run() error {
 	cartHandler := cart.NewHandler(cartService)
 	cartHandler.RegisterRoutes(subrouter, userStore)
 
+	// shipping
+	shippingHandler := shipping.NewHandler(shippingService)
+	shippingHandler.RegisterRoutes(subrouter, userStore)
+
+	// coupon
+	couponHandler := coupon.NewHandler(couponService)
+	couponHandler.RegisterRoutes(subrouter, userStore)
+
 	// order
 	orderHandler := orders.NewHandler(orderService)
 	orderHandler.RegisterRoutes(subrouter, userStore)
 
+	// invoice
+	invoiceHandler := invoice.NewHandler(invoiceService)
+	invoiceHandler.RegisterRoutes(subrouter, userStore)
+
+	// payment
+	paymentHandler := payment.NewHandler(orderService, pixStore, paymentGateway, configs.Envs.PixWebhookSecret)
+	paymentHandler.RegisterRoutes(subrouter, userStore)
+
+	// reservations
+	sweeper := reservation.NewSweeper(
+		reservationStore,
+		time.Duration(configs.Envs.ReservationSweepIntervalInSeconds)*time.Second,
+	)
+	go sweeper.Run(context.Background())
+
+	guestCartSweeper := cart.NewGuestCartSweeper(
+		cartStore,
+		time.Duration(configs.Envs.GuestCartTTLInSeconds)*time.Second,
+		time.Duration(configs.Envs.GuestCartSweepIntervalInSeconds)*time.Second,
+	)
+	go guestCartSweeper.Run(context.Background())
+
+	pixSweeper := orders.NewPixSweeper(
+		orderService,
+		time.Duration(configs.Envs.PixSweepIntervalInSeconds)*time.Second,
+	)
+	go pixSweeper.Run(context.Background())
+
+	invoiceSweeper := invoice.NewSweeper(
+		invoiceService,
+		time.Duration(configs.Envs.InvoiceSweepIntervalInSeconds)*time.Second,
+	)
+	go invoiceSweeper.Run(context.Background())
+
+	// listing prices
+	priceRefresher := product.NewPriceRefresher(
+		productService,
+		time.Duration(configs.Envs.PriceRefreshIntervalInSeconds)*time.Second,
+	)
+	go priceRefresher.Run(context.Background())
+
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -0,0 +1,144 @@
+package address
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	addressService types.AddressService
+}
+
+func NewHandler(addressService types.AddressService) *Handler {
+	return &Handler{addressService: addressService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	authRouter := router.PathPrefix("").Subrouter()
+	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
+
+	authRouter.HandleFunc("/user/my/addresses", h.getAddresses).Methods("GET")
+	authRouter.HandleFunc("/user/my/addresses", h.createAddress).Methods("POST")
+
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	authRouter.HandleFunc("/user/my/addresses/{addressID}",
+		utils.Compose(h.getAddress, middleware.ErrorHandler)).Methods("GET")
+	authRouter.HandleFunc("/user/my/addresses/{addressID}",
+		utils.Compose(h.updateAddress, middleware.ErrorHandler)).Methods("PUT")
+	authRouter.HandleFunc("/user/my/addresses/{addressID}",
+		utils.Compose(h.deleteAddress, middleware.ErrorHandler)).Methods("DELETE")
+	authRouter.HandleFunc("/user/my/addresses/{addressID}/default",
+		utils.Compose(h.setDefaultAddress, middleware.ErrorHandler)).Methods("PUT")
+}
+
+func (h *Handler) getAddresses(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	addresses, err := h.addressService.GetAddresses(userID)
+	if err != nil {
+		fmt.Printf("[ADDRESS HANDLER] ERROR getting addresses of user %d: %v\n", userID, err)
+		utils.WriteServiceError(w, err, "Failed to get addresses")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, addresses)
+}
+
+func (h *Handler) getAddress(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	addressID := utils.GetParamIdfromPath(r, "addressID")
+
+	address, err := h.addressService.GetAddress(userID, addressID)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to get address")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, address)
+}
+
+func (h *Handler) createAddress(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.CreateAddressPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	address, err := h.addressService.CreateAddress(userID, payload)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to create address")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, address)
+}
+
+func (h *Handler) updateAddress(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	addressID := utils.GetParamIdfromPath(r, "addressID")
+
+	var payload types.UpdateAddressPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	address, err := h.addressService.UpdateAddress(userID, addressID, payload)
+	if err != nil {
+		fmt.Printf("[ADDRESS HANDLER] ERROR updating address %d: %v\n", addressID, err)
+		utils.WriteServiceError(w, err, "Failed to update address")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, address)
+}
+
+func (h *Handler) deleteAddress(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	addressID := utils.GetParamIdfromPath(r, "addressID")
+
+	if err := h.addressService.DeleteAddress(userID, addressID); err != nil {
+		fmt.Printf("[ADDRESS HANDLER] ERROR deleting address %d: %v\n", addressID, err)
+		utils.WriteServiceError(w, err, "Failed to delete address")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Address deleted successfully"})
+}
+
+func (h *Handler) setDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	addressID := utils.GetParamIdfromPath(r, "addressID")
+
+	address, err := h.addressService.SetDefaultAddress(userID, addressID)
+	if err != nil {
+		fmt.Printf("[ADDRESS HANDLER] ERROR setting default address %d: %v\n", addressID, err)
+		utils.WriteServiceError(w, err, "Failed to set default address")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, address)
+}
+
+// parsePayload decodes and validates the body into payload, answering with a
+// 400 when it can't.
+func parsePayload(w http.ResponseWriter, r *http.Request, payload any) bool {
+	if err := utils.ParseJson(r, payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package address
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+const defaultCountry = "Brasil"
+
+// cepPattern matches a CEP with or without its dash, such as 01310-100 or
+// 01310100.
+var cepPattern = regexp.MustCompile(`^(\d{5})-?(\d{3})$`)
+
+type Service struct {
+	store types.AddressStore
+}
+
+func NewService(store types.AddressStore) *Service {
+	return &Service{store: store}
+}
+
+func (s *Service) GetAddresses(userID int) ([]*types.Address, error) {
+	return s.store.GetAddresses(userID)
+}
+
+func (s *Service) GetAddress(userID int, addressID int) (*types.Address, error) {
+	return s.store.GetAddress(userID, addressID)
+}
+
+func (s *Service) CreateAddress(userID int, payload types.CreateAddressPayload) (*types.Address, error) {
+	postalCode, err := normalizePostalCode(payload.PostalCode)
+	if err != nil {
+		return nil, err
+	}
+	payload.PostalCode = postalCode
+
+	state, err := normalizeState(payload.State)
+	if err != nil {
+		return nil, err
+	}
+	payload.State = state
+
+	if payload.Country == "" {
+		payload.Country = defaultCountry
+	}
+
+	address, err := s.store.CreateAddress(userID, payload)
+	if err != nil {
+		fmt.Printf("[ADDRESS SERVICE] ERROR creating address of user %d: %v\n", userID, err)
+		return nil, err
+	}
+
+	fmt.Printf("[ADDRESS SERVICE] Created address %d of user %d (default: %t)\n", address.ID, userID, address.IsDefault)
+	return address, nil
+}
+
+func (s *Service) UpdateAddress(userID int, addressID int, payload types.UpdateAddressPayload) (*types.Address, error) {
+	if payload.PostalCode != nil {
+		postalCode, err := normalizePostalCode(*payload.PostalCode)
+		if err != nil {
+			return nil, err
+		}
+		payload.PostalCode = &postalCode
+	}
+
+	if payload.State != nil {
+		state, err := normalizeState(*payload.State)
+		if err != nil {
+			return nil, err
+		}
+		payload.State = &state
+	}
+
+	return s.store.UpdateAddress(userID, addressID, payload)
+}
+
+func (s *Service) DeleteAddress(userID int, addressID int) error {
+	if err := s.store.DeleteAddress(userID, addressID); err != nil {
+		return err
+	}
+
+	fmt.Printf("[ADDRESS SERVICE] Deleted address %d of user %d\n", addressID, userID)
+	return nil
+}
+
+// SetDefaultAddress makes addressID the default of the user in place of the
+// current one.
+func (s *Service) SetDefaultAddress(userID int, addressID int) (*types.Address, error) {
+	if err := s.store.SetDefaultAddress(userID, addressID); err != nil {
+		return nil, err
+	}
+
+	return s.store.GetAddress(userID, addressID)
+}
+
+// normalizePostalCode checks postalCode is a CEP and writes it as 00000-000.
+func normalizePostalCode(postalCode string) (string, error) {
+	match := cepPattern.FindStringSubmatch(strings.TrimSpace(postalCode))
+	if match == nil {
+		return "", apperrors.NewValidationError("postalCode", "postal code must be a CEP such as 01310-100")
+	}
+	return match[1] + "-" + match[2], nil
+}
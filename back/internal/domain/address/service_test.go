@@ -0,0 +1,151 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAddressStore struct {
+	types.AddressStore
+	mock.Mock
+}
+
+func (m *MockAddressStore) CreateAddress(userID int, payload types.CreateAddressPayload) (*types.Address, error) {
+	args := m.Called(userID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Address), args.Error(1)
+}
+
+func (m *MockAddressStore) UpdateAddress(userID int, addressID int, payload types.UpdateAddressPayload) (*types.Address, error) {
+	args := m.Called(userID, addressID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Address), args.Error(1)
+}
+
+func TestCreateAddress(t *testing.T) {
+	invalid := apperrors.NewValidationError("postalCode", "postal code must be a CEP such as 01310-100")
+
+	tests := []struct {
+		name          string
+		postalCode    string
+		expected      string
+		expectedError error
+	}{
+		{name: "CEP with dash", postalCode: "01310-100", expected: "01310-100"},
+		{name: "CEP without dash", postalCode: "01310100", expected: "01310-100"},
+		{name: "CEP with spaces around", postalCode: " 01310-100 ", expected: "01310-100"},
+		{name: "Too short", postalCode: "0131010", expectedError: invalid},
+		{name: "Letters", postalCode: "0131A-100", expectedError: invalid},
+		{name: "Dash in the wrong place", postalCode: "0131-0100", expectedError: invalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockAddressStore)
+			// the country defaults to Brasil
+			saved := types.CreateAddressPayload{
+				Street: "Avenida Paulista, 1000", City: "São Paulo", State: "SP", PostalCode: tt.expected, Country: "Brasil",
+			}
+			store.On("CreateAddress", 1, saved).Return(&types.Address{ID: 1, UserID: 1, PostalCode: tt.expected}, nil)
+
+			address, err := NewService(store).CreateAddress(1, types.CreateAddressPayload{
+				Street: "Avenida Paulista, 1000", City: "São Paulo", State: "SP", PostalCode: tt.postalCode,
+			})
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, address)
+				store.AssertNotCalled(t, "CreateAddress", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, address.PostalCode)
+				store.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestUpdateAddressChecksPostalCode(t *testing.T) {
+	store := new(MockAddressStore)
+	postalCode := "20040020"
+	formatted := "20040-020"
+	store.On("UpdateAddress", 1, 2, types.UpdateAddressPayload{PostalCode: &formatted}).
+		Return(&types.Address{ID: 2, UserID: 1, PostalCode: formatted}, nil)
+
+	address, err := NewService(store).UpdateAddress(1, 2, types.UpdateAddressPayload{PostalCode: &postalCode})
+	assert.NoError(t, err)
+	assert.Equal(t, formatted, address.PostalCode)
+
+	invalid := "2004-0020"
+	_, err = NewService(store).UpdateAddress(1, 2, types.UpdateAddressPayload{PostalCode: &invalid})
+	assert.Equal(t, apperrors.NewValidationError("postalCode", "postal code must be a CEP such as 01310-100"), err)
+	store.AssertNumberOfCalls(t, "UpdateAddress", 1)
+}
+
+func TestCreateAddressNormalizesState(t *testing.T) {
+	invalid := apperrors.NewValidationError("state", "state must be a UF such as SP")
+
+	tests := []struct {
+		name          string
+		state         string
+		expected      string
+		expectedError error
+	}{
+		{name: "UF", state: "SP", expected: "SP"},
+		{name: "UF in lowercase with spaces", state: " rj ", expected: "RJ"},
+		{name: "Name", state: "São Paulo", expected: "SP"},
+		{name: "Name without accents", state: "sao paulo", expected: "SP"},
+		{name: "Name of several words", state: "Rio Grande do Sul", expected: "RS"},
+		{name: "Unknown UF", state: "XX", expectedError: invalid},
+		{name: "Unknown name", state: "Paulista", expectedError: invalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockAddressStore)
+			saved := types.CreateAddressPayload{
+				Street: "Avenida Paulista, 1000", City: "São Paulo", State: tt.expected, PostalCode: "01310-100", Country: "Brasil",
+			}
+			store.On("CreateAddress", 1, saved).Return(&types.Address{ID: 1, UserID: 1, State: tt.expected}, nil)
+
+			address, err := NewService(store).CreateAddress(1, types.CreateAddressPayload{
+				Street: "Avenida Paulista, 1000", City: "São Paulo", State: tt.state, PostalCode: "01310-100",
+			})
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, address)
+				store.AssertNotCalled(t, "CreateAddress", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, address.State)
+				store.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestUpdateAddressNormalizesState(t *testing.T) {
+	store := new(MockAddressStore)
+	state := "minas gerais"
+	uf := "MG"
+	store.On("UpdateAddress", 1, 2, types.UpdateAddressPayload{State: &uf}).
+		Return(&types.Address{ID: 2, UserID: 1, State: uf}, nil)
+
+	address, err := NewService(store).UpdateAddress(1, 2, types.UpdateAddressPayload{State: &state})
+	assert.NoError(t, err)
+	assert.Equal(t, uf, address.State)
+
+	invalid := "Minas"
+	_, err = NewService(store).UpdateAddress(1, 2, types.UpdateAddressPayload{State: &invalid})
+	assert.Equal(t, apperrors.NewValidationError("state", "state must be a UF such as SP"), err)
+	store.AssertNumberOfCalls(t, "UpdateAddress", 1)
+}
@@ -0,0 +1,69 @@
+package address
+
+import (
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"golang.org/x/text/unicode/norm"
+)
+
+// states names the Brazilian states by their UF, the two letter code the
+// shipping rates are kept by.
+var states = map[string]string{
+	"AC": "Acre",
+	"AL": "Alagoas",
+	"AP": "Amapá",
+	"AM": "Amazonas",
+	"BA": "Bahia",
+	"CE": "Ceará",
+	"DF": "Distrito Federal",
+	"ES": "Espírito Santo",
+	"GO": "Goiás",
+	"MA": "Maranhão",
+	"MT": "Mato Grosso",
+	"MS": "Mato Grosso do Sul",
+	"MG": "Minas Gerais",
+	"PA": "Pará",
+	"PB": "Paraíba",
+	"PR": "Paraná",
+	"PE": "Pernambuco",
+	"PI": "Piauí",
+	"RJ": "Rio de Janeiro",
+	"RN": "Rio Grande do Norte",
+	"RS": "Rio Grande do Sul",
+	"RO": "Rondônia",
+	"RR": "Roraima",
+	"SC": "Santa Catarina",
+	"SP": "São Paulo",
+	"SE": "Sergipe",
+	"TO": "Tocantins",
+}
+
+// normalizeState turns the UF or the name of a state, in any case and with
+// or without accents, into its UF.
+func normalizeState(state string) (string, error) {
+	state = strings.TrimSpace(state)
+	if uf := strings.ToUpper(state); states[uf] != "" {
+		return uf, nil
+	}
+
+	name := foldName(state)
+	for uf, stateName := range states {
+		if foldName(stateName) == name {
+			return uf, nil
+		}
+	}
+
+	return "", apperrors.NewValidationError("state", "state must be a UF such as SP")
+}
+
+// foldName lowercases name and strips its accents.
+func foldName(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		if r < 0x300 || r > 0x36F {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,233 @@
+package address
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetAddresses(userID int) ([]*types.Address, error) {
+	return s.getAddresses(`userId = ?`, userID)
+}
+
+func (s *Store) GetAddress(userID int, addressID int) (*types.Address, error) {
+	addresses, err := s.getAddresses(`id = ? AND userId = ?`, addressID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addresses) == 0 {
+		return nil, apperrors.NewEntityNotFound("address", addressID)
+	}
+
+	return addresses[0], nil
+}
+
+func (s *Store) CreateAddress(userID int, payload types.CreateAddressPayload) (*types.Address, error) {
+	var addressID int
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		// locking the user's rows keeps two first addresses from both
+		// becoming the default
+		var others int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM user_address WHERE userId = ? FOR UPDATE
+		`, userID).Scan(&others)
+		if err != nil {
+			return fmt.Errorf("[CreateAddress] error counting addresses of user %d: %v", userID, err)
+		}
+
+		isDefault := payload.IsDefault || others == 0
+		if isDefault {
+			if err := clearDefault(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO user_address (userId, street, city, state, postalCode, country, isDefault)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, userID, payload.Street, payload.City, payload.State, payload.PostalCode, payload.Country, isDefault)
+		if err != nil {
+			return fmt.Errorf("[CreateAddress] error inserting address: %v", err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateAddress] error getting address ID: %v", err)
+		}
+		addressID = int(id)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetAddress(userID, addressID)
+}
+
+func (s *Store) UpdateAddress(userID int, addressID int, payload types.UpdateAddressPayload) (*types.Address, error) {
+	sets := []string{}
+	args := []any{}
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"street", payload.Street},
+		{"city", payload.City},
+		{"state", payload.State},
+		{"postalCode", payload.PostalCode},
+		{"country", payload.Country},
+	} {
+		if field.value != nil {
+			sets = append(sets, field.column+" = ?")
+			args = append(args, *field.value)
+		}
+	}
+
+	// an update that changes nothing affects no rows either, so the lookup
+	// below is what tells a missing address apart
+	if len(sets) > 0 {
+		args = append(args, addressID, userID)
+		_, err := s.db.Exec(`UPDATE user_address SET `+strings.Join(sets, ", ")+` WHERE id = ? AND userId = ?`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("[UpdateAddress] error updating address %d: %v", addressID, err)
+		}
+	}
+
+	return s.GetAddress(userID, addressID)
+}
+
+func (s *Store) DeleteAddress(userID int, addressID int) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		var isDefault bool
+		err := tx.QueryRow(`
+			SELECT isDefault FROM user_address WHERE id = ? AND userId = ? FOR UPDATE
+		`, addressID, userID).Scan(&isDefault)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewEntityNotFound("address", addressID)
+		}
+		if err != nil {
+			return fmt.Errorf("[DeleteAddress] error locking address %d: %v", addressID, err)
+		}
+
+		_, err = tx.Exec(`DELETE FROM user_address WHERE id = ?`, addressID)
+		if err != nil {
+			return fmt.Errorf("[DeleteAddress] error deleting address %d: %v", addressID, err)
+		}
+
+		if !isDefault {
+			return nil
+		}
+
+		_, err = tx.Exec(`
+			UPDATE user_address
+			SET isDefault = true
+			WHERE userId = ?
+			ORDER BY createdAt DESC, id DESC
+			LIMIT 1
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("[DeleteAddress] error passing on the default address of user %d: %v", userID, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) SetDefaultAddress(userID int, addressID int) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		var id int
+		err := tx.QueryRow(`
+			SELECT id FROM user_address WHERE id = ? AND userId = ? FOR UPDATE
+		`, addressID, userID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewEntityNotFound("address", addressID)
+		}
+		if err != nil {
+			return fmt.Errorf("[SetDefaultAddress] error locking address %d: %v", addressID, err)
+		}
+
+		if err := clearDefault(tx, userID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE user_address SET isDefault = true WHERE id = ?`, addressID)
+		if err != nil {
+			return fmt.Errorf("[SetDefaultAddress] error setting default address %d: %v", addressID, err)
+		}
+
+		return nil
+	})
+}
+
+// clearDefault unsets the default address of the user, which must happen
+// before another one is set for the unique key to hold.
+func clearDefault(tx database.DBTX, userID int) error {
+	_, err := tx.Exec(`UPDATE user_address SET isDefault = false WHERE userId = ? AND isDefault`, userID)
+	if err != nil {
+		return fmt.Errorf("[clearDefault] error clearing default address of user %d: %v", userID, err)
+	}
+	return nil
+}
+
+// getAddresses loads the addresses matching where, the default first.
+func (s *Store) getAddresses(where string, args ...any) ([]*types.Address, error) {
+	rows, err := s.db.Query(`
+		SELECT id, userId, street, city, state, postalCode, country, isDefault, createdAt, updatedAt
+		FROM user_address
+		WHERE `+where+`
+		ORDER BY isDefault DESC, createdAt DESC, id DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[GetAddresses] error getting addresses: %v", err)
+	}
+	defer rows.Close()
+
+	addresses := make([]*types.Address, 0)
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[GetAddresses] error scanning address: %v", err)
+		}
+		addresses = append(addresses, address)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetAddresses] error reading addresses: %v", err)
+	}
+
+	return addresses, nil
+}
+
+func scanAddress(rows *sql.Rows) (*types.Address, error) {
+	address := new(types.Address)
+	err := rows.Scan(
+		&address.ID,
+		&address.UserID,
+		&address.Street,
+		&address.City,
+		&address.State,
+		&address.PostalCode,
+		&address.Country,
+		&address.IsDefault,
+		&address.CreatedAt,
+		&address.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
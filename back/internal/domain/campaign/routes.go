@@ -0,0 +1,93 @@
+package campaign
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	campaignService types.CampaignService
+}
+
+func NewHandler(campaignService types.CampaignService) *Handler {
+	return &Handler{campaignService: campaignService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	adminRouter := router.PathPrefix("").Subrouter()
+	adminRouter.Use(
+		auth.WithJwtAuthMiddleware(userStore),
+		auth.WithAdminAuthMiddleware())
+
+	adminRouter.HandleFunc("/campaigns", h.createCampaign).Methods("POST")
+	adminRouter.HandleFunc("/campaigns", h.getCampaigns).Methods("GET")
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	adminRouter.HandleFunc("/campaigns/{campaignId}",
+		utils.Compose(h.getCampaign, middleware.ErrorHandler)).Methods("GET")
+	adminRouter.HandleFunc("/campaigns/{campaignId}",
+		utils.Compose(h.deleteCampaign, middleware.ErrorHandler)).Methods("DELETE")
+}
+
+func (h *Handler) createCampaign(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateCampaignPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(payload)
+	if err != nil {
+		fmt.Printf("[CAMPAIGN HANDLER] ERROR creating campaign: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to create campaign")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, campaign)
+}
+
+func (h *Handler) getCampaigns(w http.ResponseWriter, r *http.Request) {
+	campaigns, err := h.campaignService.GetCampaigns()
+	if err != nil {
+		fmt.Printf("[CAMPAIGN HANDLER] ERROR getting campaigns: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get campaigns")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, campaigns)
+}
+
+func (h *Handler) getCampaign(w http.ResponseWriter, r *http.Request) {
+	campaignID := utils.GetParamIdfromPath(r, "campaignId")
+
+	campaign, err := h.campaignService.GetCampaignByID(campaignID)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to get campaign")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, campaign)
+}
+
+func (h *Handler) deleteCampaign(w http.ResponseWriter, r *http.Request) {
+	campaignID := utils.GetParamIdfromPath(r, "campaignId")
+
+	if err := h.campaignService.DeleteCampaign(campaignID); err != nil {
+		fmt.Printf("[CAMPAIGN HANDLER] ERROR deleting campaign %d: %v\n", campaignID, err)
+		utils.WriteServiceError(w, err, "Failed to delete campaign")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Campaign deleted successfully"})
+}
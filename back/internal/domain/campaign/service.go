@@ -0,0 +1,140 @@
+package campaign
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Service struct {
+	campaignStore types.CampaignStore
+	categoryStore types.CategoryStore
+	productStore  types.ProductStore
+	pricer        types.ListingPricer
+}
+
+func NewService(campaignStore types.CampaignStore, categoryStore types.CategoryStore, productStore types.ProductStore, pricer types.ListingPricer) *Service {
+	return &Service{campaignStore: campaignStore, categoryStore: categoryStore, productStore: productStore, pricer: pricer}
+}
+
+func (s *Service) CreateCampaign(payload types.CreateCampaignPayload) (*types.Campaign, error) {
+	if payload.Type == "" {
+		payload.Type = types.DiscountPercentage
+	}
+
+	if err := s.validate(payload); err != nil {
+		return nil, err
+	}
+
+	campaign, err := s.campaignStore.CreateCampaign(payload)
+	if err != nil {
+		fmt.Printf("[CAMPAIGN SERVICE] ERROR creating campaign %s: %v\n", payload.Name, err)
+		return nil, err
+	}
+
+	fmt.Printf("[CAMPAIGN SERVICE] Created campaign %d (%s)\n", campaign.ID, campaign.Name)
+	s.refreshPrices(payload.Scope, payload.CategoryID, payload.ProductIDs)
+	return campaign, nil
+}
+
+func (s *Service) GetCampaigns() ([]*types.Campaign, error) {
+	return s.campaignStore.GetCampaigns()
+}
+
+func (s *Service) GetCampaignByID(campaignID int) (*types.Campaign, error) {
+	return s.campaignStore.GetCampaignByID(campaignID)
+}
+
+func (s *Service) DeleteCampaign(campaignID int) error {
+	campaign, err := s.campaignStore.GetCampaignByID(campaignID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.campaignStore.DeleteCampaign(campaignID); err != nil {
+		return err
+	}
+
+	s.refreshPrices(campaign.Scope, campaign.CategoryID, campaign.ProductIDs)
+	return nil
+}
+
+// refreshPrices stores the listing price of the products a campaign reaches,
+// every product for a storewide one. The write stands when this fails, and
+// the listing catches up on the next periodic refresh.
+func (s *Service) refreshPrices(scope types.CampaignScope, categoryID *int, productIDs []int) {
+	var err error
+	switch scope {
+	case types.CampaignStorewide:
+		_, err = s.pricer.RefreshPrices()
+	case types.CampaignCategory:
+		var products []*types.Product
+		products, err = s.productStore.GetProductsByCategory(*categoryID, true)
+		if err == nil {
+			productIDs = make([]int, 0, len(products))
+			for _, product := range products {
+				productIDs = append(productIDs, product.ID)
+			}
+			err = s.pricer.RefreshProductPrices(productIDs)
+		}
+	case types.CampaignProducts:
+		err = s.pricer.RefreshProductPrices(productIDs)
+	}
+
+	if err != nil {
+		fmt.Printf("[CAMPAIGN SERVICE] ERROR refreshing prices of the %s campaign: %v\n", scope, err)
+	}
+}
+
+// validate checks the discount of the campaign and that it targets exactly
+// what its scope calls for.
+func (s *Service) validate(payload types.CreateCampaignPayload) error {
+	if !payload.EndDate.After(payload.StartDate) {
+		return apperrors.NewValidationError("endDate", "end date must be after start date")
+	}
+
+	switch payload.Type {
+	case types.DiscountPercentage:
+	case types.DiscountBuyXGetY:
+		if payload.BuyQuantity == nil || *payload.BuyQuantity <= 0 {
+			return apperrors.NewValidationError("buyQuantity", "buy quantity must be greater than 0")
+		}
+		if payload.GetQuantity == nil || *payload.GetQuantity <= 0 {
+			return apperrors.NewValidationError("getQuantity", "get quantity must be greater than 0")
+		}
+	default:
+		return apperrors.NewValidationError("type", "a campaign takes a percentage or a buy X get Y discount")
+	}
+
+	if payload.DiscountPercent <= 0 || payload.DiscountPercent > 100 {
+		return apperrors.NewValidationError("discountPercent", "discount percent must be greater than 0 and at most 100")
+	}
+
+	switch payload.Scope {
+	case types.CampaignCategory:
+		if payload.CategoryID == nil || len(payload.ProductIDs) > 0 {
+			return apperrors.NewValidationError("categoryId", "a category campaign targets a single category and no products")
+		}
+		if _, err := s.categoryStore.GetCategoryByID(*payload.CategoryID); err != nil {
+			return apperrors.NewEntityNotFound("category", *payload.CategoryID)
+		}
+	case types.CampaignProducts:
+		if len(payload.ProductIDs) == 0 || payload.CategoryID != nil {
+			return apperrors.NewValidationError("productIds", "a product campaign targets at least one product and no category")
+		}
+		for _, productID := range payload.ProductIDs {
+			if _, err := s.productStore.GetProductByID(productID); err != nil {
+				return apperrors.NewEntityNotFound("product", productID)
+			}
+		}
+	case types.CampaignStorewide:
+		if payload.CategoryID != nil || len(payload.ProductIDs) > 0 {
+			return apperrors.NewValidationError("scope", "a storewide campaign targets the whole catalog")
+		}
+	default:
+		return apperrors.NewValidationError("scope", fmt.Sprintf("unknown campaign scope %q", payload.Scope))
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package campaign
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCampaignStore struct {
+	mock.Mock
+}
+
+func (m *MockCampaignStore) CreateCampaign(payload types.CreateCampaignPayload) (*types.Campaign, error) {
+	args := m.Called(payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignStore) GetCampaigns() ([]*types.Campaign, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignStore) GetCampaignByID(campaignID int) (*types.Campaign, error) {
+	args := m.Called(campaignID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Campaign), args.Error(1)
+}
+
+func (m *MockCampaignStore) DeleteCampaign(campaignID int) error {
+	args := m.Called(campaignID)
+	return args.Error(0)
+}
+
+type MockCategoryStore struct {
+	types.CategoryStore
+	mock.Mock
+}
+
+func (m *MockCategoryStore) GetCategoryByID(categoryID int) (*types.Category, error) {
+	args := m.Called(categoryID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Category), args.Error(1)
+}
+
+type MockProductStore struct {
+	types.ProductStore
+	mock.Mock
+}
+
+func (m *MockProductStore) GetProductByID(productID int) (*types.Product, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Product), args.Error(1)
+}
+
+func (m *MockProductStore) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	args := m.Called(categoryID, includeSubcategories)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Product), args.Error(1)
+}
+
+type MockListingPricer struct {
+	mock.Mock
+}
+
+func (m *MockListingPricer) RefreshPrices() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockListingPricer) RefreshProductPrices(productIDs []int) error {
+	args := m.Called(productIDs)
+	return args.Error(0)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestServiceCreateCampaign(t *testing.T) {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		payload       types.CreateCampaignPayload
+		expectedError error
+	}{
+		{
+			name:    "Category campaign",
+			payload: types.CreateCampaignPayload{Name: "Electronics week", Scope: types.CampaignCategory, CategoryID: intPtr(3), DiscountPercent: 20, StartDate: start, EndDate: end},
+		},
+		{
+			name:    "Product campaign",
+			payload: types.CreateCampaignPayload{Name: "Mugs", Scope: types.CampaignProducts, ProductIDs: []int{1, 2}, DiscountPercent: 20, StartDate: start, EndDate: end},
+		},
+		{
+			name: "Storewide buy X get Y",
+			payload: types.CreateCampaignPayload{
+				Name: "Black friday", Scope: types.CampaignStorewide, Type: types.DiscountBuyXGetY,
+				DiscountPercent: 100, BuyQuantity: intPtr(2), GetQuantity: intPtr(1), StartDate: start, EndDate: end,
+			},
+		},
+		{
+			name:          "End date before start date",
+			payload:       types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignStorewide, DiscountPercent: 20, StartDate: end, EndDate: start},
+			expectedError: apperrors.NewValidationError("endDate", "end date must be after start date"),
+		},
+		{
+			name:          "Fixed price",
+			payload:       types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignStorewide, Type: types.DiscountFixedPrice, DiscountPercent: 20, StartDate: start, EndDate: end},
+			expectedError: apperrors.NewValidationError("type", "a campaign takes a percentage or a buy X get Y discount"),
+		},
+		{
+			name:          "Storewide campaign with products",
+			payload:       types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignStorewide, ProductIDs: []int{1}, DiscountPercent: 20, StartDate: start, EndDate: end},
+			expectedError: apperrors.NewValidationError("scope", "a storewide campaign targets the whole catalog"),
+		},
+		{
+			name:          "Unknown category",
+			payload:       types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignCategory, CategoryID: intPtr(99), DiscountPercent: 20, StartDate: start, EndDate: end},
+			expectedError: apperrors.NewEntityNotFound("category", 99),
+		},
+		{
+			name:          "Unknown product",
+			payload:       types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignProducts, ProductIDs: []int{1, 99}, DiscountPercent: 20, StartDate: start, EndDate: end},
+			expectedError: apperrors.NewEntityNotFound("product", 99),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockCampaignStore)
+			mockCategoryStore := new(MockCategoryStore)
+			mockProductStore := new(MockProductStore)
+			mockPricer := new(MockListingPricer)
+			service := NewService(mockStore, mockCategoryStore, mockProductStore, mockPricer)
+
+			mockCategoryStore.On("GetCategoryByID", 3).Return(&types.Category{ID: 3}, nil).Maybe()
+			mockCategoryStore.On("GetCategoryByID", 99).Return(nil, fmt.Errorf("category not found")).Maybe()
+			mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1}, nil).Maybe()
+			mockProductStore.On("GetProductByID", 2).Return(&types.Product{ID: 2}, nil).Maybe()
+			mockProductStore.On("GetProductByID", 99).Return(nil, fmt.Errorf("product not found")).Maybe()
+			mockStore.On("CreateCampaign", mock.Anything).Return(&types.Campaign{ID: 1, Name: tt.payload.Name}, nil).Maybe()
+			mockProductStore.On("GetProductsByCategory", 3, true).Return([]*types.Product{{ID: 4}, {ID: 5}}, nil).Maybe()
+			mockPricer.On("RefreshProductPrices", []int{4, 5}).Return(nil).Maybe()
+			mockPricer.On("RefreshProductPrices", []int{1, 2}).Return(nil).Maybe()
+			mockPricer.On("RefreshPrices").Return(10, nil).Maybe()
+
+			campaign, err := service.CreateCampaign(tt.payload)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, campaign)
+				mockStore.AssertNotCalled(t, "CreateCampaign", mock.Anything)
+				assert.Empty(t, mockPricer.Calls)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, campaign.ID)
+				// the products the campaign reaches are priced at once
+				assert.Len(t, mockPricer.Calls, 1)
+			}
+		})
+	}
+
+	t.Run("Defaults to a percentage discount", func(t *testing.T) {
+		mockStore := new(MockCampaignStore)
+		mockPricer := new(MockListingPricer)
+		service := NewService(mockStore, new(MockCategoryStore), new(MockProductStore), mockPricer)
+
+		payload := types.CreateCampaignPayload{Name: "Sale", Scope: types.CampaignStorewide, DiscountPercent: 10, StartDate: start, EndDate: end}
+		stored := payload
+		stored.Type = types.DiscountPercentage
+		mockStore.On("CreateCampaign", stored).Return(&types.Campaign{ID: 1}, nil)
+		mockPricer.On("RefreshPrices").Return(10, nil)
+
+		_, err := service.CreateCampaign(payload)
+
+		assert.NoError(t, err)
+		mockStore.AssertExpectations(t)
+	})
+}
+
+func TestServiceDeleteCampaign(t *testing.T) {
+	t.Run("Refreshes the prices of the products it reached", func(t *testing.T) {
+		mockStore := new(MockCampaignStore)
+		mockPricer := new(MockListingPricer)
+		service := NewService(mockStore, new(MockCategoryStore), new(MockProductStore), mockPricer)
+		mockStore.On("GetCampaignByID", 1).Return(&types.Campaign{ID: 1, Scope: types.CampaignProducts, ProductIDs: []int{2, 3}}, nil)
+		mockStore.On("DeleteCampaign", 1).Return(nil)
+		mockPricer.On("RefreshProductPrices", []int{2, 3}).Return(nil)
+
+		err := service.DeleteCampaign(1)
+
+		assert.NoError(t, err)
+		mockStore.AssertExpectations(t)
+		mockPricer.AssertExpectations(t)
+	})
+
+	t.Run("Campaign not found", func(t *testing.T) {
+		mockStore := new(MockCampaignStore)
+		service := NewService(mockStore, new(MockCategoryStore), new(MockProductStore), new(MockListingPricer))
+		mockStore.On("GetCampaignByID", 9).Return(nil, apperrors.NewEntityNotFound("campaign", 9))
+
+		err := service.DeleteCampaign(9)
+
+		assert.Equal(t, apperrors.NewEntityNotFound("campaign", 9), err)
+		mockStore.AssertNotCalled(t, "DeleteCampaign", mock.Anything)
+	})
+}
@@ -0,0 +1,203 @@
+package campaign
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+const campaignColumns = `
+	id,
+	name,
+	scope,
+	categoryId,
+	discountType,
+	discountPercent,
+	buyQuantity,
+	getQuantity,
+	stackable,
+	priority,
+	startDate,
+	endDate,
+	createdAt
+`
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateCampaign(payload types.CreateCampaignPayload) (*types.Campaign, error) {
+	var campaignID int
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		res, err := tx.Exec(`
+			INSERT INTO campaigns
+				(name, scope, categoryId, discountType, discountPercent, buyQuantity, getQuantity,
+				 stackable, priority, startDate, endDate)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			payload.Name,
+			payload.Scope,
+			payload.CategoryID,
+			payload.Type,
+			payload.DiscountPercent,
+			payload.BuyQuantity,
+			payload.GetQuantity,
+			payload.Stackable,
+			payload.Priority,
+			payload.StartDate,
+			payload.EndDate,
+		)
+		if err != nil {
+			return fmt.Errorf("[CreateCampaign] error inserting campaign: %v", err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateCampaign] error getting campaign ID: %v", err)
+		}
+		campaignID = int(id)
+
+		for _, productID := range payload.ProductIDs {
+			_, err = tx.Exec(`INSERT INTO campaign_products (campaignId, productId) VALUES (?, ?)`, campaignID, productID)
+			if err != nil {
+				return fmt.Errorf("[CreateCampaign] error adding product %d to campaign: %v", productID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCampaignByID(campaignID)
+}
+
+func (s *Store) GetCampaigns() ([]*types.Campaign, error) {
+	rows, err := s.db.Query(`SELECT ` + campaignColumns + ` FROM campaigns ORDER BY startDate DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("[GetCampaigns] error getting campaigns: %v", err)
+	}
+	defer rows.Close()
+
+	campaigns := make([]*types.Campaign, 0)
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[GetCampaigns] error scanning campaign: %v", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetCampaigns] error reading campaigns: %v", err)
+	}
+
+	for _, campaign := range campaigns {
+		if err := s.loadProducts(campaign); err != nil {
+			return nil, err
+		}
+	}
+
+	return campaigns, nil
+}
+
+func (s *Store) GetCampaignByID(campaignID int) (*types.Campaign, error) {
+	row := s.db.QueryRow(`SELECT `+campaignColumns+` FROM campaigns WHERE id = ?`, campaignID)
+
+	campaign, err := scanCampaign(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.NewEntityNotFound("campaign", campaignID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GetCampaign] error getting campaign %d: %v", campaignID, err)
+	}
+
+	if err := s.loadProducts(campaign); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+func (s *Store) DeleteCampaign(campaignID int) error {
+	res, err := s.db.Exec(`DELETE FROM campaigns WHERE id = ?`, campaignID)
+	if err != nil {
+		return fmt.Errorf("[DeleteCampaign] error deleting campaign %d: %v", campaignID, err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return apperrors.NewEntityNotFound("campaign", campaignID)
+	}
+
+	return nil
+}
+
+func (s *Store) loadProducts(campaign *types.Campaign) error {
+	rows, err := s.db.Query(`SELECT productId FROM campaign_products WHERE campaignId = ?`, campaign.ID)
+	if err != nil {
+		return fmt.Errorf("[GetCampaign] error getting products of campaign %d: %v", campaign.ID, err)
+	}
+	defer rows.Close()
+
+	campaign.ProductIDs = make([]int, 0)
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			return fmt.Errorf("[GetCampaign] error scanning product of campaign %d: %v", campaign.ID, err)
+		}
+		campaign.ProductIDs = append(campaign.ProductIDs, productID)
+	}
+
+	return rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCampaign(row scanner) (*types.Campaign, error) {
+	campaign := new(types.Campaign)
+	var categoryID, buyQuantity, getQuantity sql.NullInt64
+
+	err := row.Scan(
+		&campaign.ID,
+		&campaign.Name,
+		&campaign.Scope,
+		&categoryID,
+		&campaign.Type,
+		&campaign.DiscountPercent,
+		&buyQuantity,
+		&getQuantity,
+		&campaign.Stackable,
+		&campaign.Priority,
+		&campaign.StartDate,
+		&campaign.EndDate,
+		&campaign.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		campaign.CategoryID = &id
+	}
+	if buyQuantity.Valid {
+		n := int(buyQuantity.Int64)
+		campaign.BuyQuantity = &n
+	}
+	if getQuantity.Valid {
+		n := int(getQuantity.Int64)
+		campaign.GetQuantity = &n
+	}
+
+	return campaign, nil
+}
@@ -1,10 +1,15 @@
 package cart
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
@@ -21,17 +26,81 @@ func NewHandler(cartService types.CartService) *Handler {
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	router.HandleFunc("/cart/guest", h.createGuestCart).Methods("POST")
 
 	authRouter := router.PathPrefix("").Subrouter()
 	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
 
 	authRouter.HandleFunc("/cart", h.createCart).Methods("POST")
-	authRouter.HandleFunc("/cart/items", h.getMyCartItems).Methods("GET")
-	authRouter.HandleFunc("/cart/items/{productId}", h.addItemToCart).Methods("POST")
-	authRouter.HandleFunc("/cart/items/{productId}", h.removeItemFromCart).Methods("DELETE")
-	authRouter.HandleFunc("/cart/items/{productId}/remove", h.removeEntireItemFromCart).Methods("DELETE")
-	authRouter.HandleFunc("/cart/clear", h.removeAllItemsFromCart).Methods("DELETE")
-	authRouter.HandleFunc("/cart/total", h.getTotal).Methods("GET")
+
+	// the cart of a signed in user, or a guest cart by its token
+	cartRouter := router.PathPrefix("").Subrouter()
+	cartRouter.Use(withCartOwner(userStore))
+
+	cartRouter.HandleFunc("/cart/items", h.getMyCartItems).Methods("GET")
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	cartRouter.HandleFunc("/cart/items/{productId}",
+		utils.Compose(h.addItemToCart, middleware.ErrorHandler)).Methods("POST")
+	cartRouter.HandleFunc("/cart/items/{productId}",
+		utils.Compose(h.updateItemQuantity, middleware.ErrorHandler)).Methods("PUT")
+	cartRouter.HandleFunc("/cart/items/{productId}",
+		utils.Compose(h.removeItemFromCart, middleware.ErrorHandler)).Methods("DELETE")
+	cartRouter.HandleFunc("/cart/items/{productId}/remove",
+		utils.Compose(h.removeEntireItemFromCart, middleware.ErrorHandler)).Methods("DELETE")
+	cartRouter.HandleFunc("/cart/clear", h.removeAllItemsFromCart).Methods("DELETE")
+	cartRouter.HandleFunc("/cart/total", h.getTotal).Methods("GET")
+	cartRouter.HandleFunc("/cart/validate", h.validateCart).Methods("GET")
+	cartRouter.HandleFunc("/cart/coupon", h.applyCoupon).Methods("POST")
+	cartRouter.HandleFunc("/cart/coupon", h.removeCoupon).Methods("DELETE")
+}
+
+type contextKey string
+
+const guestTokenKey contextKey = "guestCartToken"
+
+// withCartOwner lets requests without a user token through when they carry
+// the token of a guest cart. A user token always wins, and an invalid one is
+// rejected as on any other authenticated route.
+func withCartOwner(userStore types.UserStore) mux.MiddlewareFunc {
+	jwtAuth := auth.WithJwtAuthMiddleware(userStore)
+
+	return func(next http.Handler) http.Handler {
+		authenticated := jwtAuth(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			guestToken := r.Header.Get(types.GuestCartHeader)
+			if guestToken == "" || utils.GetTokenFromRequest(r) != "" {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), guestTokenKey, guestToken)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func cartOwner(r *http.Request) (types.CartOwner, bool) {
+	if userID := auth.GetUserIDFromContext(r.Context()); userID != 0 {
+		return types.UserCart(userID), true
+	}
+
+	if token, _ := r.Context().Value(guestTokenKey).(string); token != "" {
+		return types.GuestCart(token), true
+	}
+
+	return types.CartOwner{}, false
+}
+
+func (h *Handler) createGuestCart(w http.ResponseWriter, r *http.Request) {
+	token, err := h.cartService.CreateGuestCart()
+	if err != nil {
+		fmt.Printf("[CART HANDLER] ERROR creating guest cart: %v\n", err)
+		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to create cart"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, map[string]string{"cartToken": token})
 }
 
 func (h *Handler) createCart(w http.ResponseWriter, r *http.Request) {
@@ -51,13 +120,13 @@ func (h *Handler) createCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) getMyCartItems(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
-	items, err := h.cartService.GetMyCartItems(userID)
+	items, err := h.cartService.GetMyCartItems(owner)
 	if err != nil {
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get cart items"})
 		return
@@ -67,33 +136,67 @@ func (h *Handler) getMyCartItems(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) addItemToCart(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	productID := utils.GetParamIdfromPath(r, "productId")
+	variantID := variantIDFromQuery(r)
 
-	item, err := h.cartService.AddItemToCart(productID, userID)
+	item, err := h.cartService.AddItemToCart(productID, variantID, owner)
 	if err != nil {
-		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to add item to cart"})
+		fmt.Printf("[CART HANDLER] ERROR adding product %d to cart: %v\n", productID, err)
+		utils.WriteServiceError(w, err, "Failed to add item to cart")
 		return
 	}
 
 	utils.WriteJson(w, http.StatusCreated, item)
 }
 
+func (h *Handler) updateItemQuantity(w http.ResponseWriter, r *http.Request) {
+	owner, ok := cartOwner(r)
+	if !ok {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	productID := utils.GetParamIdfromPath(r, "productId")
+	variantID := variantIDFromQuery(r)
+
+	var payload types.UpdateCartItemPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	item, err := h.cartService.UpdateItemQuantity(productID, variantID, owner, payload.Quantity)
+	if err != nil {
+		fmt.Printf("[CART HANDLER] ERROR updating quantity of product %d: %v\n", productID, err)
+		utils.WriteServiceError(w, err, "Failed to update item quantity")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, item)
+}
+
 func (h *Handler) removeItemFromCart(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	productID := utils.GetParamIdfromPath(r, "productId")
+	variantID := variantIDFromQuery(r)
 
-	err := h.cartService.RemoveItemFromCart(productID, userID)
+	err := h.cartService.RemoveItemFromCart(productID, variantID, owner)
 	if err != nil {
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove item from cart"})
 		return
@@ -103,15 +206,16 @@ func (h *Handler) removeItemFromCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) removeEntireItemFromCart(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	productID := utils.GetParamIdfromPath(r, "productId")
+	variantID := variantIDFromQuery(r)
 
-	err := h.cartService.RemoveEntireItemFromCart(productID, userID)
+	err := h.cartService.RemoveEntireItemFromCart(productID, variantID, owner)
 	if err != nil {
 		fmt.Printf("[CART ROUTES] ERROR removing entire item from cart: %v\n", err)
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove item from cart"})
@@ -122,13 +226,13 @@ func (h *Handler) removeEntireItemFromCart(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *Handler) getTotal(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
-	total, err := h.cartService.GetTotal(userID)
+	total, err := h.cartService.GetTotal(owner)
 	if err != nil {
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get cart total"})
 		return
@@ -137,19 +241,97 @@ func (h *Handler) getTotal(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJson(w, http.StatusOK, total)
 }
 
+func (h *Handler) validateCart(w http.ResponseWriter, r *http.Request) {
+	owner, ok := cartOwner(r)
+	if !ok {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	validation, err := h.cartService.ValidateCart(owner)
+	if err != nil {
+		fmt.Printf("[CART HANDLER] ERROR validating cart for %s: %v\n", owner, err)
+		utils.WriteServiceError(w, err, "Failed to validate cart")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, validation)
+}
+
+func (h *Handler) applyCoupon(w http.ResponseWriter, r *http.Request) {
+	owner, ok := cartOwner(r)
+	if !ok {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var payload types.ApplyCouponPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	validation, err := h.cartService.ApplyCoupon(owner, payload.Code)
+	if err != nil {
+		fmt.Printf("[CART HANDLER] ERROR applying coupon for %s: %v\n", owner, err)
+		utils.WriteServiceError(w, err, "Failed to apply coupon")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, validation)
+}
+
+func (h *Handler) removeCoupon(w http.ResponseWriter, r *http.Request) {
+	owner, ok := cartOwner(r)
+	if !ok {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.cartService.RemoveCoupon(owner)
+	if err != nil {
+		fmt.Printf("[CART HANDLER] ERROR removing coupon for %s: %v\n", owner, err)
+		utils.WriteServiceError(w, err, "Failed to remove coupon")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Coupon removed from cart successfully"})
+}
+
 func (h *Handler) removeAllItemsFromCart(w http.ResponseWriter, r *http.Request) {
-	userID := auth.GetUserIDFromContext(r.Context())
-	if userID == 0 {
+	owner, ok := cartOwner(r)
+	if !ok {
 		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
-	err := h.cartService.RemoveItemsFromCart(userID)
+	err := h.cartService.RemoveItemsFromCart(owner)
 	if err != nil {
-		fmt.Printf("[CART HANDLER] ERROR removing all items from cart for user %d: %v\n", userID, err)
+		fmt.Printf("[CART HANDLER] ERROR removing all items from cart for %s: %v\n", owner, err)
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to remove items from cart"})
 		return
 	}
 
 	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "All items removed from cart successfully"})
 }
+
+// variantIDFromQuery reads the variant of the product from the variantId
+// query parameter, zero when there is none. Like GetParamIdfromPath it panics
+// on a malformed id, which ErrorHandler turns into a 400.
+func variantIDFromQuery(r *http.Request) int {
+	value := r.URL.Query().Get("variantId")
+	if value == "" {
+		return 0
+	}
+
+	variantID, err := strconv.Atoi(value)
+	if err != nil || variantID < 0 {
+		panic(apperrors.NewValidationError("variantId", "invalid variantId value"))
+	}
+	return variantID
+}
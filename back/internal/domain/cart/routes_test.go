@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/stretchr/testify/assert"
@@ -25,32 +27,84 @@ func (m *MockCartService) CreateCart(userID int) error {
 	return args.Error(0)
 }
 
-func (m *MockCartService) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
-	args := m.Called(userID)
+func (m *MockCartService) CreateGuestCart() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCartService) MergeGuestCart(token string, userID int) (*types.CartMerge, error) {
+	args := m.Called(token, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartMerge), args.Error(1)
+}
+
+func (m *MockCartService) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	args := m.Called(owner)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartService) AddItemToCart(productID int, userID int) (*types.CartItem, error) {
-	args := m.Called(productID, userID)
+func (m *MockCartService) AddItemToCart(productID int, variantID int, owner types.CartOwner) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, owner)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartItem), args.Error(1)
+}
+
+func (m *MockCartService) UpdateItemQuantity(productID int, variantID int, owner types.CartOwner, quantity int) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, owner, quantity)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartService) RemoveItemFromCart(productID int, userID int) error {
-	args := m.Called(productID, userID)
+func (m *MockCartService) RemoveItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	args := m.Called(productID, variantID, owner)
 	return args.Error(0)
 }
 
-func (m *MockCartService) GetTotal(userID int) (float64, error) {
-	args := m.Called(userID)
+func (m *MockCartService) GetTotal(owner types.CartOwner) (float64, error) {
+	args := m.Called(owner)
 	return args.Get(0).(float64), args.Error(1)
 }
 
+func (m *MockCartService) RemoveEntireItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	args := m.Called(productID, variantID, owner)
+	return args.Error(0)
+}
+
+func (m *MockCartService) RemoveItemsFromCart(owner types.CartOwner) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
+func (m *MockCartService) ValidateCart(owner types.CartOwner) (*types.CartValidation, error) {
+	args := m.Called(owner)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartValidation), args.Error(1)
+}
+
+func (m *MockCartService) ApplyCoupon(owner types.CartOwner, code string) (*types.CartValidation, error) {
+	args := m.Called(owner, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartValidation), args.Error(1)
+}
+
+func (m *MockCartService) RemoveCoupon(owner types.CartOwner) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
 // MockUserStore é uma implementação mock da interface UserStore
 type MockUserStore struct {
 	mock.Mock
@@ -87,7 +141,7 @@ func (m *MockUserStore) CreateUser(user types.User) error {
 
 // createTestToken cria um token JWT válido para os testes
 func createTestToken(userID int) string {
-	token, _ := auth.CreateJWT([]byte("test-jwt-secret-key"), userID, types.RoleUser)
+	token, _ := auth.CreateJWT([]byte(testJWTSecret), userID, types.RoleUser)
 	return token
 }
 
@@ -169,7 +223,7 @@ func TestGetMyCartItems(t *testing.T) {
 				items := &[]*types.CartItem{
 					{CartID: 1, ProductID: 1, Quantity: 2},
 				}
-				mcs.On("GetMyCartItems", 1).Return(items, nil)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -181,7 +235,7 @@ func TestGetMyCartItems(t *testing.T) {
 			name:   "Error - Service error",
 			userID: 1,
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("GetMyCartItems", 1).Return(nil, assert.AnError)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(nil, assert.AnError)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -235,7 +289,7 @@ func TestAddItemToCart(t *testing.T) {
 			productID: "1",
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
 				item := &types.CartItem{CartID: 1, ProductID: 1, Quantity: 1}
-				mcs.On("AddItemToCart", 1, 1).Return(item, nil)
+				mcs.On("AddItemToCart", 1, 0, types.UserCart(1)).Return(item, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusCreated,
@@ -255,7 +309,7 @@ func TestAddItemToCart(t *testing.T) {
 			userID:    1,
 			productID: "1",
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("AddItemToCart", 1, 1).Return(nil, assert.AnError)
+				mcs.On("AddItemToCart", 1, 0, types.UserCart(1)).Return(nil, assert.AnError)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -294,6 +348,66 @@ func TestAddItemToCart(t *testing.T) {
 	}
 }
 
+func TestUpdateItemQuantity(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockSetup      func(*MockCartService)
+		expectedStatus int
+	}{
+		{
+			name: "Success - Quantity updated",
+			body: `{"quantity":3}`,
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("UpdateItemQuantity", 1, 0, types.UserCart(1), 3).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 3}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Error - Zero quantity",
+			body:           `{"quantity":0}`,
+			mockSetup:      func(mcs *MockCartService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Invalid body",
+			body:           `{"quantity":"three"}`,
+			mockSetup:      func(mcs *MockCartService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Error - Not enough stock",
+			body: `{"quantity":30}`,
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("UpdateItemQuantity", 1, 0, types.UserCart(1), 30).Return(nil, apperrors.NewValidationError("quantity", "only 3 units in stock"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCartService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("PUT", "/cart/items/1", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestRemoveItemFromCart(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -308,7 +422,7 @@ func TestRemoveItemFromCart(t *testing.T) {
 			userID:    1,
 			productID: "1",
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("RemoveItemFromCart", 1, 1).Return(nil)
+				mcs.On("RemoveItemFromCart", 1, 0, types.UserCart(1)).Return(nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -330,7 +444,7 @@ func TestRemoveItemFromCart(t *testing.T) {
 			userID:    1,
 			productID: "1",
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("RemoveItemFromCart", 1, 1).Return(assert.AnError)
+				mcs.On("RemoveItemFromCart", 1, 0, types.UserCart(1)).Return(assert.AnError)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -381,7 +495,7 @@ func TestGetTotal(t *testing.T) {
 			name:   "Success - Total retrieved",
 			userID: 1,
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("GetTotal", 1).Return(100.0, nil)
+				mcs.On("GetTotal", types.UserCart(1)).Return(100.0, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -391,7 +505,7 @@ func TestGetTotal(t *testing.T) {
 			name:   "Error - Service error",
 			userID: 1,
 			mockSetup: func(mcs *MockCartService, mus *MockUserStore) {
-				mcs.On("GetTotal", 1).Return(0.0, assert.AnError)
+				mcs.On("GetTotal", types.UserCart(1)).Return(0.0, assert.AnError)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusInternalServerError,
@@ -418,12 +532,216 @@ func TestGetTotal(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 
 			if tt.expectedStatus == http.StatusOK {
-				var response map[string]float64
+				// the app reads the total as a bare number
+				var response float64
 				err := json.NewDecoder(rr.Body).Decode(&response)
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedTotal, response["total"])
+				assert.Equal(t, tt.expectedTotal, response)
+			}
+
+			mockService.AssertExpectations(t)
+			mockUserStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestValidateCart(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockCartService)
+		expectedStatus int
+		expected       *types.CartValidation
+	}{
+		{
+			name: "Success - Reports changed price",
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("ValidateCart", types.UserCart(1)).Return(&types.CartValidation{
+					Items: []*types.CartItemValidation{
+						{ProductID: 1, Quantity: 2, PriceAtAdding: 80.0, CurrentPrice: 100.0, AvailableQuantity: 5, PriceChanged: true},
+					},
+					Total:        200.0,
+					PriceChanged: true,
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expected: &types.CartValidation{
+				Items: []*types.CartItemValidation{
+					{ProductID: 1, Quantity: 2, PriceAtAdding: 80.0, CurrentPrice: 100.0, AvailableQuantity: 5, PriceChanged: true},
+				},
+				Total:        200.0,
+				PriceChanged: true,
+			},
+		},
+		{
+			name: "Error - Service error",
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("ValidateCart", types.UserCart(1)).Return(nil, assert.AnError)
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCartService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("GET", "/cart/validate", nil)
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expected != nil {
+				var response types.CartValidation
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, *tt.expected, response)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApplyCoupon(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockSetup      func(*MockCartService)
+		expectedStatus int
+	}{
+		{
+			name: "Success - Coupon applied",
+			body: `{"code":"SAVE10"}`,
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("ApplyCoupon", types.UserCart(1), "SAVE10").Return(&types.CartValidation{
+					Subtotal: 100.0,
+					Discount: 10.0,
+					Coupon:   &types.CartCoupon{ID: 7, Code: "SAVE10", Discount: 10.0, Valid: true},
+					Total:    90.0,
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Error - Missing code",
+			body:           `{}`,
+			mockSetup:      func(mcs *MockCartService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Error - Coupon does not apply",
+			body: `{"code":"SAVE10"}`,
+			mockSetup: func(mcs *MockCartService) {
+				mcs.On("ApplyCoupon", types.UserCart(1), "SAVE10").Return(nil, apperrors.NewValidationError("coupon", "coupon has expired"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCartService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("POST", "/cart/coupon", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGuestCart(t *testing.T) {
+	t.Run("Create guest cart", func(t *testing.T) {
+		mockService := new(MockCartService)
+		mockService.On("CreateGuestCart").Return("guest-token", nil)
+
+		handler := NewHandler(mockService)
+		router := mux.NewRouter()
+		handler.RegisterRoutes(router, new(MockUserStore))
+
+		req := httptest.NewRequest("POST", "/cart/guest", nil)
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var response map[string]string
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "guest-token", response["cartToken"])
+		mockService.AssertExpectations(t)
+	})
+
+	tests := []struct {
+		name           string
+		userToken      bool
+		guestToken     string
+		expectedOwner  *types.CartOwner
+		expectedStatus int
+	}{
+		{
+			name:           "Guest token reaches the guest cart",
+			guestToken:     "guest-token",
+			expectedOwner:  &types.CartOwner{GuestToken: "guest-token"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "User token wins over the guest token",
+			userToken:      true,
+			guestToken:     "guest-token",
+			expectedOwner:  &types.CartOwner{UserID: 1},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Neither token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCartService)
+			mockUserStore := new(MockUserStore)
+			if tt.userToken {
+				mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			}
+			if tt.expectedOwner != nil {
+				mockService.On("GetMyCartItems", *tt.expectedOwner).Return(&[]*types.CartItem{}, nil)
 			}
 
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("GET", "/cart/items", nil)
+			if tt.userToken {
+				req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			}
+			if tt.guestToken != "" {
+				req.Header.Set(types.GuestCartHeader, tt.guestToken)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
 			mockService.AssertExpectations(t)
 			mockUserStore.AssertExpectations(t)
 		})
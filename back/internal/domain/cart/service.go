@@ -1,27 +1,52 @@
 package cart
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/pricing"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
 )
 
+// guestTokenBytes of randomness encode to the 43 characters of a guest token
+const guestTokenBytes = 32
+
 type Service struct {
-	cartStore     types.CartStore
-	productStore  types.ProductStore
-	discountStore types.ProductDiscountStore
+	cartStore        types.CartStore
+	productStore     types.ProductStore
+	variantStore     types.VariantStore
+	discountStore    types.ProductDiscountStore
+	reservationStore types.ReservationStore
+	couponService    types.CouponService
+	reservationTTL   time.Duration
+	uow              types.UnitOfWork
 }
 
 func NewService(
 	cartStore types.CartStore,
 	productStore types.ProductStore,
+	variantStore types.VariantStore,
 	discountStore types.ProductDiscountStore,
+	reservationStore types.ReservationStore,
+	couponService types.CouponService,
+	reservationTTL time.Duration,
+	uow types.UnitOfWork,
 ) *Service {
 	return &Service{
-		cartStore:     cartStore,
-		productStore:  productStore,
-		discountStore: discountStore,
+		cartStore:        cartStore,
+		productStore:     productStore,
+		variantStore:     variantStore,
+		discountStore:    discountStore,
+		reservationStore: reservationStore,
+		couponService:    couponService,
+		reservationTTL:   reservationTTL,
+		uow:              uow,
 	}
 }
 
@@ -29,16 +54,219 @@ func (s *Service) CreateCart(userID int) error {
 	return s.cartStore.CreateCart(userID)
 }
 
-func (s *Service) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
-	items, err := s.cartStore.GetMyCartItems(userID)
+func (s *Service) CreateGuestCart() (string, error) {
+	token, err := newGuestToken()
+	if err != nil {
+		return "", fmt.Errorf("error generating guest cart token: %w", err)
+	}
+
+	cartID, err := s.cartStore.CreateGuestCart(token)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR creating guest cart: %v\n", err)
+		return "", err
+	}
+
+	fmt.Printf("[CART SERVICE] Created guest cart %d\n", cartID)
+	return token, nil
+}
+
+// MergeGuestCart adds the units of every guest item to the user's cart, as
+// far as the purchase limit and the stock left for the user's cart allow.
+// The merge runs in one unit of work, so one that fails halfway leaves both
+// carts as they were and can be retried.
+func (s *Service) MergeGuestCart(token string, userID int) (*types.CartMerge, error) {
+	guest := types.GuestCart(token)
+	user := types.UserCart(userID)
+	fmt.Printf("[CART SERVICE] Merging guest cart into cart of %s\n", user)
+
+	var merge *types.CartMerge
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		var err error
+		merge, err = s.mergeGuestCart(tx, guest, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (s *Service) mergeGuestCart(tx *types.TxStores, guest types.CartOwner, user types.CartOwner) (*types.CartMerge, error) {
+	guestCartID, err := tx.Carts.GetCartID(guest)
+	if err != nil {
+		return nil, err
+	}
+
+	userCartID, err := tx.Carts.GetCartID(user)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart of %s: %v\n", user, err)
+		return nil, err
+	}
+
+	guestItems, err := tx.Carts.GetMyCartItems(guest)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting guest cart items: %v\n", err)
+		return nil, err
+	}
+
+	// the guest's units move to the user's cart, so they must not count
+	// against it while it reserves them
+	err = tx.Reservations.ReleaseCartReservations(guestCartID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR releasing reservations of cart %d: %v\n", guestCartID, err)
+		return nil, err
+	}
+
+	merge := &types.CartMerge{Adjustments: []*types.CartMergeAdjustment{}}
+	for _, item := range *guestItems {
+		adjustment, merged, err := s.mergeItem(tx, item, userCartID, user)
+		if err != nil {
+			return nil, err
+		}
+		if adjustment != nil {
+			merge.Adjustments = append(merge.Adjustments, adjustment)
+		}
+		if merged {
+			merge.MergedItems++
+		}
+	}
+
+	err = mergeCoupon(tx.Carts, guest, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// deleting the guest cart takes its items along
+	err = tx.Carts.DeleteCart(guestCartID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR deleting guest cart %d: %v\n", guestCartID, err)
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+// mergeCoupon keeps the guest's coupon when the user's cart has none.
+func mergeCoupon(carts types.CartStore, guest types.CartOwner, user types.CartOwner) error {
+	guestCoupon, err := carts.GetCouponID(guest)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting guest cart coupon: %v\n", err)
+		return err
+	}
+	if guestCoupon == 0 {
+		return nil
+	}
+
+	userCoupon, err := carts.GetCouponID(user)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting coupon of %s: %v\n", user, err)
+		return err
+	}
+	if userCoupon != 0 {
+		return nil
+	}
+
+	err = carts.SetCoupon(user, guestCoupon)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR moving coupon to cart of %s: %v\n", user, err)
+		return err
+	}
+	return nil
+}
+
+// mergeItem adds the units of the guest item to the user's cart and tells
+// whether any were added. It returns an adjustment when fewer units than the
+// carts held together fit, and drops the item of a product that no longer
+// exists.
+func (s *Service) mergeItem(tx *types.TxStores, item *types.CartItem, userCartID int, user types.CartOwner) (*types.CartMergeAdjustment, bool, error) {
+	current, productCurrent, err := cartQuantity(tx.Carts, user, item.ProductID, item.VariantID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	requested := current + item.Quantity
+
+	product, err := tx.Products.GetProductByID(item.ProductID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return mergeAdjustment(item, requested, current, "unavailable"), false, nil
+	}
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting product %d: %v\n", item.ProductID, err)
+		return nil, false, err
+	}
+
+	quantity := requested
+	reason := ""
+
+	// the limit counts the units of every variant of the product
+	otherVariants := productCurrent - current
+	if limit := product.Inventory.MaxPurchaseQuantity; limit != nil && otherVariants+quantity > *limit {
+		quantity = *limit - otherVariants
+		reason = "purchase limit"
+	}
+
+	if quantity > current {
+		err = s.reserve(tx.Reservations, userCartID, item.ProductID, item.VariantID, quantity)
+		if errors.Is(err, types.ErrInsufficientStock) {
+			available, availableErr := tx.Reservations.GetAvailableStock(item.ProductID, item.VariantID, userCartID)
+			if availableErr != nil {
+				fmt.Printf("[CART SERVICE] ERROR getting available stock of product %d: %v\n", item.ProductID, availableErr)
+				return nil, false, availableErr
+			}
+
+			quantity = min(quantity, available)
+			reason = "out of stock"
+			err = nil
+			if quantity > current {
+				err = s.reserve(tx.Reservations, userCartID, item.ProductID, item.VariantID, quantity)
+			}
+		}
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR reserving product %d: %v\n", item.ProductID, err)
+			return nil, false, err
+		}
+	}
+
+	// the user's cart never loses units it already had
+	quantity = max(quantity, current)
+	if quantity > current {
+		// an item the user already had keeps its price
+		_, err = tx.Carts.SetItemQuantity(item.ProductID, item.VariantID, item.VariantLabel, user, quantity, item.PriceAtAdding)
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR merging product %d: %v\n", item.ProductID, err)
+			return nil, false, err
+		}
+	}
+
+	merged := quantity > current
+	if quantity == requested {
+		return nil, merged, nil
+	}
+	return mergeAdjustment(item, requested, quantity, reason), merged, nil
+}
+
+func mergeAdjustment(item *types.CartItem, requested int, quantity int, reason string) *types.CartMergeAdjustment {
+	return &types.CartMergeAdjustment{
+		ProductID:    item.ProductID,
+		VariantID:    item.VariantID,
+		ProductTitle: item.ProductTitle,
+		Requested:    requested,
+		Quantity:     quantity,
+		Reason:       reason,
+	}
+}
+
+func (s *Service) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	items, err := s.cartStore.GetMyCartItems(owner)
 	if err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
-func (s *Service) AddItemToCart(productID int, userID int) (*types.CartItem, error) {
-	fmt.Printf("[CART SERVICE] Starting to add product %d to cart for user %d\n", productID, userID)
+func (s *Service) AddItemToCart(productID int, variantID int, owner types.CartOwner) (*types.CartItem, error) {
+	fmt.Printf("[CART SERVICE] Starting to add product %d (variant %d) to cart for %s\n", productID, variantID, owner)
 
 	product, err := s.productStore.GetProductByID(productID)
 	if err != nil {
@@ -51,37 +279,49 @@ func (s *Service) AddItemToCart(productID int, userID int) (*types.CartItem, err
 		return nil, apperrors.NewEntityNotFound("product", productID)
 	}
 
-	fmt.Printf("[CART SERVICE] Product %d found. Stock: %d\n", productID, product.Inventory.StockQuantity)
-	if product.Inventory.StockQuantity <= 0 {
-		fmt.Printf("[CART SERVICE] Product %d out of stock\n", productID)
-		return nil, apperrors.NewValidationError("product", "product out of stock")
+	fmt.Printf("[CART SERVICE] Product %d found. Available: %d\n", productID, product.Inventory.AvailableQuantity)
+
+	variant, err := s.variant(productID, variantID)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("[CART SERVICE] Checking discounts for product %d\n", productID)
-	discounts, err := s.discountStore.GetActiveDiscounts(productID)
+	cartID, err := s.cartStore.GetCartID(owner)
 	if err != nil {
-		fmt.Printf("[CART SERVICE] ERROR getting discounts for product %d: %v\n", productID, err)
-		return nil, fmt.Errorf("error getting discounts: %w", err)
+		fmt.Printf("[CART SERVICE] ERROR getting cart of %s: %v\n", owner, err)
+		return nil, err
 	}
 
-	finalPrice := product.BasePrice
-	if len(discounts) > 0 {
-		highestDiscount := 0.0
-		for _, discount := range discounts {
-			if discount.DiscountPercent > highestDiscount {
-				highestDiscount = discount.DiscountPercent
-			}
-		}
-		fmt.Printf("[CART SERVICE] Applying discount %.2f%% to product %d. Original: %.2f, Final: %.2f\n",
-			highestDiscount, productID, product.BasePrice, finalPrice)
+	quantity, productQuantity, err := cartQuantity(s.cartStore, owner, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
 
-		finalPrice = product.BasePrice * (1 - highestDiscount/100)
+	if err := checkMaxPurchase(product, productQuantity+1); err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("[CART SERVICE] Sending to store: product %d, user %d, price %.2f\n", productID, userID, finalPrice)
-	item, err := s.cartStore.AddItemToCart(productID, userID, finalPrice)
+	err = s.reserve(s.reservationStore, cartID, productID, variantID, quantity+1)
+	if errors.Is(err, types.ErrInsufficientStock) {
+		fmt.Printf("[CART SERVICE] Product %d out of stock\n", productID)
+		return nil, apperrors.NewValidationError("product", "product out of stock")
+	}
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR reserving product %d: %v\n", productID, err)
+		return nil, err
+	}
+
+	finalPrice, err := s.currentPrice(product, variant)
+	if err != nil {
+		s.releaseUnits(cartID, productID, variantID, quantity)
+		return nil, err
+	}
+
+	fmt.Printf("[CART SERVICE] Sending to store: product %d, %s, price %.2f\n", productID, owner, finalPrice)
+	item, err := s.cartStore.AddItemToCart(productID, variantID, variantLabel(variant), owner, finalPrice)
 	if err != nil {
 		fmt.Printf("[CART SERVICE] ERROR adding item to cart: %v\n", err)
+		s.releaseUnits(cartID, productID, variantID, quantity)
 		return nil, err
 	}
 
@@ -89,45 +329,507 @@ func (s *Service) AddItemToCart(productID int, userID int) (*types.CartItem, err
 	return item, nil
 }
 
-func (s *Service) RemoveItemFromCart(productID int, userID int) error {
+// UpdateItemQuantity sets how many units of the variant of productID the
+// cart holds. The units are reserved before the cart changes, so a quantity
+// the stock cannot cover leaves the cart as it was.
+func (s *Service) UpdateItemQuantity(productID int, variantID int, owner types.CartOwner, quantity int) (*types.CartItem, error) {
+	fmt.Printf("[CART SERVICE] Setting quantity of product %d (variant %d) to %d for %s\n", productID, variantID, quantity, owner)
+
+	if quantity <= 0 {
+		return nil, apperrors.NewValidationError("quantity", "quantity must be greater than zero")
+	}
+
+	product, err := s.productStore.GetProductByID(productID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting product %d: %v\n", productID, err)
+		return nil, err
+	}
+
+	if product == nil {
+		return nil, apperrors.NewEntityNotFound("product", productID)
+	}
+
+	variant, err := s.variant(productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	cartID, err := s.cartStore.GetCartID(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart of %s: %v\n", owner, err)
+		return nil, err
+	}
+
+	previous, productQuantity, err := cartQuantity(s.cartStore, owner, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMaxPurchase(product, productQuantity-previous+quantity); err != nil {
+		return nil, err
+	}
+
+	err = s.reserve(s.reservationStore, cartID, productID, variantID, quantity)
+	if errors.Is(err, types.ErrInsufficientStock) {
+		return nil, s.insufficientStock(cartID, productID, variantID)
+	}
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR reserving product %d: %v\n", productID, err)
+		return nil, err
+	}
+
+	price, err := s.currentPrice(product, variant)
+	if err != nil {
+		s.releaseUnits(cartID, productID, variantID, previous)
+		return nil, err
+	}
+
+	item, err := s.cartStore.SetItemQuantity(productID, variantID, variantLabel(variant), owner, quantity, price)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR setting quantity of product %d: %v\n", productID, err)
+		s.releaseUnits(cartID, productID, variantID, previous)
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s *Service) RemoveItemFromCart(productID int, variantID int, owner types.CartOwner) error {
 
-	item, err := s.cartStore.GetCartItem(userID, productID)
+	item, err := s.cartStore.GetCartItem(owner, productID, variantID)
 	if err != nil {
 		fmt.Printf("[CART SERVICE] ERROR getting item at remove item from cart %d: %v\n", productID, err)
 		return err
 	}
 
 	if item.Quantity > 1 {
-		err := s.cartStore.RemoveOneItemFromCart(userID, productID)
+		err := s.cartStore.RemoveOneItemFromCart(owner, productID, variantID)
 		if err != nil {
 			fmt.Printf("[CART SERVICE] ERROR removing one item from cart %d: %v\n", productID, err)
 			return err
 		}
 	} else {
-		err := s.cartStore.RemoveItemFromCart(userID, productID)
+		err := s.cartStore.RemoveItemFromCart(productID, variantID, owner)
 		if err != nil {
 			fmt.Printf("[CART SERVICE] ERROR removing item from cart %d: %v\n", productID, err)
 			return err
 		}
 	}
 
+	s.releaseUnits(item.CartID, productID, variantID, item.Quantity-1)
 	return nil
 }
 
-func (s *Service) RemoveEntireItemFromCart(productID int, userID int) error {
-	fmt.Printf("[CART SERVICE] removing product %d from user %d\n", productID, userID)
-	return s.cartStore.RemoveItemFromCart(productID, userID)
+func (s *Service) RemoveEntireItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	fmt.Printf("[CART SERVICE] removing product %d (variant %d) from %s\n", productID, variantID, owner)
+
+	err := s.cartStore.RemoveItemFromCart(productID, variantID, owner)
+	if err != nil {
+		return err
+	}
+
+	cartID, err := s.cartStore.GetCartID(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart of %s: %v\n", owner, err)
+		return nil
+	}
+
+	s.releaseUnits(cartID, productID, variantID, 0)
+	return nil
 }
 
-func (s *Service) GetTotal(userID int) (float64, error) {
-	total, err := s.cartStore.GetTotal(userID)
+// GetTotal prices the items at the price they were added at, and takes off
+// the discount of the cart's coupon while it still applies.
+func (s *Service) GetTotal(owner types.CartOwner) (float64, error) {
+	total, err := s.cartStore.GetTotal(owner)
 	if err != nil {
 		return 0, err
 	}
-	return total, nil
+
+	coupon, err := s.appliedCoupon(owner)
+	if err != nil || coupon == nil {
+		return total, err
+	}
+
+	items, err := s.cartStore.GetMyCartItems(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart items of %s: %v\n", owner, err)
+		return 0, err
+	}
+
+	lines := make([]types.CouponLine, 0, len(*items))
+	for _, item := range *items {
+		product, err := s.productStore.GetProductByID(item.ProductID)
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR getting product %d: %v\n", item.ProductID, err)
+			return 0, err
+		}
+		lines = append(lines, couponLine(product, item.Quantity, item.PriceAtAdding))
+	}
+
+	applied, err := s.evaluateCoupon(coupon, owner, lines)
+	if err != nil {
+		return 0, err
+	}
+
+	return utils.RoundCents(total - applied.Discount), nil
+}
+
+func (s *Service) RemoveItemsFromCart(owner types.CartOwner) error {
+	fmt.Printf("[CART SERVICE] Removing all items from cart for %s\n", owner)
+
+	err := s.cartStore.RemoveItemsFromCart(owner)
+	if err != nil {
+		return err
+	}
+
+	cartID, err := s.cartStore.GetCartID(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart of %s: %v\n", owner, err)
+		return nil
+	}
+
+	err = s.reservationStore.ReleaseCartReservations(cartID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR releasing reservations of cart %d: %v\n", cartID, err)
+	}
+	return nil
+}
+
+// ValidateCart reprices every item with the discounts active now and checks
+// it against the stock left for this cart and the conditions of its coupon.
+// Nothing in the cart is changed.
+func (s *Service) ValidateCart(owner types.CartOwner) (*types.CartValidation, error) {
+	fmt.Printf("[CART SERVICE] Validating cart of %s\n", owner)
+
+	validation, lines, err := s.validateItems(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	coupon, err := s.appliedCoupon(owner)
+	if err != nil || coupon == nil {
+		return validation, err
+	}
+
+	applied, err := s.evaluateCoupon(coupon, owner, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	withCoupon(validation, applied)
+	return validation, nil
+}
+
+// ApplyCoupon only keeps a coupon that takes something off the cart as it is
+// now, so the customer learns right away why a code does not work.
+func (s *Service) ApplyCoupon(owner types.CartOwner, code string) (*types.CartValidation, error) {
+	fmt.Printf("[CART SERVICE] Applying coupon to cart of %s\n", owner)
+
+	coupon, err := s.couponService.GetCouponByCode(code)
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) && appErr.Type == apperrors.NotFound {
+		return nil, apperrors.NewValidationError("coupon", "coupon code is not valid")
+	}
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting coupon: %v\n", err)
+		return nil, err
+	}
+
+	validation, lines, err := s.validateItems(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.evaluateCoupon(coupon, owner, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	if !applied.Valid {
+		return nil, apperrors.NewValidationError("coupon", applied.Reason)
+	}
+
+	err = s.cartStore.SetCoupon(owner, coupon.ID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR applying coupon %d to cart of %s: %v\n", coupon.ID, owner, err)
+		return nil, err
+	}
+
+	withCoupon(validation, applied)
+	return validation, nil
 }
 
-func (s *Service) RemoveItemsFromCart(userID int) error {
-	fmt.Printf("[CART SERVICE] Removing all items from cart for user %d\n", userID)
-	return s.cartStore.RemoveItemsFromCart(userID)
+func (s *Service) RemoveCoupon(owner types.CartOwner) error {
+	err := s.cartStore.SetCoupon(owner, 0)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR removing coupon from cart of %s: %v\n", owner, err)
+		return err
+	}
+	return nil
+}
+
+// validateItems checks the items of the cart and returns them as the lines a
+// coupon is evaluated on.
+func (s *Service) validateItems(owner types.CartOwner) (*types.CartValidation, []types.CouponLine, error) {
+	items, err := s.cartStore.GetMyCartItems(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart items of %s: %v\n", owner, err)
+		return nil, nil, err
+	}
+
+	validation := &types.CartValidation{Items: []*types.CartItemValidation{}}
+	lines := make([]types.CouponLine, 0, len(*items))
+	for _, item := range *items {
+		product, err := s.productStore.GetProductByID(item.ProductID)
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR getting product %d: %v\n", item.ProductID, err)
+			return nil, nil, err
+		}
+
+		var variant *types.ProductVariant
+		if item.VariantID != 0 {
+			variant, err = s.variantStore.GetVariant(item.ProductID, item.VariantID)
+			if err != nil {
+				fmt.Printf("[CART SERVICE] ERROR getting variant %d: %v\n", item.VariantID, err)
+				return nil, nil, err
+			}
+		}
+
+		breakdown, err := s.price(product, variant, item.Quantity)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		available, err := s.reservationStore.GetAvailableStock(item.ProductID, item.VariantID, item.CartID)
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR getting available stock of product %d: %v\n", item.ProductID, err)
+			return nil, nil, err
+		}
+
+		line := &types.CartItemValidation{
+			ProductID:         item.ProductID,
+			VariantID:         item.VariantID,
+			ProductTitle:      item.ProductTitle,
+			VariantLabel:      item.VariantLabel,
+			Quantity:          item.Quantity,
+			PriceAtAdding:     item.PriceAtAdding,
+			CurrentPrice:      breakdown.UnitPrice,
+			LineTotal:         breakdown.Total,
+			AvailableQuantity: max(available, 0),
+			Pricing:           breakdown,
+		}
+		line.PriceChanged = line.CurrentPrice != utils.RoundCents(item.PriceAtAdding)
+		line.OutOfStock = available < item.Quantity
+
+		validation.Items = append(validation.Items, line)
+		validation.Subtotal += line.LineTotal
+		validation.PriceChanged = validation.PriceChanged || line.PriceChanged
+		validation.OutOfStock = validation.OutOfStock || line.OutOfStock
+		// multi unit offers lower the worth of every unit to the coupon
+		lines = append(lines, couponLine(product, line.Quantity, line.LineTotal/float64(line.Quantity)))
+	}
+	validation.Subtotal = utils.RoundCents(validation.Subtotal)
+	validation.Total = validation.Subtotal
+
+	return validation, lines, nil
+}
+
+// appliedCoupon is the coupon applied to the cart, or nil when there is none.
+func (s *Service) appliedCoupon(owner types.CartOwner) (*types.Coupon, error) {
+	couponID, err := s.cartStore.GetCouponID(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting coupon of %s: %v\n", owner, err)
+		return nil, err
+	}
+	if couponID == 0 {
+		return nil, nil
+	}
+
+	coupon, err := s.couponService.GetCouponByID(couponID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting coupon %d: %v\n", couponID, err)
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (s *Service) evaluateCoupon(coupon *types.Coupon, owner types.CartOwner, lines []types.CouponLine) (*types.CartCoupon, error) {
+	evaluation, err := s.couponService.Evaluate(coupon, owner.UserID, lines)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR evaluating coupon %d: %v\n", coupon.ID, err)
+		return nil, err
+	}
+
+	return &types.CartCoupon{
+		ID:            coupon.ID,
+		Code:          coupon.Code,
+		Discount:      evaluation.Discount,
+		Valid:         evaluation.Reason == "",
+		Reason:        evaluation.Reason,
+		LineDiscounts: evaluation.LineDiscounts,
+	}, nil
+}
+
+// withCoupon takes the discount of a valid coupon off the validated cart and
+// its items.
+func withCoupon(validation *types.CartValidation, coupon *types.CartCoupon) {
+	validation.Coupon = coupon
+	if !coupon.Valid {
+		return
+	}
+
+	validation.Discount = coupon.Discount
+	validation.Total = utils.RoundCents(validation.Subtotal - coupon.Discount)
+	// the coupon was evaluated on the lines validateItems returned, which
+	// follow the validated items
+	for i, discount := range coupon.LineDiscounts {
+		if i < len(validation.Items) {
+			validation.Items[i].CouponDiscount = discount
+		}
+	}
+}
+
+func couponLine(product *types.Product, quantity int, price float64) types.CouponLine {
+	categoryIDs := make([]int, 0, len(product.Categories))
+	for _, category := range product.Categories {
+		categoryIDs = append(categoryIDs, category.ID)
+	}
+
+	return types.CouponLine{
+		ProductID:   product.ID,
+		CategoryIDs: categoryIDs,
+		Quantity:    quantity,
+		UnitPrice:   price,
+	}
+}
+
+// variant is the variant of productID a cart line is for, nil for a product
+// sold without variants. A product with variants only goes in the cart as
+// one of them.
+func (s *Service) variant(productID int, variantID int) (*types.ProductVariant, error) {
+	if variantID != 0 {
+		variant, err := s.variantStore.GetVariant(productID, variantID)
+		if err != nil {
+			fmt.Printf("[CART SERVICE] ERROR getting variant %d of product %d: %v\n", variantID, productID, err)
+			return nil, err
+		}
+		return variant, nil
+	}
+
+	variants, err := s.variantStore.GetVariants(productID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting variants of product %d: %v\n", productID, err)
+		return nil, err
+	}
+
+	if len(variants) > 0 {
+		return nil, apperrors.NewValidationError("variantId", "choose a variant of the product")
+	}
+	return nil, nil
+}
+
+func variantLabel(variant *types.ProductVariant) string {
+	if variant == nil {
+		return ""
+	}
+	return variant.Label
+}
+
+// cartQuantity is how many units of the variant of productID the cart
+// holds, and how many of the product across all its variants.
+func cartQuantity(carts types.CartStore, owner types.CartOwner, productID int, variantID int) (int, int, error) {
+	items, err := carts.GetMyCartItems(owner)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting cart items of %s: %v\n", owner, err)
+		return 0, 0, err
+	}
+
+	line, product := 0, 0
+	for _, item := range *items {
+		if item.ProductID != productID {
+			continue
+		}
+		product += item.Quantity
+		if item.VariantID == variantID {
+			line = item.Quantity
+		}
+	}
+
+	return line, product, nil
+}
+
+// currentPrice is the price of one unit of product, or of its variant, with
+// the active discounts of the product.
+func (s *Service) currentPrice(product *types.Product, variant *types.ProductVariant) (float64, error) {
+	breakdown, err := s.price(product, variant, 1)
+	if err != nil {
+		return 0, err
+	}
+	return breakdown.UnitPrice, nil
+}
+
+// price works out what quantity units of product, or of its variant, cost
+// with the active discounts of the product, multi unit offers included.
+func (s *Service) price(product *types.Product, variant *types.ProductVariant, quantity int) (*types.PriceBreakdown, error) {
+	discounts, err := s.discountStore.GetActiveDiscounts(product.ID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting discounts for product %d: %v\n", product.ID, err)
+		return nil, fmt.Errorf("error getting discounts: %w", err)
+	}
+
+	basePrice := product.BasePrice
+	if variant != nil && variant.PriceOverride != nil {
+		basePrice = *variant.PriceOverride
+	}
+
+	return pricing.Price(basePrice, quantity, discounts), nil
+}
+
+// insufficientStock tells how many units the cart can still get, so the
+// client can offer that quantity instead.
+func (s *Service) insufficientStock(cartID int, productID int, variantID int) error {
+	available, err := s.reservationStore.GetAvailableStock(productID, variantID, cartID)
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR getting available stock of product %d: %v\n", productID, err)
+		return apperrors.NewValidationError("quantity", "not enough units in stock")
+	}
+
+	return apperrors.NewValidationError("quantity", fmt.Sprintf("only %d units in stock", max(available, 0)))
+}
+
+func checkMaxPurchase(product *types.Product, quantity int) error {
+	limit := product.Inventory.MaxPurchaseQuantity
+	if limit != nil && quantity > *limit {
+		return apperrors.NewValidationError("quantity", fmt.Sprintf("at most %d units of this product per order", *limit))
+	}
+	return nil
+}
+
+func newGuestToken() (string, error) {
+	buf := make([]byte, guestTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (s *Service) reserve(reservations types.ReservationStore, cartID int, productID int, variantID int, quantity int) error {
+	return reservations.ReserveStock(cartID, productID, variantID, quantity, time.Now().Add(s.reservationTTL))
+}
+
+// releaseUnits shrinks the cart's reservation of the variant of productID to
+// quantity units. The cart itself is already right at this point, so a
+// failure is only logged: the reservation expires on its own.
+func (s *Service) releaseUnits(cartID int, productID int, variantID int, quantity int) {
+	var err error
+	if quantity > 0 {
+		err = s.reserve(s.reservationStore, cartID, productID, variantID, quantity)
+	} else {
+		err = s.reservationStore.ReleaseReservation(cartID, productID, variantID)
+	}
+
+	if err != nil {
+		fmt.Printf("[CART SERVICE] ERROR releasing product %d of cart %d: %v\n", productID, cartID, err)
+	}
 }
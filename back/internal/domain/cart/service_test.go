@@ -1,6 +1,7 @@
 package cart
 
 import (
+	"database/sql"
 	"errors"
 	"testing"
 	"time"
@@ -21,37 +22,88 @@ func (m *MockCartStore) CreateCart(userID int) error {
 	return args.Error(0)
 }
 
-func (m *MockCartStore) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) CreateGuestCart(token string) (int, error) {
+	args := m.Called(token)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCartStore) DeleteCart(cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) DeleteIdleGuestCarts(idleSince time.Time) (int64, error) {
+	args := m.Called(idleSince)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCartStore) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	args := m.Called(owner)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartStore) AddItemToCart(productID int, userID int, price float64) (*types.CartItem, error) {
-	args := m.Called(productID, userID, price)
+func (m *MockCartStore) AddItemToCart(productID int, variantID int, variantLabel string, owner types.CartOwner, price float64) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, variantLabel, owner, price)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartStore) RemoveItemFromCart(productID int, userID int) error {
-	args := m.Called(productID, userID)
+func (m *MockCartStore) SetItemQuantity(productID int, variantID int, variantLabel string, owner types.CartOwner, quantity int, price float64) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, variantLabel, owner, quantity, price)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartItem), args.Error(1)
+}
+
+func (m *MockCartStore) RemoveItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	args := m.Called(productID, variantID, owner)
 	return args.Error(0)
 }
 
-func (m *MockCartStore) GetTotal(userID int) (float64, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) GetTotal(owner types.CartOwner) (float64, error) {
+	args := m.Called(owner)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockCartStore) GetCartID(userID int) (int, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) GetCartID(owner types.CartOwner) (int, error) {
+	args := m.Called(owner)
 	return args.Get(0).(int), args.Error(1)
 }
 
+func (m *MockCartStore) GetCartItem(owner types.CartOwner, productID int, variantID int) (*types.CartItem, error) {
+	args := m.Called(owner, productID, variantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartItem), args.Error(1)
+}
+
+func (m *MockCartStore) RemoveOneItemFromCart(owner types.CartOwner, productID int, variantID int) error {
+	args := m.Called(owner, productID, variantID)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) RemoveItemsFromCart(owner types.CartOwner) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) SetCoupon(owner types.CartOwner, couponID int) error {
+	args := m.Called(owner, couponID)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) GetCouponID(owner types.CartOwner) (int, error) {
+	args := m.Called(owner)
+	return args.Int(0), args.Error(1)
+}
+
 // MockProductStore is a mock implementation of the ProductStore interface
 type MockProductStore struct {
 	mock.Mock
@@ -91,6 +143,14 @@ func (m *MockProductStore) GetImagesForProducts(productIDs []int) (map[int][]typ
 	return args.Get(0).(map[int][]types.ProductImage), args.Error(1)
 }
 
+func (m *MockProductStore) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.ProductDimensions), args.Error(1)
+}
+
 func (m *MockProductStore) GetInventory(productID int) (*types.Inventory, error) {
 	args := m.Called(productID)
 	if args.Get(0) == nil {
@@ -107,30 +167,75 @@ func (m *MockProductStore) GetProductDetails(productID int, userID int) (*types.
 	return args.Get(0).(*types.ProductDetails), args.Error(1)
 }
 
-func (m *MockProductStore) GetProducts() ([]*types.Product, error) {
-	args := m.Called()
+func (m *MockProductStore) GetProducts(page types.PageRequest) (*types.Page[*types.Product], error) {
+	args := m.Called(page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.Product), args.Error(1)
+	return args.Get(0).(*types.Page[*types.Product]), args.Error(1)
 }
 
-func (m *MockProductStore) GetProductsByCategory(categoryID int) ([]*types.Product, error) {
-	args := m.Called(categoryID)
+func (m *MockProductStore) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	args := m.Called(categoryID, includeSubcategories)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*types.Product), args.Error(1)
 }
 
-func (m *MockProductStore) GetSimpleProductDetails(productID int) (*[]*types.SimpleProductObject, error) {
-	args := m.Called(productID)
+func (m *MockProductStore) GetSimpleProductDetails(productID int, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(productID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) SearchProducts(userID int, text string, limit int) (*[]*types.SimpleProductObject, error) {
+	args := m.Called(userID, text, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.SimpleProductObject), args.Error(1)
 }
 
+func (m *MockProductStore) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductFacets), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePrices(afterID int, limit int) ([]*types.ProductPrice, error) {
+	args := m.Called(afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePricesForProducts(productIDs []int) ([]*types.ProductPrice, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) SavePrices(prices []*types.ProductPrice) error {
+	args := m.Called(prices)
+	return args.Error(0)
+}
+
 func (m *MockProductStore) UpdateProduct(id int, payload types.UpdateProductPayload) error {
 	args := m.Called(id, payload)
 	return args.Error(0)
@@ -154,6 +259,22 @@ func (m *MockProductDiscountStore) GetActiveDiscounts(productID int) ([]*types.P
 	return args.Get(0).([]*types.ProductDiscount), args.Error(1)
 }
 
+func (m *MockProductDiscountStore) GetDiscountCalendar(start time.Time, end time.Time) ([]*types.CalendarDiscount, error) {
+	args := m.Called(start, end)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.CalendarDiscount), args.Error(1)
+}
+
+func (m *MockProductDiscountStore) GetActiveDiscountsForProducts(productIDs []int) (map[int][]*types.ProductDiscount, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]*types.ProductDiscount), args.Error(1)
+}
+
 func (m *MockProductDiscountStore) CreateDiscount(payload *types.CreateProductDiscountPayload) (*types.ProductDiscount, error) {
 	args := m.Called(payload)
 	if args.Get(0) == nil {
@@ -199,12 +320,111 @@ func (m *MockProductDiscountStore) UpdateDiscount(id int, payload *types.UpdateP
 	return args.Get(0).(*types.ProductDiscount), args.Error(1)
 }
 
+// MockVariantStore is a mock of the parts of the VariantStore interface the
+// cart uses
+type MockVariantStore struct {
+	types.VariantStore
+	mock.Mock
+}
+
+func (m *MockVariantStore) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductVariant), args.Error(1)
+}
+
+func (m *MockVariantStore) GetVariant(productID int, variantID int) (*types.ProductVariant, error) {
+	args := m.Called(productID, variantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductVariant), args.Error(1)
+}
+
+// noVariants is a variant store for products sold without variants
+func noVariants() *MockVariantStore {
+	m := new(MockVariantStore)
+	m.On("GetVariants", mock.Anything).Return([]*types.ProductVariant{}, nil)
+	return m
+}
+
+// MockReservationStore is a mock implementation of the ReservationStore interface
+type MockReservationStore struct {
+	mock.Mock
+}
+
+func (m *MockReservationStore) ReserveStock(cartID int, productID int, variantID int, quantity int, expiresAt time.Time) error {
+	args := m.Called(cartID, productID, variantID, quantity, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockReservationStore) ReleaseReservation(cartID int, productID int, variantID int) error {
+	args := m.Called(cartID, productID, variantID)
+	return args.Error(0)
+}
+
+func (m *MockReservationStore) ReleaseCartReservations(cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+func (m *MockReservationStore) GetAvailableStock(productID int, variantID int, exceptCartID int) (int, error) {
+	args := m.Called(productID, variantID, exceptCartID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockReservationStore) DeleteExpiredReservations() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockCouponService struct {
+	types.CouponService
+	mock.Mock
+}
+
+func (m *MockCouponService) GetCouponByCode(code string) (*types.Coupon, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponService) GetCouponByID(couponID int) (*types.Coupon, error) {
+	args := m.Called(couponID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponService) Evaluate(coupon *types.Coupon, userID int, lines []types.CouponLine) (*types.CouponEvaluation, error) {
+	args := m.Called(coupon, userID, lines)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CouponEvaluation), args.Error(1)
+}
+
+type MockUnitOfWork struct {
+	stores *types.TxStores
+}
+
+func (m *MockUnitOfWork) Do(fn func(stores *types.TxStores) error) error {
+	return fn(m.stores)
+}
+
 func TestServiceCreateCart(t *testing.T) {
 	mockCartStore := new(MockCartStore)
 	mockProductStore := new(MockProductStore)
 	mockDiscountStore := new(MockProductDiscountStore)
 
-	service := NewService(mockCartStore, mockProductStore, mockDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
 
 	tests := []struct {
 		name          string
@@ -250,7 +470,9 @@ func TestServiceGetMyCartItems(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockDiscountStore := new(MockProductDiscountStore)
 
-	service := NewService(mockCartStore, mockProductStore, mockDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
 
 	tests := []struct {
 		name          string
@@ -272,7 +494,7 @@ func TestServiceGetMyCartItems(t *testing.T) {
 						AddedAt:       time.Now(),
 					},
 				}
-				mockCartStore.On("GetMyCartItems", 1).Return(items, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
 			},
 			expectedError: nil,
 		},
@@ -280,7 +502,7 @@ func TestServiceGetMyCartItems(t *testing.T) {
 			name:   "Cart not found",
 			userID: 1,
 			mockSetup: func() {
-				mockCartStore.On("GetMyCartItems", 1).Return(nil, apperrors.NewEntityNotFound("cart", 1))
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(nil, apperrors.NewEntityNotFound("cart", 1))
 			},
 			expectedError: apperrors.NewEntityNotFound("cart", 1),
 		},
@@ -290,7 +512,7 @@ func TestServiceGetMyCartItems(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockCartStore.ExpectedCalls = nil
 			tt.mockSetup()
-			items, err := service.GetMyCartItems(tt.userID)
+			items, err := service.GetMyCartItems(types.UserCart(tt.userID))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 				assert.Nil(t, items)
@@ -308,7 +530,9 @@ func TestServiceAddItemToCart(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockDiscountStore := new(MockProductDiscountStore)
 
-	service := NewService(mockCartStore, mockProductStore, mockDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
 
 	tests := []struct {
 		name          string
@@ -339,11 +563,48 @@ func TestServiceAddItemToCart(t *testing.T) {
 				}
 
 				mockProductStore.On("GetProductByID", 1).Return(product, nil)
+				mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(&[]*types.CartItem{}, nil)
+				mockReservationStore.On("ReserveStock", 1, 1, 0, 1, mock.Anything).Return(nil)
 				mockDiscountStore.On("GetActiveDiscounts", 1).Return(discounts, nil)
-				mockCartStore.On("AddItemToCart", 1, 1, 80.0).Return(cartItem, nil)
+				mockCartStore.On("AddItemToCart", 1, 0, "", types.UserCart(1), 80.0).Return(cartItem, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:      "Another unit grows the reservation",
+			userID:    1,
+			productID: 1,
+			mockSetup: func() {
+				product := &types.Product{ID: 1, BasePrice: 100.0, Inventory: types.Inventory{StockQuantity: 10}}
+				items := &[]*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 2}}
+
+				mockProductStore.On("GetProductByID", 1).Return(product, nil)
+				mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+				mockReservationStore.On("ReserveStock", 1, 1, 0, 3, mock.Anything).Return(nil)
+				mockDiscountStore.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+				mockCartStore.On("AddItemToCart", 1, 0, "", types.UserCart(1), 100.0).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 3}, nil)
 			},
 			expectedError: nil,
 		},
+		{
+			name:      "Reservation is undone when the item cannot be added",
+			userID:    1,
+			productID: 1,
+			mockSetup: func() {
+				product := &types.Product{ID: 1, BasePrice: 100.0, Inventory: types.Inventory{StockQuantity: 10}}
+
+				mockProductStore.On("GetProductByID", 1).Return(product, nil)
+				mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(&[]*types.CartItem{}, nil)
+				mockReservationStore.On("ReserveStock", 1, 1, 0, 1, mock.Anything).Return(nil)
+				mockDiscountStore.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+				mockCartStore.On("AddItemToCart", 1, 0, "", types.UserCart(1), 100.0).Return(nil, errors.New("database error"))
+				mockReservationStore.On("ReleaseReservation", 1, 1, 0).Return(nil)
+			},
+			expectedError: errors.New("database error"),
+		},
 		{
 			name:      "Product not found",
 			userID:    1,
@@ -358,15 +619,34 @@ func TestServiceAddItemToCart(t *testing.T) {
 			userID:    1,
 			productID: 1,
 			mockSetup: func() {
+				// the last units are held by other carts
 				product := &types.Product{
 					ID:        1,
 					BasePrice: 100.0,
-					Inventory: types.Inventory{StockQuantity: 0},
+					Inventory: types.Inventory{StockQuantity: 2},
 				}
 				mockProductStore.On("GetProductByID", 1).Return(product, nil)
+				mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(&[]*types.CartItem{}, nil)
+				mockReservationStore.On("ReserveStock", 1, 1, 0, 1, mock.Anything).Return(types.ErrInsufficientStock)
 			},
 			expectedError: apperrors.NewValidationError("product", "product out of stock"),
 		},
+		{
+			name:      "Purchase limit reached",
+			userID:    1,
+			productID: 1,
+			mockSetup: func() {
+				limit := 2
+				product := &types.Product{ID: 1, BasePrice: 100.0, Inventory: types.Inventory{StockQuantity: 10, MaxPurchaseQuantity: &limit}}
+				items := &[]*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 2}}
+
+				mockProductStore.On("GetProductByID", 1).Return(product, nil)
+				mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+			},
+			expectedError: apperrors.NewValidationError("quantity", "at most 2 units of this product per order"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -374,8 +654,9 @@ func TestServiceAddItemToCart(t *testing.T) {
 			mockCartStore.ExpectedCalls = nil
 			mockProductStore.ExpectedCalls = nil
 			mockDiscountStore.ExpectedCalls = nil
+			mockReservationStore.ExpectedCalls = nil
 			tt.mockSetup()
-			item, err := service.AddItemToCart(tt.productID, tt.userID)
+			item, err := service.AddItemToCart(tt.productID, 0, types.UserCart(tt.userID))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 				assert.Nil(t, item)
@@ -386,6 +667,168 @@ func TestServiceAddItemToCart(t *testing.T) {
 			mockCartStore.AssertExpectations(t)
 			mockProductStore.AssertExpectations(t)
 			mockDiscountStore.AssertExpectations(t)
+			mockReservationStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceAddVariantToCart(t *testing.T) {
+	limit := 3
+	product := &types.Product{ID: 1, BasePrice: 100.0, Inventory: types.Inventory{MaxPurchaseQuantity: &limit}}
+	override := 120.0
+	variant := &types.ProductVariant{ID: 7, ProductID: 1, SKU: "TSHIRT-M-BLUE", PriceOverride: &override, Label: "M / Blue"}
+
+	t.Run("Variant is priced at its own price", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockVariantStore := new(MockVariantStore)
+		mockDiscountStore := new(MockProductDiscountStore)
+		mockReservationStore := new(MockReservationStore)
+
+		mockProductStore.On("GetProductByID", 1).Return(product, nil)
+		mockVariantStore.On("GetVariant", 1, 7).Return(variant, nil)
+		mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(&[]*types.CartItem{}, nil)
+		mockReservationStore.On("ReserveStock", 1, 1, 7, 1, mock.Anything).Return(nil)
+		mockDiscountStore.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{{DiscountPercent: 10}}, nil)
+		mockCartStore.On("AddItemToCart", 1, 7, "M / Blue", types.UserCart(1), 108.0).
+			Return(&types.CartItem{CartID: 1, ProductID: 1, VariantID: 7, Quantity: 1, PriceAtAdding: 108.0}, nil)
+
+		service := NewService(mockCartStore, mockProductStore, mockVariantStore, mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+		item, err := service.AddItemToCart(1, 7, types.UserCart(1))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, item.VariantID)
+		mockCartStore.AssertExpectations(t)
+		mockVariantStore.AssertExpectations(t)
+		mockReservationStore.AssertExpectations(t)
+	})
+
+	t.Run("A product with variants needs one chosen", func(t *testing.T) {
+		mockProductStore := new(MockProductStore)
+		mockVariantStore := new(MockVariantStore)
+
+		mockProductStore.On("GetProductByID", 1).Return(product, nil)
+		mockVariantStore.On("GetVariants", 1).Return([]*types.ProductVariant{variant}, nil)
+
+		service := NewService(new(MockCartStore), mockProductStore, mockVariantStore, new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+		item, err := service.AddItemToCart(1, 0, types.UserCart(1))
+
+		assert.EqualError(t, err, apperrors.NewValidationError("variantId", "choose a variant of the product").Error())
+		assert.Nil(t, item)
+	})
+
+	t.Run("Purchase limit counts every variant of the product", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockVariantStore := new(MockVariantStore)
+		mockReservationStore := new(MockReservationStore)
+
+		items := &[]*types.CartItem{{CartID: 1, ProductID: 1, VariantID: 8, Quantity: 3}}
+		mockProductStore.On("GetProductByID", 1).Return(product, nil)
+		mockVariantStore.On("GetVariant", 1, 7).Return(variant, nil)
+		mockCartStore.On("GetCartID", types.UserCart(1)).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+
+		service := NewService(mockCartStore, mockProductStore, mockVariantStore, new(MockProductDiscountStore), mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+		item, err := service.AddItemToCart(1, 7, types.UserCart(1))
+
+		assert.EqualError(t, err, apperrors.NewValidationError("quantity", "at most 3 units of this product per order").Error())
+		assert.Nil(t, item)
+		mockReservationStore.AssertNotCalled(t, "ReserveStock", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestServiceUpdateItemQuantity(t *testing.T) {
+	limit := 5
+	product := &types.Product{ID: 1, BasePrice: 100.0, Inventory: types.Inventory{StockQuantity: 10, MaxPurchaseQuantity: &limit}}
+	items := &[]*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 2}}
+
+	tests := []struct {
+		name          string
+		quantity      int
+		mockSetup     func(*MockCartStore, *MockProductStore, *MockProductDiscountStore, *MockReservationStore)
+		expectedError error
+	}{
+		{
+			name:     "Success setting a larger quantity",
+			quantity: 4,
+			mockSetup: func(mcs *MockCartStore, mps *MockProductStore, mds *MockProductDiscountStore, mrs *MockReservationStore) {
+				mps.On("GetProductByID", 1).Return(product, nil)
+				mcs.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+				mrs.On("ReserveStock", 1, 1, 0, 4, mock.Anything).Return(nil)
+				mds.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+				mcs.On("SetItemQuantity", 1, 0, "", types.UserCart(1), 4, 100.0).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 4}, nil)
+			},
+		},
+		{
+			name:     "Zero quantity",
+			quantity: 0,
+			mockSetup: func(mcs *MockCartStore, mps *MockProductStore, mds *MockProductDiscountStore, mrs *MockReservationStore) {
+			},
+			expectedError: apperrors.NewValidationError("quantity", "quantity must be greater than zero"),
+		},
+		{
+			name:     "Above the purchase limit",
+			quantity: 6,
+			mockSetup: func(mcs *MockCartStore, mps *MockProductStore, mds *MockProductDiscountStore, mrs *MockReservationStore) {
+				mps.On("GetProductByID", 1).Return(product, nil)
+				mcs.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+			},
+			expectedError: apperrors.NewValidationError("quantity", "at most 5 units of this product per order"),
+		},
+		{
+			name:     "Not enough stock tells how many units are left",
+			quantity: 5,
+			mockSetup: func(mcs *MockCartStore, mps *MockProductStore, mds *MockProductDiscountStore, mrs *MockReservationStore) {
+				mps.On("GetProductByID", 1).Return(product, nil)
+				mcs.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+				mrs.On("ReserveStock", 1, 1, 0, 5, mock.Anything).Return(types.ErrInsufficientStock)
+				mrs.On("GetAvailableStock", 1, 0, 1).Return(3, nil)
+			},
+			expectedError: apperrors.NewValidationError("quantity", "only 3 units in stock"),
+		},
+		{
+			name:     "Previous reservation is restored when the cart cannot change",
+			quantity: 4,
+			mockSetup: func(mcs *MockCartStore, mps *MockProductStore, mds *MockProductDiscountStore, mrs *MockReservationStore) {
+				mps.On("GetProductByID", 1).Return(product, nil)
+				mcs.On("GetCartID", types.UserCart(1)).Return(1, nil)
+				mcs.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+				mrs.On("ReserveStock", 1, 1, 0, 4, mock.Anything).Return(nil)
+				mds.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+				mcs.On("SetItemQuantity", 1, 0, "", types.UserCart(1), 4, 100.0).Return(nil, errors.New("database error"))
+				mrs.On("ReserveStock", 1, 1, 0, 2, mock.Anything).Return(nil)
+			},
+			expectedError: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCartStore := new(MockCartStore)
+			mockProductStore := new(MockProductStore)
+			mockDiscountStore := new(MockProductDiscountStore)
+			mockReservationStore := new(MockReservationStore)
+			tt.mockSetup(mockCartStore, mockProductStore, mockDiscountStore, mockReservationStore)
+
+			service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+			item, err := service.UpdateItemQuantity(1, 0, types.UserCart(1), tt.quantity)
+
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error())
+				assert.Nil(t, item)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.quantity, item.Quantity)
+			}
+			mockCartStore.AssertExpectations(t)
+			mockProductStore.AssertExpectations(t)
+			mockDiscountStore.AssertExpectations(t)
+			mockReservationStore.AssertExpectations(t)
 		})
 	}
 }
@@ -395,7 +838,9 @@ func TestServiceRemoveItemFromCart(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockDiscountStore := new(MockProductDiscountStore)
 
-	service := NewService(mockCartStore, mockProductStore, mockDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
 
 	tests := []struct {
 		name          string
@@ -405,11 +850,24 @@ func TestServiceRemoveItemFromCart(t *testing.T) {
 		expectedError error
 	}{
 		{
-			name:      "Success removing item",
+			name:      "Success removing last unit releases the reservation",
 			userID:    1,
 			productID: 1,
 			mockSetup: func() {
-				mockCartStore.On("RemoveItemFromCart", 1, 1).Return(nil)
+				mockCartStore.On("GetCartItem", types.UserCart(1), 1, 0).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 1}, nil)
+				mockCartStore.On("RemoveItemFromCart", 1, 0, types.UserCart(1)).Return(nil)
+				mockReservationStore.On("ReleaseReservation", 1, 1, 0).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:      "Success removing one unit shrinks the reservation",
+			userID:    1,
+			productID: 1,
+			mockSetup: func() {
+				mockCartStore.On("GetCartItem", types.UserCart(1), 1, 0).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 3}, nil)
+				mockCartStore.On("RemoveOneItemFromCart", types.UserCart(1), 1, 0).Return(nil)
+				mockReservationStore.On("ReserveStock", 1, 1, 0, 2, mock.Anything).Return(nil)
 			},
 			expectedError: nil,
 		},
@@ -418,7 +876,8 @@ func TestServiceRemoveItemFromCart(t *testing.T) {
 			userID:    1,
 			productID: 1,
 			mockSetup: func() {
-				mockCartStore.On("RemoveItemFromCart", 1, 1).Return(errors.New("database error"))
+				mockCartStore.On("GetCartItem", types.UserCart(1), 1, 0).Return(&types.CartItem{CartID: 1, ProductID: 1, Quantity: 1}, nil)
+				mockCartStore.On("RemoveItemFromCart", 1, 0, types.UserCart(1)).Return(errors.New("database error"))
 			},
 			expectedError: errors.New("database error"),
 		},
@@ -427,14 +886,16 @@ func TestServiceRemoveItemFromCart(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockCartStore.ExpectedCalls = nil
+			mockReservationStore.ExpectedCalls = nil
 			tt.mockSetup()
-			err := service.RemoveItemFromCart(tt.productID, tt.userID)
+			err := service.RemoveItemFromCart(tt.productID, 0, types.UserCart(tt.userID))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 			} else {
 				assert.NoError(t, err)
 			}
 			mockCartStore.AssertExpectations(t)
+			mockReservationStore.AssertExpectations(t)
 		})
 	}
 }
@@ -444,7 +905,10 @@ func TestServiceGetTotal(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockDiscountStore := new(MockProductDiscountStore)
 
-	service := NewService(mockCartStore, mockProductStore, mockDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+	mockCouponService := new(MockCouponService)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, mockCouponService, 15*time.Minute, new(MockUnitOfWork))
 
 	tests := []struct {
 		name          string
@@ -457,16 +921,37 @@ func TestServiceGetTotal(t *testing.T) {
 			name:   "Success getting total",
 			userID: 1,
 			mockSetup: func() {
-				mockCartStore.On("GetTotal", 1).Return(100.0, nil)
+				mockCartStore.On("GetTotal", types.UserCart(1)).Return(100.0, nil)
+				mockCartStore.On("GetCouponID", types.UserCart(1)).Return(0, nil)
 			},
 			expectedTotal: 100.0,
 			expectedError: nil,
 		},
+		{
+			name:   "Success getting total with coupon",
+			userID: 1,
+			mockSetup: func() {
+				coupon := &types.Coupon{ID: 7, Code: "SAVE10"}
+				mockCartStore.On("GetTotal", types.UserCart(1)).Return(100.0, nil)
+				mockCartStore.On("GetCouponID", types.UserCart(1)).Return(7, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).
+					Return(&[]*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 2, PriceAtAdding: 50.0}}, nil)
+				mockProductStore.On("GetProductByID", 1).
+					Return(&types.Product{ID: 1, BasePrice: 60.0, Categories: []types.Category{{ID: 3}}}, nil)
+				mockCouponService.On("GetCouponByID", 7).Return(coupon, nil)
+				// lines are priced at the price the items were added at
+				mockCouponService.On("Evaluate", coupon, 1, []types.CouponLine{
+					{ProductID: 1, CategoryIDs: []int{3}, Quantity: 2, UnitPrice: 50.0},
+				}).Return(&types.CouponEvaluation{Discount: 10.0}, nil)
+			},
+			expectedTotal: 90.0,
+			expectedError: nil,
+		},
 		{
 			name:   "Error getting total",
 			userID: 1,
 			mockSetup: func() {
-				mockCartStore.On("GetTotal", 1).Return(0.0, errors.New("database error"))
+				mockCartStore.On("GetTotal", types.UserCart(1)).Return(0.0, errors.New("database error"))
 			},
 			expectedTotal: 0.0,
 			expectedError: errors.New("database error"),
@@ -477,7 +962,7 @@ func TestServiceGetTotal(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockCartStore.ExpectedCalls = nil
 			tt.mockSetup()
-			total, err := service.GetTotal(tt.userID)
+			total, err := service.GetTotal(types.UserCart(tt.userID))
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error())
 				assert.Equal(t, tt.expectedTotal, total)
@@ -489,3 +974,312 @@ func TestServiceGetTotal(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceValidateCart(t *testing.T) {
+	mockCartStore := new(MockCartStore)
+	mockProductStore := new(MockProductStore)
+	mockDiscountStore := new(MockProductDiscountStore)
+	mockReservationStore := new(MockReservationStore)
+
+	service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+
+	items := &[]*types.CartItem{
+		// added while a 20% discount was running
+		{CartID: 1, ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 80.0},
+		{CartID: 1, ProductID: 2, ProductTitle: "Plate", Quantity: 3, PriceAtAdding: 33.33},
+	}
+	mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(items, nil)
+	mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 100.0}, nil)
+	mockProductStore.On("GetProductByID", 2).Return(&types.Product{ID: 2, BasePrice: 44.44}, nil)
+	mockDiscountStore.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+	mockDiscountStore.On("GetActiveDiscounts", 2).Return([]*types.ProductDiscount{{ID: 4, DiscountPercent: 25}}, nil)
+	mockReservationStore.On("GetAvailableStock", 1, 0, 1).Return(5, nil)
+	mockReservationStore.On("GetAvailableStock", 2, 0, 1).Return(2, nil)
+	mockCartStore.On("GetCouponID", types.UserCart(1)).Return(0, nil)
+
+	validation, err := service.ValidateCart(types.UserCart(1))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CartValidation{
+		Items: []*types.CartItemValidation{
+			{
+				ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 80.0, CurrentPrice: 100.0, LineTotal: 200.0,
+				AvailableQuantity: 5, PriceChanged: true,
+				Pricing: &types.PriceBreakdown{BasePrice: 100.0, Quantity: 2, UnitPrice: 100.0, Total: 200.0, Applied: []*types.AppliedDiscount{}},
+			},
+			{
+				ProductID: 2, ProductTitle: "Plate", Quantity: 3, PriceAtAdding: 33.33, CurrentPrice: 33.33, LineTotal: 99.99,
+				AvailableQuantity: 2, OutOfStock: true,
+				Pricing: &types.PriceBreakdown{
+					BasePrice: 44.44, Quantity: 3, UnitPrice: 33.33, Total: 99.99, Savings: 33.33,
+					Applied: []*types.AppliedDiscount{{DiscountID: 4, Type: types.DiscountPercentage, Description: "25% off", Savings: 33.33}},
+				},
+			},
+		},
+		Subtotal:     299.99,
+		Total:        299.99,
+		PriceChanged: true,
+		OutOfStock:   true,
+	}, validation)
+	mockCartStore.AssertExpectations(t)
+	mockProductStore.AssertExpectations(t)
+	mockDiscountStore.AssertExpectations(t)
+	mockReservationStore.AssertExpectations(t)
+}
+
+func TestServiceCreateGuestCart(t *testing.T) {
+	mockCartStore := new(MockCartStore)
+	service := NewService(mockCartStore, new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, new(MockUnitOfWork))
+
+	var stored string
+	mockCartStore.On("CreateGuestCart", mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { stored = args.String(0) }).
+		Return(5, nil)
+
+	token, err := service.CreateGuestCart()
+
+	assert.NoError(t, err)
+	assert.Len(t, token, 43)
+	assert.Equal(t, stored, token)
+
+	other, err := service.CreateGuestCart()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestServiceMergeGuestCart(t *testing.T) {
+	guest := types.GuestCart("guest-token")
+	user := types.UserCart(1)
+
+	t.Run("Folds every item into the user's cart", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockReservationStore := new(MockReservationStore)
+		uow := &MockUnitOfWork{stores: &types.TxStores{Carts: mockCartStore, Products: mockProductStore, Reservations: mockReservationStore}}
+		service := NewService(new(MockCartStore), new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, uow)
+
+		limit := 4
+		guestItems := &[]*types.CartItem{
+			{CartID: 2, ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 10.0},
+			{CartID: 2, ProductID: 2, ProductTitle: "Plate", Quantity: 3, PriceAtAdding: 20.0},
+			{CartID: 2, ProductID: 3, ProductTitle: "Bowl", Quantity: 5, PriceAtAdding: 30.0},
+		}
+		userItems := &[]*types.CartItem{{CartID: 1, ProductID: 2, Quantity: 2, PriceAtAdding: 18.0}}
+
+		mockCartStore.On("GetCartID", guest).Return(2, nil)
+		mockCartStore.On("GetCartID", user).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", guest).Return(guestItems, nil)
+		mockCartStore.On("GetMyCartItems", user).Return(userItems, nil)
+		mockReservationStore.On("ReleaseCartReservations", 2).Return(nil)
+
+		// product 1 is new to the user's cart
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1}, nil)
+		mockReservationStore.On("ReserveStock", 1, 1, 0, 2, mock.Anything).Return(nil)
+		mockCartStore.On("SetItemQuantity", 1, 0, "", user, 2, 10.0).Return(&types.CartItem{}, nil)
+
+		// product 2 is capped at 4 units per order
+		mockProductStore.On("GetProductByID", 2).Return(&types.Product{ID: 2, Inventory: types.Inventory{MaxPurchaseQuantity: &limit}}, nil)
+		mockReservationStore.On("ReserveStock", 1, 2, 0, 4, mock.Anything).Return(nil)
+		mockCartStore.On("SetItemQuantity", 2, 0, "", user, 4, 20.0).Return(&types.CartItem{}, nil)
+
+		// only 3 units of product 3 are left
+		mockProductStore.On("GetProductByID", 3).Return(&types.Product{ID: 3}, nil)
+		mockReservationStore.On("ReserveStock", 1, 3, 0, 5, mock.Anything).Return(types.ErrInsufficientStock)
+		mockReservationStore.On("GetAvailableStock", 3, 0, 1).Return(3, nil)
+		mockReservationStore.On("ReserveStock", 1, 3, 0, 3, mock.Anything).Return(nil)
+		mockCartStore.On("SetItemQuantity", 3, 0, "", user, 3, 30.0).Return(&types.CartItem{}, nil)
+
+		// the guest's coupon moves to the user's cart, which has none
+		mockCartStore.On("GetCouponID", guest).Return(7, nil)
+		mockCartStore.On("GetCouponID", user).Return(0, nil)
+		mockCartStore.On("SetCoupon", user, 7).Return(nil)
+		mockCartStore.On("DeleteCart", 2).Return(nil)
+
+		merge, err := service.MergeGuestCart("guest-token", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &types.CartMerge{
+			MergedItems: 3,
+			Adjustments: []*types.CartMergeAdjustment{
+				{ProductID: 2, ProductTitle: "Plate", Requested: 5, Quantity: 4, Reason: "purchase limit"},
+				{ProductID: 3, ProductTitle: "Bowl", Requested: 5, Quantity: 3, Reason: "out of stock"},
+			},
+		}, merge)
+		mockCartStore.AssertExpectations(t)
+		mockProductStore.AssertExpectations(t)
+		mockReservationStore.AssertExpectations(t)
+	})
+
+	t.Run("User's own units are kept when nothing more fits", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockReservationStore := new(MockReservationStore)
+		uow := &MockUnitOfWork{stores: &types.TxStores{Carts: mockCartStore, Products: mockProductStore, Reservations: mockReservationStore}}
+		service := NewService(new(MockCartStore), new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, uow)
+
+		mockCartStore.On("GetCartID", guest).Return(2, nil)
+		mockCartStore.On("GetCartID", user).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", guest).Return(&[]*types.CartItem{{CartID: 2, ProductID: 1, Quantity: 1}}, nil)
+		mockCartStore.On("GetMyCartItems", user).Return(&[]*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 2}}, nil)
+		mockReservationStore.On("ReleaseCartReservations", 2).Return(nil)
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1}, nil)
+		mockReservationStore.On("ReserveStock", 1, 1, 0, 3, mock.Anything).Return(types.ErrInsufficientStock)
+		mockReservationStore.On("GetAvailableStock", 1, 0, 1).Return(1, nil)
+		mockCartStore.On("GetCouponID", guest).Return(0, nil)
+		mockCartStore.On("DeleteCart", 2).Return(nil)
+
+		merge, err := service.MergeGuestCart("guest-token", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, merge.MergedItems)
+		assert.Equal(t, []*types.CartMergeAdjustment{{ProductID: 1, Requested: 3, Quantity: 2, Reason: "out of stock"}}, merge.Adjustments)
+		mockCartStore.AssertNotCalled(t, "SetItemQuantity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockCartStore.AssertExpectations(t)
+		mockReservationStore.AssertExpectations(t)
+	})
+
+	t.Run("Drops the items of a product that no longer exists", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockReservationStore := new(MockReservationStore)
+		uow := &MockUnitOfWork{stores: &types.TxStores{Carts: mockCartStore, Products: mockProductStore, Reservations: mockReservationStore}}
+		service := NewService(new(MockCartStore), new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, uow)
+
+		mockCartStore.On("GetCartID", guest).Return(2, nil)
+		mockCartStore.On("GetCartID", user).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", guest).Return(&[]*types.CartItem{
+			{CartID: 2, ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 10.0},
+			{CartID: 2, ProductID: 2, Quantity: 1, PriceAtAdding: 20.0},
+		}, nil)
+		mockCartStore.On("GetMyCartItems", user).Return(&[]*types.CartItem{}, nil)
+		mockReservationStore.On("ReleaseCartReservations", 2).Return(nil)
+		mockProductStore.On("GetProductByID", 1).Return(nil, sql.ErrNoRows)
+		mockProductStore.On("GetProductByID", 2).Return(&types.Product{ID: 2}, nil)
+		mockReservationStore.On("ReserveStock", 1, 2, 0, 1, mock.Anything).Return(nil)
+		mockCartStore.On("SetItemQuantity", 2, 0, "", user, 1, 20.0).Return(&types.CartItem{}, nil)
+		mockCartStore.On("GetCouponID", guest).Return(0, nil)
+		mockCartStore.On("DeleteCart", 2).Return(nil)
+
+		merge, err := service.MergeGuestCart("guest-token", 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &types.CartMerge{
+			MergedItems: 1,
+			Adjustments: []*types.CartMergeAdjustment{{ProductID: 1, ProductTitle: "Mug", Requested: 2, Quantity: 0, Reason: "unavailable"}},
+		}, merge)
+		mockReservationStore.AssertNotCalled(t, "ReserveStock", 1, 1, 0, mock.Anything, mock.Anything)
+		mockCartStore.AssertExpectations(t)
+		mockReservationStore.AssertExpectations(t)
+	})
+
+	t.Run("A failed item fails the whole merge", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockReservationStore := new(MockReservationStore)
+		uow := &MockUnitOfWork{stores: &types.TxStores{Carts: mockCartStore, Products: mockProductStore, Reservations: mockReservationStore}}
+		service := NewService(new(MockCartStore), new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, uow)
+
+		mockCartStore.On("GetCartID", guest).Return(2, nil)
+		mockCartStore.On("GetCartID", user).Return(1, nil)
+		mockCartStore.On("GetMyCartItems", guest).Return(&[]*types.CartItem{
+			{CartID: 2, ProductID: 1, Quantity: 1, PriceAtAdding: 10.0},
+			{CartID: 2, ProductID: 2, Quantity: 1, PriceAtAdding: 20.0},
+		}, nil)
+		mockCartStore.On("GetMyCartItems", user).Return(&[]*types.CartItem{}, nil)
+		mockReservationStore.On("ReleaseCartReservations", 2).Return(nil)
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1}, nil)
+		mockReservationStore.On("ReserveStock", 1, 1, 0, 1, mock.Anything).Return(nil)
+		mockCartStore.On("SetItemQuantity", 1, 0, "", user, 1, 10.0).Return(&types.CartItem{}, nil)
+		mockProductStore.On("GetProductByID", 2).Return(nil, errors.New("connection reset"))
+
+		merge, err := service.MergeGuestCart("guest-token", 1)
+
+		// the unit of work rolls back the first item along with the rest
+		assert.Error(t, err)
+		assert.Nil(t, merge)
+		mockCartStore.AssertNotCalled(t, "DeleteCart", mock.Anything)
+		mockCartStore.AssertNotCalled(t, "SetCoupon", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Unknown guest cart", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		uow := &MockUnitOfWork{stores: &types.TxStores{Carts: mockCartStore}}
+		service := NewService(new(MockCartStore), new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), new(MockCouponService), 15*time.Minute, uow)
+
+		mockCartStore.On("GetCartID", guest).Return(0, apperrors.NewEntityNotFound("cart", "guest"))
+
+		merge, err := service.MergeGuestCart("guest-token", 1)
+
+		assert.Error(t, err)
+		assert.Nil(t, merge)
+		mockCartStore.AssertExpectations(t)
+	})
+}
+
+func TestServiceApplyCoupon(t *testing.T) {
+	owner := types.GuestCart("guest-token")
+	coupon := &types.Coupon{ID: 7, Code: "SAVE10"}
+	items := &[]*types.CartItem{{CartID: 2, ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 50.0}}
+	lines := []types.CouponLine{{ProductID: 1, CategoryIDs: []int{}, Quantity: 2, UnitPrice: 50.0}}
+
+	setup := func() (*Service, *MockCartStore, *MockCouponService) {
+		mockCartStore := new(MockCartStore)
+		mockProductStore := new(MockProductStore)
+		mockDiscountStore := new(MockProductDiscountStore)
+		mockReservationStore := new(MockReservationStore)
+		mockCouponService := new(MockCouponService)
+
+		mockCartStore.On("GetMyCartItems", owner).Return(items, nil)
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 50.0}, nil)
+		mockDiscountStore.On("GetActiveDiscounts", 1).Return([]*types.ProductDiscount{}, nil)
+		mockReservationStore.On("GetAvailableStock", 1, 0, 2).Return(5, nil)
+
+		service := NewService(mockCartStore, mockProductStore, noVariants(), mockDiscountStore, mockReservationStore, mockCouponService, 15*time.Minute, new(MockUnitOfWork))
+		return service, mockCartStore, mockCouponService
+	}
+
+	t.Run("Applies a coupon that gives a discount", func(t *testing.T) {
+		service, mockCartStore, mockCouponService := setup()
+		mockCouponService.On("GetCouponByCode", "save10").Return(coupon, nil)
+		// guests are evaluated without a user
+		mockCouponService.On("Evaluate", coupon, 0, lines).Return(&types.CouponEvaluation{Discount: 10.0, LineDiscounts: []float64{10.0}}, nil)
+		mockCartStore.On("SetCoupon", owner, 7).Return(nil)
+
+		validation, err := service.ApplyCoupon(owner, "save10")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 100.0, validation.Subtotal)
+		assert.Equal(t, 10.0, validation.Discount)
+		assert.Equal(t, 90.0, validation.Total)
+		assert.Equal(t, 10.0, validation.Items[0].CouponDiscount)
+		assert.Equal(t, &types.CartCoupon{ID: 7, Code: "SAVE10", Discount: 10.0, Valid: true, LineDiscounts: []float64{10.0}}, validation.Coupon)
+		mockCartStore.AssertExpectations(t)
+		mockCouponService.AssertExpectations(t)
+	})
+
+	t.Run("Rejects a coupon that does not apply", func(t *testing.T) {
+		service, mockCartStore, mockCouponService := setup()
+		mockCouponService.On("GetCouponByCode", "SAVE10").Return(coupon, nil)
+		mockCouponService.On("Evaluate", coupon, 0, lines).
+			Return(&types.CouponEvaluation{Reason: "cart must be worth at least 150.00"}, nil)
+
+		validation, err := service.ApplyCoupon(owner, "SAVE10")
+
+		assert.Equal(t, apperrors.NewValidationError("coupon", "cart must be worth at least 150.00"), err)
+		assert.Nil(t, validation)
+		mockCartStore.AssertNotCalled(t, "SetCoupon", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Rejects an unknown code", func(t *testing.T) {
+		mockCartStore := new(MockCartStore)
+		mockCouponService := new(MockCouponService)
+		service := NewService(mockCartStore, new(MockProductStore), noVariants(), new(MockProductDiscountStore), new(MockReservationStore), mockCouponService, 15*time.Minute, new(MockUnitOfWork))
+		mockCouponService.On("GetCouponByCode", "NOPE").Return(nil, apperrors.NewEntityNotFound("coupon", "NOPE"))
+
+		validation, err := service.ApplyCoupon(owner, "NOPE")
+
+		assert.Equal(t, apperrors.NewValidationError("coupon", "coupon code is not valid"), err)
+		assert.Nil(t, validation)
+		mockCartStore.AssertNotCalled(t, "SetCoupon", mock.Anything, mock.Anything)
+	})
+}
@@ -5,17 +5,37 @@ import (
 	"errors"
 	"fmt"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"time"
 )
 
+// cartItemColumns are the columns scanRow and scanRows read.
+const cartItemColumns = `
+			cartId, 
+			productId, 
+			variantId,
+			quantity, 
+			priceAtAdding, 
+			addedAt, 
+			productImage,
+			productTitle,
+			variantLabel
+`
+
 type Store struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{db}
 }
 
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{tx}
+}
+
 func (s *Store) CreateCart(userID int) error {
 	query := `
 		INSERT INTO carts (userId, createdAt, updatedAt)
@@ -28,24 +48,16 @@ func (s *Store) CreateCart(userID int) error {
 	return nil
 }
 
-func (s *Store) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		return &[]*types.CartItem{}, fmt.Errorf("error getting cart ID: %w", err)
 	}
 
 	query := `
-		SELECT 
-			c.id as cartId,
-			ci.productId,
-			ci.quantity,
-			ci.priceAtAdding,
-			ci.addedAt,
-			ci.productImage,
-			ci.productTitle
-		FROM carts c
-		JOIN cart_items ci ON c.id = ci.cartId
-		WHERE c.id = ?
+		SELECT ` + cartItemColumns + `
+		FROM cart_items
+		WHERE cartId = ?
 	`
 	rows, err := s.db.Query(query, cartID)
 	if err != nil {
@@ -61,93 +73,118 @@ func (s *Store) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
 	return items, nil
 }
 
-func (s *Store) AddItemToCart(productID int, userID int, price float64) (*types.CartItem, error) {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) AddItemToCart(productID int, variantID int, variantLabel string, owner types.CartOwner, price float64) (*types.CartItem, error) {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		return nil, fmt.Errorf("error getting cart ID: %w", err)
 	}
 
-	var productTitle string
-	var productImage string
-	err = s.db.QueryRow(`
-		SELECT 
-			p.title,
-			COALESCE(pi.imageUrl, '') 
-		FROM products p
-		LEFT JOIN product_images pi 
-			ON p.id = pi.productId 
-			AND pi.sortOrder = (SELECT MIN(sortOrder) FROM product_images WHERE productId = p.id)
-		WHERE p.id = ?
-	`, productID).Scan(&productTitle, &productImage)
+	productTitle, productImage, err := s.productSnapshot(productID, variantID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, apperrors.NewEntityNotFound("product", productID)
-		}
-		return nil, fmt.Errorf("error fetching product details: %w", err)
+		return nil, err
 	}
 
 	result, err := s.db.Exec(`
 		UPDATE cart_items 
 		SET quantity = quantity + 1 
-		WHERE cartId = ? AND productId = ?
-	`, cartID, productID)
+		WHERE cartId = ? AND productId = ? AND variantId = ?
+	`, cartID, productID, variantID)
 	if err != nil {
 		return nil, fmt.Errorf("error updating cart item: %w", err)
 	}
 
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-		row := s.db.QueryRow(`
-			SELECT 
-				cartId, 
-				productId, 
-				quantity, 
-				priceAtAdding, 
-				addedAt, 
-				productImage,
-				productTitle 
-			FROM cart_items 
-			WHERE cartId = ? AND productId = ?
-		`, cartID, productID)
-		return scanRow(row)
+		return s.getItem(cartID, productID, variantID)
 	}
 
 	_, err = s.db.Exec(`
 		INSERT INTO cart_items 
-			(cartId, productId, quantity, priceAtAdding, addedAt, productImage, productTitle)
-		VALUES (?, ?, 1, ?, NOW(), ?, ?)
-	`, cartID, productID, price, productImage, productTitle)
+			(cartId, productId, variantId, quantity, priceAtAdding, addedAt, productImage, productTitle, variantLabel)
+		VALUES (?, ?, ?, 1, ?, NOW(), ?, ?, ?)
+	`, cartID, productID, variantID, price, productImage, productTitle, variantLabel)
 	if err != nil {
 		return nil, fmt.Errorf("error inserting cart item: %w", err)
 	}
 
-	newItemRow := s.db.QueryRow(`
+	return s.getItem(cartID, productID, variantID)
+}
+
+func (s *Store) SetItemQuantity(productID int, variantID int, variantLabel string, owner types.CartOwner, quantity int, price float64) (*types.CartItem, error) {
+	cartID, err := s.GetCartID(owner)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cart ID: %w", err)
+	}
+
+	productTitle, productImage, err := s.productSnapshot(productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// an item already in the cart keeps the price it was added at
+	_, err = s.db.Exec(`
+		INSERT INTO cart_items 
+			(cartId, productId, variantId, quantity, priceAtAdding, addedAt, productImage, productTitle, variantLabel)
+		VALUES (?, ?, ?, ?, ?, NOW(), ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity)
+	`, cartID, productID, variantID, quantity, price, productImage, productTitle, variantLabel)
+	if err != nil {
+		return nil, fmt.Errorf("error setting cart item quantity: %w", err)
+	}
+
+	return s.getItem(cartID, productID, variantID)
+}
+
+// productSnapshot is the title and the image of the product that a cart item
+// keeps. A variant shows its own first image when it has any.
+func (s *Store) productSnapshot(productID int, variantID int) (string, string, error) {
+	var productTitle string
+	var productImage string
+	err := s.db.QueryRow(`
 		SELECT 
-			cartId, 
-			productId, 
-			quantity, 
-			priceAtAdding, 
-			addedAt, 
-			productImage,
-			productTitle 
+			p.title,
+			COALESCE(
+				(SELECT imageUrl FROM product_variant_images WHERE variantId = ? ORDER BY sortOrder LIMIT 1),
+				pi.imageUrl,
+				''
+			)
+		FROM products p
+		LEFT JOIN product_images pi 
+			ON p.id = pi.productId 
+			AND pi.sortOrder = (SELECT MIN(sortOrder) FROM product_images WHERE productId = p.id)
+		WHERE p.id = ?
+	`, variantID, productID).Scan(&productTitle, &productImage)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", apperrors.NewEntityNotFound("product", productID)
+		}
+		return "", "", fmt.Errorf("error fetching product details: %w", err)
+	}
+
+	return productTitle, productImage, nil
+}
+
+func (s *Store) getItem(cartID int, productID int, variantID int) (*types.CartItem, error) {
+	row := s.db.QueryRow(`
+		SELECT `+cartItemColumns+`
 		FROM cart_items 
-		WHERE cartId = ? AND productId = ?
-	`, cartID, productID)
+		WHERE cartId = ? AND productId = ? AND variantId = ?
+	`, cartID, productID, variantID)
 
-	return scanRow(newItemRow)
+	return scanRow(row)
 }
 
-func (s *Store) RemoveItemFromCart(productID int, userID int) error {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) RemoveItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		return fmt.Errorf("error getting cart ID: %w", err)
 	}
 
-	fmt.Printf("[CART STORE] removing product %d from user %d and cart %d\n", productID, userID, cartID)
+	fmt.Printf("[CART STORE] removing product %d (variant %d) from %s and cart %d\n", productID, variantID, owner, cartID)
 
 	result, err := s.db.Exec(`
 		DELETE FROM cart_items 
-		WHERE cartId = ? AND productId = ?
-	`, cartID, productID)
+		WHERE cartId = ? AND productId = ? AND variantId = ?
+	`, cartID, productID, variantID)
 	if err != nil {
 		return fmt.Errorf("error removing item from cart: %w", err)
 	}
@@ -164,8 +201,8 @@ func (s *Store) RemoveItemFromCart(productID int, userID int) error {
 	return nil
 }
 
-func (s *Store) GetTotal(userID int) (float64, error) {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) GetTotal(owner types.CartOwner) (float64, error) {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		return 0, fmt.Errorf("error getting cart ID: %w", err)
 	}
@@ -182,7 +219,12 @@ func (s *Store) GetTotal(userID int) (float64, error) {
 	return total, nil
 }
 
-func (s *Store) GetCartID(userID int) (int, error) {
+func (s *Store) GetCartID(owner types.CartOwner) (int, error) {
+	if owner.IsGuest() {
+		return s.getGuestCartID(owner.GuestToken)
+	}
+	userID := owner.UserID
+
 	var cartID int
 	err := s.db.QueryRow(`
 		SELECT id 
@@ -213,30 +255,68 @@ func (s *Store) GetCartID(userID int) (int, error) {
 	return cartID, nil
 }
 
-func (s *Store) GetCartItem(userID int, productID int) (*types.CartItem, error) {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) getGuestCartID(token string) (int, error) {
+	var cartID int
+	err := s.db.QueryRow(`SELECT id FROM carts WHERE guestToken = ?`, token).Scan(&cartID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the token is a credential, so it is left out of the error
+		return 0, apperrors.NewEntityNotFound("cart", "guest")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error getting cart ID: %w", err)
+		return 0, fmt.Errorf("error getting guest cart ID: %w", err)
 	}
 
-	row := s.db.QueryRow(`
-		SELECT 
-			cartId, 
-			productId, 
-			quantity, 
-			priceAtAdding, 
-			addedAt, 
-			productImage,
-			productTitle 
-		FROM cart_items 
-		WHERE cartId = ? AND productId = ?
-	`, cartID, productID)
+	return cartID, nil
+}
 
-	return scanRow(row)
+func (s *Store) CreateGuestCart(token string) (int, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO carts (guestToken, createdAt, updatedAt)
+		VALUES (?, NOW(), NOW())
+	`, token)
+	if err != nil {
+		return 0, fmt.Errorf("error creating guest cart: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error getting cart ID: %w", err)
+	}
+	return int(id), nil
+}
+
+func (s *Store) DeleteCart(cartID int) error {
+	_, err := s.db.Exec(`DELETE FROM carts WHERE id = ?`, cartID)
+	if err != nil {
+		return fmt.Errorf("error deleting cart: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteIdleGuestCarts(idleSince time.Time) (int64, error) {
+	res, err := s.db.Exec(`
+		DELETE FROM carts
+		WHERE guestToken IS NOT NULL AND updatedAt < ?
+			AND NOT EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cartId = carts.id AND cart_items.addedAt >= ?)
+	`, idleSince, idleSince)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting idle guest carts: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *Store) GetCartItem(owner types.CartOwner, productID int, variantID int) (*types.CartItem, error) {
+	cartID, err := s.GetCartID(owner)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cart ID: %w", err)
+	}
+
+	return s.getItem(cartID, productID, variantID)
 }
 
-func (s *Store) RemoveOneItemFromCart(userID int, productID int) error {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) RemoveOneItemFromCart(owner types.CartOwner, productID int, variantID int) error {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		fmt.Printf("[CART STORE]: ERROR getting cart ID at removeOneItemFromCart: %v", err)
 		return err
@@ -244,19 +324,19 @@ func (s *Store) RemoveOneItemFromCart(userID int, productID int) error {
 
 	query := `UPDATE cart_items 
 		SET quantity = quantity - 1 
-		WHERE cartId = ? AND productId = ? AND quantity > 1;
+		WHERE cartId = ? AND productId = ? AND variantId = ? AND quantity > 1;
 	`
-	_, err = s.db.Exec(query, cartID, productID)
+	_, err = s.db.Exec(query, cartID, productID, variantID)
 	if err != nil {
-		fmt.Printf("[CART STORE]: ERROR removing one item from cart: %w", err)
+		fmt.Printf("[CART STORE]: ERROR removing one item from cart: %v\n", err)
 		return err
 	}
 
 	return nil
 }
 
-func (s *Store) RemoveItemsFromCart(userID int) error {
-	cartID, err := s.GetCartID(userID)
+func (s *Store) RemoveItemsFromCart(owner types.CartOwner) error {
+	cartID, err := s.GetCartID(owner)
 	if err != nil {
 		return fmt.Errorf("error getting cart ID: %w", err)
 	}
@@ -269,16 +349,52 @@ func (s *Store) RemoveItemsFromCart(userID int) error {
 	return nil
 }
 
+func (s *Store) SetCoupon(owner types.CartOwner, couponID int) error {
+	cartID, err := s.GetCartID(owner)
+	if err != nil {
+		return fmt.Errorf("error getting cart ID: %w", err)
+	}
+
+	var coupon sql.NullInt64
+	if couponID != 0 {
+		coupon = sql.NullInt64{Int64: int64(couponID), Valid: true}
+	}
+
+	_, err = s.db.Exec(`UPDATE carts SET couponId = ?, updatedAt = NOW() WHERE id = ?`, coupon, cartID)
+	if err != nil {
+		return fmt.Errorf("error setting cart coupon: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetCouponID(owner types.CartOwner) (int, error) {
+	cartID, err := s.GetCartID(owner)
+	if err != nil {
+		return 0, fmt.Errorf("error getting cart ID: %w", err)
+	}
+
+	var couponID sql.NullInt64
+	err = s.db.QueryRow(`SELECT couponId FROM carts WHERE id = ?`, cartID).Scan(&couponID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting cart coupon: %w", err)
+	}
+
+	return int(couponID.Int64), nil
+}
+
 func scanRow(row *sql.Row) (*types.CartItem, error) {
 	c := new(types.CartItem)
 	err := row.Scan(
 		&c.CartID,
 		&c.ProductID,
+		&c.VariantID,
 		&c.Quantity,
 		&c.PriceAtAdding,
 		&c.AddedAt,
 		&c.ProductImage,
 		&c.ProductTitle,
+		&c.VariantLabel,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan row: %w", err)
@@ -294,11 +410,13 @@ func scanRows(rows *sql.Rows) (*[]*types.CartItem, error) {
 		err := rows.Scan(
 			&c.CartID,
 			&c.ProductID,
+			&c.VariantID,
 			&c.Quantity,
 			&c.PriceAtAdding,
 			&c.AddedAt,
 			&c.ProductImage,
 			&c.ProductTitle,
+			&c.VariantLabel,
 		)
 		if err != nil {
 			return &carts, err
@@ -0,0 +1,53 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// GuestCartSweeper periodically deletes the guest carts left idle for longer
+// than ttl, along with their items and reservations. A guest who comes back
+// after that starts a new cart.
+type GuestCartSweeper struct {
+	store    types.CartStore
+	ttl      time.Duration
+	interval time.Duration
+}
+
+func NewGuestCartSweeper(store types.CartStore, ttl time.Duration, interval time.Duration) *GuestCartSweeper {
+	return &GuestCartSweeper{
+		store:    store,
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *GuestCartSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+func (s *GuestCartSweeper) Sweep() {
+	deleted, err := s.store.DeleteIdleGuestCarts(time.Now().Add(-s.ttl))
+	if err != nil {
+		fmt.Printf("[GUEST CART SWEEPER] Error deleting idle guest carts: %v\n", err)
+		return
+	}
+
+	if deleted > 0 {
+		fmt.Printf("[GUEST CART SWEEPER] Deleted %d idle guest carts\n", deleted)
+	}
+}
@@ -0,0 +1,51 @@
+package cart
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingStore struct {
+	types.CartStore
+	sweeps chan time.Time
+}
+
+func (c *countingStore) DeleteIdleGuestCarts(idleSince time.Time) (int64, error) {
+	select {
+	case c.sweeps <- idleSince:
+	default:
+	}
+	return 1, nil
+}
+
+func TestGuestCartSweeperRunsUntilCancelled(t *testing.T) {
+	store := &countingStore{sweeps: make(chan time.Time, 10)}
+	sweeper := NewGuestCartSweeper(store, 24*time.Hour, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweeper.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case idleSince := <-store.sweeps:
+			assert.WithinDuration(t, time.Now().Add(-24*time.Hour), idleSince, time.Minute)
+		case <-time.After(time.Second):
+			t.Fatal("sweeper did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop")
+	}
+}
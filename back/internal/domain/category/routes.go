@@ -13,17 +13,25 @@ import (
 
 type Handler struct {
 	store     types.CategoryStore
+	service   types.CategoryService
 	userStore types.UserStore
 }
 
-func NewHandler(store types.CategoryStore, userStore types.UserStore) *Handler {
-	return &Handler{store: store, userStore: userStore}
+func NewHandler(store types.CategoryStore, service types.CategoryService, userStore types.UserStore) *Handler {
+	return &Handler{store: store, service: service, userStore: userStore}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	// user routes
 	router.HandleFunc("/category", auth.WithJwtAuth(h.handleGetCategories, h.userStore)).Methods(http.MethodGet)
 
+	// registered ahead of /category/{categoryID}, which would take "tree"
+	// for an id
+	router.HandleFunc("/category/tree", auth.WithJwtAuth(h.handleGetCategoryTree, h.userStore)).Methods(http.MethodGet)
+
+	router.HandleFunc("/category/{categoryID}/breadcrumbs", auth.WithJwtAuth(
+		h.handleGetBreadcrumbs, h.userStore)).Methods(http.MethodGet)
+
 	router.HandleFunc("/category/{categoryID}", auth.WithJwtAuth(
 		h.handleGetCategoryByID, h.userStore)).Methods(http.MethodGet)
 
@@ -40,7 +48,13 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 }
 
 func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.store.GetCategories()
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	categories, err := h.store.GetCategories(page)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -49,6 +63,28 @@ func (h *Handler) handleGetCategories(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJson(w, http.StatusOK, categories)
 }
 
+func (h *Handler) handleGetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.service.GetCategoryTree()
+	if err != nil {
+		utils.WriteServiceError(w, err, "failed to get category tree")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, tree)
+}
+
+func (h *Handler) handleGetBreadcrumbs(w http.ResponseWriter, r *http.Request) {
+	categoryID := utils.GetParamIdfromPath(r, "categoryID")
+
+	breadcrumbs, err := h.service.GetBreadcrumbs(categoryID)
+	if err != nil {
+		utils.WriteServiceError(w, err, "failed to get breadcrumbs")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, breadcrumbs)
+}
+
 func (h *Handler) handleGetCategoryByID(w http.ResponseWriter, r *http.Request) {
 	categoryID := utils.GetParamIdfromPath(r, "categoryID")
 
@@ -99,13 +135,6 @@ func (h *Handler) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleUpdateCategoryByID(w http.ResponseWriter, r *http.Request) {
 	categoryID := utils.GetParamIdfromPath(r, "categoryID")
 
-	// verify if the category exists
-	_, err := h.store.GetCategoryByID(categoryID)
-	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category with id %d not found", categoryID))
-		return
-	}
-
 	// get payload
 	var payload types.UpdateCategoryPayload
 	if err := utils.ParseJson(r, &payload); err != nil {
@@ -121,28 +150,23 @@ func (h *Handler) handleUpdateCategoryByID(w http.ResponseWriter, r *http.Reques
 	}
 
 	// update
-	updatedCategory, err := h.store.UpdateCategory(categoryID, payload)
+	updatedCategory, err := h.service.UpdateCategory(categoryID, payload)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteServiceError(w, err, "failed to update category")
 		return
 	}
 
 	utils.WriteJson(w, http.StatusOK, updatedCategory)
 }
 
+// handleDeleteCategory takes ?mode=cascade or ?mode=reparent to delete a
+// category that has subcategories.
 func (h *Handler) handleDeleteCategory(w http.ResponseWriter, r *http.Request) {
 	categoryID := utils.GetParamIdfromPath(r, "categoryID")
+	mode := types.CategoryDeleteMode(r.URL.Query().Get("mode"))
 
-	// verify if the category exists
-	_, err := h.store.GetCategoryByID(categoryID)
-	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("category with id %d not found", categoryID))
-		return
-	}
-
-	// delete category
-	if err := h.store.DeleteCategory(categoryID); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+	if err := h.service.DeleteCategory(categoryID, mode); err != nil {
+		utils.WriteServiceError(w, err, "failed to delete category")
 		return
 	}
 
@@ -0,0 +1,161 @@
+package category
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Service struct {
+	store types.CategoryStore
+}
+
+func NewService(store types.CategoryStore) *Service {
+	return &Service{store: store}
+}
+
+// GetCategoryTree nests every category under its parent. A category whose
+// parent is missing shows up as a root.
+func (s *Service) GetCategoryTree() ([]*types.CategoryNode, error) {
+	categories, err := s.store.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int]*types.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &types.CategoryNode{Category: category, Children: []*types.CategoryNode{}}
+	}
+
+	roots := []*types.CategoryNode{}
+	for _, category := range categories {
+		var parent *types.CategoryNode
+		if category.ParentCategoryId != nil {
+			parent = nodes[*category.ParentCategoryId]
+		}
+
+		if parent != nil {
+			parent.Children = append(parent.Children, nodes[category.ID])
+		} else {
+			roots = append(roots, nodes[category.ID])
+		}
+	}
+
+	return roots, nil
+}
+
+// GetBreadcrumbs returns the path from the root down to the category.
+func (s *Service) GetBreadcrumbs(categoryID int) ([]types.Category, error) {
+	categories, err := s.categoriesByID()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := categories[categoryID]; !ok {
+		return nil, apperrors.NewEntityNotFound("category", categoryID)
+	}
+
+	breadcrumbs := ancestors(categories, categoryID)
+	for i, j := 0, len(breadcrumbs)-1; i < j; i, j = i+1, j-1 {
+		breadcrumbs[i], breadcrumbs[j] = breadcrumbs[j], breadcrumbs[i]
+	}
+
+	return breadcrumbs, nil
+}
+
+// UpdateCategory refuses to move a category under itself or one of its
+// subcategories, which would cut the branch off the tree.
+func (s *Service) UpdateCategory(categoryID int, payload types.UpdateCategoryPayload) (*types.Category, error) {
+	categories, err := s.categoriesByID()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := categories[categoryID]; !ok {
+		return nil, apperrors.NewEntityNotFound("category", categoryID)
+	}
+
+	if payload.ParentCategoryId != nil {
+		parentID := *payload.ParentCategoryId
+		if _, ok := categories[parentID]; !ok {
+			return nil, apperrors.NewEntityNotFound("parent category", parentID)
+		}
+
+		for _, ancestor := range ancestors(categories, parentID) {
+			if ancestor.ID == categoryID {
+				return nil, apperrors.NewValidationError("parentCategoryId",
+					"a category can't move under itself or one of its subcategories")
+			}
+		}
+	}
+
+	return s.store.UpdateCategory(categoryID, payload)
+}
+
+// DeleteCategory takes a mode only when the category has subcategories, to
+// tell whether they go along or move up a level.
+func (s *Service) DeleteCategory(categoryID int, mode types.CategoryDeleteMode) error {
+	switch mode {
+	case "", types.CategoryDeleteCascade, types.CategoryDeleteReparent:
+	default:
+		return apperrors.NewValidationError("mode", fmt.Sprintf("unknown delete mode %q, use cascade or reparent", mode))
+	}
+
+	categories, err := s.categoriesByID()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := categories[categoryID]; !ok {
+		return apperrors.NewEntityNotFound("category", categoryID)
+	}
+
+	if mode == "" {
+		for _, category := range categories {
+			if category.ParentCategoryId != nil && *category.ParentCategoryId == categoryID {
+				return apperrors.NewConflictError("mode", "the category has subcategories, delete with mode cascade or reparent")
+			}
+		}
+	}
+
+	if err := s.store.DeleteCategory(categoryID, mode); err != nil {
+		return err
+	}
+
+	fmt.Printf("[CATEGORY SERVICE] Deleted category %d (mode %q)\n", categoryID, mode)
+	return nil
+}
+
+func (s *Service) categoriesByID() (map[int]types.Category, error) {
+	categories, err := s.store.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]types.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	return byID, nil
+}
+
+// ancestors walks up from the category to its root, the category first. The
+// walk stops at a category seen before, should the stored tree hold a loop.
+func ancestors(categories map[int]types.Category, categoryID int) []types.Category {
+	path := []types.Category{}
+	seen := make(map[int]bool)
+
+	for id := &categoryID; id != nil && !seen[*id]; {
+		category, ok := categories[*id]
+		if !ok {
+			break
+		}
+		seen[*id] = true
+		path = append(path, category)
+		id = category.ParentCategoryId
+	}
+
+	return path
+}
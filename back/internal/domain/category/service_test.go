@@ -0,0 +1,157 @@
+package category
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCategoryStore struct {
+	types.CategoryStore
+	mock.Mock
+}
+
+func (m *MockCategoryStore) GetAllCategories() ([]types.Category, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]types.Category), args.Error(1)
+}
+
+func (m *MockCategoryStore) UpdateCategory(categoryID int, payload types.UpdateCategoryPayload) (*types.Category, error) {
+	args := m.Called(categoryID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Category), args.Error(1)
+}
+
+func (m *MockCategoryStore) DeleteCategory(categoryID int, mode types.CategoryDeleteMode) error {
+	args := m.Called(categoryID, mode)
+	return args.Error(0)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// Electronics > Phones > Smartphones, and Home at the root
+func newMockStore() *MockCategoryStore {
+	store := new(MockCategoryStore)
+	store.On("GetAllCategories").Return([]types.Category{
+		{ID: 1, Name: "Electronics"},
+		{ID: 2, Name: "Phones", ParentCategoryId: intPtr(1)},
+		{ID: 3, Name: "Smartphones", ParentCategoryId: intPtr(2)},
+		{ID: 4, Name: "Home"},
+	}, nil)
+	return store
+}
+
+func TestGetCategoryTree(t *testing.T) {
+	tree, err := NewService(newMockStore()).GetCategoryTree()
+
+	assert.NoError(t, err)
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "Electronics", tree[0].Name)
+	assert.Equal(t, "Phones", tree[0].Children[0].Name)
+	assert.Equal(t, "Smartphones", tree[0].Children[0].Children[0].Name)
+	assert.Empty(t, tree[0].Children[0].Children[0].Children)
+	assert.Equal(t, "Home", tree[1].Name)
+	assert.Empty(t, tree[1].Children)
+}
+
+func TestGetBreadcrumbs(t *testing.T) {
+	service := NewService(newMockStore())
+
+	breadcrumbs, err := service.GetBreadcrumbs(3)
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, category := range breadcrumbs {
+		names = append(names, category.Name)
+	}
+	assert.Equal(t, []string{"Electronics", "Phones", "Smartphones"}, names)
+
+	_, err = service.GetBreadcrumbs(99)
+	assert.Equal(t, apperrors.NewEntityNotFound("category", 99), err)
+}
+
+func TestUpdateCategory(t *testing.T) {
+	cycle := apperrors.NewValidationError("parentCategoryId", "a category can't move under itself or one of its subcategories")
+
+	tests := []struct {
+		name          string
+		categoryID    int
+		parentID      int
+		expectedError error
+	}{
+		{name: "Move to another branch", categoryID: 2, parentID: 4},
+		{name: "Under itself", categoryID: 2, parentID: 2, expectedError: cycle},
+		{name: "Under a descendant", categoryID: 1, parentID: 3, expectedError: cycle},
+		{name: "Unknown parent", categoryID: 2, parentID: 99, expectedError: apperrors.NewEntityNotFound("parent category", 99)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			payload := types.UpdateCategoryPayload{Name: "Renamed", ImageUrl: "image.png", ParentCategoryId: intPtr(tt.parentID)}
+			store.On("UpdateCategory", tt.categoryID, payload).Return(&types.Category{ID: tt.categoryID}, nil).Maybe()
+
+			_, err := NewService(store).UpdateCategory(tt.categoryID, payload)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				store.AssertNotCalled(t, "UpdateCategory", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteCategory(t *testing.T) {
+	tests := []struct {
+		name          string
+		categoryID    int
+		mode          types.CategoryDeleteMode
+		expectedError error
+	}{
+		{name: "Leaf without a mode", categoryID: 3},
+		{name: "Cascade", categoryID: 1, mode: types.CategoryDeleteCascade},
+		{name: "Reparent", categoryID: 2, mode: types.CategoryDeleteReparent},
+		{
+			name:          "Subcategories without a mode",
+			categoryID:    1,
+			expectedError: apperrors.NewConflictError("mode", "the category has subcategories, delete with mode cascade or reparent"),
+		},
+		{
+			name:          "Unknown mode",
+			categoryID:    1,
+			mode:          "orphan",
+			expectedError: apperrors.NewValidationError("mode", `unknown delete mode "orphan", use cascade or reparent`),
+		},
+		{name: "Unknown category", categoryID: 99, expectedError: apperrors.NewEntityNotFound("category", 99)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			store.On("DeleteCategory", tt.categoryID, tt.mode).Return(nil).Maybe()
+
+			err := NewService(store).DeleteCategory(tt.categoryID, tt.mode)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				store.AssertNotCalled(t, "DeleteCategory", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			store.AssertExpectations(t)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package category
 import (
 	"database/sql"
 	"fmt"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"strings"
 )
@@ -15,11 +16,14 @@ func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetCategories() (*[]types.Category, error) {
+func (s *Store) GetCategories(page types.PageRequest) (*types.Page[types.Category], error) {
 	rows, err := s.db.Query(`
 		SELECT id, name, imageUrl, parentCategoryId 
 		FROM categories
-	`)
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, page.AfterID(), page.Limit+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get categories: %w", err)
 	}
@@ -34,7 +38,32 @@ func (s *Store) GetCategories() (*[]types.Category, error) {
 		categories = append(categories, *c)
 	}
 
-	return &categories, nil
+	return types.NewPage(categories, page.Limit, func(c types.Category) types.Cursor {
+		return types.Cursor{ID: c.ID}
+	}), nil
+}
+
+func (s *Store) GetAllCategories() ([]types.Category, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, imageUrl, parentCategoryId
+		FROM categories
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make([]types.Category, 0)
+	for rows.Next() {
+		c, err := scanRowsIntoCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *c)
+	}
+
+	return categories, rows.Err()
 }
 
 func (s *Store) GetCategoryByID(categoryID int) (*types.Category, error) {
@@ -140,26 +169,55 @@ func (s *Store) UpdateCategory(categoryID int, payload types.UpdateCategoryPaylo
 	return s.GetCategoryByID(categoryID)
 }
 
-func (s *Store) DeleteCategory(categoryID int) error {
-	// delete category
-	result, err := s.db.Exec(`
-		DELETE FROM categories 
-		WHERE id = ?
-	`, categoryID)
-	if err != nil {
-		return fmt.Errorf("failed to delete category: %w", err)
-	}
+func (s *Store) DeleteCategory(categoryID int, mode types.CategoryDeleteMode) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		switch mode {
+		case types.CategoryDeleteReparent:
+			_, err := tx.Exec(`
+				UPDATE categories c
+				JOIN categories deleted ON deleted.id = ?
+				SET c.parentCategoryId = deleted.parentCategoryId
+				WHERE c.parentCategoryId = deleted.id
+			`, categoryID)
+			if err != nil {
+				return fmt.Errorf("failed to reparent subcategories: %w", err)
+			}
+		case types.CategoryDeleteCascade:
+			// the subcategories would otherwise be left at the root, their
+			// parent key being ON DELETE SET NULL
+			_, err := tx.Exec(`
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM categories WHERE parentCategoryId = ?
+					UNION
+					SELECT c.id FROM categories c JOIN subtree s ON c.parentCategoryId = s.id
+				)
+				DELETE c FROM categories c JOIN subtree s ON c.id = s.id
+			`, categoryID)
+			if err != nil {
+				return fmt.Errorf("failed to delete subcategories: %w", err)
+			}
+		}
 
-	// verify if category exists
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("category not found")
-	}
+		// delete category
+		result, err := tx.Exec(`
+			DELETE FROM categories 
+			WHERE id = ?
+		`, categoryID)
+		if err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
+
+		// verify if category exists
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("category not found")
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func scanRowsIntoCategory(rows *sql.Rows) (*types.Category, error) {
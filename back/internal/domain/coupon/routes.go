@@ -0,0 +1,93 @@
+package coupon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	couponService types.CouponService
+}
+
+func NewHandler(couponService types.CouponService) *Handler {
+	return &Handler{couponService: couponService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	adminRouter := router.PathPrefix("").Subrouter()
+	adminRouter.Use(
+		auth.WithJwtAuthMiddleware(userStore),
+		auth.WithAdminAuthMiddleware())
+
+	adminRouter.HandleFunc("/coupons", h.createCoupon).Methods("POST")
+	adminRouter.HandleFunc("/coupons", h.getCoupons).Methods("GET")
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	adminRouter.HandleFunc("/coupons/{couponId}",
+		utils.Compose(h.getCoupon, middleware.ErrorHandler)).Methods("GET")
+	adminRouter.HandleFunc("/coupons/{couponId}",
+		utils.Compose(h.deleteCoupon, middleware.ErrorHandler)).Methods("DELETE")
+}
+
+func (h *Handler) createCoupon(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateCouponPayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	coupon, err := h.couponService.CreateCoupon(payload)
+	if err != nil {
+		fmt.Printf("[COUPON HANDLER] ERROR creating coupon: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to create coupon")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, coupon)
+}
+
+func (h *Handler) getCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.couponService.GetCoupons()
+	if err != nil {
+		fmt.Printf("[COUPON HANDLER] ERROR getting coupons: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get coupons")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, coupons)
+}
+
+func (h *Handler) getCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID := utils.GetParamIdfromPath(r, "couponId")
+
+	coupon, err := h.couponService.GetCouponByID(couponID)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to get coupon")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, coupon)
+}
+
+func (h *Handler) deleteCoupon(w http.ResponseWriter, r *http.Request) {
+	couponID := utils.GetParamIdfromPath(r, "couponId")
+
+	if err := h.couponService.DeleteCoupon(couponID); err != nil {
+		fmt.Printf("[COUPON HANDLER] ERROR deleting coupon %d: %v\n", couponID, err)
+		utils.WriteServiceError(w, err, "Failed to delete coupon")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Coupon deleted successfully"})
+}
@@ -0,0 +1,172 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Service struct {
+	couponStore types.CouponStore
+}
+
+func NewService(couponStore types.CouponStore) *Service {
+	return &Service{couponStore: couponStore}
+}
+
+func (s *Service) CreateCoupon(payload types.CreateCouponPayload) (*types.Coupon, error) {
+	payload.Code = normalizeCode(payload.Code)
+
+	if payload.Type == types.CouponPercentage && payload.Value > 100 {
+		return nil, apperrors.NewValidationError("value", "a percentage coupon takes at most 100")
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		return nil, apperrors.NewValidationError("expiresAt", "expiry must be in the future")
+	}
+
+	_, err := s.couponStore.GetCouponByCode(payload.Code)
+	if err == nil {
+		return nil, apperrors.NewConflictError("code", "a coupon with this code already exists")
+	}
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) || appErr.Type != apperrors.NotFound {
+		return nil, err
+	}
+
+	coupon, err := s.couponStore.CreateCoupon(payload)
+	if err != nil {
+		fmt.Printf("[COUPON SERVICE] ERROR creating coupon %s: %v\n", payload.Code, err)
+		return nil, err
+	}
+
+	fmt.Printf("[COUPON SERVICE] Created coupon %d (%s)\n", coupon.ID, coupon.Code)
+	return coupon, nil
+}
+
+func (s *Service) GetCoupons() ([]*types.Coupon, error) {
+	return s.couponStore.GetCoupons()
+}
+
+func (s *Service) GetCouponByID(couponID int) (*types.Coupon, error) {
+	return s.couponStore.GetCouponByID(couponID)
+}
+
+func (s *Service) GetCouponByCode(code string) (*types.Coupon, error) {
+	return s.couponStore.GetCouponByCode(normalizeCode(code))
+}
+
+func (s *Service) DeleteCoupon(couponID int) error {
+	return s.couponStore.DeleteCoupon(couponID)
+}
+
+// Evaluate checks the coupon against the cart and its usage limits. A
+// percentage coupon takes its share of the eligible items, a fixed one takes
+// its value, but never more than the eligible items are worth.
+func (s *Service) Evaluate(coupon *types.Coupon, userID int, lines []types.CouponLine) (*types.CouponEvaluation, error) {
+	if coupon.ExpiresAt != nil && !time.Now().Before(*coupon.ExpiresAt) {
+		return rejected("coupon has expired"), nil
+	}
+
+	subtotal := 0.0
+	eligible := 0.0
+	for _, line := range lines {
+		amount := line.UnitPrice * float64(line.Quantity)
+		subtotal += amount
+		if applies(coupon, line) {
+			eligible += amount
+		}
+	}
+
+	if utils.RoundCents(subtotal) < coupon.MinCartValue {
+		return rejected(fmt.Sprintf("cart must be worth at least %.2f", coupon.MinCartValue)), nil
+	}
+
+	if eligible <= 0 {
+		return rejected("coupon does not apply to any item in the cart"), nil
+	}
+
+	total, byUser, err := s.couponStore.CountRedemptions(coupon.ID, userID)
+	if err != nil {
+		fmt.Printf("[COUPON SERVICE] ERROR counting redemptions of coupon %d: %v\n", coupon.ID, err)
+		return nil, err
+	}
+
+	if coupon.MaxUses != nil && total >= *coupon.MaxUses {
+		return rejected("coupon is no longer available"), nil
+	}
+
+	if userID != 0 && coupon.MaxUsesPerUser != nil && byUser >= *coupon.MaxUsesPerUser {
+		return rejected("coupon was already used the maximum number of times"), nil
+	}
+
+	discount := coupon.Value
+	if coupon.Type == types.CouponPercentage {
+		discount = eligible * coupon.Value / 100
+	}
+
+	discount = utils.RoundCents(math.Min(discount, eligible))
+	return &types.CouponEvaluation{
+		Discount:      discount,
+		LineDiscounts: splitDiscount(coupon, lines, eligible, discount),
+	}, nil
+}
+
+// splitDiscount shares discount among the lines the coupon applies to, in
+// proportion to their worth. The last of them takes what rounding leaves, so
+// the shares add up to discount.
+func splitDiscount(coupon *types.Coupon, lines []types.CouponLine, eligible float64, discount float64) []float64 {
+	last := -1
+	for i, line := range lines {
+		if applies(coupon, line) {
+			last = i
+		}
+	}
+
+	shares := make([]float64, len(lines))
+	left := discount
+	for i, line := range lines {
+		if i == last || !applies(coupon, line) {
+			continue
+		}
+		shares[i] = utils.RoundCents(discount * line.UnitPrice * float64(line.Quantity) / eligible)
+		left -= shares[i]
+	}
+	shares[last] = utils.RoundCents(left)
+
+	return shares
+}
+
+// applies tells whether line is one of the items the coupon is restricted to.
+func applies(coupon *types.Coupon, line types.CouponLine) bool {
+	if !coupon.Restricted() {
+		return true
+	}
+
+	if slices.Contains(coupon.ProductIDs, line.ProductID) {
+		return true
+	}
+
+	for _, categoryID := range line.CategoryIDs {
+		if slices.Contains(coupon.CategoryIDs, categoryID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func rejected(reason string) *types.CouponEvaluation {
+	return &types.CouponEvaluation{Reason: reason}
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
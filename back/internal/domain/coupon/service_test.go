@@ -0,0 +1,220 @@
+package coupon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCouponStore struct {
+	mock.Mock
+}
+
+func (m *MockCouponStore) CreateCoupon(payload types.CreateCouponPayload) (*types.Coupon, error) {
+	args := m.Called(payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponStore) GetCoupons() ([]*types.Coupon, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponStore) GetCouponByID(couponID int) (*types.Coupon, error) {
+	args := m.Called(couponID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponStore) GetCouponByCode(code string) (*types.Coupon, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Coupon), args.Error(1)
+}
+
+func (m *MockCouponStore) DeleteCoupon(couponID int) error {
+	args := m.Called(couponID)
+	return args.Error(0)
+}
+
+func (m *MockCouponStore) CountRedemptions(couponID int, userID int) (int, int, error) {
+	args := m.Called(couponID, userID)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+func (m *MockCouponStore) RedeemCoupon(redemption *types.CouponRedemption) error {
+	args := m.Called(redemption)
+	return args.Error(0)
+}
+
+func (m *MockCouponStore) ReleaseRedemption(orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestServiceEvaluate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	lines := []types.CouponLine{
+		{ProductID: 1, CategoryIDs: []int{10}, Quantity: 2, UnitPrice: 25.0},
+		{ProductID: 2, CategoryIDs: []int{20, 21}, Quantity: 1, UnitPrice: 30.0},
+		{ProductID: 3, CategoryIDs: []int{}, Quantity: 3, UnitPrice: 3.33},
+	}
+
+	tests := []struct {
+		name       string
+		coupon     *types.Coupon
+		userID     int
+		total      int
+		byUser     int
+		countCalls bool
+		expected   *types.CouponEvaluation
+	}{
+		{
+			name:       "Percentage of the whole cart",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponPercentage, Value: 10},
+			userID:     1,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Discount: 9.0, LineDiscounts: []float64{5.0, 3.0, 1.0}},
+		},
+		{
+			name:       "Fixed amount",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 15, MinCartValue: 80, ExpiresAt: &future},
+			userID:     1,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Discount: 15.0, LineDiscounts: []float64{8.33, 5.0, 1.67}},
+		},
+		{
+			name:       "Percentage of the products and categories it is restricted to",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponPercentage, Value: 50, ProductIDs: []int{3}, CategoryIDs: []int{21}},
+			userID:     1,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Discount: 20.0, LineDiscounts: []float64{0, 15.0, 5.0}},
+		},
+		{
+			name:       "Fixed amount capped at the eligible items",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 100, CategoryIDs: []int{10}},
+			userID:     1,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Discount: 50.0, LineDiscounts: []float64{50.0, 0, 0}},
+		},
+		{
+			name:     "Expired",
+			coupon:   &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, ExpiresAt: &past},
+			userID:   1,
+			expected: &types.CouponEvaluation{Reason: "coupon has expired"},
+		},
+		{
+			name:     "Cart below the minimum",
+			coupon:   &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, MinCartValue: 100},
+			userID:   1,
+			expected: &types.CouponEvaluation{Reason: "cart must be worth at least 100.00"},
+		},
+		{
+			name:     "No eligible item",
+			coupon:   &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, ProductIDs: []int{9}},
+			userID:   1,
+			expected: &types.CouponEvaluation{Reason: "coupon does not apply to any item in the cart"},
+		},
+		{
+			name:       "Global limit reached",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, MaxUses: intPtr(3)},
+			userID:     1,
+			total:      3,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Reason: "coupon is no longer available"},
+		},
+		{
+			name:       "Per-user limit reached",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, MaxUses: intPtr(3), MaxUsesPerUser: intPtr(1)},
+			userID:     1,
+			total:      1,
+			byUser:     1,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Reason: "coupon was already used the maximum number of times"},
+		},
+		{
+			name:       "Per-user limit left to sign in for guests",
+			coupon:     &types.Coupon{ID: 1, Type: types.CouponFixed, Value: 5, MaxUsesPerUser: intPtr(1)},
+			userID:     0,
+			countCalls: true,
+			expected:   &types.CouponEvaluation{Discount: 5.0, LineDiscounts: []float64{2.78, 1.67, 0.55}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockCouponStore)
+			if tt.countCalls {
+				mockStore.On("CountRedemptions", tt.coupon.ID, tt.userID).Return(tt.total, tt.byUser, nil)
+			}
+			service := NewService(mockStore)
+
+			evaluation, err := service.Evaluate(tt.coupon, tt.userID, lines)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, evaluation)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceCreateCoupon(t *testing.T) {
+	t.Run("Stores the code in upper case", func(t *testing.T) {
+		mockStore := new(MockCouponStore)
+		service := NewService(mockStore)
+
+		payload := types.CreateCouponPayload{Code: " save10 ", Type: types.CouponPercentage, Value: 10}
+		stored := payload
+		stored.Code = "SAVE10"
+		mockStore.On("GetCouponByCode", "SAVE10").Return(nil, apperrors.NewEntityNotFound("coupon", "SAVE10"))
+		mockStore.On("CreateCoupon", stored).Return(&types.Coupon{ID: 1, Code: "SAVE10"}, nil)
+
+		coupon, err := service.CreateCoupon(payload)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "SAVE10", coupon.Code)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("Duplicate code", func(t *testing.T) {
+		mockStore := new(MockCouponStore)
+		service := NewService(mockStore)
+		mockStore.On("GetCouponByCode", "SAVE10").Return(&types.Coupon{ID: 1, Code: "SAVE10"}, nil)
+
+		coupon, err := service.CreateCoupon(types.CreateCouponPayload{Code: "SAVE10", Type: types.CouponFixed, Value: 10})
+
+		assert.Equal(t, apperrors.NewConflictError("code", "a coupon with this code already exists"), err)
+		assert.Nil(t, coupon)
+		mockStore.AssertNotCalled(t, "CreateCoupon", mock.Anything)
+	})
+
+	t.Run("Percentage above 100", func(t *testing.T) {
+		mockStore := new(MockCouponStore)
+		service := NewService(mockStore)
+
+		coupon, err := service.CreateCoupon(types.CreateCouponPayload{Code: "HALF", Type: types.CouponPercentage, Value: 150})
+
+		assert.Equal(t, apperrors.NewValidationError("value", "a percentage coupon takes at most 100"), err)
+		assert.Nil(t, coupon)
+	})
+}
@@ -0,0 +1,302 @@
+package coupon
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+const couponColumns = `
+	id,
+	code,
+	discountType,
+	value,
+	minCartValue,
+	maxUses,
+	maxUsesPerUser,
+	expiresAt,
+	createdAt
+`
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) CreateCoupon(payload types.CreateCouponPayload) (*types.Coupon, error) {
+	var couponID int
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		res, err := tx.Exec(`
+			INSERT INTO coupons
+				(code, discountType, value, minCartValue, maxUses, maxUsesPerUser, expiresAt)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`,
+			payload.Code,
+			payload.Type,
+			payload.Value,
+			payload.MinCartValue,
+			payload.MaxUses,
+			payload.MaxUsesPerUser,
+			payload.ExpiresAt,
+		)
+		if err != nil {
+			return fmt.Errorf("[CreateCoupon] error inserting coupon: %v", err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateCoupon] error getting coupon ID: %v", err)
+		}
+		couponID = int(id)
+
+		for _, productID := range payload.ProductIDs {
+			_, err = tx.Exec(`INSERT INTO coupon_products (couponId, productId) VALUES (?, ?)`, couponID, productID)
+			if err != nil {
+				return fmt.Errorf("[CreateCoupon] error restricting coupon to product %d: %v", productID, err)
+			}
+		}
+
+		for _, categoryID := range payload.CategoryIDs {
+			_, err = tx.Exec(`INSERT INTO coupon_categories (couponId, categoryId) VALUES (?, ?)`, couponID, categoryID)
+			if err != nil {
+				return fmt.Errorf("[CreateCoupon] error restricting coupon to category %d: %v", categoryID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCouponByID(couponID)
+}
+
+func (s *Store) GetCoupons() ([]*types.Coupon, error) {
+	rows, err := s.db.Query(`SELECT ` + couponColumns + ` FROM coupons ORDER BY createdAt DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("[GetCoupons] error getting coupons: %v", err)
+	}
+	defer rows.Close()
+
+	coupons := make([]*types.Coupon, 0)
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[GetCoupons] error scanning coupon: %v", err)
+		}
+		coupons = append(coupons, coupon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetCoupons] error reading coupons: %v", err)
+	}
+
+	for _, coupon := range coupons {
+		if err := s.loadRestrictions(coupon); err != nil {
+			return nil, err
+		}
+	}
+
+	return coupons, nil
+}
+
+func (s *Store) GetCouponByID(couponID int) (*types.Coupon, error) {
+	row := s.db.QueryRow(`SELECT `+couponColumns+` FROM coupons WHERE id = ?`, couponID)
+	return s.getCoupon(row, couponID)
+}
+
+func (s *Store) GetCouponByCode(code string) (*types.Coupon, error) {
+	// the column collation is case insensitive
+	row := s.db.QueryRow(`SELECT `+couponColumns+` FROM coupons WHERE code = ?`, code)
+	return s.getCoupon(row, code)
+}
+
+func (s *Store) getCoupon(row *sql.Row, key interface{}) (*types.Coupon, error) {
+	coupon, err := scanCoupon(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.NewEntityNotFound("coupon", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GetCoupon] error getting coupon %v: %v", key, err)
+	}
+
+	if err := s.loadRestrictions(coupon); err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+func (s *Store) DeleteCoupon(couponID int) error {
+	res, err := s.db.Exec(`DELETE FROM coupons WHERE id = ?`, couponID)
+	if err != nil {
+		return fmt.Errorf("[DeleteCoupon] error deleting coupon %d: %v", couponID, err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return apperrors.NewEntityNotFound("coupon", couponID)
+	}
+
+	return nil
+}
+
+func (s *Store) CountRedemptions(couponID int, userID int) (int, int, error) {
+	return countRedemptions(s.db, couponID, userID)
+}
+
+// RedeemCoupon locks the coupon row, so concurrent checkouts redeeming the
+// same coupon check its limits one at a time.
+func (s *Store) RedeemCoupon(redemption *types.CouponRedemption) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		var maxUses, maxUsesPerUser sql.NullInt64
+		err := tx.QueryRow(`
+			SELECT maxUses, maxUsesPerUser
+			FROM coupons
+			WHERE id = ?
+			FOR UPDATE
+		`, redemption.CouponID).Scan(&maxUses, &maxUsesPerUser)
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ErrCouponUnavailable
+		}
+		if err != nil {
+			return fmt.Errorf("[RedeemCoupon] error locking coupon %d: %v", redemption.CouponID, err)
+		}
+
+		total, byUser, err := countRedemptions(tx, redemption.CouponID, redemption.UserID)
+		if err != nil {
+			return err
+		}
+
+		if maxUses.Valid && int64(total) >= maxUses.Int64 {
+			return types.ErrCouponUnavailable
+		}
+		if maxUsesPerUser.Valid && int64(byUser) >= maxUsesPerUser.Int64 {
+			return types.ErrCouponUnavailable
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO coupon_redemptions (couponId, userId, orderId, amount)
+			VALUES (?, ?, ?, ?)
+		`, redemption.CouponID, redemption.UserID, redemption.OrderID, redemption.Amount)
+		if err != nil {
+			return fmt.Errorf("[RedeemCoupon] error redeeming coupon %d: %v", redemption.CouponID, err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[RedeemCoupon] error getting redemption ID: %v", err)
+		}
+		redemption.ID = int(id)
+
+		return nil
+	})
+}
+
+func (s *Store) ReleaseRedemption(orderID int) error {
+	_, err := s.db.Exec(`DELETE FROM coupon_redemptions WHERE orderId = ?`, orderID)
+	if err != nil {
+		return fmt.Errorf("[ReleaseRedemption] error releasing coupon redeemed by order %d: %v", orderID, err)
+	}
+
+	return nil
+}
+
+func countRedemptions(db database.DBTX, couponID int, userID int) (int, int, error) {
+	var total, byUser int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(userId = ?), 0)
+		FROM coupon_redemptions
+		WHERE couponId = ?
+	`, userID, couponID).Scan(&total, &byUser)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[CountRedemptions] error counting redemptions of coupon %d: %v", couponID, err)
+	}
+
+	return total, byUser, nil
+}
+
+func (s *Store) loadRestrictions(coupon *types.Coupon) error {
+	productIDs, err := s.getIDs(`SELECT productId FROM coupon_products WHERE couponId = ?`, coupon.ID)
+	if err != nil {
+		return fmt.Errorf("[GetCoupon] error getting products of coupon %d: %v", coupon.ID, err)
+	}
+
+	categoryIDs, err := s.getIDs(`SELECT categoryId FROM coupon_categories WHERE couponId = ?`, coupon.ID)
+	if err != nil {
+		return fmt.Errorf("[GetCoupon] error getting categories of coupon %d: %v", coupon.ID, err)
+	}
+
+	coupon.ProductIDs = productIDs
+	coupon.CategoryIDs = categoryIDs
+	return nil
+}
+
+func (s *Store) getIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCoupon(row scanner) (*types.Coupon, error) {
+	coupon := new(types.Coupon)
+	var maxUses, maxUsesPerUser sql.NullInt64
+	var expiresAt sql.NullTime
+
+	err := row.Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.Type,
+		&coupon.Value,
+		&coupon.MinCartValue,
+		&maxUses,
+		&maxUsesPerUser,
+		&expiresAt,
+		&coupon.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		coupon.MaxUses = &n
+	}
+	if maxUsesPerUser.Valid {
+		n := int(maxUsesPerUser.Int64)
+		coupon.MaxUsesPerUser = &n
+	}
+	if expiresAt.Valid {
+		coupon.ExpiresAt = &expiresAt.Time
+	}
+
+	return coupon, nil
+}
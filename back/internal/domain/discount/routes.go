@@ -2,6 +2,7 @@ package discount
 
 import (
 	"fmt"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	types "github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
@@ -11,17 +12,15 @@ import (
 )
 
 type Handler struct {
-	store        types.ProductDiscountStore
-	productStore types.ProductStore
-	userStore    types.UserStore
+	service   types.ProductDiscountService
+	userStore types.UserStore
 }
 
 func NewHandler(
-	store types.ProductDiscountStore,
-	productStore types.ProductStore,
+	service types.ProductDiscountService,
 	userStore types.UserStore) *Handler {
 
-	return &Handler{store: store, productStore: productStore, userStore: userStore}
+	return &Handler{service: service, userStore: userStore}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
@@ -33,6 +32,9 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 		auth.WithJwtAuth(h.HandleGetActiveDiscounts, h.userStore)).Methods(http.MethodGet)
 
 	// admin routes
+	router.HandleFunc("/discounts/calendar",
+		auth.WithJwtAuth(auth.WithAdminAuth(h.HandleGetDiscountCalendar), h.userStore)).Methods(http.MethodGet)
+
 	router.HandleFunc("/product/{productID}/discounts",
 		auth.WithJwtAuth(auth.WithAdminAuth(h.HandleCreateProductDiscount), h.userStore)).Methods(http.MethodPost)
 
@@ -47,7 +49,7 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 func (h *Handler) HandleGetProductDiscounts(w http.ResponseWriter, r *http.Request) {
 	productID := utils.GetParamIdfromPath(r, "productID")
 
-	discounts, err := h.store.GetDiscountsByProduct(productID)
+	discounts, err := h.service.GetDiscountsByProduct(productID)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -59,7 +61,7 @@ func (h *Handler) HandleGetProductDiscounts(w http.ResponseWriter, r *http.Reque
 func (h *Handler) HandleGetActiveDiscounts(w http.ResponseWriter, r *http.Request) {
 	productID := utils.GetParamIdfromPath(r, "productID")
 
-	discounts, err := h.store.GetActiveDiscounts(productID)
+	discounts, err := h.service.GetActiveDiscounts(productID)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -78,11 +80,6 @@ func (h *Handler) HandleCreateProductDiscount(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if _, err := h.productStore.GetProductByID(productID); err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("product not found"))
-		return
-	}
-
 	payload.ProductID = productID
 
 	if err := utils.Validate.Struct(payload); err != nil {
@@ -90,9 +87,9 @@ func (h *Handler) HandleCreateProductDiscount(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	discount, err := h.store.CreateDiscount(payload)
+	discount, err := h.service.CreateDiscount(payload)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteServiceError(w, err, "Failed to create discount")
 		return
 	}
 
@@ -109,19 +106,14 @@ func (h *Handler) HandleUpdateProductDiscount(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	if _, err := h.store.GetDiscoutsByID(discountID); err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("discount not found"))
-		return
-	}
-
 	if err := utils.Validate.Struct(payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", err))
 		return
 	}
 
-	discount, err := h.store.UpdateDiscount(discountID, payload)
+	discount, err := h.service.UpdateDiscount(discountID, payload)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteServiceError(w, err, "Failed to update discount")
 		return
 	}
 
@@ -131,15 +123,43 @@ func (h *Handler) HandleUpdateProductDiscount(w http.ResponseWriter, r *http.Req
 func (h *Handler) HandleDeleteProductDiscount(w http.ResponseWriter, r *http.Request) {
 	discountID := utils.GetParamIdfromPath(r, "discountID")
 
-	if _, err := h.store.GetDiscoutsByID(discountID); err != nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("discount not found"))
+	if err := h.service.DeleteDiscount(discountID); err != nil {
+		utils.WriteServiceError(w, err, "Failed to delete discount")
 		return
 	}
 
-	if err := h.store.DeleteDiscount(discountID); err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+	utils.WriteJson(w, http.StatusNoContent, nil)
+}
+
+// HandleGetDiscountCalendar lists the discounts of every product running
+// between the start and end query parameters. They take a date, which
+// covers the whole day, or an RFC 3339 timestamp.
+func (h *Handler) HandleGetDiscountCalendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	start, err := utils.ParseDateParam(query, "start", false)
+	if err == nil && start == nil {
+		err = apperrors.NewValidationError("start", "start is required")
+	}
+	if err != nil {
+		utils.WriteServiceError(w, err, "Invalid calendar range")
 		return
 	}
 
-	utils.WriteJson(w, http.StatusNoContent, nil)
+	end, err := utils.ParseDateParam(query, "end", true)
+	if err == nil && end == nil {
+		err = apperrors.NewValidationError("end", "end is required")
+	}
+	if err != nil {
+		utils.WriteServiceError(w, err, "Invalid calendar range")
+		return
+	}
+
+	calendar, err := h.service.GetDiscountCalendar(*start, *end)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to get discount calendar")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, calendar)
 }
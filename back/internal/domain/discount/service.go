@@ -0,0 +1,249 @@
+package discount
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Service struct {
+	store        types.ProductDiscountStore
+	productStore types.ProductStore
+	pricer       types.ListingPricer
+}
+
+func NewService(store types.ProductDiscountStore, productStore types.ProductStore, pricer types.ListingPricer) *Service {
+	return &Service{store: store, productStore: productStore, pricer: pricer}
+}
+
+func (s *Service) CreateDiscount(payload *types.CreateProductDiscountPayload) (*types.ProductDiscount, error) {
+	if payload.Type == "" {
+		payload.Type = types.DiscountPercentage
+	}
+
+	candidate := &types.ProductDiscount{
+		ProductID:       payload.ProductID,
+		Type:            payload.Type,
+		DiscountPercent: payload.DiscountPercent,
+		FixedPrice:      payload.FixedPrice,
+		BuyQuantity:     payload.BuyQuantity,
+		GetQuantity:     payload.GetQuantity,
+		Stackable:       payload.Stackable,
+		Priority:        payload.Priority,
+		StartDate:       payload.StartDate,
+		EndDate:         payload.EndDate,
+	}
+	if err := s.check(candidate); err != nil {
+		return nil, err
+	}
+
+	discount, err := s.store.CreateDiscount(payload)
+	if err != nil {
+		fmt.Printf("[DISCOUNT SERVICE] ERROR creating discount for product %d: %v\n", payload.ProductID, err)
+		return nil, err
+	}
+
+	fmt.Printf("[DISCOUNT SERVICE] Created discount %d for product %d\n", discount.ID, discount.ProductID)
+	s.refreshPrice(discount.ProductID)
+	return discount, nil
+}
+
+// UpdateDiscount checks the discount as it will be once payload is applied,
+// so a partial update can't leave it invalid or overlapping another one.
+func (s *Service) UpdateDiscount(discountID int, payload *types.UpdateProductDiscountPayload) (*types.ProductDiscount, error) {
+	current, err := s.GetDiscountByID(discountID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := *current
+	if payload.DiscountPercent != nil {
+		candidate.DiscountPercent = *payload.DiscountPercent
+	}
+	if payload.FixedPrice != nil {
+		candidate.FixedPrice = payload.FixedPrice
+	}
+	if payload.BuyQuantity != nil {
+		candidate.BuyQuantity = payload.BuyQuantity
+	}
+	if payload.GetQuantity != nil {
+		candidate.GetQuantity = payload.GetQuantity
+	}
+	if payload.Stackable != nil {
+		candidate.Stackable = *payload.Stackable
+	}
+	if payload.Priority != nil {
+		candidate.Priority = *payload.Priority
+	}
+	if payload.StartDate != nil {
+		candidate.StartDate = *payload.StartDate
+	}
+	if payload.EndDate != nil {
+		candidate.EndDate = *payload.EndDate
+	}
+
+	if err := s.check(&candidate); err != nil {
+		return nil, err
+	}
+
+	discount, err := s.store.UpdateDiscount(discountID, payload)
+	if err != nil {
+		fmt.Printf("[DISCOUNT SERVICE] ERROR updating discount %d: %v\n", discountID, err)
+		return nil, err
+	}
+
+	s.refreshPrice(current.ProductID)
+	return discount, nil
+}
+
+func (s *Service) DeleteDiscount(discountID int) error {
+	discount, err := s.GetDiscountByID(discountID)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.DeleteDiscount(discountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.NewEntityNotFound("discount", discountID)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.refreshPrice(discount.ProductID)
+	return nil
+}
+
+// refreshPrice stores the listing price of a product whose discounts just
+// changed. The write stands when this fails, and the listing catches up on
+// the next periodic refresh.
+func (s *Service) refreshPrice(productID int) {
+	if err := s.pricer.RefreshProductPrices([]int{productID}); err != nil {
+		fmt.Printf("[DISCOUNT SERVICE] ERROR refreshing price of product %d: %v\n", productID, err)
+	}
+}
+
+func (s *Service) GetDiscountByID(discountID int) (*types.ProductDiscount, error) {
+	discount, err := s.store.GetDiscoutsByID(discountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.NewEntityNotFound("discount", discountID)
+	}
+	return discount, err
+}
+
+func (s *Service) GetDiscountsByProduct(productID int) ([]*types.ProductDiscount, error) {
+	return s.store.GetDiscountsByProduct(productID)
+}
+
+func (s *Service) GetActiveDiscounts(productID int) ([]*types.ProductDiscount, error) {
+	return s.store.GetActiveDiscounts(productID)
+}
+
+func (s *Service) GetDiscountCalendar(start time.Time, end time.Time) (*types.DiscountCalendar, error) {
+	if !end.After(start) {
+		return nil, apperrors.NewValidationError("end", "end must be after start")
+	}
+
+	discounts, err := s.store.GetDiscountCalendar(start, end)
+	if err != nil {
+		fmt.Printf("[DISCOUNT SERVICE] ERROR getting discount calendar: %v\n", err)
+		return nil, err
+	}
+
+	calendar := &types.DiscountCalendar{
+		Start:    start,
+		End:      end,
+		Upcoming: []*types.CalendarDiscount{},
+		Active:   []*types.CalendarDiscount{},
+		Expired:  []*types.CalendarDiscount{},
+	}
+
+	now := time.Now()
+	for _, discount := range discounts {
+		switch {
+		case discount.StartDate.After(now):
+			calendar.Upcoming = append(calendar.Upcoming, discount)
+		case discount.EndDate.Before(now):
+			calendar.Expired = append(calendar.Expired, discount)
+		default:
+			calendar.Active = append(calendar.Active, discount)
+		}
+	}
+
+	return calendar, nil
+}
+
+// check validates discount against the rules of its type and the product it
+// applies to, then looks for another discount of the product running at the
+// same time. Two stackable discounts may overlap, since they are meant to
+// combine; any other overlap is a conflict.
+func (s *Service) check(discount *types.ProductDiscount) error {
+	product, err := s.productStore.GetProductByID(discount.ProductID)
+	if err != nil {
+		return apperrors.NewEntityNotFound("product", discount.ProductID)
+	}
+
+	if err := validate(discount, product); err != nil {
+		return err
+	}
+
+	overlapping, err := s.store.GetDiscountsByDateRange(discount.ProductID, discount.StartDate, discount.EndDate)
+	if err != nil {
+		fmt.Printf("[DISCOUNT SERVICE] ERROR getting discounts of product %d: %v\n", discount.ProductID, err)
+		return err
+	}
+
+	for _, other := range overlapping {
+		if other.ID == discount.ID || (other.Stackable && discount.Stackable) {
+			continue
+		}
+		// the store matches on inclusive bounds, a discount starting the
+		// moment another one ends doesn't overlap it
+		if !discount.StartDate.Before(other.EndDate) || !other.StartDate.Before(discount.EndDate) {
+			continue
+		}
+
+		return apperrors.NewConflictError("startDate",
+			fmt.Sprintf("overlaps discount %d, running from %s to %s",
+				other.ID, other.StartDate.Format(time.RFC3339), other.EndDate.Format(time.RFC3339)))
+	}
+
+	return nil
+}
+
+func validate(discount *types.ProductDiscount, product *types.Product) error {
+	if !discount.EndDate.After(discount.StartDate) {
+		return apperrors.NewValidationError("endDate", "end date must be after start date")
+	}
+
+	switch discount.Type {
+	case types.DiscountFixedPrice:
+		if discount.FixedPrice == nil || *discount.FixedPrice < 0 {
+			return apperrors.NewValidationError("fixedPrice", "a fixed price discount needs a price of at least 0")
+		}
+		if *discount.FixedPrice >= product.BasePrice {
+			return apperrors.NewValidationError("fixedPrice", "fixed price must be below the product price")
+		}
+		return nil
+	case types.DiscountBuyXGetY:
+		if discount.BuyQuantity == nil || *discount.BuyQuantity <= 0 {
+			return apperrors.NewValidationError("buyQuantity", "buy quantity must be greater than 0")
+		}
+		if discount.GetQuantity == nil || *discount.GetQuantity <= 0 {
+			return apperrors.NewValidationError("getQuantity", "get quantity must be greater than 0")
+		}
+	case types.DiscountPercentage:
+	default:
+		return apperrors.NewValidationError("type", fmt.Sprintf("unknown discount type %q", discount.Type))
+	}
+
+	if discount.DiscountPercent <= 0 || discount.DiscountPercent > 100 {
+		return apperrors.NewValidationError("discountPercent", "discount percent must be greater than 0 and at most 100")
+	}
+
+	return nil
+}
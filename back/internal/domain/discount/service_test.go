@@ -0,0 +1,340 @@
+package discount
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDiscountStore struct {
+	mock.Mock
+}
+
+func (m *MockDiscountStore) CreateDiscount(payload *types.CreateProductDiscountPayload) (*types.ProductDiscount, error) {
+	args := m.Called(payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) UpdateDiscount(discountID int, payload *types.UpdateProductDiscountPayload) (*types.ProductDiscount, error) {
+	args := m.Called(discountID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) DeleteDiscount(discountID int) error {
+	args := m.Called(discountID)
+	return args.Error(0)
+}
+
+func (m *MockDiscountStore) GetDiscoutsByID(discountID int) (*types.ProductDiscount, error) {
+	args := m.Called(discountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetDiscountsByProduct(productID int) ([]*types.ProductDiscount, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetActiveDiscounts(productID int) ([]*types.ProductDiscount, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetActiveDiscountsForProducts(productIDs []int) (map[int][]*types.ProductDiscount, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetDiscountsByDateRange(productID int, start time.Time, end time.Time) ([]*types.ProductDiscount, error) {
+	args := m.Called(productID, start, end)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetDiscountCalendar(start time.Time, end time.Time) ([]*types.CalendarDiscount, error) {
+	args := m.Called(start, end)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.CalendarDiscount), args.Error(1)
+}
+
+type MockListingPricer struct {
+	mock.Mock
+}
+
+func (m *MockListingPricer) RefreshPrices() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockListingPricer) RefreshProductPrices(productIDs []int) error {
+	args := m.Called(productIDs)
+	return args.Error(0)
+}
+
+type MockProductStore struct {
+	types.ProductStore
+	mock.Mock
+}
+
+func (m *MockProductStore) GetProductByID(productID int) (*types.Product, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Product), args.Error(1)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestServiceCreateDiscount(t *testing.T) {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		payload       *types.CreateProductDiscountPayload
+		existing      []*types.ProductDiscount
+		expectedError error
+	}{
+		{
+			name:    "Percentage discount",
+			payload: &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 20, StartDate: start, EndDate: end},
+		},
+		{
+			name:    "Fixed price discount",
+			payload: &types.CreateProductDiscountPayload{ProductID: 1, Type: types.DiscountFixedPrice, FixedPrice: floatPtr(39.9), StartDate: start, EndDate: end},
+		},
+		{
+			name:          "End date before start date",
+			payload:       &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 20, StartDate: end, EndDate: start},
+			expectedError: apperrors.NewValidationError("endDate", "end date must be after start date"),
+		},
+		{
+			name:          "Percent above 100",
+			payload:       &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 150, StartDate: start, EndDate: end},
+			expectedError: apperrors.NewValidationError("discountPercent", "discount percent must be greater than 0 and at most 100"),
+		},
+		{
+			name:          "Fixed price above the product price",
+			payload:       &types.CreateProductDiscountPayload{ProductID: 1, Type: types.DiscountFixedPrice, FixedPrice: floatPtr(60), StartDate: start, EndDate: end},
+			expectedError: apperrors.NewValidationError("fixedPrice", "fixed price must be below the product price"),
+		},
+		{
+			name: "Buy X get Y without a get quantity",
+			payload: &types.CreateProductDiscountPayload{
+				ProductID: 1, Type: types.DiscountBuyXGetY, DiscountPercent: 100, BuyQuantity: intPtr(2), StartDate: start, EndDate: end,
+			},
+			expectedError: apperrors.NewValidationError("getQuantity", "get quantity must be greater than 0"),
+		},
+		{
+			name:     "Overlaps another discount",
+			payload:  &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 20, StartDate: start, EndDate: end},
+			existing: []*types.ProductDiscount{{ID: 3, ProductID: 1, DiscountPercent: 10, StartDate: start.AddDate(0, 0, 5), EndDate: end.AddDate(0, 0, 5)}},
+			expectedError: apperrors.NewConflictError("startDate",
+				"overlaps discount 3, running from 2025-05-06T00:00:00Z to 2025-05-15T00:00:00Z"),
+		},
+		{
+			name:     "Overlaps a stackable discount while being stackable",
+			payload:  &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 20, Stackable: true, StartDate: start, EndDate: end},
+			existing: []*types.ProductDiscount{{ID: 3, ProductID: 1, DiscountPercent: 10, Stackable: true, StartDate: start, EndDate: end}},
+		},
+		{
+			name:     "Starts when another discount ends",
+			payload:  &types.CreateProductDiscountPayload{ProductID: 1, DiscountPercent: 20, StartDate: start, EndDate: end},
+			existing: []*types.ProductDiscount{{ID: 3, ProductID: 1, DiscountPercent: 10, StartDate: start.AddDate(0, 0, -5), EndDate: start}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockDiscountStore)
+			mockProductStore := new(MockProductStore)
+			mockPricer := new(MockListingPricer)
+			service := NewService(mockStore, mockProductStore, mockPricer)
+
+			mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 50.0}, nil)
+			mockStore.On("GetDiscountsByDateRange", 1, tt.payload.StartDate, tt.payload.EndDate).Return(tt.existing, nil).Maybe()
+			mockStore.On("CreateDiscount", tt.payload).Return(&types.ProductDiscount{ID: 9, ProductID: 1}, nil).Maybe()
+			mockPricer.On("RefreshProductPrices", []int{1}).Return(nil).Maybe()
+
+			discount, err := service.CreateDiscount(tt.payload)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, discount)
+				mockStore.AssertNotCalled(t, "CreateDiscount", mock.Anything)
+				mockPricer.AssertNotCalled(t, "RefreshProductPrices", mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 9, discount.ID)
+				mockStore.AssertCalled(t, "CreateDiscount", tt.payload)
+				mockPricer.AssertCalled(t, "RefreshProductPrices", []int{1})
+			}
+		})
+	}
+
+	t.Run("Product not found", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		mockProductStore := new(MockProductStore)
+		service := NewService(mockStore, mockProductStore, new(MockListingPricer))
+		mockProductStore.On("GetProductByID", 99).Return(nil, fmt.Errorf("product not found"))
+
+		discount, err := service.CreateDiscount(&types.CreateProductDiscountPayload{ProductID: 99, DiscountPercent: 20, StartDate: start, EndDate: end})
+
+		assert.Equal(t, apperrors.NewEntityNotFound("product", 99), err)
+		assert.Nil(t, discount)
+	})
+}
+
+func TestServiceUpdateDiscount(t *testing.T) {
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+	current := &types.ProductDiscount{ID: 1, ProductID: 1, Type: types.DiscountPercentage, DiscountPercent: 20, StartDate: start, EndDate: end}
+
+	t.Run("Extending into another discount", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		mockProductStore := new(MockProductStore)
+		service := NewService(mockStore, mockProductStore, new(MockListingPricer))
+
+		newEnd := end.AddDate(0, 0, 10)
+		mockStore.On("GetDiscoutsByID", 1).Return(current, nil)
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 50.0}, nil)
+		mockStore.On("GetDiscountsByDateRange", 1, start, newEnd).Return([]*types.ProductDiscount{
+			current,
+			{ID: 2, ProductID: 1, DiscountPercent: 10, StartDate: end.AddDate(0, 0, 5), EndDate: end.AddDate(0, 0, 15)},
+		}, nil)
+
+		discount, err := service.UpdateDiscount(1, &types.UpdateProductDiscountPayload{EndDate: &newEnd})
+
+		assert.Equal(t, apperrors.NewConflictError("startDate",
+			"overlaps discount 2, running from 2025-05-15T00:00:00Z to 2025-05-25T00:00:00Z"), err)
+		assert.Nil(t, discount)
+		mockStore.AssertNotCalled(t, "UpdateDiscount", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Checks the discount as updated", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		mockProductStore := new(MockProductStore)
+		service := NewService(mockStore, mockProductStore, new(MockListingPricer))
+
+		newStart := end.AddDate(0, 0, 1)
+		mockStore.On("GetDiscoutsByID", 1).Return(current, nil)
+		mockProductStore.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 50.0}, nil)
+
+		discount, err := service.UpdateDiscount(1, &types.UpdateProductDiscountPayload{StartDate: &newStart})
+
+		assert.Equal(t, apperrors.NewValidationError("endDate", "end date must be after start date"), err)
+		assert.Nil(t, discount)
+	})
+
+	t.Run("Discount not found", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		service := NewService(mockStore, new(MockProductStore), new(MockListingPricer))
+		mockStore.On("GetDiscoutsByID", 5).Return(nil, fmt.Errorf("failed to scan discount: %w", sql.ErrNoRows))
+
+		discount, err := service.UpdateDiscount(5, &types.UpdateProductDiscountPayload{})
+
+		assert.Equal(t, apperrors.NewEntityNotFound("discount", 5), err)
+		assert.Nil(t, discount)
+	})
+}
+
+func TestServiceDeleteDiscount(t *testing.T) {
+	t.Run("Refreshes the price of the product", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		mockPricer := new(MockListingPricer)
+		service := NewService(mockStore, new(MockProductStore), mockPricer)
+		mockStore.On("GetDiscoutsByID", 1).Return(&types.ProductDiscount{ID: 1, ProductID: 4}, nil)
+		mockStore.On("DeleteDiscount", 1).Return(nil)
+		mockPricer.On("RefreshProductPrices", []int{4}).Return(nil)
+
+		err := service.DeleteDiscount(1)
+
+		assert.NoError(t, err)
+		mockStore.AssertExpectations(t)
+		mockPricer.AssertExpectations(t)
+	})
+
+	t.Run("Discount not found", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		service := NewService(mockStore, new(MockProductStore), new(MockListingPricer))
+		mockStore.On("GetDiscoutsByID", 5).Return(nil, fmt.Errorf("failed to scan discount: %w", sql.ErrNoRows))
+
+		err := service.DeleteDiscount(5)
+
+		assert.Equal(t, apperrors.NewEntityNotFound("discount", 5), err)
+		mockStore.AssertNotCalled(t, "DeleteDiscount", mock.Anything)
+	})
+}
+
+func TestServiceGetDiscountCalendar(t *testing.T) {
+	now := time.Now()
+	start := now.AddDate(0, -1, 0)
+	end := now.AddDate(0, 1, 0)
+
+	expired := &types.CalendarDiscount{ProductDiscount: &types.ProductDiscount{ID: 1, StartDate: now.AddDate(0, 0, -20), EndDate: now.AddDate(0, 0, -10)}}
+	active := &types.CalendarDiscount{ProductDiscount: &types.ProductDiscount{ID: 2, StartDate: now.AddDate(0, 0, -5), EndDate: now.AddDate(0, 0, 5)}}
+	upcoming := &types.CalendarDiscount{ProductDiscount: &types.ProductDiscount{ID: 3, StartDate: now.AddDate(0, 0, 10), EndDate: now.AddDate(0, 0, 20)}}
+
+	t.Run("Groups the discounts by when they run", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		service := NewService(mockStore, new(MockProductStore), new(MockListingPricer))
+		mockStore.On("GetDiscountCalendar", start, end).Return([]*types.CalendarDiscount{expired, active, upcoming}, nil)
+
+		calendar, err := service.GetDiscountCalendar(start, end)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &types.DiscountCalendar{
+			Start:    start,
+			End:      end,
+			Upcoming: []*types.CalendarDiscount{upcoming},
+			Active:   []*types.CalendarDiscount{active},
+			Expired:  []*types.CalendarDiscount{expired},
+		}, calendar)
+	})
+
+	t.Run("End before start", func(t *testing.T) {
+		mockStore := new(MockDiscountStore)
+		service := NewService(mockStore, new(MockProductStore), new(MockListingPricer))
+
+		calendar, err := service.GetDiscountCalendar(end, start)
+
+		assert.Equal(t, apperrors.NewValidationError("end", "end must be after start"), err)
+		assert.Nil(t, calendar)
+		mockStore.AssertNotCalled(t, "GetDiscountCalendar", mock.Anything, mock.Anything)
+	})
+}
@@ -5,9 +5,20 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"strings"
 	"time"
 )
 
+const discountColumns = `
+	id, productId, discountType, discountPercent, fixedPrice, buyQuantity, getQuantity,
+	stackable, priority, startDate, endDate, createdAt
+`
+
+const prefixedDiscountColumns = `
+	d.id, d.productId, d.discountType, d.discountPercent, d.fixedPrice, d.buyQuantity, d.getQuantity,
+	d.stackable, d.priority, d.startDate, d.endDate, d.createdAt
+`
+
 type Store struct {
 	db *sql.DB
 }
@@ -19,15 +30,27 @@ func NewStore(db *sql.DB) *Store {
 func (s *Store) CreateDiscount(payload *types.CreateProductDiscountPayload) (*types.ProductDiscount, error) {
 	query := `
 		INSERT INTO product_discounts
-			(productId, discountPercent, startDate, endDate)
-		Values (?, ?, ?, ?)
+			(productId, discountType, discountPercent, fixedPrice, buyQuantity, getQuantity,
+			 stackable, priority, startDate, endDate)
+		Values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	discountType := payload.Type
+	if discountType == "" {
+		discountType = types.DiscountPercentage
+	}
+
 	res, err := s.db.ExecContext(
 		context.Background(),
 		query,
 		payload.ProductID,
+		discountType,
 		payload.DiscountPercent,
+		payload.FixedPrice,
+		payload.BuyQuantity,
+		payload.GetQuantity,
+		payload.Stackable,
+		payload.Priority,
 		payload.StartDate,
 		payload.EndDate,
 	)
@@ -44,18 +67,29 @@ func (s *Store) CreateDiscount(payload *types.CreateProductDiscountPayload) (*ty
 	return discount, nil
 }
 
+// UpdateDiscount only changes the fields set in payload.
 func (s *Store) UpdateDiscount(discountID int, payload *types.UpdateProductDiscountPayload) (*types.ProductDiscount, error) {
 	query := `
 		UPDATE product_discounts SET
-			discountPercent = ?,
-			startDate = ?,
-			endDate = ?
+			discountPercent = COALESCE(?, discountPercent),
+			fixedPrice = COALESCE(?, fixedPrice),
+			buyQuantity = COALESCE(?, buyQuantity),
+			getQuantity = COALESCE(?, getQuantity),
+			stackable = COALESCE(?, stackable),
+			priority = COALESCE(?, priority),
+			startDate = COALESCE(?, startDate),
+			endDate = COALESCE(?, endDate)
 		WHERE id = ?
 	`
 
 	_, err := s.db.Exec(
 		query,
 		payload.DiscountPercent,
+		payload.FixedPrice,
+		payload.BuyQuantity,
+		payload.GetQuantity,
+		payload.Stackable,
+		payload.Priority,
 		payload.StartDate,
 		payload.EndDate,
 		discountID,
@@ -104,7 +138,7 @@ func (s *Store) DeleteDiscount(discountID int) error {
 
 func (s *Store) GetDiscoutsByID(discoutId int) (*types.ProductDiscount, error) {
 	query := `
-		SELECT id, productId, discountPercent, startDate, endDate, createdAt
+		SELECT ` + discountColumns + `
 		FROM product_discounts
 		WHERE id = ?
 	`
@@ -116,7 +150,7 @@ func (s *Store) GetDiscoutsByID(discoutId int) (*types.ProductDiscount, error) {
 
 func (s *Store) GetDiscountsByProduct(productID int) ([]*types.ProductDiscount, error) {
 	query := `
-		SELECT id, productId, discountPercent, startDate, endDate, createdAt
+		SELECT ` + discountColumns + `
 		FROM product_discounts
 		WHERE productId = ?
 		ORDER BY startDate DESC
@@ -137,31 +171,143 @@ func (s *Store) GetDiscountsByProduct(productID int) ([]*types.ProductDiscount,
 }
 
 func (s *Store) GetActiveDiscounts(productID int) ([]*types.ProductDiscount, error) {
-	query := `
-		SELECT id, productId, discountPercent, startDate, endDate, createdAt
+	discounts, err := s.GetActiveDiscountsForProducts([]int{productID})
+	if err != nil {
+		return nil, err
+	}
+
+	return discounts[productID], nil
+}
+
+func (s *Store) GetActiveDiscountsForProducts(productIDs []int) (map[int][]*types.ProductDiscount, error) {
+	discounts := make(map[int][]*types.ProductDiscount)
+	if len(productIDs) == 0 {
+		return discounts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(productIDs)), ",")
+	args := make([]interface{}, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM product_discounts
-		WHERE productId = ?
+		WHERE productId IN (%s)
 		AND startDate <= NOW()
 		AND endDate >= NOW()
-	`
+	`, discountColumns, placeholders)
 
-	rows, err := s.db.Query(query, productID)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query discounts: %w", err)
 	}
 	defer rows.Close()
 
-	discounts, err := scanRowsIntoDiscount(rows)
+	active, err := scanRowsIntoDiscount(rows)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan discounts: %w", err)
 	}
 
+	campaigns, err := s.getActiveCampaignDiscounts(placeholders, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, discount := range append(active, campaigns...) {
+		discounts[discount.ProductID] = append(discounts[discount.ProductID], discount)
+	}
+
 	return discounts, nil
 }
 
+// getActiveCampaignDiscounts turns the running campaigns into a discount for
+// each of the products they target. A category campaign reaches the whole
+// subtree below its category; UNION rather than UNION ALL stops the walk
+// should the categories ever loop.
+func (s *Store) getActiveCampaignDiscounts(placeholders string, productIDs []interface{}) ([]*types.ProductDiscount, error) {
+	query := fmt.Sprintf(`
+		WITH RECURSIVE campaign_categories (campaignId, categoryId) AS (
+			SELECT id, categoryId
+			FROM campaigns
+			WHERE scope = 'CATEGORY'
+			AND startDate <= NOW()
+			AND endDate >= NOW()
+			UNION
+			SELECT cc.campaignId, c.id
+			FROM categories c
+			JOIN campaign_categories cc ON c.parentCategoryId = cc.categoryId
+		)
+		SELECT
+			c.id, c.name, p.id, c.discountType, c.discountPercent, c.buyQuantity, c.getQuantity,
+			c.stackable, c.priority, c.startDate, c.endDate, c.createdAt
+		FROM campaigns c
+		JOIN products p ON p.id IN (%s)
+		WHERE c.startDate <= NOW()
+		AND c.endDate >= NOW()
+		AND (
+			c.scope = 'STORE'
+			OR (c.scope = 'PRODUCTS' AND EXISTS (
+				SELECT 1 FROM campaign_products cp
+				WHERE cp.campaignId = c.id AND cp.productId = p.id
+			))
+			OR (c.scope = 'CATEGORY' AND EXISTS (
+				SELECT 1 FROM campaign_categories cc
+				JOIN product_categories pc ON pc.categoryId = cc.categoryId
+				WHERE cc.campaignId = c.id AND pc.productId = p.id
+			))
+		)
+	`, placeholders)
+
+	rows, err := s.db.Query(query, productIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign discounts: %w", err)
+	}
+	defer rows.Close()
+
+	var discounts []*types.ProductDiscount
+	for rows.Next() {
+		discount := new(types.ProductDiscount)
+		var campaignID int
+		var buyQuantity, getQuantity sql.NullInt64
+
+		err := rows.Scan(
+			&campaignID,
+			&discount.CampaignName,
+			&discount.ProductID,
+			&discount.Type,
+			&discount.DiscountPercent,
+			&buyQuantity,
+			&getQuantity,
+			&discount.Stackable,
+			&discount.Priority,
+			&discount.StartDate,
+			&discount.EndDate,
+			&discount.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign discount: %w", err)
+		}
+
+		discount.CampaignID = &campaignID
+		if buyQuantity.Valid {
+			n := int(buyQuantity.Int64)
+			discount.BuyQuantity = &n
+		}
+		if getQuantity.Valid {
+			n := int(getQuantity.Int64)
+			discount.GetQuantity = &n
+		}
+		discounts = append(discounts, discount)
+	}
+
+	return discounts, rows.Err()
+}
+
 func (s *Store) GetDiscountsByDateRange(productID int, start time.Time, end time.Time) ([]*types.ProductDiscount, error) {
 	query := `
-		SELECT id, productId, discountPercent, startDate, endDate, createdAt
+		SELECT ` + discountColumns + `
 		FROM product_discounts
 		WHERE productId = ?
 		AND (
@@ -191,41 +337,98 @@ func (s *Store) GetDiscountsByDateRange(productID int, start time.Time, end time
 	return discounts, nil
 }
 
+func (s *Store) GetDiscountCalendar(start time.Time, end time.Time) ([]*types.CalendarDiscount, error) {
+	query := `
+		SELECT ` + prefixedDiscountColumns + `, p.title
+		FROM product_discounts d
+		JOIN products p ON p.id = d.productId
+		WHERE d.startDate <= ?
+		AND d.endDate >= ?
+		ORDER BY d.startDate, d.id
+	`
+
+	rows, err := s.db.Query(query, end, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discounts: %w", err)
+	}
+	defer rows.Close()
+
+	calendar := []*types.CalendarDiscount{}
+	for rows.Next() {
+		entry := new(types.CalendarDiscount)
+		entry.ProductDiscount, err = scanDiscount(rows, &entry.ProductTitle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan discount: %w", err)
+		}
+		calendar = append(calendar, entry)
+	}
+
+	return calendar, nil
+}
+
 func scanRowsIntoDiscount(rows *sql.Rows) ([]*types.ProductDiscount, error) {
 	var discounts []*types.ProductDiscount
 
 	for rows.Next() {
-		var discount types.ProductDiscount
-		err := rows.Scan(
-			&discount.ID,
-			&discount.ProductID,
-			&discount.DiscountPercent,
-			&discount.StartDate,
-			&discount.EndDate,
-			&discount.CreatedAt,
-		)
+		discount, err := scanDiscount(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan discount: %w", err)
 		}
-		discounts = append(discounts, &discount)
+		discounts = append(discounts, discount)
 	}
 
 	return discounts, nil
 }
 
 func scanRowIntoDiscount(row *sql.Row) (*types.ProductDiscount, error) {
+	discount, err := scanDiscount(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan discount: %w", err)
+	}
+
+	return discount, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDiscount reads the discount columns and then into extra, for the
+// columns a query selects after them.
+func scanDiscount(row scanner, extra ...interface{}) (*types.ProductDiscount, error) {
 	discount := new(types.ProductDiscount)
+	var fixedPrice sql.NullFloat64
+	var buyQuantity, getQuantity sql.NullInt64
 
-	err := row.Scan(
+	dest := []interface{}{
 		&discount.ID,
 		&discount.ProductID,
+		&discount.Type,
 		&discount.DiscountPercent,
+		&fixedPrice,
+		&buyQuantity,
+		&getQuantity,
+		&discount.Stackable,
+		&discount.Priority,
 		&discount.StartDate,
 		&discount.EndDate,
 		&discount.CreatedAt,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan discount: %w", err)
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	if fixedPrice.Valid {
+		discount.FixedPrice = &fixedPrice.Float64
+	}
+	if buyQuantity.Valid {
+		n := int(buyQuantity.Int64)
+		discount.BuyQuantity = &n
+	}
+	if getQuantity.Valid {
+		n := int(getQuantity.Int64)
+		discount.GetQuantity = &n
 	}
 
 	return discount, nil
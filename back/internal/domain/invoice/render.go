@@ -0,0 +1,231 @@
+package invoice
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+const (
+	margin     = 50.0
+	fontSize   = 9.0
+	lineHeight = 13.0
+	// the item table is set in Courier, so its columns line up by counting
+	// characters: 90 of them fill the width between the margins
+	descriptionWidth = 39
+	amountWidth      = 14
+	tableWidth       = 90
+)
+
+// invoiceLayout writes the lines of an invoice top to bottom, moving to a
+// new page when one fills up.
+type invoiceLayout struct {
+	doc *pdfDocument
+	y   float64
+}
+
+func newInvoiceLayout() *invoiceLayout {
+	l := &invoiceLayout{doc: &pdfDocument{}}
+	l.newPage()
+	return l
+}
+
+func (l *invoiceLayout) newPage() {
+	l.doc.newPage()
+	l.y = pageHeight - margin
+}
+
+// next moves down by height and returns the baseline to write at.
+func (l *invoiceLayout) next(height float64) float64 {
+	if l.y-height < margin {
+		l.newPage()
+	}
+	l.y -= height
+	return l.y
+}
+
+func (l *invoiceLayout) write(font pdfFont, size float64, s string) {
+	l.doc.text(margin, l.next(size+lineHeight-fontSize), font, size, s)
+}
+
+func (l *invoiceLayout) space() {
+	l.next(lineHeight / 2)
+}
+
+func (l *invoiceLayout) rule() {
+	y := l.next(lineHeight / 2)
+	l.doc.line(margin, y, pageWidth-margin, y)
+	l.space()
+}
+
+// renderInvoice draws invoice as issued by issuer for order: who sold and
+// who bought, where it ships, each line of the order and the totals.
+func renderInvoice(issuer types.InvoiceIssuer, invoice *types.Invoice, order *types.OrderDetails) []byte {
+	l := newInvoiceLayout()
+
+	l.write(fontBold, 16, issuer.Name)
+	if issuer.CNPJ != "" {
+		l.write(fontRegular, fontSize, "CNPJ "+issuer.CNPJ)
+	}
+	if issuer.Address != "" {
+		l.write(fontRegular, fontSize, issuer.Address)
+	}
+	l.space()
+
+	l.write(fontBold, 12, fmt.Sprintf("INVOICE No. %06d", invoice.Number))
+	l.write(fontRegular, fontSize, fmt.Sprintf("Issued on %s", invoice.IssuedAt.UTC().Format("02/01/2006 15:04 MST")))
+	l.write(fontRegular, fontSize, fmt.Sprintf("Order #%d placed on %s, paid by %s", order.Order.ID,
+		order.Order.CreatedAt.UTC().Format("02/01/2006"), paymentMethodName(order.Order.PaymentMethod)))
+	l.rule()
+
+	l.write(fontBold, fontSize, "CUSTOMER")
+	if order.Customer != nil {
+		l.write(fontRegular, fontSize, order.Customer.FullName)
+		l.write(fontRegular, fontSize, "CPF "+formatCPF(order.Customer.Cpf))
+		l.write(fontRegular, fontSize, order.Customer.Email)
+	}
+	if address := order.Order.ShippingAddress; address != nil {
+		l.space()
+		l.write(fontBold, fontSize, "SHIP TO")
+		l.write(fontRegular, fontSize, address.Street)
+		l.write(fontRegular, fontSize, fmt.Sprintf("%s - %s, CEP %s, %s", address.City, address.State, address.PostalCode, address.Country))
+	}
+	l.rule()
+
+	l.write(fontMonoBold, fontSize, fmt.Sprintf("%-*s %5s %*s %*s %*s", descriptionWidth, "DESCRIPTION", "QTY",
+		amountWidth, "UNIT PRICE", amountWidth, "DISCOUNT", amountWidth, "AMOUNT"))
+	subtotal := 0.0
+	for _, item := range order.Items {
+		// orders placed before the base price was kept have it at zero
+		unitPrice := max(item.BasePrice, item.Price)
+		gross := utils.RoundCents(unitPrice * float64(item.Quantity))
+		amount := utils.RoundCents(item.Price*float64(item.Quantity) - item.Discount)
+		subtotal += gross
+
+		discount := ""
+		if lineDiscount := utils.RoundCents(gross - amount); lineDiscount > 0 {
+			discount = formatBRL(-lineDiscount)
+		}
+
+		description := item.ProductTitle
+		if item.VariantLabel != "" {
+			description = fmt.Sprintf("%s (%s)", description, item.VariantLabel)
+		}
+
+		lines := wrapText(description, descriptionWidth)
+		l.write(fontMono, fontSize, fmt.Sprintf("%-*s %5d %*s %*s %*s", descriptionWidth, lines[0], item.Quantity,
+			amountWidth, formatBRL(unitPrice), amountWidth, discount, amountWidth, formatBRL(amount)))
+		for _, line := range lines[1:] {
+			l.write(fontMono, fontSize, line)
+		}
+	}
+	subtotal = utils.RoundCents(subtotal)
+	l.rule()
+
+	total := func(font pdfFont, label string, amount float64) {
+		l.write(font, fontSize, fmt.Sprintf("%*s %16s", tableWidth-17, label, formatBRL(amount)))
+	}
+
+	total(fontMono, "Subtotal", subtotal)
+	// the discounts of the lines and any rounding of the coupon across them
+	// are all that set the total apart from the subtotal and shipping
+	if discount := utils.RoundCents(subtotal + order.Order.ShippingCost - order.Order.TotalAmount); discount > 0 {
+		total(fontMono, "Discount", -discount)
+	}
+	shipping := "Shipping"
+	if order.Order.ShippingService != "" {
+		shipping = fmt.Sprintf("Shipping (%s)", order.Order.ShippingService)
+	}
+	total(fontMono, shipping, order.Order.ShippingCost)
+	total(fontMonoBold, "TOTAL", order.Order.TotalAmount)
+
+	l.space()
+	l.write(fontRegular, 7, "This invoice is issued for the customer's records and does not replace the electronic nota fiscal (NF-e).")
+
+	return l.doc.bytes()
+}
+
+// wrapText breaks s into lines of at most width characters, between words
+// where it can.
+func wrapText(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for utf8.RuneCountInString(word) > width {
+			runes := []rune(word)
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	return append(lines, line)
+}
+
+// formatBRL writes amount in reais, as in R$ 1.234,56.
+func formatBRL(amount float64) string {
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	digits := fmt.Sprintf("%d", cents/100)
+
+	var reais strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			reais.WriteByte('.')
+		}
+		reais.WriteRune(digit)
+	}
+
+	sign := ""
+	if amount < 0 && cents > 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, reais.String(), cents%100)
+}
+
+// formatCPF writes the 11 digits of a CPF as 123.456.789-09, leaving
+// anything else as it is.
+func formatCPF(cpf string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, cpf)
+	if len(digits) != 11 {
+		return cpf
+	}
+
+	return fmt.Sprintf("%s.%s.%s-%s", digits[:3], digits[3:6], digits[6:9], digits[9:])
+}
+
+func paymentMethodName(method types.PaymentMethod) string {
+	switch method {
+	case types.PaymentCreditCard:
+		return "credit card"
+	case types.PaymentDebitCard:
+		return "debit card"
+	case types.PaymentPix:
+		return "PIX"
+	case types.PaymentBankTransfer:
+		return "bank transfer"
+	default:
+		return string(method)
+	}
+}
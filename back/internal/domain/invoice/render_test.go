@@ -0,0 +1,161 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testIssuer = types.InvoiceIssuer{Name: "Loja Exemplo", CNPJ: "12.345.678/0001-90", Address: "Rua Augusta, 500 - São Paulo/SP"}
+
+func testOrder(items ...*types.OrderItem) *types.OrderDetails {
+	return &types.OrderDetails{
+		OrderWithItems: types.OrderWithItems{
+			Order: types.OrderHistory{
+				ID:            42,
+				UserID:        7,
+				TotalAmount:   1229.5,
+				Status:        types.OrderPaid,
+				PaymentMethod: types.PaymentPix,
+				ShippingAddress: &types.ShippingAddress{
+					Street: "Avenida Paulista, 1000", City: "São Paulo", State: "SP", PostalCode: "01310-100", Country: "Brasil",
+				},
+				ShippingService: "SEDEX",
+				ShippingCost:    25,
+				CreatedAt:       time.Date(2025, 5, 10, 15, 0, 0, 0, time.UTC),
+			},
+			Items: items,
+		},
+		Customer: &types.OrderCustomer{ID: 7, FullName: "João Silva", Email: "joao@example.com", Cpf: "12345678909"},
+	}
+}
+
+// pdfText is how s shows up in the content streams of the PDF.
+func pdfText(s string) []byte {
+	return []byte(pdfString(s))
+}
+
+func TestRenderInvoice(t *testing.T) {
+	order := testOrder(
+		&types.OrderItem{ProductID: 1, ProductTitle: "Camiseta Básica", VariantLabel: "Azul / M", Quantity: 2, BasePrice: 59.9, Price: 49.9},
+		&types.OrderItem{ProductID: 2, ProductTitle: "Tênis (edição limitada)", Quantity: 1, BasePrice: 1129.7, Price: 1129.7, Discount: 25},
+	)
+	invoice := &types.Invoice{Number: 123, OrderID: 42, IssuedAt: time.Date(2025, 5, 14, 9, 30, 0, 0, time.UTC)}
+
+	pdf := renderInvoice(testIssuer, invoice, order)
+
+	assertValidPDF(t, pdf, 1)
+	for _, expected := range []string{
+		"Loja Exemplo",
+		"INVOICE No. 000123",
+		"Issued on 14/05/2025 09:30 UTC",
+		"Order #42 placed on 10/05/2025, paid by PIX",
+		"João Silva",
+		"CPF 123.456.789-09",
+		"São Paulo - SP, CEP 01310-100, Brasil",
+		// 2 x 59,90 with 10,00 off each
+		fmt.Sprintf("%-*s %5d %*s %*s %*s", descriptionWidth, "Camiseta Básica (Azul / M)", 2,
+			amountWidth, "R$ 59,90", amountWidth, "-R$ 20,00", amountWidth, "R$ 99,80"),
+		// the coupon took 25,00 off the line
+		fmt.Sprintf("%-*s %5d %*s %*s %*s", descriptionWidth, "Tênis (edição limitada)", 1,
+			amountWidth, "R$ 1.129,70", amountWidth, "-R$ 25,00", amountWidth, "R$ 1.104,70"),
+		// 2 x 59,90 + 1.129,70
+		fmt.Sprintf("%*s %16s", tableWidth-17, "Subtotal", "R$ 1.249,50"),
+		fmt.Sprintf("%*s %16s", tableWidth-17, "Discount", "-R$ 45,00"),
+		fmt.Sprintf("%*s %16s", tableWidth-17, "Shipping (SEDEX)", "R$ 25,00"),
+		fmt.Sprintf("%*s %16s", tableWidth-17, "TOTAL", "R$ 1.229,50"),
+	} {
+		assert.True(t, bytes.Contains(pdf, pdfText(expected)), "missing %q", expected)
+	}
+}
+
+func TestRenderInvoiceWithoutDiscount(t *testing.T) {
+	order := testOrder(&types.OrderItem{ProductID: 1, ProductTitle: "Camiseta", Quantity: 1, BasePrice: 1204.5, Price: 1204.5})
+
+	pdf := renderInvoice(testIssuer, &types.Invoice{Number: 1}, order)
+
+	assert.False(t, bytes.Contains(pdf, pdfText("Discount")))
+	assert.True(t, bytes.Contains(pdf, pdfText(fmt.Sprintf("%-*s %5d %*s %*s %*s", descriptionWidth, "Camiseta", 1,
+		amountWidth, "R$ 1.204,50", amountWidth, "", amountWidth, "R$ 1.204,50"))))
+}
+
+func TestRenderInvoiceBreaksPages(t *testing.T) {
+	var items []*types.OrderItem
+	for i := 1; i <= 80; i++ {
+		items = append(items, &types.OrderItem{ProductID: i, ProductTitle: fmt.Sprintf("Produto %d", i), Quantity: 1, Price: 10})
+	}
+
+	pdf := renderInvoice(testIssuer, &types.Invoice{Number: 1}, testOrder(items...))
+
+	assertValidPDF(t, pdf, 2)
+	assert.True(t, bytes.Contains(pdf, pdfText("Produto 80")))
+}
+
+// assertValidPDF checks that pdf has the given number of pages and that its
+// cross-reference table points at each of its objects.
+func assertValidPDF(t *testing.T, pdf []byte, pages int) {
+	t.Helper()
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.True(t, bytes.Contains(pdf, []byte(fmt.Sprintf("/Count %d >>", pages))))
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if !assert.NotNil(t, match) {
+		return
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !assert.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n"))) {
+		return
+	}
+
+	entries := strings.Split(string(pdf[xref:]), "\n")[3:]
+	for i, entry := range entries {
+		if strings.HasPrefix(entry, "trailer") {
+			break
+		}
+		offset, _ := strconv.Atoi(entry[:10])
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d is not at %d", i+1, offset)
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	assert.Equal(t, "A\xe7\xe3o \\(1\\) \\\\ ?", pdfString("Ação (1) \\ 中"))
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, []string{"Camiseta"}, wrapText("Camiseta", 10))
+	assert.Equal(t, []string{"Camiseta", "Básica", "Azul"}, wrapText("Camiseta Básica Azul", 10))
+	assert.Equal(t, []string{"Superlongp", "alavra de", "algodão"}, wrapText("Superlongpalavra de algodão", 10))
+}
+
+func TestFormatBRL(t *testing.T) {
+	tests := map[float64]string{
+		0:          "R$ 0,00",
+		9.9:        "R$ 9,90",
+		1234.56:    "R$ 1.234,56",
+		1000000:    "R$ 1.000.000,00",
+		-25:        "-R$ 25,00",
+		0.004:      "R$ 0,00",
+		-0.001:     "R$ 0,00",
+		999.999:    "R$ 1.000,00",
+		123456.789: "R$ 123.456,79",
+	}
+
+	for amount, expected := range tests {
+		assert.Equal(t, expected, formatBRL(amount), "formatBRL(%v)", amount)
+	}
+}
+
+func TestFormatCPF(t *testing.T) {
+	assert.Equal(t, "123.456.789-09", formatCPF("12345678909"))
+	assert.Equal(t, "123.456.789-09", formatCPF("123.456.789-09"))
+	assert.Equal(t, "1234", formatCPF("1234"))
+}
@@ -0,0 +1,49 @@
+package invoice
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	invoiceService types.InvoiceService
+}
+
+func NewHandler(invoiceService types.InvoiceService) *Handler {
+	return &Handler{invoiceService: invoiceService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	authRouter := router.PathPrefix("").Subrouter()
+	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
+
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	authRouter.HandleFunc("/orders/{orderId}/invoice",
+		utils.Compose(h.getInvoice, middleware.ErrorHandler)).Methods("GET")
+}
+
+func (h *Handler) getInvoice(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	role := auth.GetUserRoleFromContext(r.Context())
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+
+	invoice, err := h.invoiceService.GetInvoice(orderID, userID, role)
+	if err != nil {
+		fmt.Printf("[INVOICE HANDLER] ERROR getting invoice of order %d: %v\n", orderID, err)
+		utils.WriteServiceError(w, err, "Failed to get invoice")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%06d.pdf"`, invoice.Number))
+	w.Header().Set("Content-Length", strconv.Itoa(len(invoice.PDF)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(invoice.PDF)
+}
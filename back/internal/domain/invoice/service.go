@@ -0,0 +1,109 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// invoicedStatuses are the statuses of the orders that were paid for and
+// not cancelled.
+var invoicedStatuses = []types.OrderStatus{
+	types.OrderPaid,
+	types.OrderShipped,
+	types.OrderDelivered,
+	types.OrderCompleted,
+}
+
+// invoiceBatch is how many orders IssuePendingInvoices issues at a time.
+const invoiceBatch = 100
+
+type Service struct {
+	store        types.InvoiceStore
+	orderService types.OrderService
+	issuer       types.InvoiceIssuer
+}
+
+func NewService(store types.InvoiceStore, orderService types.OrderService, issuer types.InvoiceIssuer) *Service {
+	return &Service{
+		store:        store,
+		orderService: orderService,
+		issuer:       issuer,
+	}
+}
+
+func (s *Service) GetInvoice(orderID int, userID int, role types.UserRole) (*types.Invoice, error) {
+	fmt.Printf("[INVOICE SERVICE] Getting invoice of order %d for user %d\n", orderID, userID)
+
+	invoice, err := s.store.GetInvoiceByOrderID(orderID)
+	if err != nil {
+		if !isNotFound(err) {
+			fmt.Printf("[INVOICE SERVICE] Error getting invoice: %v\n", err)
+		}
+		return nil, err
+	}
+
+	if !canAccess(invoice.UserID, userID, role) {
+		return nil, apperrors.NewForbiddenError("the order belongs to another user")
+	}
+
+	return invoice, nil
+}
+
+func (s *Service) IssuePendingInvoices() (int, error) {
+	orderIDs, err := s.store.GetUninvoicedOrderIDs(invoicedStatuses, invoiceBatch)
+	if err != nil {
+		fmt.Printf("[INVOICE SERVICE] Error getting orders to invoice: %v\n", err)
+		return 0, err
+	}
+
+	issued := 0
+	for _, orderID := range orderIDs {
+		if err := s.issueInvoice(orderID); err != nil {
+			fmt.Printf("[INVOICE SERVICE] Error issuing invoice of order %d: %v\n", orderID, err)
+			continue
+		}
+		issued++
+	}
+
+	return issued, nil
+}
+
+func (s *Service) issueInvoice(orderID int) error {
+	order, err := s.orderService.GetOrderDetails(orderID)
+	if err != nil {
+		return err
+	}
+
+	// the order may have been cancelled since it was picked
+	if !slices.Contains(invoicedStatuses, order.Order.Status) {
+		return fmt.Errorf("order in status %s has no invoice", order.Order.Status)
+	}
+
+	invoice := &types.Invoice{
+		OrderID:     orderID,
+		UserID:      order.Order.UserID,
+		TotalAmount: order.Order.TotalAmount,
+	}
+	err = s.store.CreateInvoice(invoice, func(invoice *types.Invoice) ([]byte, error) {
+		return renderInvoice(s.issuer, invoice, order), nil
+	})
+	if err != nil {
+		return fmt.Errorf("error creating invoice: %w", err)
+	}
+
+	fmt.Printf("[INVOICE SERVICE] Invoice %d issued for order %d\n", invoice.Number, orderID)
+	return nil
+}
+
+func canAccess(ownerID int, userID int, role types.UserRole) bool {
+	return ownerID == userID || role == types.RoleAdmin
+}
+
+func isNotFound(err error) bool {
+	var appErr *apperrors.AppError
+	return errors.As(err, &appErr) && appErr.Type == apperrors.NotFound
+}
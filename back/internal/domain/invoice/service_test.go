@@ -0,0 +1,171 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInvoiceStore struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceStore) GetInvoiceByOrderID(orderID int) (*types.Invoice, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceStore) GetUninvoicedOrderIDs(statuses []types.OrderStatus, limit int) ([]int, error) {
+	args := m.Called(statuses, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// CreateInvoice numbers the invoice 42 and renders it, as the store does
+// within its transaction.
+func (m *MockInvoiceStore) CreateInvoice(invoice *types.Invoice, render func(*types.Invoice) ([]byte, error)) error {
+	args := m.Called(invoice)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+
+	invoice.ID = 1
+	invoice.Number = 42
+	invoice.IssuedAt = time.Date(2025, 5, 14, 9, 30, 0, 0, time.UTC)
+	pdf, err := render(invoice)
+	if err != nil {
+		return err
+	}
+	invoice.PDF = pdf
+	return nil
+}
+
+type MockOrderService struct {
+	types.OrderService
+	mock.Mock
+}
+
+func (m *MockOrderService) GetOrderDetails(orderID int) (*types.OrderDetails, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.OrderDetails), args.Error(1)
+}
+
+func TestGetInvoice(t *testing.T) {
+	t.Run("Returns the invoice issued", func(t *testing.T) {
+		issued := &types.Invoice{ID: 1, Number: 3, OrderID: 42, UserID: 7, PDF: []byte("%PDF-1.4")}
+		store := new(MockInvoiceStore)
+		store.On("GetInvoiceByOrderID", 42).Return(issued, nil)
+		orderService := new(MockOrderService)
+
+		invoice, err := NewService(store, orderService, testIssuer).GetInvoice(42, 7, types.RoleUser)
+
+		assert.NoError(t, err)
+		assert.Same(t, issued, invoice)
+		store.AssertNotCalled(t, "CreateInvoice", mock.Anything)
+		orderService.AssertNotCalled(t, "GetOrderDetails", mock.Anything)
+	})
+
+	t.Run("Does not issue the invoice on request", func(t *testing.T) {
+		store := new(MockInvoiceStore)
+		store.On("GetInvoiceByOrderID", 42).Return(nil, apperrors.NewEntityNotFound("invoice", 42))
+		orderService := new(MockOrderService)
+
+		_, err := NewService(store, orderService, testIssuer).GetInvoice(42, 7, types.RoleUser)
+
+		var appErr *apperrors.AppError
+		assert.True(t, errors.As(err, &appErr))
+		assert.Equal(t, apperrors.NotFound, appErr.Type)
+		store.AssertNotCalled(t, "CreateInvoice", mock.Anything)
+		orderService.AssertNotCalled(t, "GetOrderDetails", mock.Anything)
+	})
+
+	t.Run("Hides the invoice of another user", func(t *testing.T) {
+		store := new(MockInvoiceStore)
+		store.On("GetInvoiceByOrderID", 42).Return(&types.Invoice{ID: 1, OrderID: 42, UserID: 7}, nil)
+
+		_, err := NewService(store, new(MockOrderService), testIssuer).GetInvoice(42, 8, types.RoleUser)
+
+		var appErr *apperrors.AppError
+		assert.True(t, errors.As(err, &appErr))
+		assert.Equal(t, apperrors.FORBIDDEN, appErr.Type)
+	})
+
+	t.Run("Admins get the invoice of any order", func(t *testing.T) {
+		store := new(MockInvoiceStore)
+		store.On("GetInvoiceByOrderID", 42).Return(&types.Invoice{ID: 1, OrderID: 42, UserID: 7}, nil)
+
+		invoice, err := NewService(store, new(MockOrderService), testIssuer).GetInvoice(42, 1, types.RoleAdmin)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 7, invoice.UserID)
+	})
+}
+
+func TestIssuePendingInvoices(t *testing.T) {
+	order := testOrder(&types.OrderItem{ProductID: 1, ProductTitle: "Camiseta", Quantity: 1, BasePrice: 1204.5, Price: 1204.5})
+
+	t.Run("Issues the invoices of the paid orders", func(t *testing.T) {
+		store := new(MockInvoiceStore)
+		store.On("GetUninvoicedOrderIDs", invoicedStatuses, invoiceBatch).Return([]int{42}, nil)
+		store.On("CreateInvoice", mock.MatchedBy(func(invoice *types.Invoice) bool {
+			return invoice.OrderID == 42 && invoice.UserID == 7 && invoice.TotalAmount == 1229.5
+		})).Return(nil)
+		orderService := new(MockOrderService)
+		orderService.On("GetOrderDetails", 42).Return(order, nil)
+
+		issued, err := NewService(store, orderService, testIssuer).IssuePendingInvoices()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, issued)
+		store.AssertExpectations(t)
+		invoice := store.Calls[1].Arguments.Get(0).(*types.Invoice)
+		assert.True(t, bytes.Contains(invoice.PDF, pdfText("INVOICE No. 000042")))
+	})
+
+	t.Run("Skips the orders cancelled since they were picked", func(t *testing.T) {
+		cancelled := testOrder()
+		cancelled.Order.Status = types.OrderCancelled
+		store := new(MockInvoiceStore)
+		store.On("GetUninvoicedOrderIDs", invoicedStatuses, invoiceBatch).Return([]int{42}, nil)
+		orderService := new(MockOrderService)
+		orderService.On("GetOrderDetails", 42).Return(cancelled, nil)
+
+		issued, err := NewService(store, orderService, testIssuer).IssuePendingInvoices()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, issued)
+		store.AssertNotCalled(t, "CreateInvoice", mock.Anything)
+	})
+
+	t.Run("Goes on after an order fails", func(t *testing.T) {
+		other := testOrder()
+		other.Order.ID = 43
+		store := new(MockInvoiceStore)
+		store.On("GetUninvoicedOrderIDs", invoicedStatuses, invoiceBatch).Return([]int{42, 43}, nil)
+		store.On("CreateInvoice", mock.MatchedBy(func(invoice *types.Invoice) bool { return invoice.OrderID == 42 })).
+			Return(errors.New("Duplicate entry '42' for key 'invoices.orderId'"))
+		store.On("CreateInvoice", mock.MatchedBy(func(invoice *types.Invoice) bool { return invoice.OrderID == 43 })).Return(nil)
+		orderService := new(MockOrderService)
+		orderService.On("GetOrderDetails", 42).Return(order, nil)
+		orderService.On("GetOrderDetails", 43).Return(other, nil)
+
+		issued, err := NewService(store, orderService, testIssuer).IssuePendingInvoices()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, issued)
+	})
+}
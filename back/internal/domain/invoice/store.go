@@ -0,0 +1,113 @@
+package invoice
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetInvoiceByOrderID(orderID int) (*types.Invoice, error) {
+	invoice := new(types.Invoice)
+	err := s.db.QueryRow(`
+		SELECT id, number, orderId, userId, totalAmount, pdf, issuedAt
+		FROM invoices
+		WHERE orderId = ?
+	`, orderID).Scan(
+		&invoice.ID,
+		&invoice.Number,
+		&invoice.OrderID,
+		&invoice.UserID,
+		&invoice.TotalAmount,
+		&invoice.PDF,
+		&invoice.IssuedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, apperrors.NewEntityNotFound("invoice", orderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GetInvoiceByOrderID] error getting invoice: %v", err)
+	}
+
+	return invoice, nil
+}
+
+func (s *Store) GetUninvoicedOrderIDs(statuses []types.OrderStatus, limit int) ([]int, error) {
+	args := make([]interface{}, 0, len(statuses)+1)
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	args = append(args, limit)
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",")
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT o.id
+		FROM order_history o
+		LEFT JOIN invoices i ON i.orderId = o.id
+		WHERE o.status IN (%s) AND i.id IS NULL
+		ORDER BY o.id
+		LIMIT ?
+	`, placeholders), args...)
+	if err != nil {
+		return nil, fmt.Errorf("[GetUninvoicedOrderIDs] error getting orders: %v", err)
+	}
+	defer rows.Close()
+
+	var orderIDs []int
+	for rows.Next() {
+		var orderID int
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, fmt.Errorf("[GetUninvoicedOrderIDs] error scanning order: %v", err)
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+
+	return orderIDs, rows.Err()
+}
+
+func (s *Store) CreateInvoice(invoice *types.Invoice, render func(invoice *types.Invoice) ([]byte, error)) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		// locking the last number makes concurrent invoices wait their turn
+		var last int
+		err := tx.QueryRow(`SELECT COALESCE(MAX(number), 0) FROM invoices FOR UPDATE`).Scan(&last)
+		if err != nil {
+			return fmt.Errorf("[CreateInvoice] error getting the last invoice number: %v", err)
+		}
+
+		invoice.Number = last + 1
+		invoice.IssuedAt = time.Now().UTC().Truncate(time.Second)
+
+		invoice.PDF, err = render(invoice)
+		if err != nil {
+			return fmt.Errorf("[CreateInvoice] error rendering invoice %d: %v", invoice.Number, err)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO invoices (number, orderId, userId, totalAmount, pdf, issuedAt)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, invoice.Number, invoice.OrderID, invoice.UserID, invoice.TotalAmount, invoice.PDF, invoice.IssuedAt)
+		if err != nil {
+			return fmt.Errorf("[CreateInvoice] error creating invoice: %v", err)
+		}
+
+		invoiceID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateInvoice] error getting invoice ID: %v", err)
+		}
+		invoice.ID = int(invoiceID)
+
+		return nil
+	})
+}
@@ -0,0 +1,51 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// Sweeper periodically issues the invoices of the orders that became PAID,
+// so each one is issued once, shortly after it is paid for, whether or not
+// anyone asks for it.
+type Sweeper struct {
+	service  types.InvoiceService
+	interval time.Duration
+}
+
+func NewSweeper(service types.InvoiceService, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+func (s *Sweeper) Sweep() {
+	issued, err := s.service.IssuePendingInvoices()
+	if err != nil {
+		fmt.Printf("[INVOICE SWEEPER] Error issuing invoices: %v\n", err)
+		return
+	}
+
+	if issued > 0 {
+		fmt.Printf("[INVOICE SWEEPER] Issued %d invoices\n", issued)
+	}
+}
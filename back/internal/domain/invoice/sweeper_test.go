@@ -0,0 +1,54 @@
+package invoice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInvoiceService struct {
+	types.InvoiceService
+	mock.Mock
+}
+
+func (m *MockInvoiceService) IssuePendingInvoices() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func TestSweeperRunsUntilCancelled(t *testing.T) {
+	sweeps := make(chan struct{}, 10)
+	service := new(MockInvoiceService)
+	service.On("IssuePendingInvoices").Return(1, nil).Run(func(_ mock.Arguments) {
+		select {
+		case sweeps <- struct{}{}:
+		default:
+		}
+	})
+	sweeper := NewSweeper(service, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweeper.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sweeps:
+		case <-time.After(time.Second):
+			t.Fatal("sweeper did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop")
+	}
+}
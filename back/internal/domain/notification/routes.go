@@ -28,7 +28,7 @@ func (h *Handler) RegisterRouter(router *mux.Router) {
 
 	authRouter.HandleFunc("/notification/my",
 		utils.Compose(
-			h.handleGetNotifications,
+			h.handleGetMyNotifications,
 			middleware.ErrorHandler,
 		)).Methods(http.MethodGet)
 
@@ -65,12 +65,22 @@ func (h *Handler) RegisterRouter(router *mux.Router) {
 func (h *Handler) handleGetMyNotifications(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserIDFromContext(r.Context())
 
-	notifications := h.notificationService.GetMyNotifications(userID)
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		panic(err)
+	}
+
+	notifications := h.notificationService.GetMyNotifications(userID, page)
 	utils.WriteJson(w, http.StatusOK, notifications)
 }
 
 func (h *Handler) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
-	notifications := h.notificationService.GetNotifications()
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		panic(err)
+	}
+
+	notifications := h.notificationService.GetNotifications(page)
 	utils.WriteJson(w, http.StatusOK, notifications)
 }
 
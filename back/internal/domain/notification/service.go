@@ -15,14 +15,14 @@ func NewNotificationService(notificationStore types.NotificationStore, userStore
 	return &Service{notificationStore: notificationStore, userStore: userStore}
 }
 
-func (s *Service) GetMyNotifications(userID int) *[]types.Notification {
+func (s *Service) GetMyNotifications(userID int, page types.PageRequest) *types.Page[types.Notification] {
 	_, err := s.userStore.GetUserByID(userID)
 	if err != nil {
 		panic(apperrors.NewEntityNotFound("user", userID))
 		return nil
 	}
 
-	notifications, err := s.notificationStore.GetMyNotifications(userID)
+	notifications, err := s.notificationStore.GetMyNotifications(userID, page)
 	if err != nil {
 		panic(err)
 		return nil
@@ -31,8 +31,8 @@ func (s *Service) GetMyNotifications(userID int) *[]types.Notification {
 	return notifications
 }
 
-func (s *Service) GetNotifications() *[]types.Notification {
-	notifications, err := s.notificationStore.GetNotifications()
+func (s *Service) GetNotifications(page types.PageRequest) *types.Page[types.Notification] {
+	notifications, err := s.notificationStore.GetNotifications(page)
 	if err != nil {
 		panic(err)
 		return nil
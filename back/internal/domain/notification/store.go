@@ -4,25 +4,36 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetMyNotifications(userID int) (*[]types.Notification, error) {
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) GetMyNotifications(userID int, page types.PageRequest) (*types.Page[types.Notification], error) {
+	after, args := database.NewestFirstAfter(page.After, "createdAt", "id")
 	query := `
 		SELECT id, userId, title, message, isRead, createdAt
 		FROM notifications
-		WHERE userId = ?
+		WHERE userId = ? AND ` + after + `
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, userID)
+	args = append([]any{userID}, args...)
+	rows, err := s.db.Query(query, append(args, page.Limit+1)...)
 	if err != nil {
 		return nil, fmt.Errorf("[GetMyNotifications] error getting notifications of user %d: %v", userID, err)
 	}
@@ -37,14 +48,18 @@ func (s *Store) GetMyNotifications(userID int) (*[]types.Notification, error) {
 		notifications = append(notifications, *n)
 	}
 
-	return &notifications, nil
+	return types.NewPage(notifications, page.Limit, notificationCursor), nil
 }
 
-func (s *Store) GetNotifications() (*[]types.Notification, error) {
+func (s *Store) GetNotifications(page types.PageRequest) (*types.Page[types.Notification], error) {
+	after, args := database.NewestFirstAfter(page.After, "createdAt", "id")
 	rows, err := s.db.Query(`
 		SELECT id, userId, title, message, isRead, createdAt
 		FROM notifications
-	`)
+		WHERE `+after+`
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
+	`, append(args, page.Limit+1)...)
 
 	if err != nil {
 		return nil, fmt.Errorf("[GetNotifications] error getting notifications: %v", err)
@@ -61,7 +76,11 @@ func (s *Store) GetNotifications() (*[]types.Notification, error) {
 		notifications = append(notifications, *n)
 	}
 
-	return &notifications, nil
+	return types.NewPage(notifications, page.Limit, notificationCursor), nil
+}
+
+func notificationCursor(n types.Notification) types.Cursor {
+	return types.Cursor{ID: n.ID, Time: &n.CreatedAt}
 }
 
 func (s *Store) GetNotificationByID(notificationID int) (*types.Notification, error) {
@@ -82,33 +101,31 @@ func (s *Store) GetNotificationByID(notificationID int) (*types.Notification, er
 }
 
 func (s *Store) CreateNotification(payload *types.CreateNotificationPayload, userID int) (*types.Notification, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, fmt.Errorf("[CreateNotification] error begin transaction: %v", err)
-	}
-	defer tx.Rollback()
+	var notificationID int64
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		var userExists bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&userExists)
+		if err != nil || !userExists {
+			return fmt.Errorf("[CreateNotification] error creating notification, user does not exist %d: %v", userID, err)
+		}
 
-	var userExists bool
-	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&userExists)
-	if err != nil || !userExists {
-		return nil, fmt.Errorf("[CreateNotification] error creating notification, user does not exist %d: %v", userID, err)
-	}
+		res, err := tx.Exec(`
+			INSERT INTO notifications(userId, title, message)
+			VALUES (?, ?, ?)
+		`, userID, payload.Title, payload.Message)
+		if err != nil {
+			return fmt.Errorf("[CreateNotification] error creating notification: %v", err)
+		}
 
-	res, err := tx.Exec(`
-		INSERT INTO notifications(userId, title, message)
-		VALUES (?, ?, ?)
-	`, userID, payload.Title, payload.Message)
-	if err != nil {
-		return nil, fmt.Errorf("[CreateNotification] error creating notification: %v", err)
-	}
+		notificationID, err = res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateNotification] error get notification ID: %v", err)
+		}
 
-	notificationID, err := res.LastInsertId()
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("[CreateNotification] error get notification ID: %v", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("[CreateNotification] error commit transaction: %v", err)
+		return nil, err
 	}
 
 	return s.GetNotificationByID(int(notificationID))
@@ -0,0 +1,131 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// SearchOrders lists the orders of every customer matching filter, each with
+// the customer who placed it.
+func (s *Service) SearchOrders(filter types.OrderFilter, page types.PageRequest) (*types.Page[*types.AdminOrder], error) {
+	fmt.Printf("[ORDER SERVICE] Searching orders\n")
+
+	if err := validateOrderFilter(filter); err != nil {
+		return nil, err
+	}
+
+	orders, err := s.orderStore.SearchOrders(filter, page)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error searching orders: %v\n", err)
+		return nil, err
+	}
+
+	adminOrders := &types.Page[*types.AdminOrder]{
+		Items:      make([]*types.AdminOrder, 0, len(orders.Items)),
+		NextCursor: orders.NextCursor,
+	}
+	if len(orders.Items) == 0 {
+		return adminOrders, nil
+	}
+
+	userIDs := make([]int, 0, len(orders.Items))
+	for _, order := range orders.Items {
+		userIDs = append(userIDs, order.UserID)
+	}
+
+	customers, err := s.orderStore.GetOrderCustomers(userIDs)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting customers: %v\n", err)
+		return nil, err
+	}
+
+	for _, order := range orders.Items {
+		adminOrders.Items = append(adminOrders.Items, &types.AdminOrder{
+			OrderHistory: *order,
+			Customer:     customers[order.UserID],
+		})
+	}
+
+	return adminOrders, nil
+}
+
+func validateOrderFilter(filter types.OrderFilter) error {
+	if filter.Status != nil {
+		if err := filter.Status.Valid(); err != nil {
+			return apperrors.NewValidationError("status", err.Error())
+		}
+	}
+
+	if filter.PaymentMethod != nil {
+		if err := filter.PaymentMethod.Valid(); err != nil {
+			return apperrors.NewValidationError("paymentMethod", err.Error())
+		}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return apperrors.NewValidationError("to", "to must be after from")
+	}
+
+	if filter.MinTotal != nil && filter.MaxTotal != nil && *filter.MinTotal > *filter.MaxTotal {
+		return apperrors.NewValidationError("minTotal", "minTotal can't be above maxTotal")
+	}
+
+	return nil
+}
+
+// GetOrderDetails returns any order with its items, shipments and customer.
+func (s *Service) GetOrderDetails(orderID int) (*types.OrderDetails, error) {
+	orderWithItems, err := s.GetOrderWithItems(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	customers, err := s.orderStore.GetOrderCustomers([]int{orderWithItems.Order.UserID})
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting customer: %v\n", err)
+		return nil, err
+	}
+
+	return &types.OrderDetails{
+		OrderWithItems: *orderWithItems,
+		Customer:       customers[orderWithItems.Order.UserID],
+	}, nil
+}
+
+// BulkUpdateOrderStatus moves every order of orderIDs to status as an admin,
+// each in its own transaction, and reports how each one went.
+func (s *Service) BulkUpdateOrderStatus(orderIDs []int, status types.OrderStatus, userID int) ([]*types.OrderStatusUpdateResult, error) {
+	fmt.Printf("[ORDER SERVICE] Updating %d orders to %s by user %d\n", len(orderIDs), status, userID)
+
+	if err := status.Valid(); err != nil {
+		return nil, apperrors.NewValidationError("status", err.Error())
+	}
+
+	results := make([]*types.OrderStatusUpdateResult, 0, len(orderIDs))
+	seen := make(map[int]bool, len(orderIDs))
+	for _, orderID := range orderIDs {
+		if seen[orderID] {
+			continue
+		}
+		seen[orderID] = true
+
+		result := &types.OrderStatusUpdateResult{OrderID: orderID, Updated: true}
+		if err := s.UpdateOrderStatus(orderID, status, userID, types.RoleAdmin); err != nil {
+			result.Updated = false
+
+			var appErr *apperrors.AppError
+			if errors.As(err, &appErr) {
+				result.Error = appErr.Code
+				result.Details = appErr.Details
+			} else {
+				result.Error = "INTERNAL"
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -1,11 +1,19 @@
 package orders
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
@@ -29,6 +37,22 @@ func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore)
 	authRouter.HandleFunc("/orders", h.getOrders).Methods("GET")
 	authRouter.HandleFunc("/orders/{orderId}", h.getOrderByID).Methods("GET")
 	authRouter.HandleFunc("/orders/{orderId}/status", h.updateOrderStatus).Methods("PATCH")
+	authRouter.HandleFunc("/orders/{orderId}/cancel", h.cancelOrder).Methods("POST")
+	authRouter.HandleFunc("/orders/{orderId}/history", h.getOrderStatusHistory).Methods("GET")
+	authRouter.HandleFunc("/orders/{orderId}/refunds", h.getRefunds).Methods("GET")
+	authRouter.HandleFunc("/orders/{orderId}/shipments", h.getShipments).Methods("GET")
+
+	adminRouter := router.PathPrefix("").Subrouter()
+	adminRouter.Use(
+		auth.WithJwtAuthMiddleware(userStore),
+		auth.WithAdminAuthMiddleware())
+
+	adminRouter.HandleFunc("/admin/orders", h.searchOrders).Methods("GET")
+	adminRouter.HandleFunc("/admin/orders/status", h.bulkUpdateOrderStatus).Methods("PATCH")
+	adminRouter.HandleFunc("/admin/orders/{orderId:[0-9]+}", h.getOrderDetails).Methods("GET")
+	adminRouter.HandleFunc("/orders/{orderId}/refunds", h.refundOrder).Methods("POST")
+	adminRouter.HandleFunc("/orders/{orderId}/shipments", h.createShipment).Methods("POST")
+	adminRouter.HandleFunc("/orders/{orderId}/shipments/{shipmentId}", h.updateShipment).Methods("PATCH")
 }
 
 func (h *Handler) createOrder(w http.ResponseWriter, r *http.Request) {
@@ -50,9 +74,14 @@ func (h *Handler) createOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.orderService.CreateOrderFromCart(userID, payload.PaymentMethod, payload.PaymentID)
+	order, err := h.orderService.CreateOrderFromCart(userID, payload.AddressID, payload.ShippingOption, payload.PaymentMethod, payload.PaymentID, payload.AcceptedTotal)
 	if err != nil {
 		fmt.Printf("[ORDER HANDLER] Error creating order: %v\n", err)
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) {
+			utils.WriteServiceError(w, err, "Failed to create order")
+			return
+		}
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create order: %v", err)})
 		return
 	}
@@ -67,35 +96,33 @@ func (h *Handler) getOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Invalid page")
+		return
+	}
+
 	withItems := r.URL.Query().Get("withItems") == "true"
 
 	if withItems {
-		orders, err := h.orderService.GetOrdersWithItems(userID)
+		orders, err := h.orderService.GetOrdersWithItems(userID, page)
 		if err != nil {
 			fmt.Printf("[ORDER HANDLER] Error getting orders with items: %v\n", err)
 			utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get orders"})
 			return
 		}
 
-		if orders == nil {
-			orders = []*types.OrderWithItems{}
-		}
-
 		utils.WriteJson(w, http.StatusOK, orders)
 		return
 	}
 
-	orders, err := h.orderService.GetOrdersByUserID(userID)
+	orders, err := h.orderService.GetOrdersByUserID(userID, page)
 	if err != nil {
 		fmt.Printf("[ORDER HANDLER] Error getting orders: %v\n", err)
 		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get orders"})
 		return
 	}
 
-	if orders == nil {
-		orders = []*types.OrderHistory{}
-	}
-
 	utils.WriteJson(w, http.StatusOK, orders)
 }
 
@@ -166,7 +193,7 @@ func (h *Handler) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if order.UserID != userID {
+	if !canAccessOrder(r.Context(), order) {
 		utils.WriteJson(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
 		return
 	}
@@ -183,12 +210,385 @@ func (h *Handler) updateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.orderService.UpdateOrderStatus(orderID, payload.Status)
+	role := auth.GetUserRoleFromContext(r.Context())
+	err = h.orderService.UpdateOrderStatus(orderID, payload.Status, userID, role)
 	if err != nil {
 		fmt.Printf("[ORDER HANDLER] Error updating order status: %v\n", err)
-		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to update order status"})
+		utils.WriteServiceError(w, err, "Failed to update order status")
 		return
 	}
 
 	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Order status updated successfully"})
 }
+
+func (h *Handler) cancelOrder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order for cancellation: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	if !canAccessOrder(r.Context(), order) {
+		utils.WriteJson(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	// the reason is optional, so an empty body is accepted
+	var payload types.CancelOrderPayload
+	err = json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if len(payload.Reason) > 255 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Reason must be at most 255 characters"})
+		return
+	}
+
+	role := auth.GetUserRoleFromContext(r.Context())
+	err = h.orderService.CancelOrder(orderID, userID, role, payload.Reason)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error cancelling order: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to cancel order")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Order cancelled successfully"})
+}
+
+func (h *Handler) getOrderStatusHistory(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order for status history: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	if !canAccessOrder(r.Context(), order) {
+		utils.WriteJson(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	history, err := h.orderService.GetOrderStatusHistory(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order status history: %v\n", err)
+		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get order status history"})
+		return
+	}
+
+	if history == nil {
+		history = []*types.OrderStatusHistory{}
+	}
+
+	utils.WriteJson(w, http.StatusOK, history)
+}
+
+func (h *Handler) refundOrder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	// an empty body refunds everything not refunded yet
+	var payload types.CreateRefundPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	refund, err := h.orderService.RefundOrder(orderID, userID, &payload)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error refunding order: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to refund order")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, refund)
+}
+
+func (h *Handler) getRefunds(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+	if userID == 0 {
+		utils.WriteJson(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order for refunds: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	if !canAccessOrder(r.Context(), order) {
+		utils.WriteJson(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	refunds, err := h.orderService.GetRefunds(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting refunds: %v\n", err)
+		utils.WriteJson(w, http.StatusInternalServerError, map[string]string{"error": "Failed to get refunds"})
+		return
+	}
+
+	if refunds == nil {
+		refunds = []*types.Refund{}
+	}
+
+	utils.WriteJson(w, http.StatusOK, refunds)
+}
+
+func (h *Handler) createShipment(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	var payload types.CreateShipmentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	payload.Carrier = strings.TrimSpace(payload.Carrier)
+	payload.TrackingCode = strings.TrimSpace(payload.TrackingCode)
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	shipment, err := h.orderService.CreateShipment(orderID, userID, &payload)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error creating shipment: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to create shipment")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, shipment)
+}
+
+func (h *Handler) updateShipment(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	shipmentID := utils.GetParamIdfromPath(r, "shipmentId")
+	if orderID == 0 || shipmentID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order or shipment ID"})
+		return
+	}
+
+	var payload types.UpdateShipmentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	shipment, err := h.orderService.UpdateShipment(orderID, shipmentID, userID, &payload)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error updating shipment: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to update shipment")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, shipment)
+}
+
+func (h *Handler) getShipments(w http.ResponseWriter, r *http.Request) {
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderByID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order for shipments: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	if !canAccessOrder(r.Context(), order) {
+		utils.WriteJson(w, http.StatusForbidden, map[string]string{"error": "Access denied"})
+		return
+	}
+
+	// GetOrderByID loads the shipments along with the order
+	utils.WriteJson(w, http.StatusOK, order.Shipments)
+}
+
+func (h *Handler) searchOrders(w http.ResponseWriter, r *http.Request) {
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Invalid page")
+		return
+	}
+
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteServiceError(w, err, "Invalid filter")
+		return
+	}
+
+	orders, err := h.orderService.SearchOrders(filter, page)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error searching orders: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to search orders")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, orders)
+}
+
+// parseOrderFilter reads the admin order filters from the query string:
+// status, paymentMethod, from, to, userId, customer, minTotal and maxTotal.
+// Dates are either RFC 3339 times or plain dates, a plain to date including
+// the whole day.
+func parseOrderFilter(query url.Values) (types.OrderFilter, error) {
+	filter := types.OrderFilter{Customer: strings.TrimSpace(query.Get("customer"))}
+
+	if value := query.Get("status"); value != "" {
+		status := types.OrderStatus(strings.ToUpper(value))
+		filter.Status = &status
+	}
+
+	if value := query.Get("paymentMethod"); value != "" {
+		method := types.PaymentMethod(strings.ToUpper(value))
+		filter.PaymentMethod = &method
+	}
+
+	var err error
+	if filter.From, err = utils.ParseDateParam(query, "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = utils.ParseDateParam(query, "to", true); err != nil {
+		return filter, err
+	}
+
+	if value := query.Get("userId"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil || userID <= 0 {
+			return filter, apperrors.NewValidationError("userId", "userId must be a positive number")
+		}
+		filter.UserID = &userID
+	}
+
+	if filter.MinTotal, err = parseTotalParam(query, "minTotal"); err != nil {
+		return filter, err
+	}
+	if filter.MaxTotal, err = parseTotalParam(query, "maxTotal"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseTotalParam(query url.Values, name string) (*float64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	total, err := strconv.ParseFloat(value, 64)
+	if err != nil || total < 0 {
+		return nil, apperrors.NewValidationError(name, name+" must be a positive number")
+	}
+	return &total, nil
+}
+
+func (h *Handler) getOrderDetails(w http.ResponseWriter, r *http.Request) {
+	orderID := utils.GetParamIdfromPath(r, "orderId")
+	if orderID == 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.orderService.GetOrderDetails(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error getting order details: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get order")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, order)
+}
+
+func (h *Handler) bulkUpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.BulkUpdateOrderStatusPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	results, err := h.orderService.BulkUpdateOrderStatus(payload.OrderIDs, payload.Status, userID)
+	if err != nil {
+		fmt.Printf("[ORDER HANDLER] Error updating order statuses: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to update order statuses")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, results)
+}
+
+// canAccessOrder reports whether the caller owns the order or is an admin
+func canAccessOrder(ctx context.Context, order *types.OrderHistory) bool {
+	return order.UserID == auth.GetUserIDFromContext(ctx) || auth.GetUserRoleFromContext(ctx) == types.RoleAdmin
+}
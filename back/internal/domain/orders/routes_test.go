@@ -1,6 +1,7 @@
 package orders
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,8 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database/dbtest"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/stretchr/testify/assert"
@@ -23,20 +26,20 @@ type MockOrderService struct {
 	mock.Mock
 }
 
-func (m *MockOrderService) CreateOrderFromCart(userID int, paymentMethod types.PaymentMethod, paymentID string) (*types.OrderHistory, error) {
-	args := m.Called(userID, paymentMethod, paymentID)
+func (m *MockOrderService) CreateOrderFromCart(userID int, addressID int, shippingOption string, paymentMethod types.PaymentMethod, paymentID string, acceptedTotal *float64) (*types.OrderHistory, error) {
+	args := m.Called(userID, addressID, shippingOption, paymentMethod, paymentID, acceptedTotal)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*types.OrderHistory), args.Error(1)
 }
 
-func (m *MockOrderService) GetOrdersByUserID(userID int) ([]*types.OrderHistory, error) {
-	args := m.Called(userID)
+func (m *MockOrderService) GetOrdersByUserID(userID int, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
+	args := m.Called(userID, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.OrderHistory), args.Error(1)
+	return args.Get(0).(*types.Page[*types.OrderHistory]), args.Error(1)
 }
 
 func (m *MockOrderService) GetOrderByID(orderID int) (*types.OrderHistory, error) {
@@ -55,19 +58,98 @@ func (m *MockOrderService) GetOrderWithItems(orderID int) (*types.OrderWithItems
 	return args.Get(0).(*types.OrderWithItems), args.Error(1)
 }
 
-func (m *MockOrderService) GetOrdersWithItems(userID int) ([]*types.OrderWithItems, error) {
-	args := m.Called(userID)
+func (m *MockOrderService) GetOrdersWithItems(userID int, page types.PageRequest) (*types.Page[*types.OrderWithItems], error) {
+	args := m.Called(userID, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.OrderWithItems), args.Error(1)
+	return args.Get(0).(*types.Page[*types.OrderWithItems]), args.Error(1)
 }
 
-func (m *MockOrderService) UpdateOrderStatus(orderID int, status types.OrderStatus) error {
-	args := m.Called(orderID, status)
+func (m *MockOrderService) UpdateOrderStatus(orderID int, status types.OrderStatus, userID int, role types.UserRole) error {
+	args := m.Called(orderID, status, userID, role)
 	return args.Error(0)
 }
 
+func (m *MockOrderService) CancelOrder(orderID int, userID int, role types.UserRole, reason string) error {
+	args := m.Called(orderID, userID, role, reason)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) ConfirmPixPayment(txid string, endToEndID string, amount float64) error {
+	args := m.Called(txid, endToEndID, amount)
+	return args.Error(0)
+}
+
+func (m *MockOrderService) CancelExpiredPixOrders() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderStatusHistory(orderID int) ([]*types.OrderStatusHistory, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.OrderStatusHistory), args.Error(1)
+}
+
+func (m *MockOrderService) RefundOrder(orderID int, userID int, payload *types.CreateRefundPayload) (*types.Refund, error) {
+	args := m.Called(orderID, userID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Refund), args.Error(1)
+}
+
+func (m *MockOrderService) GetRefunds(orderID int) ([]*types.Refund, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Refund), args.Error(1)
+}
+
+func (m *MockOrderService) SearchOrders(filter types.OrderFilter, page types.PageRequest) (*types.Page[*types.AdminOrder], error) {
+	args := m.Called(filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.AdminOrder]), args.Error(1)
+}
+
+func (m *MockOrderService) GetOrderDetails(orderID int) (*types.OrderDetails, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.OrderDetails), args.Error(1)
+}
+
+func (m *MockOrderService) BulkUpdateOrderStatus(orderIDs []int, status types.OrderStatus, userID int) ([]*types.OrderStatusUpdateResult, error) {
+	args := m.Called(orderIDs, status, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.OrderStatusUpdateResult), args.Error(1)
+}
+
+func (m *MockOrderService) CreateShipment(orderID int, userID int, payload *types.CreateShipmentPayload) (*types.Shipment, error) {
+	args := m.Called(orderID, userID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Shipment), args.Error(1)
+}
+
+func (m *MockOrderService) UpdateShipment(orderID int, shipmentID int, userID int, payload *types.UpdateShipmentPayload) (*types.Shipment, error) {
+	args := m.Called(orderID, shipmentID, userID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Shipment), args.Error(1)
+}
+
 // MockUserStore é uma implementação mock da interface UserStore
 type MockUserStore struct {
 	mock.Mock
@@ -129,6 +211,8 @@ func TestCreateOrder(t *testing.T) {
 			name:   "Success - Order created",
 			userID: 1,
 			payload: `{
+				"addressId": 3,
+				"shippingOption": "PAC",
 				"paymentMethod": "CREDIT_CARD",
 				"paymentId": "payment123"
 			}`,
@@ -143,7 +227,7 @@ func TestCreateOrder(t *testing.T) {
 					CreatedAt:     time.Now(),
 					UpdatedAt:     time.Now(),
 				}
-				mos.On("CreateOrderFromCart", 1, types.PaymentCreditCard, "payment123").Return(order, nil)
+				mos.On("CreateOrderFromCart", 1, 3, "PAC", types.PaymentCreditCard, "payment123", (*float64)(nil)).Return(order, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusCreated,
@@ -153,6 +237,8 @@ func TestCreateOrder(t *testing.T) {
 			name:   "Error - Invalid payment method",
 			userID: 1,
 			payload: `{
+				"addressId": 3,
+				"shippingOption": "PAC",
 				"paymentMethod": "INVALID_METHOD",
 				"paymentId": "payment123"
 			}`,
@@ -162,6 +248,59 @@ func TestCreateOrder(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedJSON:   true,
 		},
+		{
+			name:   "Error - Payment declined",
+			userID: 1,
+			payload: `{
+				"addressId": 3,
+				"shippingOption": "PAC",
+				"paymentMethod": "CREDIT_CARD",
+				"paymentId": "tok_declined"
+			}`,
+			mockSetup: func(mos *MockOrderService, mus *MockUserStore) {
+				mos.On("CreateOrderFromCart", 1, 3, "PAC", types.PaymentCreditCard, "tok_declined", (*float64)(nil)).
+					Return(nil, apperrors.NewValidationError("payment", "payment was declined"))
+				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON:   true,
+		},
+		{
+			name:   "Error - Prices changed since the items were added",
+			userID: 1,
+			payload: `{
+				"addressId": 3,
+				"shippingOption": "PAC",
+				"paymentMethod": "CREDIT_CARD",
+				"paymentId": "payment123"
+			}`,
+			mockSetup: func(mos *MockOrderService, mus *MockUserStore) {
+				mos.On("CreateOrderFromCart", 1, 3, "PAC", types.PaymentCreditCard, "payment123", (*float64)(nil)).
+					Return(nil, apperrors.NewConflictError("cart", "prices changed since the items were added, accept the new total to continue"))
+				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedJSON:   true,
+		},
+		{
+			name:   "Success - New total accepted",
+			userID: 1,
+			payload: `{
+				"addressId": 3,
+				"shippingOption": "PAC",
+				"paymentMethod": "CREDIT_CARD",
+				"paymentId": "payment123",
+				"acceptedTotal": 95.5
+			}`,
+			mockSetup: func(mos *MockOrderService, mus *MockUserStore) {
+				accepted := mock.MatchedBy(func(total *float64) bool { return total != nil && *total == 95.5 })
+				mos.On("CreateOrderFromCart", 1, 3, "PAC", types.PaymentCreditCard, "payment123", accepted).
+					Return(&types.OrderHistory{ID: 1, UserID: 1, TotalAmount: 95.5, Status: types.OrderPaid}, nil)
+				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedJSON:   true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -222,7 +361,8 @@ func TestGetOrders(t *testing.T) {
 						UpdatedAt:     time.Now(),
 					},
 				}
-				mos.On("GetOrdersByUserID", 1).Return(orders, nil)
+				mos.On("GetOrdersByUserID", 1, types.PageRequest{Limit: types.DefaultPageLimit}).
+					Return(&types.Page[*types.OrderHistory]{Items: orders}, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -255,7 +395,8 @@ func TestGetOrders(t *testing.T) {
 						},
 					},
 				}
-				mos.On("GetOrdersWithItems", 1).Return(ordersWithItems, nil)
+				mos.On("GetOrdersWithItems", 1, types.PageRequest{Limit: types.DefaultPageLimit}).
+					Return(&types.Page[*types.OrderWithItems]{Items: ordersWithItems}, nil)
 				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -289,13 +430,15 @@ func TestGetOrders(t *testing.T) {
 
 			if tt.expectedJSON {
 				if tt.withItems {
-					var response []*types.OrderWithItems
+					var response types.Page[*types.OrderWithItems]
 					err := json.NewDecoder(rr.Body).Decode(&response)
 					assert.NoError(t, err)
+					assert.Len(t, response.Items, 1)
 				} else {
-					var response []*types.OrderHistory
+					var response types.Page[*types.OrderHistory]
 					err := json.NewDecoder(rr.Body).Decode(&response)
 					assert.NoError(t, err)
+					assert.Len(t, response.Items, 1)
 				}
 			}
 
@@ -444,7 +587,7 @@ func TestUpdateOrderStatus(t *testing.T) {
 			userID:  1,
 			orderID: "1",
 			payload: `{
-				"status": "COMPLETED"
+				"status": "CANCELLED"
 			}`,
 			mockSetup: func(mos *MockOrderService, mus *MockUserStore) {
 				order := &types.OrderHistory{
@@ -458,12 +601,33 @@ func TestUpdateOrderStatus(t *testing.T) {
 					UpdatedAt:     time.Now(),
 				}
 				mos.On("GetOrderByID", 1).Return(order, nil)
-				mos.On("UpdateOrderStatus", 1, types.OrderCompleted).Return(nil)
-				mus.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+				mos.On("UpdateOrderStatus", 1, types.OrderCancelled, 1, types.RoleUser).Return(nil)
+				mus.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleUser}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedJSON:   true,
 		},
+		{
+			name:    "Error - Transition not allowed for role",
+			userID:  1,
+			orderID: "1",
+			payload: `{
+				"status": "SHIPPED"
+			}`,
+			mockSetup: func(mos *MockOrderService, mus *MockUserStore) {
+				order := &types.OrderHistory{
+					ID:     1,
+					UserID: 1,
+					Status: types.OrderPaid,
+				}
+				mos.On("GetOrderByID", 1).Return(order, nil)
+				mos.On("UpdateOrderStatus", 1, types.OrderShipped, 1, types.RoleUser).
+					Return(apperrors.NewForbiddenError("role USER cannot change order status from PAID to SHIPPED"))
+				mus.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleUser}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedJSON:   true,
+		},
 		{
 			name:    "Error - Invalid status",
 			userID:  1,
@@ -547,3 +711,637 @@ func TestUpdateOrderStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrderStatusHistory(t *testing.T) {
+	pending := types.OrderPending
+	changedBy := 1
+
+	tests := []struct {
+		name           string
+		userID         int
+		role           types.UserRole
+		orderID        string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:    "Success - Owner gets timeline",
+			userID:  1,
+			role:    types.RoleUser,
+			orderID: "1",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1}, nil)
+				mos.On("GetOrderStatusHistory", 1).Return([]*types.OrderStatusHistory{
+					{ID: 1, OrderID: 1, ToStatus: types.OrderPending, ChangedBy: &changedBy},
+					{ID: 2, OrderID: 1, FromStatus: &pending, ToStatus: types.OrderCancelled, ChangedBy: &changedBy},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Success - Admin gets any timeline",
+			userID:  1,
+			role:    types.RoleAdmin,
+			orderID: "2",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 2).Return(&types.OrderHistory{ID: 2, UserID: 2}, nil)
+				mos.On("GetOrderStatusHistory", 2).Return(nil, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Error - Access denied",
+			userID:  1,
+			role:    types.RoleUser,
+			orderID: "2",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 2).Return(&types.OrderHistory{ID: 2, UserID: 2}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", tt.userID).Return(&types.User{ID: tt.userID, Role: tt.role}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("GET", "/orders/"+tt.orderID+"/history", nil)
+			req.Header.Set("Authorization", "Bearer "+createTestToken(tt.userID))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var response []*types.OrderStatusHistory
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         int
+		orderID        string
+		payload        string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:    "Success - Cancel with reason",
+			userID:  1,
+			orderID: "1",
+			payload: `{"reason": "  ordered by mistake "}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderPending}, nil)
+				mos.On("CancelOrder", 1, 1, types.RoleUser, "ordered by mistake").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Success - Cancel without body",
+			userID:  1,
+			orderID: "1",
+			payload: "",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderPending}, nil)
+				mos.On("CancelOrder", 1, 1, types.RoleUser, "").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Error - Order not cancellable",
+			userID:  1,
+			orderID: "1",
+			payload: `{}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderShipped}, nil)
+				mos.On("CancelOrder", 1, 1, types.RoleUser, "").
+					Return(apperrors.NewValidationError("status", "cannot change order status from SHIPPED to CANCELLED"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Error - Reason too long",
+			userID:  1,
+			orderID: "1",
+			payload: `{"reason": "` + strings.Repeat("a", 256) + `"}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderPending}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Error - Access denied",
+			userID:  1,
+			orderID: "2",
+			payload: `{}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("GetOrderByID", 2).Return(&types.OrderHistory{ID: 2, UserID: 2, Status: types.OrderPending}, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", tt.userID).Return(&types.User{ID: tt.userID, Role: types.RoleUser}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("POST", "/orders/"+tt.orderID+"/cancel", strings.NewReader(tt.payload))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(tt.userID))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefundOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           types.UserRole
+		payload        string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:    "Success - Admin refunds line quantities",
+			role:    types.RoleAdmin,
+			payload: `{"items": [{"productId": 3, "quantity": 1}], "reason": " damaged ", "restock": true}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("RefundOrder", 1, 1, &types.CreateRefundPayload{
+					Items:   []types.RefundItemPayload{{ProductID: 3, Quantity: 1}},
+					Reason:  "damaged",
+					Restock: true,
+				}).Return(&types.Refund{ID: 1, OrderID: 1, Amount: 10.0}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:    "Success - Admin refunds whole order without body",
+			role:    types.RoleAdmin,
+			payload: "",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("RefundOrder", 1, 1, &types.CreateRefundPayload{}).Return(&types.Refund{ID: 1, OrderID: 1, Amount: 50.0}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Error - Invalid quantity",
+			role:           types.RoleAdmin,
+			payload:        `{"items": [{"productId": 3, "quantity": 0}]}`,
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Error - Refund exceeds what was bought",
+			role:    types.RoleAdmin,
+			payload: `{"items": [{"productId": 3, "quantity": 5}]}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("RefundOrder", 1, 1, mock.Anything).Return(nil, apperrors.NewValidationError("items", "product 3 has 2 units left to refund"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Customers cannot refund",
+			role:           types.RoleUser,
+			payload:        `{}`,
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: tt.role}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("POST", "/orders/1/refunds", strings.NewReader(tt.payload))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetRefunds(t *testing.T) {
+	mockService := new(MockOrderService)
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleUser}, nil)
+	mockService.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderPaid}, nil)
+	mockService.On("GetOrderByID", 2).Return(&types.OrderHistory{ID: 2, UserID: 2, Status: types.OrderPaid}, nil)
+	mockService.On("GetRefunds", 1).Return([]*types.Refund{{ID: 1, OrderID: 1, Amount: 10.0}}, nil)
+
+	handler := NewHandler(mockService)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router, mockUserStore)
+
+	req := httptest.NewRequest("GET", "/orders/1/refunds", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var refunds []*types.Refund
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &refunds))
+	assert.Len(t, refunds, 1)
+
+	req = httptest.NewRequest("GET", "/orders/2/refunds", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetOrdersQueryBudget(t *testing.T) {
+	// the queries must not grow with the number of orders listed
+	const orders = 50
+	const budget = 3
+
+	db := dbtest.New(t)
+	var orderRows, itemRows, refundRows [][]driver.Value
+	for id := int64(1); id <= orders; id++ {
+		orderRows = append(orderRows, []driver.Value{
+			id, int64(1), 100.0, string(types.OrderPaid), string(types.PaymentCreditCard), "payment",
+			"Avenida Paulista, 1000", "São Paulo", "SP", "01310-100", "Brasil", "PAC", 0.0, time.Now(), time.Now(),
+		})
+		itemRows = append(itemRows, []driver.Value{id, int64(1), "Camiseta", int64(0), "", int64(2), 50.0, 50.0, 0.0, int64(0), int64(0)})
+		refundRows = append(refundRows, []driver.Value{id, 10.0})
+	}
+	db.On("FROM order_history", orderRows...)
+	db.On("FROM order_items", itemRows...)
+	db.On("FROM refunds", refundRows...)
+
+	store := NewStore(db.DB)
+	service := NewService(store, new(MockCartStore), new(MockCartService), new(MockProductStore), newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore),
+		new(MockPaymentGateway), testPixIssuer, &MockUnitOfWork{})
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+
+	router := mux.NewRouter()
+	NewHandler(service).RegisterRoutes(router, mockUserStore)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders?withItems=true&limit="+strconv.Itoa(orders), nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response types.Page[*types.OrderWithItems]
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Len(t, response.Items, orders)
+	assert.Len(t, response.Items[0].Items, 1)
+	assert.Equal(t, 10.0, response.Items[0].RefundedAmount)
+	db.AssertQueryBudget(t, budget)
+}
+
+func TestCreateShipment(t *testing.T) {
+	tests := []struct {
+		name           string
+		role           types.UserRole
+		payload        string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:    "Success - Admin ships some items",
+			role:    types.RoleAdmin,
+			payload: `{"carrier": " Correios ", "trackingCode": "BR123", "items": [{"productId": 3, "quantity": 1}]}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("CreateShipment", 1, 1, &types.CreateShipmentPayload{
+					Carrier:      "Correios",
+					TrackingCode: "BR123",
+					Items:        []types.ShipmentItemPayload{{ProductID: 3, Quantity: 1}},
+				}).Return(&types.Shipment{ID: 1, OrderID: 1, Status: types.ShipmentShipped}, nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Error - Missing carrier",
+			role:           types.RoleAdmin,
+			payload:        `{"trackingCode": "BR123"}`,
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Error - Order not paid",
+			role:    types.RoleAdmin,
+			payload: `{"carrier": "Correios"}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("CreateShipment", 1, 1, mock.Anything).Return(nil, apperrors.NewValidationError("status", "order in status PENDING cannot be shipped"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Customers cannot ship",
+			role:           types.RoleUser,
+			payload:        `{"carrier": "Correios"}`,
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: tt.role}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("POST", "/orders/1/shipments", strings.NewReader(tt.payload))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateShipment(t *testing.T) {
+	mockService := new(MockOrderService)
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleAdmin}, nil)
+	delivered := types.ShipmentDelivered
+	mockService.On("UpdateShipment", 1, 2, 1, &types.UpdateShipmentPayload{Status: &delivered}).
+		Return(&types.Shipment{ID: 2, OrderID: 1, Status: types.ShipmentDelivered}, nil)
+
+	handler := NewHandler(mockService)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router, mockUserStore)
+
+	req := httptest.NewRequest("PATCH", "/orders/1/shipments/2", strings.NewReader(`{"status": "DELIVERED"}`))
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetShipments(t *testing.T) {
+	mockService := new(MockOrderService)
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleUser}, nil)
+	mockService.On("GetOrderByID", 1).Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderShipped,
+		Shipments: []*types.Shipment{{ID: 1, OrderID: 1, Carrier: "Correios", TrackingCode: "BR123"}}}, nil)
+	mockService.On("GetOrderByID", 2).Return(&types.OrderHistory{ID: 2, UserID: 2, Status: types.OrderShipped}, nil)
+
+	handler := NewHandler(mockService)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router, mockUserStore)
+
+	req := httptest.NewRequest("GET", "/orders/1/shipments", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var shipments []*types.Shipment
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shipments))
+	assert.Len(t, shipments, 1)
+	assert.Equal(t, "BR123", shipments[0].TrackingCode)
+
+	req = httptest.NewRequest("GET", "/orders/2/shipments", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestSearchOrders(t *testing.T) {
+	paid := types.OrderPaid
+	pix := types.PaymentPix
+	userID := 7
+	from := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	minTotal := 50.0
+
+	tests := []struct {
+		name           string
+		role           types.UserRole
+		query          string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:  "Success - Admin filters orders",
+			role:  types.RoleAdmin,
+			query: "?status=paid&paymentMethod=PIX&from=2025-05-01&to=2025-05-31&userId=7&customer=+maria+&minTotal=50",
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("SearchOrders", types.OrderFilter{
+					Status:        &paid,
+					PaymentMethod: &pix,
+					From:          &from,
+					To:            &to,
+					UserID:        &userID,
+					Customer:      "maria",
+					MinTotal:      &minTotal,
+				}, types.PageRequest{Limit: types.DefaultPageLimit}).Return(&types.Page[*types.AdminOrder]{Items: []*types.AdminOrder{}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Error - Invalid date",
+			role:           types.RoleAdmin,
+			query:          "?from=yesterday",
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Negative total",
+			role:           types.RoleAdmin,
+			query:          "?maxTotal=-1",
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Customers cannot search every order",
+			role:           types.RoleUser,
+			query:          "",
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: tt.role}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("GET", "/admin/orders"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetOrderDetails(t *testing.T) {
+	mockService := new(MockOrderService)
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleAdmin}, nil)
+	mockService.On("GetOrderDetails", 5).Return(&types.OrderDetails{
+		OrderWithItems: types.OrderWithItems{
+			Order: types.OrderHistory{ID: 5, UserID: 7, Status: types.OrderPaid},
+			Items: []*types.OrderItem{{OrderID: 5, ProductID: 3, Quantity: 2}},
+		},
+		Customer: &types.OrderCustomer{ID: 7, FullName: "Maria Souza", Email: "maria@example.com"},
+	}, nil)
+
+	handler := NewHandler(mockService)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router, mockUserStore)
+
+	req := httptest.NewRequest("GET", "/admin/orders/5", nil)
+	req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var details types.OrderDetails
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &details))
+	assert.Equal(t, 5, details.Order.ID)
+	assert.Len(t, details.Items, 1)
+	assert.Equal(t, "maria@example.com", details.Customer.Email)
+	mockService.AssertExpectations(t)
+}
+
+func TestBulkUpdateOrderStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		payload        string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:    "Success - Reports each order",
+			payload: `{"orderIds": [1, 2], "status": "SHIPPED"}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("BulkUpdateOrderStatus", []int{1, 2}, types.OrderShipped, 1).Return([]*types.OrderStatusUpdateResult{
+					{OrderID: 1, Updated: true},
+					{OrderID: 2, Error: "BAD_REQUEST"},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Error - No orders",
+			payload:        `{"orderIds": [], "status": "SHIPPED"}`,
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Error - Unknown status",
+			payload: `{"orderIds": [1], "status": "LOST"}`,
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("BulkUpdateOrderStatus", []int{1}, types.OrderStatus("LOST"), 1).Return(nil, apperrors.NewValidationError("status", "invalid order status"))
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1, Role: types.RoleAdmin}, nil)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("PATCH", "/admin/orders/status", strings.NewReader(tt.payload))
+			req.Header.Set("Authorization", "Bearer "+createTestToken(1))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchOrdersQueryBudget(t *testing.T) {
+	// the customers of a page load in one query, however many orders it has
+	const orders = 50
+	const budget = 2
+
+	db := dbtest.New(t)
+	var orderRows, customerRows [][]driver.Value
+	for id := int64(1); id <= orders; id++ {
+		orderRows = append(orderRows, []driver.Value{
+			id, id, 100.0, string(types.OrderPaid), string(types.PaymentCreditCard), "payment",
+			"Avenida Paulista, 1000", "São Paulo", "SP", "01310-100", "Brasil", "PAC", 0.0, time.Now(), time.Now(),
+		})
+		customerRows = append(customerRows, []driver.Value{id, "Customer", "customer@example.com", "12345678909"})
+	}
+	db.On("FROM order_history", orderRows...)
+	db.On("FROM users", customerRows...)
+
+	service := NewService(NewStore(db.DB), new(MockCartStore), new(MockCartService), new(MockProductStore), newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore),
+		new(MockPaymentGateway), testPixIssuer, &MockUnitOfWork{})
+
+	page, err := service.SearchOrders(types.OrderFilter{Customer: "100%_off"}, types.PageRequest{Limit: orders})
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, orders)
+	assert.Equal(t, orders, page.Items[orders-1].Customer.ID)
+	db.AssertQueryBudget(t, budget)
+}
@@ -1,96 +1,412 @@
 package orders
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
 )
 
 type Service struct {
-	orderStore   types.OrderStore
-	cartStore    types.CartStore
-	productStore types.ProductStore
+	orderStore    types.OrderStore
+	cartStore     types.CartStore
+	cartService   types.CartService
+	productStore  types.ProductStore
+	addressStore  types.AddressStore
+	shipping      types.ShippingService
+	shipmentStore types.ShipmentStore
+	pixStore      types.PixStore
+	gateway       types.PaymentGateway
+	pix           types.PixIssuer
+	uow           types.UnitOfWork
 }
 
 func NewService(
 	orderStore types.OrderStore,
 	cartStore types.CartStore,
+	cartService types.CartService,
 	productStore types.ProductStore,
+	addressStore types.AddressStore,
+	shipping types.ShippingService,
+	shipmentStore types.ShipmentStore,
+	pixStore types.PixStore,
+	gateway types.PaymentGateway,
+	pix types.PixIssuer,
+	uow types.UnitOfWork,
 ) *Service {
 	return &Service{
-		orderStore:   orderStore,
-		cartStore:    cartStore,
-		productStore: productStore,
+		orderStore:    orderStore,
+		cartStore:     cartStore,
+		cartService:   cartService,
+		productStore:  productStore,
+		addressStore:  addressStore,
+		shipping:      shipping,
+		shipmentStore: shipmentStore,
+		pixStore:      pixStore,
+		gateway:       gateway,
+		pix:           pix,
+		uow:           uow,
 	}
 }
 
-func (s *Service) CreateOrderFromCart(userID int, paymentMethod types.PaymentMethod, paymentID string) (*types.OrderHistory, error) {
+// CreateOrderFromCart authorizes the cart total with the payment gateway and
+// turns the cart into a PENDING order. The order only becomes PAID once the
+// gateway confirms the capture. PIX orders come back with the charge the
+// customer has to pay, and are captured when the PSP reports the payment.
+//
+// Items are charged at their current price. When a price changed since the
+// item was added, the client has to send the new total as acceptedTotal,
+// otherwise a conflict carrying the cart validation is returned. The coupon of
+// the cart is redeemed with the order, and fails the checkout once it no
+// longer applies. The order keeps a copy of the address it ships to.
+//
+// The cost of the chosen shipping option is added to the order total, so
+// acceptedTotal is the total of the items alone, as the cart validation
+// gives it.
+func (s *Service) CreateOrderFromCart(userID int, addressID int, shippingOption string, paymentMethod types.PaymentMethod, paymentToken string, acceptedTotal *float64) (*types.OrderHistory, error) {
 	fmt.Printf("[ORDER SERVICE] Creating order for user %d with payment method %s\n", userID, paymentMethod)
 
 	if err := paymentMethod.Valid(); err != nil {
 		return nil, apperrors.NewValidationError("paymentMethod", err.Error())
 	}
 
-	cartItems, err := s.cartStore.GetMyCartItems(userID)
+	address, err := s.addressStore.GetAddress(userID, addressID)
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) && appErr.Type == apperrors.NotFound {
+		return nil, apperrors.NewValidationError("addressId", "choose one of your addresses to ship the order to")
+	}
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting address %d: %v\n", addressID, err)
+		return nil, fmt.Errorf("error getting address: %w", err)
+	}
+
+	validation, err := s.cartService.ValidateCart(types.UserCart(userID))
 	if err != nil {
-		fmt.Printf("[ORDER SERVICE] Error getting cart items: %v\n", err)
-		return nil, fmt.Errorf("error getting cart items: %w", err)
+		fmt.Printf("[ORDER SERVICE] Error validating cart: %v\n", err)
+		return nil, fmt.Errorf("error validating cart: %w", err)
+	}
+
+	if validation.Coupon != nil && !validation.Coupon.Valid {
+		return nil, apperrors.NewValidationError("coupon", validation.Coupon.Reason)
 	}
 
-	if cartItems == nil || len(*cartItems) == 0 {
+	if len(validation.Items) == 0 {
 		return nil, apperrors.NewValidationError("cart", "cart is empty")
 	}
 
-	total, err := s.cartStore.GetTotal(userID)
+	if validation.PriceChanged && (acceptedTotal == nil || utils.RoundCents(*acceptedTotal) != utils.RoundCents(validation.Total)) {
+		return nil, priceChangedError(validation)
+	}
+
+	shipping, err := s.chooseShipping(address.ShippingAddress(), validation, shippingOption)
 	if err != nil {
-		fmt.Printf("[ORDER SERVICE] Error calculating cart total: %v\n", err)
-		return nil, fmt.Errorf("error calculating cart total: %w", err)
+		return nil, err
 	}
+	total := utils.RoundCents(validation.Total + shipping.Price)
 
-	order, err := s.orderStore.CreateOrder(userID, total, paymentMethod, paymentID)
+	// the gateway is called before the transaction, so no stock stays locked
+	// while the provider answers
+	payment, err := s.gateway.Authorize(&types.PaymentRequest{
+		UserID: userID,
+		Amount: total,
+		Method: paymentMethod,
+		Token:  paymentToken,
+	})
+	if errors.Is(err, types.ErrPaymentDeclined) {
+		return nil, apperrors.NewValidationError("payment", "payment was declined")
+	}
 	if err != nil {
-		fmt.Printf("[ORDER SERVICE] Error creating order: %v\n", err)
-		return nil, fmt.Errorf("error creating order: %w", err)
+		fmt.Printf("[ORDER SERVICE] Error authorizing payment: %v\n", err)
+		return nil, fmt.Errorf("error authorizing payment: %w", err)
 	}
 
-	var orderItems []*types.OrderItem
-	for _, cartItem := range *cartItems {
-		orderItem := &types.OrderItem{
-			OrderID:   order.ID,
-			ProductID: cartItem.ProductID,
-			Quantity:  cartItem.Quantity,
-			Price:     cartItem.PriceAtAdding,
+	var order *types.OrderHistory
+	var pixCharge *types.PixCharge
+	err = s.uow.Do(func(tx *types.TxStores) error {
+		cartItems, err := tx.Carts.GetMyCartItems(types.UserCart(userID))
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting cart items: %v\n", err)
+			return fmt.Errorf("error getting cart items: %w", err)
 		}
-		orderItems = append(orderItems, orderItem)
 
-		err = s.productStore.UpdateStock(cartItem.ProductID, -cartItem.Quantity)
+		if cartItems == nil || len(*cartItems) == 0 {
+			return apperrors.NewValidationError("cart", "cart is empty")
+		}
+
+		prices, err := priceCart(*cartItems, validation)
+		if err != nil {
+			return err
+		}
+
+		order, err = tx.Orders.CreateOrder(userID, total, paymentMethod, payment.ID, address.ShippingAddress(), shipping)
 		if err != nil {
-			fmt.Printf("[ORDER SERVICE] Error updating stock for product %d: %v\n", cartItem.ProductID, err)
-			return nil, fmt.Errorf("error updating stock: %w", err)
+			fmt.Printf("[ORDER SERVICE] Error creating order: %v\n", err)
+			return fmt.Errorf("error creating order: %w", err)
 		}
+
+		err = tx.Orders.AddOrderStatusHistory(order.ID, nil, order.Status, userID, "")
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error recording order status: %v\n", err)
+			return fmt.Errorf("error recording order status: %w", err)
+		}
+
+		if validation.Coupon != nil {
+			err = redeemCoupon(tx, validation.Coupon, userID, order.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if paymentMethod == types.PaymentPix {
+			pixCharge, err = s.pix.NewCharge(order.ID, total)
+			if err != nil {
+				fmt.Printf("[ORDER SERVICE] Error generating pix charge: %v\n", err)
+				return fmt.Errorf("error generating pix charge: %w", err)
+			}
+
+			err = tx.Pix.CreatePixCharge(pixCharge)
+			if err != nil {
+				fmt.Printf("[ORDER SERVICE] Error saving pix charge: %v\n", err)
+				return fmt.Errorf("error saving pix charge: %w", err)
+			}
+		}
+
+		// the cart's own reservations are what it checks out, so only the
+		// units held by other carts are off limits
+		cartID := (*cartItems)[0].CartID
+
+		var orderItems []*types.OrderItem
+		for _, cartItem := range *cartItems {
+			available, err := tx.Reservations.GetAvailableStock(cartItem.ProductID, cartItem.VariantID, cartID)
+			if err != nil {
+				fmt.Printf("[ORDER SERVICE] Error getting available stock for product %d: %v\n", cartItem.ProductID, err)
+				return fmt.Errorf("error getting available stock: %w", err)
+			}
+
+			if available < cartItem.Quantity {
+				return apperrors.NewValidationError("stock",
+					fmt.Sprintf("only %d units of %s are available", max(available, 0), cartItem.ProductTitle))
+			}
+
+			line := validation.Item(cartItem.ProductID, cartItem.VariantID)
+			price := prices[lineKey{cartItem.ProductID, cartItem.VariantID}]
+			orderItem := &types.OrderItem{
+				OrderID:      order.ID,
+				ProductID:    cartItem.ProductID,
+				ProductTitle: line.ProductTitle,
+				VariantID:    cartItem.VariantID,
+				VariantLabel: cartItem.VariantLabel,
+				Quantity:     cartItem.Quantity,
+				BasePrice:    basePrice(line, price),
+				Price:        price,
+				Discount:     line.CouponDiscount,
+			}
+			orderItems = append(orderItems, orderItem)
+
+			err = updateStock(tx, cartItem.ProductID, cartItem.VariantID, -cartItem.Quantity)
+			if err != nil {
+				fmt.Printf("[ORDER SERVICE] Error updating stock for product %d: %v\n", cartItem.ProductID, err)
+				return fmt.Errorf("error updating stock: %w", err)
+			}
+		}
+
+		err = tx.Orders.AddOrderItems(order.ID, orderItems)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error adding order items: %v\n", err)
+			return fmt.Errorf("error adding order items: %w", err)
+		}
+
+		err = tx.Reservations.ReleaseCartReservations(cartID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error releasing cart reservations: %v\n", err)
+			return fmt.Errorf("error releasing cart reservations: %w", err)
+		}
+
+		err = tx.Carts.RemoveItemsFromCart(types.UserCart(userID))
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error clearing cart: %v\n", err)
+			return fmt.Errorf("error clearing cart: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		// nothing was saved, so release the money held for this checkout
+		if _, voidErr := s.gateway.Refund(payment.ID, payment.Amount); voidErr != nil {
+			fmt.Printf("[ORDER SERVICE] Error voiding payment %s: %v\n", payment.ID, voidErr)
+		}
+		return nil, err
 	}
 
-	err = s.orderStore.AddOrderItems(order.ID, orderItems)
+	fmt.Printf("[ORDER SERVICE] Order created successfully with ID %d\n", order.ID)
+
+	if pixCharge != nil {
+		s.attachPixCharge(order, pixCharge)
+		return order, nil
+	}
+
+	return s.capturePayment(order, userID), nil
+}
+
+// chooseShipping quotes the cart for delivery to destination and returns the
+// option of the given ID, which has to still be on offer.
+func (s *Service) chooseShipping(destination *types.ShippingAddress, cart *types.CartValidation, optionID string) (*types.ShippingOption, error) {
+	options, err := s.shipping.QuoteCart(destination, cart)
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		return nil, err
+	}
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error quoting shipping: %v\n", err)
+		return nil, fmt.Errorf("error quoting shipping: %w", err)
+	}
+
+	for _, option := range options {
+		if option.ID == optionID {
+			return option, nil
+		}
+	}
+
+	return nil, apperrors.NewValidationError("shippingOption",
+		fmt.Sprintf("shipping option %s is not available for this address", optionID))
+}
+
+// priceCart prices items at the current prices found by validation. The cart
+// must hold what it held when it was validated, as multi unit offers and the
+// coupon discount were worked out for those quantities.
+//
+// An item sold under a multi unit offer is recorded at the average price of
+// its units.
+func priceCart(items []*types.CartItem, validation *types.CartValidation) (map[lineKey]float64, error) {
+	if len(items) != len(validation.Items) {
+		return nil, apperrors.NewConflictError("cart", "cart changed during checkout, try again")
+	}
+
+	prices := make(map[lineKey]float64, len(items))
+	for _, item := range items {
+		line := validation.Item(item.ProductID, item.VariantID)
+		if line == nil || line.Quantity != item.Quantity {
+			return nil, apperrors.NewConflictError("cart", "cart changed during checkout, try again")
+		}
+
+		prices[lineKey{item.ProductID, item.VariantID}] = utils.RoundCents(line.LineTotal / float64(line.Quantity))
+	}
+
+	return prices, nil
+}
+
+// basePrice is the price of a unit of line before the product's discounts,
+// never below price, what the unit sells for.
+func basePrice(line *types.CartItemValidation, price float64) float64 {
+	if line.Pricing == nil {
+		return price
+	}
+	return max(line.Pricing.BasePrice, price)
+}
+
+// lineKey tells the lines of a cart or an order apart: a product sold without
+// variants, or one variant of a product.
+type lineKey struct {
+	productID int
+	variantID int
+}
+
+func (k lineKey) String() string {
+	if k.variantID == 0 {
+		return fmt.Sprintf("product %d", k.productID)
+	}
+	return fmt.Sprintf("variant %d of product %d", k.variantID, k.productID)
+}
+
+// updateStock changes the stock of the product of a line, or of its variant.
+func updateStock(tx *types.TxStores, productID int, variantID int, quantityChange int) error {
+	if variantID != 0 {
+		return tx.Variants.UpdateVariantStock(variantID, quantityChange)
+	}
+	return tx.Products.UpdateStock(productID, quantityChange)
+}
+
+// redeemCoupon records the coupon against the order and takes it off the
+// cart. The coupon may have run out of uses since the cart was validated.
+func redeemCoupon(tx *types.TxStores, coupon *types.CartCoupon, userID int, orderID int) error {
+	err := tx.Coupons.RedeemCoupon(&types.CouponRedemption{
+		CouponID: coupon.ID,
+		UserID:   userID,
+		OrderID:  orderID,
+		Amount:   coupon.Discount,
+	})
+	if errors.Is(err, types.ErrCouponUnavailable) {
+		return apperrors.NewValidationError("coupon", "coupon is no longer available")
+	}
 	if err != nil {
-		fmt.Printf("[ORDER SERVICE] Error adding order items: %v\n", err)
-		return nil, fmt.Errorf("error adding order items: %w", err)
+		fmt.Printf("[ORDER SERVICE] Error redeeming coupon %d: %v\n", coupon.ID, err)
+		return fmt.Errorf("error redeeming coupon: %w", err)
 	}
 
-	err = s.cartStore.RemoveItemsFromCart(userID)
+	err = tx.Carts.SetCoupon(types.UserCart(userID), 0)
 	if err != nil {
-		fmt.Printf("[ORDER SERVICE] Error clearing cart: %v\n", err)
-		return nil, fmt.Errorf("error clearing cart: %w", err)
+		fmt.Printf("[ORDER SERVICE] Error removing coupon from cart: %v\n", err)
+		return fmt.Errorf("error removing coupon from cart: %w", err)
 	}
 
-	fmt.Printf("[ORDER SERVICE] Order created successfully with ID %d\n", order.ID)
-	return order, nil
+	return nil
+}
+
+// priceChangedError carries the validation so the client can show what
+// changed and confirm the new total.
+func priceChangedError(validation *types.CartValidation) error {
+	err := apperrors.NewConflictError("cart", "prices changed since the items were added, accept the new total to continue")
+	err.Details["validation"] = validation
+	return err
+}
+
+// attachPixCharge renders the QR image of charge and adds it to order. Without
+// the image the customer can still pay with the copy and paste code.
+func (s *Service) attachPixCharge(order *types.OrderHistory, charge *types.PixCharge) {
+	png, err := s.pix.QRCode(charge.BRCode)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error rendering pix qr code for order %d: %v\n", order.ID, err)
+	}
+
+	charge.QRCodePNG = png
+	order.Pix = charge
 }
 
-func (s *Service) GetOrdersByUserID(userID int) ([]*types.OrderHistory, error) {
+// capturePayment asks the gateway to capture the order's payment and marks the
+// order PAID when it does. A failed capture is not a checkout failure: the
+// order stays PENDING and can be paid later.
+func (s *Service) capturePayment(order *types.OrderHistory, userID int) *types.OrderHistory {
+	payment, err := s.gateway.Capture(order.PaymentID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error capturing payment for order %d: %v\n", order.ID, err)
+		return order
+	}
+
+	if payment.Status != types.PaymentCaptured {
+		return order
+	}
+
+	err = s.uow.Do(func(tx *types.TxStores) error {
+		return s.changeStatus(tx, order, types.OrderPaid, userID, "")
+	})
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error marking order %d as paid: %v\n", order.ID, err)
+		return order
+	}
+
+	paid := *order
+	paid.Status = types.OrderPaid
+	return &paid
+}
+
+func (s *Service) GetOrdersByUserID(userID int, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
 	fmt.Printf("[ORDER SERVICE] Getting orders for user %d\n", userID)
 
-	orders, err := s.orderStore.GetOrdersByUserID(userID)
+	orders, err := s.orderStore.GetOrdersByUserID(userID, page)
 	if err != nil {
 		fmt.Printf("[ORDER SERVICE] Error getting orders: %v\n", err)
 		return nil, fmt.Errorf("error getting orders: %w", err)
@@ -108,6 +424,22 @@ func (s *Service) GetOrderByID(orderID int) (*types.OrderHistory, error) {
 		return nil, err
 	}
 
+	order.Shipments, err = s.shipmentStore.GetShipmentsByOrderID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+		return nil, fmt.Errorf("error getting shipments: %w", err)
+	}
+
+	if order.PaymentMethod == types.PaymentPix && order.Status == types.OrderPending {
+		// orders placed before PIX charges existed have none to show
+		charge, err := s.pixStore.GetPixChargeByOrderID(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting pix charge: %v\n", err)
+			return order, nil
+		}
+		s.attachPixCharge(order, charge)
+	}
+
 	return order, nil
 }
 
@@ -120,13 +452,19 @@ func (s *Service) GetOrderWithItems(orderID int) (*types.OrderWithItems, error)
 		return nil, err
 	}
 
+	orderWithItems.Order.Shipments, err = s.shipmentStore.GetShipmentsByOrderID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+		return nil, fmt.Errorf("error getting shipments: %w", err)
+	}
+
 	return orderWithItems, nil
 }
 
-func (s *Service) GetOrdersWithItems(userID int) ([]*types.OrderWithItems, error) {
+func (s *Service) GetOrdersWithItems(userID int, page types.PageRequest) (*types.Page[*types.OrderWithItems], error) {
 	fmt.Printf("[ORDER SERVICE] Getting orders with items for user ID %d\n", userID)
 
-	ordersWithItems, err := s.orderStore.GetOrdersWithItems(userID)
+	ordersWithItems, err := s.orderStore.GetOrdersWithItems(userID, page)
 	if err != nil {
 		fmt.Printf("[ORDER SERVICE] Error getting orders with items: %v\n", err)
 		return nil, err
@@ -135,18 +473,638 @@ func (s *Service) GetOrdersWithItems(userID int) ([]*types.OrderWithItems, error
 	return ordersWithItems, nil
 }
 
-func (s *Service) UpdateOrderStatus(orderID int, status types.OrderStatus) error {
-	fmt.Printf("[ORDER SERVICE] Updating order %d status to %s\n", orderID, status)
+func (s *Service) UpdateOrderStatus(orderID int, status types.OrderStatus, userID int, role types.UserRole) error {
+	fmt.Printf("[ORDER SERVICE] Updating order %d status to %s by user %d\n", orderID, status, userID)
 
 	if err := status.Valid(); err != nil {
 		return apperrors.NewValidationError("status", err.Error())
 	}
 
-	err := s.orderStore.UpdateOrderStatus(orderID, status)
+	if status == types.OrderPaid {
+		order, err := s.orderStore.GetOrderByID(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if err := s.checkCaptured(order); err != nil {
+			return err
+		}
+	}
+
+	var order *types.OrderHistory
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		var err error
+		order, err = tx.Orders.GetOrderByIDForUpdate(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if err := checkTransition(order.Status, status, role); err != nil {
+			return err
+		}
+
+		return s.changeStatus(tx, order, status, userID, "")
+	})
+	if err != nil {
+		return err
+	}
+
+	if status == types.OrderCancelled {
+		s.releasePayment(order)
+	}
+	return nil
+}
+
+func (s *Service) CancelOrder(orderID int, userID int, role types.UserRole, reason string) error {
+	fmt.Printf("[ORDER SERVICE] Cancelling order %d by user %d\n", orderID, userID)
+
+	var order *types.OrderHistory
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		var err error
+		order, err = tx.Orders.GetOrderByIDForUpdate(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if err := checkTransition(order.Status, types.OrderCancelled, role); err != nil {
+			return err
+		}
+
+		return s.changeStatus(tx, order, types.OrderCancelled, userID, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.releasePayment(order)
+	return nil
+}
+
+// changeStatus moves order to status and records it in the history. Callers
+// check the transition against the user's role first, and only move an order
+// to PAID once the gateway reports the payment as captured. Moving to
+// CANCELLED is refused once the order has shipments, and otherwise returns
+// the items not restocked yet to stock, records what is left of a paid order
+// as refunded, releases its coupon and notifies the customer. Once the
+// cancellation is committed, the caller releases the payment with
+// releasePayment.
+func (s *Service) changeStatus(tx *types.TxStores, order *types.OrderHistory, status types.OrderStatus, userID int, note string) error {
+	if status == types.OrderCancelled && order.Status == types.OrderPaid {
+		if err := checkNothingShipped(tx, order); err != nil {
+			return err
+		}
+	}
+
+	err := tx.Orders.UpdateOrderStatus(order.ID, order.Status, status)
 	if err != nil {
 		fmt.Printf("[ORDER SERVICE] Error updating order status: %v\n", err)
 		return err
 	}
 
+	err = tx.Orders.AddOrderStatusHistory(order.ID, &order.Status, status, userID, note)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error recording order status: %v\n", err)
+		return fmt.Errorf("error recording order status: %w", err)
+	}
+
+	if status != types.OrderCancelled {
+		return nil
+	}
+
+	items, err := tx.Orders.GetOrderItems(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting order items: %v\n", err)
+		return fmt.Errorf("error getting order items: %w", err)
+	}
+
+	// units already returned to stock by a refund are not restocked again
+	for _, item := range items {
+		quantity := item.Quantity - item.RestockedQuantity
+		if quantity <= 0 {
+			continue
+		}
+
+		err = updateStock(tx, item.ProductID, item.VariantID, quantity)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error restocking product %d: %v\n", item.ProductID, err)
+			return fmt.Errorf("error restocking product: %w", err)
+		}
+	}
+
+	if refundableStatuses[order.Status] {
+		err = s.recordCancellationRefund(tx, order, items, userID, note)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Coupons.ReleaseRedemption(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error releasing coupon of order %d: %v\n", order.ID, err)
+		return fmt.Errorf("error releasing coupon: %w", err)
+	}
+
+	message := fmt.Sprintf("Your order #%d has been cancelled.", order.ID)
+	if note != "" {
+		message = fmt.Sprintf("%s Reason: %s", message, note)
+	}
+
+	_, err = tx.Notifications.CreateNotification(&types.CreateNotificationPayload{
+		Title:   "Order cancelled",
+		Message: message,
+	}, order.UserID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error notifying user %d: %v\n", order.UserID, err)
+		return fmt.Errorf("error notifying customer: %w", err)
+	}
+
 	return nil
 }
+
+func (s *Service) checkCaptured(order *types.OrderHistory) error {
+	payment, err := s.gateway.Status(order.PaymentID)
+	if errors.Is(err, types.ErrPaymentNotFound) {
+		return apperrors.NewValidationError("status", "order has no payment to confirm")
+	}
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting payment status for order %d: %v\n", order.ID, err)
+		return fmt.Errorf("error getting payment status: %w", err)
+	}
+
+	if payment.Status != types.PaymentCaptured {
+		return apperrors.NewValidationError("status", fmt.Sprintf("payment is %s, not captured", payment.Status))
+	}
+
+	return nil
+}
+
+// recordCancellationRefund records what is left of a paid order as refunded
+// by its cancellation, matching what releasePayment gives back once the
+// cancellation is committed.
+func (s *Service) recordCancellationRefund(tx *types.TxStores, order *types.OrderHistory, items []*types.OrderItem, userID int, note string) error {
+	refunded, err := tx.Orders.GetRefundedAmount(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting refunded amount: %v\n", err)
+		return fmt.Errorf("error getting refunded amount: %w", err)
+	}
+
+	amount := utils.RoundCents(order.TotalAmount - refunded)
+	if amount <= 0 {
+		return nil
+	}
+
+	refund := &types.Refund{
+		OrderID:   order.ID,
+		Amount:    amount,
+		Reason:    note,
+		Restocked: true,
+	}
+	if refund.Reason == "" {
+		refund.Reason = "Order cancelled"
+	}
+	// cancellations made by no user, such as expired charges, have no author
+	if userID != 0 {
+		refund.CreatedBy = &userID
+	}
+
+	for _, item := range items {
+		remaining := item.Quantity - item.RefundedQuantity
+		if remaining > 0 {
+			refund.Items = append(refund.Items, newRefundItem(item, remaining))
+		}
+	}
+
+	err = tx.Orders.CreateRefund(refund)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error creating refund: %v\n", err)
+		return fmt.Errorf("error creating refund: %w", err)
+	}
+
+	return nil
+}
+
+// releasePayment voids the authorization of a cancelled order that was not
+// paid, or refunds what is left of one that was. It runs once the
+// cancellation is committed, so the money is never given back for an order
+// that stays open. The order is cancelled either way, so a gateway failure
+// is logged for the payment to be released by hand. Orders whose payment the
+// gateway does not know have nothing to release.
+func (s *Service) releasePayment(order *types.OrderHistory) {
+	if order.PaymentID == "" {
+		return
+	}
+
+	payment, err := s.gateway.Status(order.PaymentID)
+	if errors.Is(err, types.ErrPaymentNotFound) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting payment status for cancelled order %d, release it manually: %v\n", order.ID, err)
+		return
+	}
+
+	amount := payment.Amount
+	switch payment.Status {
+	case types.PaymentAuthorized:
+	case types.PaymentCaptured, types.PaymentPartiallyRefunded:
+		amount = utils.RoundCents(payment.Amount - payment.Refunded)
+	default:
+		return
+	}
+
+	_, err = s.gateway.Refund(order.PaymentID, amount)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error releasing payment for cancelled order %d, release it manually: %v\n", order.ID, err)
+	}
+}
+
+// refundableStatuses are the statuses in which the order's payment has been
+// captured, so there is money to give back.
+var refundableStatuses = map[types.OrderStatus]bool{
+	types.OrderPaid:      true,
+	types.OrderShipped:   true,
+	types.OrderDelivered: true,
+	types.OrderCompleted: true,
+}
+
+// RefundOrder gives back the money for the requested line quantities, or for
+// everything not refunded yet when no items are given. The refund never
+// exceeds what is left of the order total. Each unit gives back its price
+// less its share of the coupon discount. The refund of the last units left
+// gives back the rest of the total, shipping included, and releases the
+// coupon of the order. A partly shipped order becomes SHIPPED once the
+// refund leaves nothing more to ship.
+func (s *Service) RefundOrder(orderID int, userID int, payload *types.CreateRefundPayload) (*types.Refund, error) {
+	fmt.Printf("[ORDER SERVICE] Refunding order %d by user %d\n", orderID, userID)
+
+	var order *types.OrderHistory
+	var refund *types.Refund
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		var err error
+		// the lock keeps concurrent refunds from both reading the same
+		// refunded amount
+		order, err = tx.Orders.GetOrderByIDForUpdate(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if !refundableStatuses[order.Status] {
+			return apperrors.NewValidationError("status", fmt.Sprintf("order in status %s cannot be refunded", order.Status))
+		}
+
+		orderItems, err := tx.Orders.GetOrderItems(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order items: %v\n", err)
+			return fmt.Errorf("error getting order items: %w", err)
+		}
+
+		items, err := buildRefundItems(orderItems, payload.Items)
+		if err != nil {
+			return err
+		}
+
+		refunded, err := tx.Orders.GetRefundedAmount(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting refunded amount: %v\n", err)
+			return fmt.Errorf("error getting refunded amount: %w", err)
+		}
+
+		amount := 0.0
+		for _, item := range items {
+			amount += item.Amount
+		}
+		amount = math.Min(utils.RoundCents(amount), utils.RoundCents(order.TotalAmount-refunded))
+		full := refundsEveryUnit(orderItems, items)
+		if full {
+			// the last units refunded take the shipping not refunded yet
+			amount = utils.RoundCents(order.TotalAmount - refunded)
+		}
+		if amount <= 0 {
+			return apperrors.NewValidationError("items", "order is already fully refunded")
+		}
+
+		refund = &types.Refund{
+			OrderID:   orderID,
+			Amount:    amount,
+			Reason:    payload.Reason,
+			Restocked: payload.Restock,
+			CreatedBy: &userID,
+			Items:     items,
+		}
+
+		err = tx.Orders.CreateRefund(refund)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error creating refund: %v\n", err)
+			return fmt.Errorf("error creating refund: %w", err)
+		}
+
+		if full {
+			err = tx.Coupons.ReleaseRedemption(orderID)
+			if err != nil {
+				fmt.Printf("[ORDER SERVICE] Error releasing coupon of order %d: %v\n", orderID, err)
+				return fmt.Errorf("error releasing coupon: %w", err)
+			}
+		}
+
+		if payload.Restock {
+			for _, item := range items {
+				err = updateStock(tx, item.ProductID, item.VariantID, item.Quantity)
+				if err != nil {
+					fmt.Printf("[ORDER SERVICE] Error restocking product %d: %v\n", item.ProductID, err)
+					return fmt.Errorf("error restocking product: %w", err)
+				}
+			}
+		}
+
+		// refunding the units left to ship completes a partly shipped order
+		return s.shipIfComplete(tx, order, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.refundPayment(order, refund.Amount)
+
+	fmt.Printf("[ORDER SERVICE] Refund %d of %.2f created for order %d\n", refund.ID, refund.Amount, orderID)
+	return refund, nil
+}
+
+// refundPayment returns amount through the gateway once the refund is
+// committed. The refund stays recorded when the gateway fails, and is logged
+// to be paid by hand. Payments the gateway does not know were settled outside
+// of it, so the refund is only recorded.
+func (s *Service) refundPayment(order *types.OrderHistory, amount float64) {
+	if order.PaymentID == "" {
+		return
+	}
+
+	_, err := s.gateway.Refund(order.PaymentID, amount)
+	if errors.Is(err, types.ErrPaymentNotFound) {
+		fmt.Printf("[ORDER SERVICE] Payment of order %d is not known to the gateway, refund must be paid manually\n", order.ID)
+		return
+	}
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error refunding payment for order %d, refund must be paid manually: %v\n", order.ID, err)
+	}
+}
+
+// buildRefundItems checks the requested quantities against what is left to
+// refund of each order line. No request means every line is refunded whole.
+func buildRefundItems(orderItems []*types.OrderItem, requested []types.RefundItemPayload) ([]*types.RefundItem, error) {
+	var items []*types.RefundItem
+
+	if len(requested) == 0 {
+		for _, orderItem := range orderItems {
+			remaining := orderItem.Quantity - orderItem.RefundedQuantity
+			if remaining > 0 {
+				items = append(items, newRefundItem(orderItem, remaining))
+			}
+		}
+
+		if len(items) == 0 {
+			return nil, apperrors.NewValidationError("items", "order is already fully refunded")
+		}
+		return items, nil
+	}
+
+	byLine := make(map[lineKey]*types.OrderItem, len(orderItems))
+	for _, orderItem := range orderItems {
+		byLine[lineKey{orderItem.ProductID, orderItem.VariantID}] = orderItem
+	}
+
+	seen := make(map[lineKey]bool, len(requested))
+	for _, req := range requested {
+		line := lineKey{req.ProductID, req.VariantID}
+		orderItem, ok := byLine[line]
+		if !ok {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("%s is not in the order", line))
+		}
+
+		if seen[line] {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("%s is listed more than once", line))
+		}
+		seen[line] = true
+
+		remaining := orderItem.Quantity - orderItem.RefundedQuantity
+		if req.Quantity <= 0 || req.Quantity > remaining {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("%s has %d units left to refund", line, remaining))
+		}
+
+		items = append(items, newRefundItem(orderItem, req.Quantity))
+	}
+
+	return items, nil
+}
+
+// refundsEveryUnit reports whether items refund every unit of the order
+// that was not refunded before.
+func refundsEveryUnit(orderItems []*types.OrderItem, items []*types.RefundItem) bool {
+	refunding := make(map[lineKey]int, len(items))
+	for _, item := range items {
+		refunding[lineKey{item.ProductID, item.VariantID}] += item.Quantity
+	}
+
+	for _, orderItem := range orderItems {
+		if orderItem.Quantity-orderItem.RefundedQuantity > refunding[lineKey{orderItem.ProductID, orderItem.VariantID}] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRefundItem refunds quantity units of orderItem at what was paid for
+// them, so each unit gives back its share of the coupon discount.
+func newRefundItem(orderItem *types.OrderItem, quantity int) *types.RefundItem {
+	discount := orderItem.Discount * float64(quantity) / float64(orderItem.Quantity)
+	return &types.RefundItem{
+		ProductID: orderItem.ProductID,
+		VariantID: orderItem.VariantID,
+		Quantity:  quantity,
+		Amount:    utils.RoundCents(orderItem.Price*float64(quantity) - discount),
+	}
+}
+
+func (s *Service) GetRefunds(orderID int) ([]*types.Refund, error) {
+	fmt.Printf("[ORDER SERVICE] Getting refunds for order %d\n", orderID)
+
+	refunds, err := s.orderStore.GetRefundsByOrderID(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting refunds: %v\n", err)
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+// ConfirmPixPayment is called when the PSP reports that the charge with txid
+// was paid. The payment is captured with the gateway before the order is
+// marked PAID, and a capture failure is returned so the PSP notifies again.
+// A PIX received for a cancelled order or after the charge expired is
+// returned to the payer, and an expired order still PENDING is cancelled.
+// Repeated notifications for the same charge are ignored.
+func (s *Service) ConfirmPixPayment(txid string, endToEndID string, amount float64) error {
+	fmt.Printf("[ORDER SERVICE] Confirming pix payment %s\n", txid)
+
+	charge, err := s.pixStore.GetPixChargeByTxID(txid)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting pix charge: %v\n", err)
+		return err
+	}
+
+	if charge.PaidAt != nil {
+		return nil
+	}
+
+	if fmt.Sprintf("%.2f", amount) != fmt.Sprintf("%.2f", charge.Amount) {
+		return apperrors.NewValidationError("valor", fmt.Sprintf("paid %.2f but charge is %.2f", amount, charge.Amount))
+	}
+
+	order, err := s.orderStore.GetOrderByID(charge.OrderID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+		return err
+	}
+
+	expired := time.Now().After(charge.ExpiresAt)
+	late := expired || order.Status != types.OrderPending
+	if !late {
+		_, err = s.gateway.Capture(order.PaymentID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error capturing payment for order %d: %v\n", order.ID, err)
+			return fmt.Errorf("error capturing payment: %w", err)
+		}
+	}
+
+	cancelled := false
+	err = s.uow.Do(func(tx *types.TxStores) error {
+		err := tx.Pix.MarkPixChargePaid(txid, endToEndID)
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) && appErr.Type == apperrors.CONFLICT {
+			// a concurrent notification got here first
+			late = false
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error marking pix charge as paid: %v\n", err)
+			return err
+		}
+
+		order, err = tx.Orders.GetOrderByID(charge.OrderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if !late {
+			if order.Status != types.OrderPending {
+				// cancelled after the capture, so the cancellation refunds it
+				fmt.Printf("[ORDER SERVICE] Pix payment %s received for order %d in status %s\n", txid, order.ID, order.Status)
+				return nil
+			}
+
+			// no user made this change, so changedBy is left empty
+			return s.changeStatus(tx, order, types.OrderPaid, 0, fmt.Sprintf("PIX %s", endToEndID))
+		}
+
+		reason := fmt.Sprintf("PIX %s received for an order in status %s", endToEndID, order.Status)
+		if order.Status == types.OrderPending {
+			reason = fmt.Sprintf("PIX %s received after the charge expired", endToEndID)
+			err = s.changeStatus(tx, order, types.OrderCancelled, 0, "PIX charge expired")
+			if err != nil {
+				return err
+			}
+			cancelled = true
+		}
+
+		err = tx.Orders.CreateRefund(&types.Refund{
+			OrderID: order.ID,
+			Amount:  charge.Amount,
+			Reason:  reason,
+		})
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error recording pix refund: %v\n", err)
+			return fmt.Errorf("error recording pix refund: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		s.releasePayment(order)
+	}
+
+	if late {
+		err = s.gateway.RefundPix(endToEndID, charge.Amount)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error returning pix %s of order %d, refund must be paid manually: %v\n", endToEndID, order.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// expiredPixBatch is how many expired charges CancelExpiredPixOrders handles
+// in one call.
+const expiredPixBatch = 100
+
+// CancelExpiredPixOrders cancels the PENDING orders whose PIX charge expired
+// unpaid and releases their authorization. It returns how many were
+// cancelled. An order that fails to cancel is logged and left for the next
+// call.
+func (s *Service) CancelExpiredPixOrders() (int, error) {
+	charges, err := s.pixStore.GetExpiredPixCharges(expiredPixBatch)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting expired pix charges: %v\n", err)
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, charge := range charges {
+		var order *types.OrderHistory
+		err := s.uow.Do(func(tx *types.TxStores) error {
+			var err error
+			order, err = tx.Orders.GetOrderByID(charge.OrderID)
+			if err != nil {
+				return err
+			}
+
+			if order.Status != types.OrderPending {
+				order = nil
+				return nil
+			}
+
+			return s.changeStatus(tx, order, types.OrderCancelled, 0, "PIX charge expired")
+		})
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error cancelling order %d with expired pix charge: %v\n", charge.OrderID, err)
+			continue
+		}
+
+		if order != nil {
+			s.releasePayment(order)
+			cancelled++
+		}
+	}
+
+	return cancelled, nil
+}
+
+func (s *Service) GetOrderStatusHistory(orderID int) ([]*types.OrderStatusHistory, error) {
+	fmt.Printf("[ORDER SERVICE] Getting status history for order %d\n", orderID)
+
+	history, err := s.orderStore.GetOrderStatusHistory(orderID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting status history: %v\n", err)
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -2,9 +2,11 @@ package orders
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/payment"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/stretchr/testify/assert"
@@ -16,8 +18,8 @@ type MockOrderStore struct {
 	mock.Mock
 }
 
-func (m *MockOrderStore) CreateOrder(userID int, totalAmount float64, paymentMethod types.PaymentMethod, paymentID string) (*types.OrderHistory, error) {
-	args := m.Called(userID, totalAmount, paymentMethod, paymentID)
+func (m *MockOrderStore) CreateOrder(userID int, totalAmount float64, paymentMethod types.PaymentMethod, paymentID string, shipping *types.ShippingAddress, option *types.ShippingOption) (*types.OrderHistory, error) {
+	args := m.Called(userID, totalAmount, paymentMethod, paymentID, shipping, option)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -29,12 +31,12 @@ func (m *MockOrderStore) AddOrderItems(orderID int, items []*types.OrderItem) er
 	return args.Error(0)
 }
 
-func (m *MockOrderStore) GetOrdersByUserID(userID int) ([]*types.OrderHistory, error) {
-	args := m.Called(userID)
+func (m *MockOrderStore) GetOrdersByUserID(userID int, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
+	args := m.Called(userID, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.OrderHistory), args.Error(1)
+	return args.Get(0).(*types.Page[*types.OrderHistory]), args.Error(1)
 }
 
 func (m *MockOrderStore) GetOrderByID(orderID int) (*types.OrderHistory, error) {
@@ -45,6 +47,14 @@ func (m *MockOrderStore) GetOrderByID(orderID int) (*types.OrderHistory, error)
 	return args.Get(0).(*types.OrderHistory), args.Error(1)
 }
 
+func (m *MockOrderStore) GetOrderByIDForUpdate(orderID int) (*types.OrderHistory, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.OrderHistory), args.Error(1)
+}
+
 func (m *MockOrderStore) GetOrderItems(orderID int) ([]*types.OrderItem, error) {
 	args := m.Called(orderID)
 	if args.Get(0) == nil {
@@ -53,17 +63,30 @@ func (m *MockOrderStore) GetOrderItems(orderID int) ([]*types.OrderItem, error)
 	return args.Get(0).([]*types.OrderItem), args.Error(1)
 }
 
-func (m *MockOrderStore) UpdateOrderStatus(orderID int, status types.OrderStatus) error {
-	args := m.Called(orderID, status)
+func (m *MockOrderStore) UpdateOrderStatus(orderID int, from types.OrderStatus, to types.OrderStatus) error {
+	args := m.Called(orderID, from, to)
 	return args.Error(0)
 }
 
-func (m *MockOrderStore) GetOrdersWithItems(userID int) ([]*types.OrderWithItems, error) {
-	args := m.Called(userID)
+func (m *MockOrderStore) AddOrderStatusHistory(orderID int, from *types.OrderStatus, to types.OrderStatus, changedBy int, note string) error {
+	args := m.Called(orderID, from, to, changedBy, note)
+	return args.Error(0)
+}
+
+func (m *MockOrderStore) GetOrderStatusHistory(orderID int) ([]*types.OrderStatusHistory, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.OrderStatusHistory), args.Error(1)
+}
+
+func (m *MockOrderStore) GetOrdersWithItems(userID int, page types.PageRequest) (*types.Page[*types.OrderWithItems], error) {
+	args := m.Called(userID, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.OrderWithItems), args.Error(1)
+	return args.Get(0).(*types.Page[*types.OrderWithItems]), args.Error(1)
 }
 
 func (m *MockOrderStore) GetOrderWithItems(orderID int) (*types.OrderWithItems, error) {
@@ -74,6 +97,40 @@ func (m *MockOrderStore) GetOrderWithItems(orderID int) (*types.OrderWithItems,
 	return args.Get(0).(*types.OrderWithItems), args.Error(1)
 }
 
+func (m *MockOrderStore) CreateRefund(refund *types.Refund) error {
+	args := m.Called(refund)
+	return args.Error(0)
+}
+
+func (m *MockOrderStore) GetRefundsByOrderID(orderID int) ([]*types.Refund, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Refund), args.Error(1)
+}
+
+func (m *MockOrderStore) GetRefundedAmount(orderID int) (float64, error) {
+	args := m.Called(orderID)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockOrderStore) SearchOrders(filter types.OrderFilter, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
+	args := m.Called(filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.OrderHistory]), args.Error(1)
+}
+
+func (m *MockOrderStore) GetOrderCustomers(userIDs []int) (map[int]*types.OrderCustomer, error) {
+	args := m.Called(userIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.OrderCustomer), args.Error(1)
+}
+
 // MockCartStore é uma implementação mock da interface CartStore
 type MockCartStore struct {
 	mock.Mock
@@ -84,66 +141,112 @@ func (m *MockCartStore) CreateCart(userID int) error {
 	return args.Error(0)
 }
 
-func (m *MockCartStore) GetMyCartItems(userID int) (*[]*types.CartItem, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) CreateGuestCart(token string) (int, error) {
+	args := m.Called(token)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCartStore) DeleteCart(cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) DeleteIdleGuestCarts(idleSince time.Time) (int64, error) {
+	args := m.Called(idleSince)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCartStore) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	args := m.Called(owner)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartStore) AddItemToCart(productID int, userID int, price float64) (*types.CartItem, error) {
-	args := m.Called(productID, userID, price)
+func (m *MockCartStore) AddItemToCart(productID int, variantID int, variantLabel string, owner types.CartOwner, price float64) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, variantLabel, owner, price)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartItem), args.Error(1)
+}
+
+func (m *MockCartStore) SetItemQuantity(productID int, variantID int, variantLabel string, owner types.CartOwner, quantity int, price float64) (*types.CartItem, error) {
+	args := m.Called(productID, variantID, variantLabel, owner, quantity, price)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartStore) RemoveItemFromCart(productID int, userID int) error {
-	args := m.Called(productID, userID)
+func (m *MockCartStore) RemoveItemFromCart(productID int, variantID int, owner types.CartOwner) error {
+	args := m.Called(productID, variantID, owner)
 	return args.Error(0)
 }
 
-func (m *MockCartStore) GetTotal(userID int) (float64, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) GetTotal(owner types.CartOwner) (float64, error) {
+	args := m.Called(owner)
 	return args.Get(0).(float64), args.Error(1)
 }
 
-func (m *MockCartStore) GetCartID(userID int) (int, error) {
-	args := m.Called(userID)
+func (m *MockCartStore) GetCartID(owner types.CartOwner) (int, error) {
+	args := m.Called(owner)
 	return args.Get(0).(int), args.Error(1)
 }
 
-func (m *MockCartStore) GetCartItem(userID int, productID int) (*types.CartItem, error) {
-	args := m.Called(userID, productID)
+func (m *MockCartStore) GetCartItem(owner types.CartOwner, productID int, variantID int) (*types.CartItem, error) {
+	args := m.Called(owner, productID, variantID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*types.CartItem), args.Error(1)
 }
 
-func (m *MockCartStore) RemoveOneItemFromCart(userID int, productID int) error {
-	args := m.Called(userID, productID)
+func (m *MockCartStore) RemoveOneItemFromCart(owner types.CartOwner, productID int, variantID int) error {
+	args := m.Called(owner, productID, variantID)
 	return args.Error(0)
 }
 
-func (m *MockCartStore) RemoveItemsFromCart(userID int) error {
-	args := m.Called(userID)
+func (m *MockCartStore) RemoveItemsFromCart(owner types.CartOwner) error {
+	args := m.Called(owner)
 	return args.Error(0)
 }
 
+func (m *MockCartStore) SetCoupon(owner types.CartOwner, couponID int) error {
+	args := m.Called(owner, couponID)
+	return args.Error(0)
+}
+
+func (m *MockCartStore) GetCouponID(owner types.CartOwner) (int, error) {
+	args := m.Called(owner)
+	return args.Int(0), args.Error(1)
+}
+
 // MockProductStore é uma implementação mock da interface ProductStore
+type MockCartService struct {
+	types.CartService
+	mock.Mock
+}
+
+func (m *MockCartService) ValidateCart(owner types.CartOwner) (*types.CartValidation, error) {
+	args := m.Called(owner)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartValidation), args.Error(1)
+}
+
 type MockProductStore struct {
 	mock.Mock
 }
 
-func (m *MockProductStore) GetProducts() ([]*types.Product, error) {
-	args := m.Called()
+func (m *MockProductStore) GetProducts(page types.PageRequest) (*types.Page[*types.Product], error) {
+	args := m.Called(page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.Product), args.Error(1)
+	return args.Get(0).(*types.Page[*types.Product]), args.Error(1)
 }
 
 func (m *MockProductStore) CreateProduct(payload types.CreateProductPayload) error {
@@ -188,6 +291,14 @@ func (m *MockProductStore) GetImagesForProducts(productIDs []int) (map[int][]typ
 	return args.Get(0).(map[int][]types.ProductImage), args.Error(1)
 }
 
+func (m *MockProductStore) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.ProductDimensions), args.Error(1)
+}
+
 func (m *MockProductStore) UpdateProduct(productID int, payload types.UpdateProductPayload) error {
 	args := m.Called(productID, payload)
 	return args.Error(0)
@@ -198,8 +309,8 @@ func (m *MockProductStore) DeleteProduct(productID int) error {
 	return args.Error(0)
 }
 
-func (m *MockProductStore) GetProductsByCategory(categoryID int) ([]*types.Product, error) {
-	args := m.Called(categoryID)
+func (m *MockProductStore) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	args := m.Called(categoryID, includeSubcategories)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -214,20 +325,279 @@ func (m *MockProductStore) GetProductDetails(userID int, productID int) (*types.
 	return args.Get(0).(*types.ProductDetails), args.Error(1)
 }
 
-func (m *MockProductStore) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObject, error) {
-	args := m.Called(userID)
+func (m *MockProductStore) GetSimpleProductDetails(userID int, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) SearchProducts(userID int, text string, limit int) (*[]*types.SimpleProductObject, error) {
+	args := m.Called(userID, text, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.SimpleProductObject), args.Error(1)
 }
 
+func (m *MockProductStore) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductFacets), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePrices(afterID int, limit int) ([]*types.ProductPrice, error) {
+	args := m.Called(afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePricesForProducts(productIDs []int) ([]*types.ProductPrice, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) SavePrices(prices []*types.ProductPrice) error {
+	args := m.Called(prices)
+	return args.Error(0)
+}
+
+// MockUnitOfWork runs the callback directly against the given stores
+type MockPaymentGateway struct {
+	mock.Mock
+}
+
+func (m *MockPaymentGateway) Authorize(req *types.PaymentRequest) (*types.PaymentResult, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PaymentResult), args.Error(1)
+}
+
+func (m *MockPaymentGateway) Capture(paymentID string) (*types.PaymentResult, error) {
+	args := m.Called(paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PaymentResult), args.Error(1)
+}
+
+func (m *MockPaymentGateway) Refund(paymentID string, amount float64) (*types.PaymentResult, error) {
+	args := m.Called(paymentID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PaymentResult), args.Error(1)
+}
+
+func (m *MockPaymentGateway) Status(paymentID string) (*types.PaymentResult, error) {
+	args := m.Called(paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PaymentResult), args.Error(1)
+}
+
+func (m *MockPaymentGateway) RefundPix(endToEndID string, amount float64) error {
+	args := m.Called(endToEndID, amount)
+	return args.Error(0)
+}
+
+type MockCouponStore struct {
+	types.CouponStore
+	mock.Mock
+}
+
+func (m *MockCouponStore) ReleaseRedemption(orderID int) error {
+	args := m.Called(orderID)
+	return args.Error(0)
+}
+
+type MockPixStore struct {
+	mock.Mock
+}
+
+func (m *MockPixStore) CreatePixCharge(charge *types.PixCharge) error {
+	args := m.Called(charge)
+	return args.Error(0)
+}
+
+func (m *MockPixStore) GetPixChargeByOrderID(orderID int) (*types.PixCharge, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PixCharge), args.Error(1)
+}
+
+func (m *MockPixStore) GetPixChargeByTxID(txid string) (*types.PixCharge, error) {
+	args := m.Called(txid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PixCharge), args.Error(1)
+}
+
+func (m *MockPixStore) MarkPixChargePaid(txid string, endToEndID string) error {
+	args := m.Called(txid, endToEndID)
+	return args.Error(0)
+}
+
+func (m *MockPixStore) GetExpiredPixCharges(limit int) ([]*types.PixCharge, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.PixCharge), args.Error(1)
+}
+
+var testPixIssuer = payment.NewPixIssuer("pix@ecommerce.test", "ECOMMERCE TEST", "SAO PAULO", time.Hour)
+
+type MockReservationStore struct {
+	types.ReservationStore
+	mock.Mock
+}
+
+func (m *MockReservationStore) GetAvailableStock(productID int, variantID int, exceptCartID int) (int, error) {
+	args := m.Called(productID, variantID, exceptCartID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockReservationStore) ReleaseCartReservations(cartID int) error {
+	args := m.Called(cartID)
+	return args.Error(0)
+}
+
+type MockAddressStore struct {
+	types.AddressStore
+	mock.Mock
+}
+
+func (m *MockAddressStore) GetAddress(userID int, addressID int) (*types.Address, error) {
+	args := m.Called(userID, addressID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Address), args.Error(1)
+}
+
+// testAddress is address 3 of user 1, the one the test orders ship to
+var testAddress = &types.Address{
+	ID:         3,
+	UserID:     1,
+	Street:     "Avenida Paulista, 1000",
+	City:       "São Paulo",
+	State:      "SP",
+	PostalCode: "01310-100",
+	Country:    "Brasil",
+	IsDefault:  true,
+}
+
+func newMockAddressStore() *MockAddressStore {
+	m := new(MockAddressStore)
+	m.On("GetAddress", 1, 3).Return(testAddress, nil)
+	m.On("GetAddress", mock.Anything, mock.Anything).Return(nil, apperrors.NewEntityNotFound("address", 0))
+	return m
+}
+
+type MockShippingService struct {
+	types.ShippingService
+	mock.Mock
+}
+
+func (m *MockShippingService) QuoteCart(destination *types.ShippingAddress, cart *types.CartValidation) ([]*types.ShippingOption, error) {
+	args := m.Called(destination, cart)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ShippingOption), args.Error(1)
+}
+
+// freeShipping and sedex are what the test carts can ship with
+var (
+	freeShipping = &types.ShippingOption{ID: "PAC", Name: "PAC", Price: 0, MinDays: 5, MaxDays: 9, Free: true}
+	sedex        = &types.ShippingOption{ID: "SEDEX", Name: "SEDEX", Price: 15.5, MinDays: 1, MaxDays: 3}
+)
+
+func newMockShippingService() *MockShippingService {
+	m := new(MockShippingService)
+	m.On("QuoteCart", mock.Anything, mock.Anything).Return([]*types.ShippingOption{freeShipping, sedex}, nil)
+	return m
+}
+
+type MockShipmentStore struct {
+	types.ShipmentStore
+	mock.Mock
+}
+
+func (m *MockShipmentStore) GetShipmentsByOrderID(orderID int) ([]*types.Shipment, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.Shipment), args.Error(1)
+}
+
+type MockVariantStore struct {
+	types.VariantStore
+	mock.Mock
+}
+
+func (m *MockVariantStore) UpdateVariantStock(variantID int, quantityChange int) error {
+	args := m.Called(variantID, quantityChange)
+	return args.Error(0)
+}
+
+type MockUnitOfWork struct {
+	stores *types.TxStores
+}
+
+func (m *MockUnitOfWork) Do(fn func(stores *types.TxStores) error) error {
+	return fn(m.stores)
+}
+
 func TestCreateOrderFromCart(t *testing.T) {
 	mockOrderStore := new(MockOrderStore)
 	mockCartStore := new(MockCartStore)
 	mockProductStore := new(MockProductStore)
+	mockReservationStore := new(MockReservationStore)
+	mockCartService := new(MockCartService)
+
+	mockUoW := &MockUnitOfWork{stores: &types.TxStores{
+		Orders:       mockOrderStore,
+		Carts:        mockCartStore,
+		Products:     mockProductStore,
+		Reservations: mockReservationStore,
+	}}
+
+	mockGateway := new(MockPaymentGateway)
+
+	unchanged := &types.CartValidation{
+		Items:    []*types.CartItemValidation{{ProductID: 1, Quantity: 2, PriceAtAdding: 10.0, CurrentPrice: 10.0, LineTotal: 20.0, AvailableQuantity: 3}},
+		Subtotal: 20.0,
+		Total:    20.0,
+	}
 
-	service := NewService(mockOrderStore, mockCartStore, mockProductStore)
+	service := NewService(mockOrderStore, mockCartStore, mockCartService, mockProductStore, newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), mockGateway, testPixIssuer, mockUoW)
+
+	pending := types.OrderPending
 
 	tests := []struct {
 		name          string
@@ -242,7 +612,7 @@ func TestCreateOrderFromCart(t *testing.T) {
 			name:          "Success - Create order from cart",
 			userID:        1,
 			paymentMethod: types.PaymentCreditCard,
-			paymentID:     "payment123",
+			paymentID:     "tok_visa",
 			mockSetup: func() {
 				cartItems := &[]*types.CartItem{
 					{
@@ -253,8 +623,10 @@ func TestCreateOrderFromCart(t *testing.T) {
 						AddedAt:       time.Now(),
 					},
 				}
-				mockCartStore.On("GetMyCartItems", 1).Return(cartItems, nil)
-				mockCartStore.On("GetTotal", 1).Return(20.0, nil)
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(unchanged, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(cartItems, nil)
+				mockGateway.On("Authorize", &types.PaymentRequest{UserID: 1, Amount: 20.0, Method: types.PaymentCreditCard, Token: "tok_visa"}).
+					Return(&types.PaymentResult{ID: "payment123", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
 
 				order := &types.OrderHistory{
 					ID:            1,
@@ -266,9 +638,12 @@ func TestCreateOrderFromCart(t *testing.T) {
 					CreatedAt:     time.Now(),
 					UpdatedAt:     time.Now(),
 				}
-				mockOrderStore.On("CreateOrder", 1, 20.0, types.PaymentCreditCard, "payment123").Return(order, nil)
+				mockOrderStore.On("CreateOrder", 1, 20.0, types.PaymentCreditCard, "payment123", testAddress.ShippingAddress(), freeShipping).Return(order, nil)
+				mockOrderStore.On("AddOrderStatusHistory", 1, (*types.OrderStatus)(nil), types.OrderPending, 1, "").Return(nil)
 
+				mockReservationStore.On("GetAvailableStock", 1, 0, 1).Return(3, nil)
 				mockProductStore.On("UpdateStock", 1, -2).Return(nil)
+				mockReservationStore.On("ReleaseCartReservations", 1).Return(nil)
 
 				var orderItems []*types.OrderItem
 				orderItems = append(orderItems, &types.OrderItem{
@@ -278,29 +653,135 @@ func TestCreateOrderFromCart(t *testing.T) {
 					Price:     10.0,
 				})
 				mockOrderStore.On("AddOrderItems", 1, mock.Anything).Return(nil)
-				mockCartStore.On("RemoveItemsFromCart", 1).Return(nil)
+				mockCartStore.On("RemoveItemsFromCart", types.UserCart(1)).Return(nil)
+
+				captured := &types.PaymentResult{ID: "payment123", Status: types.PaymentCaptured, Amount: 20.0}
+				mockGateway.On("Capture", "payment123").Return(captured, nil)
+				mockOrderStore.On("UpdateOrderStatus", 1, types.OrderPending, types.OrderPaid).Return(nil)
+				mockOrderStore.On("AddOrderStatusHistory", 1, &pending, types.OrderPaid, 1, "").Return(nil)
 			},
 			expectedOrder: &types.OrderHistory{
 				ID:            1,
 				UserID:        1,
 				TotalAmount:   20.0,
-				Status:        types.OrderPending,
+				Status:        types.OrderPaid,
 				PaymentMethod: types.PaymentCreditCard,
 				PaymentID:     "payment123",
 			},
 			expectedError: nil,
 		},
 		{
-			name:          "Error - Empty cart",
+			name:          "Success - Order stays pending when capture fails",
 			userID:        1,
 			paymentMethod: types.PaymentCreditCard,
-			paymentID:     "payment123",
+			paymentID:     "tok_visa",
 			mockSetup: func() {
-				emptyCartItems := &[]*types.CartItem{}
-				mockCartStore.On("GetMyCartItems", 1).Return(emptyCartItems, nil)
+				cartItems := &[]*types.CartItem{
+					{CartID: 1, ProductID: 1, Quantity: 2, PriceAtAdding: 10.0},
+				}
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(unchanged, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(cartItems, nil)
+				mockGateway.On("Authorize", mock.Anything).
+					Return(&types.PaymentResult{ID: "payment123", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
+
+				order := &types.OrderHistory{
+					ID:            1,
+					UserID:        1,
+					TotalAmount:   20.0,
+					Status:        types.OrderPending,
+					PaymentMethod: types.PaymentCreditCard,
+					PaymentID:     "payment123",
+				}
+				mockOrderStore.On("CreateOrder", 1, 20.0, types.PaymentCreditCard, "payment123", testAddress.ShippingAddress(), freeShipping).Return(order, nil)
+				mockOrderStore.On("AddOrderStatusHistory", 1, (*types.OrderStatus)(nil), types.OrderPending, 1, "").Return(nil)
+				mockReservationStore.On("GetAvailableStock", 1, 0, 1).Return(2, nil)
+				mockProductStore.On("UpdateStock", 1, -2).Return(nil)
+				mockReservationStore.On("ReleaseCartReservations", 1).Return(nil)
+				mockOrderStore.On("AddOrderItems", 1, mock.Anything).Return(nil)
+				mockCartStore.On("RemoveItemsFromCart", types.UserCart(1)).Return(nil)
+
+				mockGateway.On("Capture", "payment123").Return(nil, fmt.Errorf("provider unavailable"))
 			},
-			expectedOrder: nil,
-			expectedError: apperrors.NewValidationError("cart", "cart is empty"),
+			expectedOrder: &types.OrderHistory{
+				ID:            1,
+				UserID:        1,
+				TotalAmount:   20.0,
+				Status:        types.OrderPending,
+				PaymentMethod: types.PaymentCreditCard,
+				PaymentID:     "payment123",
+			},
+			expectedError: nil,
+		},
+		{
+			name:          "Error - Payment declined",
+			userID:        1,
+			paymentMethod: types.PaymentCreditCard,
+			paymentID:     "tok_declined",
+			mockSetup: func() {
+				cartItems := &[]*types.CartItem{
+					{CartID: 1, ProductID: 1, Quantity: 2, PriceAtAdding: 10.0},
+				}
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(unchanged, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(cartItems, nil)
+				mockGateway.On("Authorize", mock.Anything).Return(nil, types.ErrPaymentDeclined)
+			},
+			expectedOrder: nil,
+			expectedError: apperrors.NewValidationError("payment", "payment was declined"),
+		},
+		{
+			name:          "Error - Units held by other carts",
+			userID:        1,
+			paymentMethod: types.PaymentCreditCard,
+			paymentID:     "tok_visa",
+			mockSetup: func() {
+				cartItems := &[]*types.CartItem{
+					{CartID: 1, ProductID: 1, ProductTitle: "Mug", Quantity: 2, PriceAtAdding: 10.0},
+				}
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(unchanged, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(cartItems, nil)
+				mockGateway.On("Authorize", mock.Anything).
+					Return(&types.PaymentResult{ID: "payment123", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
+				mockOrderStore.On("CreateOrder", 1, 20.0, types.PaymentCreditCard, "payment123", testAddress.ShippingAddress(), freeShipping).
+					Return(&types.OrderHistory{ID: 1, UserID: 1, Status: types.OrderPending, PaymentID: "payment123"}, nil)
+				mockOrderStore.On("AddOrderStatusHistory", 1, (*types.OrderStatus)(nil), types.OrderPending, 1, "").Return(nil)
+				mockReservationStore.On("GetAvailableStock", 1, 0, 1).Return(1, nil)
+				mockGateway.On("Refund", "payment123", 20.0).Return(&types.PaymentResult{ID: "payment123", Status: types.PaymentVoided}, nil)
+			},
+			expectedOrder: nil,
+			expectedError: apperrors.NewValidationError("stock", "only 1 units of Mug are available"),
+		},
+		{
+			name:          "Error - Empty cart",
+			userID:        1,
+			paymentMethod: types.PaymentCreditCard,
+			paymentID:     "payment123",
+			mockSetup: func() {
+				emptyCartItems := &[]*types.CartItem{}
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(&types.CartValidation{}, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(emptyCartItems, nil)
+			},
+			expectedOrder: nil,
+			expectedError: apperrors.NewValidationError("cart", "cart is empty"),
+		},
+		{
+			name:          "Error - Price changed and new total not accepted",
+			userID:        1,
+			paymentMethod: types.PaymentCreditCard,
+			paymentID:     "tok_visa",
+			mockSetup: func() {
+				cartItems := &[]*types.CartItem{
+					{CartID: 1, ProductID: 1, Quantity: 2, PriceAtAdding: 8.0},
+				}
+				mockCartService.On("ValidateCart", types.UserCart(1)).Return(&types.CartValidation{
+					Items:        []*types.CartItemValidation{{ProductID: 1, Quantity: 2, PriceAtAdding: 8.0, CurrentPrice: 10.0, LineTotal: 20.0, PriceChanged: true}},
+					Subtotal:     20.0,
+					Total:        20.0,
+					PriceChanged: true,
+				}, nil)
+				mockCartStore.On("GetMyCartItems", types.UserCart(1)).Return(cartItems, nil)
+			},
+			expectedOrder: nil,
+			expectedError: apperrors.NewConflictError("cart", "prices changed since the items were added, accept the new total to continue"),
 		},
 		{
 			name:          "Error - Invalid payment method",
@@ -318,9 +799,12 @@ func TestCreateOrderFromCart(t *testing.T) {
 			mockOrderStore.ExpectedCalls = nil
 			mockCartStore.ExpectedCalls = nil
 			mockProductStore.ExpectedCalls = nil
+			mockGateway.ExpectedCalls = nil
+			mockReservationStore.ExpectedCalls = nil
+			mockCartService.ExpectedCalls = nil
 			tt.mockSetup()
 
-			order, err := service.CreateOrderFromCart(tt.userID, tt.paymentMethod, tt.paymentID)
+			order, err := service.CreateOrderFromCart(tt.userID, 3, "PAC", tt.paymentMethod, tt.paymentID, nil)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -348,6 +832,9 @@ func TestCreateOrderFromCart(t *testing.T) {
 			mockOrderStore.AssertExpectations(t)
 			mockCartStore.AssertExpectations(t)
 			mockProductStore.AssertExpectations(t)
+			mockGateway.AssertExpectations(t)
+			mockReservationStore.AssertExpectations(t)
+			mockCartService.AssertExpectations(t)
 		})
 	}
 }
@@ -357,7 +844,14 @@ func TestGetOrdersByUserID(t *testing.T) {
 	mockCartStore := new(MockCartStore)
 	mockProductStore := new(MockProductStore)
 
-	service := NewService(mockOrderStore, mockCartStore, mockProductStore)
+	mockUoW := &MockUnitOfWork{stores: &types.TxStores{
+		Orders:   mockOrderStore,
+		Carts:    mockCartStore,
+		Products: mockProductStore,
+	}}
+
+	service := NewService(mockOrderStore, mockCartStore, new(MockCartService), mockProductStore, newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), new(MockPaymentGateway), testPixIssuer, mockUoW)
+	firstPage := types.PageRequest{Limit: types.DefaultPageLimit}
 
 	tests := []struct {
 		name           string
@@ -382,7 +876,7 @@ func TestGetOrdersByUserID(t *testing.T) {
 						UpdatedAt:     time.Now(),
 					},
 				}
-				mockOrderStore.On("GetOrdersByUserID", 1).Return(orders, nil)
+				mockOrderStore.On("GetOrdersByUserID", 1, firstPage).Return(&types.Page[*types.OrderHistory]{Items: orders}, nil)
 			},
 			expectedOrders: []*types.OrderHistory{
 				{
@@ -400,7 +894,7 @@ func TestGetOrdersByUserID(t *testing.T) {
 			name:   "Error - Database error",
 			userID: 1,
 			mockSetup: func() {
-				mockOrderStore.On("GetOrdersByUserID", 1).Return(nil, errors.New("database error"))
+				mockOrderStore.On("GetOrdersByUserID", 1, firstPage).Return(nil, errors.New("database error"))
 			},
 			expectedOrders: nil,
 			expectedError:  errors.New("error getting orders: database error"),
@@ -414,7 +908,7 @@ func TestGetOrdersByUserID(t *testing.T) {
 			mockProductStore.ExpectedCalls = nil
 			tt.mockSetup()
 
-			orders, err := service.GetOrdersByUserID(tt.userID)
+			orders, err := service.GetOrdersByUserID(tt.userID, firstPage)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -423,8 +917,8 @@ func TestGetOrdersByUserID(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, orders)
-				assert.Equal(t, len(tt.expectedOrders), len(orders))
-				for i, order := range orders {
+				assert.Equal(t, len(tt.expectedOrders), len(orders.Items))
+				for i, order := range orders.Items {
 					assert.Equal(t, tt.expectedOrders[i].ID, order.ID)
 					assert.Equal(t, tt.expectedOrders[i].UserID, order.UserID)
 					assert.Equal(t, tt.expectedOrders[i].TotalAmount, order.TotalAmount)
@@ -438,3 +932,1864 @@ func TestGetOrdersByUserID(t *testing.T) {
 		})
 	}
 }
+
+type MockNotificationStore struct {
+	mock.Mock
+}
+
+func (m *MockNotificationStore) GetMyNotifications(userID int, page types.PageRequest) (*types.Page[types.Notification], error) {
+	args := m.Called(userID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[types.Notification]), args.Error(1)
+}
+
+func (m *MockNotificationStore) GetNotifications(page types.PageRequest) (*types.Page[types.Notification], error) {
+	args := m.Called(page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[types.Notification]), args.Error(1)
+}
+
+func (m *MockNotificationStore) GetNotificationByID(notificationID int) (*types.Notification, error) {
+	args := m.Called(notificationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Notification), args.Error(1)
+}
+
+func (m *MockNotificationStore) CreateNotification(payload *types.CreateNotificationPayload, userID int) (*types.Notification, error) {
+	args := m.Called(payload, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Notification), args.Error(1)
+}
+
+func (m *MockNotificationStore) DeleteNotification(notificationID int) error {
+	args := m.Called(notificationID)
+	return args.Error(0)
+}
+
+// fakeCheckoutDB is an in-memory copy of the tables checkout writes to.
+// Do snapshots them before running the callback and restores the snapshot
+// when it fails, the same way a rolled back transaction would.
+type fakeCheckoutDB struct {
+	orders        map[int]*types.OrderHistory
+	orderItems    map[int][]*types.OrderItem
+	history       []*types.OrderStatusHistory
+	stock         map[int]int
+	cart          []*types.CartItem
+	notifications []*types.Notification
+	pixCharges    map[string]*types.PixCharge
+	refunds       []*types.Refund
+	shipments     []*types.Shipment
+	// reserved is the units other carts hold of each product
+	reserved map[int]int
+	// prices overrides the current price of a product, which is otherwise the
+	// price it was added to the cart at
+	prices map[int]float64
+	// lineTotals overrides what all the units of a product cost, as a multi
+	// unit offer would
+	lineTotals map[int]float64
+	// coupon is the coupon applied to the cart, with the discount it gives
+	coupon      *types.CartCoupon
+	redemptions []*types.CouponRedemption
+	// couponUses is how many more times the coupon can be redeemed
+	couponUses  int
+	nextOrderID int
+	// the gateway stands for the payment provider, so Do never rolls it back
+	gateway    *payment.FakeGateway
+	failOn     string
+	failCommit bool
+	// inTx is set while Do runs its callback
+	inTx bool
+}
+
+func newFakeCheckoutDB() *fakeCheckoutDB {
+	return &fakeCheckoutDB{
+		orders:     map[int]*types.OrderHistory{},
+		orderItems: map[int][]*types.OrderItem{},
+		stock:      map[int]int{1: 5, 2: 5},
+		cart: []*types.CartItem{
+			{CartID: 1, ProductID: 1, Quantity: 2, PriceAtAdding: 10.0},
+			{CartID: 1, ProductID: 2, Quantity: 1, PriceAtAdding: 30.0},
+		},
+		pixCharges:  map[string]*types.PixCharge{},
+		reserved:    map[int]int{},
+		prices:      map[int]float64{},
+		nextOrderID: 1,
+		gateway:     payment.NewFakeGateway(),
+	}
+}
+
+func (db *fakeCheckoutDB) fail(step string) error {
+	if db.failOn == step {
+		return fmt.Errorf("%s failed", step)
+	}
+	return nil
+}
+
+func (db *fakeCheckoutDB) snapshot() *fakeCheckoutDB {
+	c := &fakeCheckoutDB{
+		orders:        map[int]*types.OrderHistory{},
+		orderItems:    map[int][]*types.OrderItem{},
+		history:       append([]*types.OrderStatusHistory{}, db.history...),
+		stock:         map[int]int{},
+		cart:          append([]*types.CartItem{}, db.cart...),
+		notifications: append([]*types.Notification{}, db.notifications...),
+		pixCharges:    map[string]*types.PixCharge{},
+		refunds:       append([]*types.Refund{}, db.refunds...),
+		shipments:     append([]*types.Shipment{}, db.shipments...),
+		reserved:      map[int]int{},
+		coupon:        db.coupon,
+		redemptions:   append([]*types.CouponRedemption{}, db.redemptions...),
+		couponUses:    db.couponUses,
+		nextOrderID:   db.nextOrderID,
+	}
+	for k, v := range db.reserved {
+		c.reserved[k] = v
+	}
+	for k, v := range db.orders {
+		c.orders[k] = v
+	}
+	for k, v := range db.orderItems {
+		c.orderItems[k] = v
+	}
+	for k, v := range db.stock {
+		c.stock[k] = v
+	}
+	for k, v := range db.pixCharges {
+		c.pixCharges[k] = v
+	}
+	return c
+}
+
+func (db *fakeCheckoutDB) restore(s *fakeCheckoutDB) {
+	db.orders = s.orders
+	db.orderItems = s.orderItems
+	db.history = s.history
+	db.stock = s.stock
+	db.cart = s.cart
+	db.notifications = s.notifications
+	db.pixCharges = s.pixCharges
+	db.refunds = s.refunds
+	db.shipments = s.shipments
+	db.reserved = s.reserved
+	db.coupon = s.coupon
+	db.redemptions = s.redemptions
+	db.couponUses = s.couponUses
+	db.nextOrderID = s.nextOrderID
+}
+
+func (db *fakeCheckoutDB) Do(fn func(stores *types.TxStores) error) error {
+	before := db.snapshot()
+	db.inTx = true
+	defer func() { db.inTx = false }()
+
+	err := fn(&types.TxStores{
+		Orders:        &fakeOrderStore{db: db},
+		Carts:         &fakeCartStore{db: db},
+		Products:      &fakeProductStore{db: db},
+		Notifications: &fakeNotificationStore{db: db},
+		Pix:           &fakePixStore{db: db},
+		Reservations:  &fakeReservationStore{db: db},
+		Coupons:       &fakeCouponStore{db: db},
+		Shipments:     &fakeShipmentStore{db: db},
+	})
+	if err == nil && db.failCommit {
+		err = fmt.Errorf("commit failed")
+	}
+	if err != nil {
+		db.restore(before)
+		return err
+	}
+	return nil
+}
+
+type fakeOrderStore struct {
+	types.OrderStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeOrderStore) CreateOrder(userID int, totalAmount float64, paymentMethod types.PaymentMethod, paymentID string, shipping *types.ShippingAddress, option *types.ShippingOption) (*types.OrderHistory, error) {
+	if err := f.db.fail("CreateOrder"); err != nil {
+		return nil, err
+	}
+	order := &types.OrderHistory{
+		ID:              f.db.nextOrderID,
+		UserID:          userID,
+		TotalAmount:     totalAmount,
+		Status:          types.OrderPending,
+		PaymentMethod:   paymentMethod,
+		PaymentID:       paymentID,
+		ShippingAddress: shipping,
+		ShippingService: option.ID,
+		ShippingCost:    option.Price,
+	}
+	f.db.orders[order.ID] = order
+	f.db.nextOrderID++
+	return order, nil
+}
+
+func (f *fakeOrderStore) AddOrderItems(orderID int, items []*types.OrderItem) error {
+	if err := f.db.fail("AddOrderItems"); err != nil {
+		return err
+	}
+	f.db.orderItems[orderID] = items
+	return nil
+}
+
+func (f *fakeOrderStore) AddOrderStatusHistory(orderID int, from *types.OrderStatus, to types.OrderStatus, changedBy int, note string) error {
+	if err := f.db.fail("AddOrderStatusHistory"); err != nil {
+		return err
+	}
+	f.db.history = append(f.db.history, &types.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  &changedBy,
+		Note:       note,
+	})
+	return nil
+}
+
+func (f *fakeOrderStore) GetOrderByID(orderID int) (*types.OrderHistory, error) {
+	order, ok := f.db.orders[orderID]
+	if !ok {
+		return nil, apperrors.NewEntityNotFound("order", orderID)
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (f *fakeOrderStore) GetOrderByIDForUpdate(orderID int) (*types.OrderHistory, error) {
+	return f.GetOrderByID(orderID)
+}
+
+func (f *fakeOrderStore) GetOrderItems(orderID int) ([]*types.OrderItem, error) {
+	if err := f.db.fail("GetOrderItems"); err != nil {
+		return nil, err
+	}
+	var items []*types.OrderItem
+	for _, item := range f.db.orderItems[orderID] {
+		copied := *item
+		for _, refund := range f.db.refunds {
+			for _, refunded := range refund.Items {
+				if refund.OrderID == orderID && refunded.ProductID == item.ProductID {
+					copied.RefundedQuantity += refunded.Quantity
+					if refund.Restocked {
+						copied.RestockedQuantity += refunded.Quantity
+					}
+				}
+			}
+		}
+		items = append(items, &copied)
+	}
+	return items, nil
+}
+
+func (f *fakeOrderStore) CreateRefund(refund *types.Refund) error {
+	if err := f.db.fail("CreateRefund"); err != nil {
+		return err
+	}
+	refund.ID = len(f.db.refunds) + 1
+	f.db.refunds = append(f.db.refunds, refund)
+	return nil
+}
+
+func (f *fakeOrderStore) GetRefundedAmount(orderID int) (float64, error) {
+	total := 0.0
+	for _, refund := range f.db.refunds {
+		if refund.OrderID == orderID {
+			total += refund.Amount
+		}
+	}
+	return total, nil
+}
+
+func (f *fakeOrderStore) UpdateOrderStatus(orderID int, from types.OrderStatus, to types.OrderStatus) error {
+	if err := f.db.fail("UpdateOrderStatus"); err != nil {
+		return err
+	}
+	// store a copy so the snapshot taken by Do keeps the old status
+	updated := *f.db.orders[orderID]
+	updated.Status = to
+	f.db.orders[orderID] = &updated
+	return nil
+}
+
+type fakeShipmentStore struct {
+	types.ShipmentStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeShipmentStore) CreateShipment(shipment *types.Shipment) error {
+	if err := f.db.fail("CreateShipment"); err != nil {
+		return err
+	}
+	shipment.ID = len(f.db.shipments) + 1
+	for _, item := range shipment.Items {
+		item.ShipmentID = shipment.ID
+	}
+	copied := *shipment
+	f.db.shipments = append(f.db.shipments, &copied)
+	return nil
+}
+
+func (f *fakeShipmentStore) GetShipment(orderID int, shipmentID int) (*types.Shipment, error) {
+	for _, shipment := range f.db.shipments {
+		if shipment.OrderID == orderID && shipment.ID == shipmentID {
+			copied := *shipment
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.NewEntityNotFound("shipment", shipmentID)
+}
+
+func (f *fakeShipmentStore) GetShipmentsByOrderID(orderID int) ([]*types.Shipment, error) {
+	shipments := []*types.Shipment{}
+	for _, shipment := range f.db.shipments {
+		if shipment.OrderID == orderID {
+			copied := *shipment
+			shipments = append(shipments, &copied)
+		}
+	}
+	return shipments, nil
+}
+
+func (f *fakeShipmentStore) UpdateShipment(shipment *types.Shipment) error {
+	if err := f.db.fail("UpdateShipment"); err != nil {
+		return err
+	}
+	// store a copy so the snapshot taken by Do keeps the old shipment
+	copied := *shipment
+	f.db.shipments[shipment.ID-1] = &copied
+	return nil
+}
+
+type fakeCartStore struct {
+	types.CartStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeCartStore) GetMyCartItems(owner types.CartOwner) (*[]*types.CartItem, error) {
+	if err := f.db.fail("GetMyCartItems"); err != nil {
+		return nil, err
+	}
+	items := append([]*types.CartItem{}, f.db.cart...)
+	return &items, nil
+}
+
+func (f *fakeCartStore) GetTotal(owner types.CartOwner) (float64, error) {
+	if err := f.db.fail("GetTotal"); err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, item := range f.db.cart {
+		total += item.PriceAtAdding * float64(item.Quantity)
+	}
+	return total, nil
+}
+
+func (f *fakeCartStore) RemoveItemsFromCart(owner types.CartOwner) error {
+	if err := f.db.fail("RemoveItemsFromCart"); err != nil {
+		return err
+	}
+	f.db.cart = nil
+	return nil
+}
+
+func (f *fakeCartStore) SetCoupon(owner types.CartOwner, couponID int) error {
+	if err := f.db.fail("SetCoupon"); err != nil {
+		return err
+	}
+	if couponID == 0 {
+		f.db.coupon = nil
+	}
+	return nil
+}
+
+type fakeCouponStore struct {
+	types.CouponStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeCouponStore) RedeemCoupon(redemption *types.CouponRedemption) error {
+	if err := f.db.fail("RedeemCoupon"); err != nil {
+		return err
+	}
+	if f.db.couponUses == 0 {
+		return types.ErrCouponUnavailable
+	}
+	f.db.couponUses--
+	f.db.redemptions = append(f.db.redemptions, redemption)
+	return nil
+}
+
+func (f *fakeCouponStore) ReleaseRedemption(orderID int) error {
+	if err := f.db.fail("ReleaseRedemption"); err != nil {
+		return err
+	}
+	var kept []*types.CouponRedemption
+	for _, redemption := range f.db.redemptions {
+		if redemption.OrderID == orderID {
+			f.db.couponUses++
+			continue
+		}
+		kept = append(kept, redemption)
+	}
+	f.db.redemptions = kept
+	return nil
+}
+
+// fakeCartService validates the cart outside of the transaction, like the
+// real cart service
+type fakeCartService struct {
+	types.CartService
+	db *fakeCheckoutDB
+}
+
+func (f *fakeCartService) ValidateCart(owner types.CartOwner) (*types.CartValidation, error) {
+	if err := f.db.fail("ValidateCart"); err != nil {
+		return nil, err
+	}
+
+	validation := &types.CartValidation{}
+	for _, item := range f.db.cart {
+		price, ok := f.db.prices[item.ProductID]
+		if !ok {
+			price = item.PriceAtAdding
+		}
+
+		var pricing *types.PriceBreakdown
+		lineTotal, ok := f.db.lineTotals[item.ProductID]
+		if ok {
+			pricing = &types.PriceBreakdown{BasePrice: price, Quantity: item.Quantity, UnitPrice: price, Total: lineTotal}
+		} else {
+			lineTotal = price * float64(item.Quantity)
+		}
+
+		line := &types.CartItemValidation{
+			ProductID:         item.ProductID,
+			Quantity:          item.Quantity,
+			PriceAtAdding:     item.PriceAtAdding,
+			CurrentPrice:      price,
+			LineTotal:         lineTotal,
+			AvailableQuantity: f.db.stock[item.ProductID] - f.db.reserved[item.ProductID],
+			PriceChanged:      price != item.PriceAtAdding,
+			Pricing:           pricing,
+		}
+		validation.Items = append(validation.Items, line)
+		validation.Subtotal += lineTotal
+		validation.PriceChanged = validation.PriceChanged || line.PriceChanged
+	}
+
+	validation.Total = validation.Subtotal
+	if f.db.coupon != nil {
+		validation.Coupon = f.db.coupon
+		if f.db.coupon.Valid {
+			validation.Discount = f.db.coupon.Discount
+			validation.Total -= f.db.coupon.Discount
+			for i, discount := range f.db.coupon.LineDiscounts {
+				validation.Items[i].CouponDiscount = discount
+			}
+		}
+	}
+
+	return validation, nil
+}
+
+type fakeProductStore struct {
+	types.ProductStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeProductStore) UpdateStock(productID int, quantityChange int) error {
+	if err := f.db.fail(fmt.Sprintf("UpdateStock:%d", productID)); err != nil {
+		return err
+	}
+	if f.db.stock[productID]+quantityChange < 0 {
+		return fmt.Errorf("insufficient stock")
+	}
+	f.db.stock[productID] += quantityChange
+	return nil
+}
+
+type fakeNotificationStore struct {
+	types.NotificationStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeNotificationStore) CreateNotification(payload *types.CreateNotificationPayload, userID int) (*types.Notification, error) {
+	if err := f.db.fail("CreateNotification"); err != nil {
+		return nil, err
+	}
+	n := &types.Notification{ID: len(f.db.notifications) + 1, UserID: userID, Title: payload.Title, Message: payload.Message}
+	f.db.notifications = append(f.db.notifications, n)
+	return n, nil
+}
+
+type fakePixStore struct {
+	types.PixStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakePixStore) CreatePixCharge(charge *types.PixCharge) error {
+	if err := f.db.fail("CreatePixCharge"); err != nil {
+		return err
+	}
+	f.db.pixCharges[charge.TxID] = charge
+	return nil
+}
+
+func (f *fakePixStore) GetPixChargeByOrderID(orderID int) (*types.PixCharge, error) {
+	for _, charge := range f.db.pixCharges {
+		if charge.OrderID == orderID {
+			copied := *charge
+			return &copied, nil
+		}
+	}
+	return nil, apperrors.NewEntityNotFound("pix charge", orderID)
+}
+
+func (f *fakePixStore) GetPixChargeByTxID(txid string) (*types.PixCharge, error) {
+	charge, ok := f.db.pixCharges[txid]
+	if !ok {
+		return nil, apperrors.NewEntityNotFound("pix charge", txid)
+	}
+	copied := *charge
+	return &copied, nil
+}
+
+func (f *fakePixStore) MarkPixChargePaid(txid string, endToEndID string) error {
+	if err := f.db.fail("MarkPixChargePaid"); err != nil {
+		return err
+	}
+	if f.db.pixCharges[txid].PaidAt != nil {
+		return apperrors.NewConflictError("txid", fmt.Sprintf("pix charge %s is already paid", txid))
+	}
+	paid := *f.db.pixCharges[txid]
+	now := time.Now()
+	paid.PaidAt = &now
+	paid.EndToEndID = endToEndID
+	f.db.pixCharges[txid] = &paid
+	return nil
+}
+
+func (f *fakePixStore) GetExpiredPixCharges(limit int) ([]*types.PixCharge, error) {
+	var charges []*types.PixCharge
+	for _, charge := range f.db.pixCharges {
+		if charge.PaidAt == nil && charge.ExpiresAt.Before(time.Now()) && f.db.orders[charge.OrderID].Status == types.OrderPending {
+			copied := *charge
+			charges = append(charges, &copied)
+		}
+	}
+	return charges, nil
+}
+
+type fakeReservationStore struct {
+	types.ReservationStore
+	db *fakeCheckoutDB
+}
+
+func (f *fakeReservationStore) GetAvailableStock(productID int, variantID int, exceptCartID int) (int, error) {
+	return f.db.stock[productID] - f.db.reserved[productID], nil
+}
+
+func (f *fakeReservationStore) ReleaseCartReservations(cartID int) error {
+	return f.db.fail("ReleaseCartReservations")
+}
+
+func TestCreateOrderFromCartRollsBackOnFailure(t *testing.T) {
+	steps := []string{
+		"ValidateCart",
+		"GetMyCartItems",
+		"CreateOrder",
+		"AddOrderStatusHistory",
+		"UpdateStock:1",
+		"UpdateStock:2",
+		"AddOrderItems",
+		"ReleaseCartReservations",
+		"RemoveItemsFromCart",
+		"commit",
+	}
+
+	for _, step := range steps {
+		t.Run(step, func(t *testing.T) {
+			db := newFakeCheckoutDB()
+			if step == "commit" {
+				db.failCommit = true
+			} else {
+				db.failOn = step
+			}
+
+			// the non transactional stores must not be touched during checkout
+			service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+			order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+			assert.Error(t, err)
+			assert.Nil(t, order)
+			assert.Empty(t, db.orders)
+			assert.Empty(t, db.orderItems)
+			assert.Empty(t, db.history)
+			assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+			assert.Len(t, db.cart, 2)
+
+			// a payment authorized before the failure must be voided
+			if p, err := db.gateway.Status("fake_pay_1"); err == nil {
+				assert.Equal(t, types.PaymentVoided, p.Status)
+			}
+		})
+	}
+}
+
+func TestCreateOrderFromCartCommitsAllSteps(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, order.TotalAmount)
+	assert.Len(t, db.orders, 1)
+	assert.Equal(t, types.OrderPaid, order.Status)
+	assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+	assert.Len(t, db.orderItems[order.ID], 2)
+	assert.Len(t, db.history, 2)
+	assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+	assert.Empty(t, db.cart)
+
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentCaptured, p.Status)
+	assert.Equal(t, 50.0, p.Amount)
+	assert.Equal(t, testAddress.ShippingAddress(), db.orders[order.ID].ShippingAddress)
+}
+
+// txCheckingGateway fails the test when the gateway is called while the
+// transaction holds its locks.
+type txCheckingGateway struct {
+	*payment.FakeGateway
+	t  *testing.T
+	db *fakeCheckoutDB
+}
+
+func (g *txCheckingGateway) check(call string) {
+	if g.db.inTx {
+		g.t.Errorf("%s called inside the transaction", call)
+	}
+}
+
+func (g *txCheckingGateway) Authorize(req *types.PaymentRequest) (*types.PaymentResult, error) {
+	g.check("Authorize")
+	return g.FakeGateway.Authorize(req)
+}
+
+func (g *txCheckingGateway) Capture(paymentID string) (*types.PaymentResult, error) {
+	g.check("Capture")
+	return g.FakeGateway.Capture(paymentID)
+}
+
+func (g *txCheckingGateway) Refund(paymentID string, amount float64) (*types.PaymentResult, error) {
+	g.check("Refund")
+	return g.FakeGateway.Refund(paymentID, amount)
+}
+
+func TestGatewayIsCalledOutsideTransactions(t *testing.T) {
+	db := newFakeCheckoutDB()
+	gateway := &txCheckingGateway{FakeGateway: db.gateway, t: t, db: db}
+	service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+	assert.NoError(t, err)
+
+	_, err = service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{Items: []types.RefundItemPayload{{ProductID: 1, Quantity: 1}}})
+	assert.NoError(t, err)
+
+	err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "")
+	assert.NoError(t, err)
+
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentRefunded, p.Status)
+
+	// a checkout that fails in the transaction voids the authorization after it
+	db.cart = []*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 1, PriceAtAdding: 10.0}}
+	db.failOn = "AddOrderItems"
+	_, err = service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+	assert.Error(t, err)
+}
+
+func TestCancelOrderKeepsTheCancellationWhenTheGatewayFails(t *testing.T) {
+	db := newFakeCheckoutDB()
+	mockGateway := new(MockPaymentGateway)
+	service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, mockGateway, testPixIssuer, db)
+	db.orders[1] = &types.OrderHistory{ID: 1, UserID: 1, TotalAmount: 20.0, Status: types.OrderPending, PaymentID: "pay_1"}
+	db.orderItems[1] = []*types.OrderItem{{OrderID: 1, ProductID: 1, Quantity: 2, Price: 10.0}}
+	mockGateway.On("Status", "pay_1").Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
+	mockGateway.On("Refund", "pay_1", 20.0).Return(nil, fmt.Errorf("gateway unavailable"))
+
+	err := service.CancelOrder(1, 1, types.RoleUser, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, types.OrderCancelled, db.orders[1].Status)
+	assert.Equal(t, 7, db.stock[1])
+	mockGateway.AssertExpectations(t)
+}
+
+func TestCreateOrderFromCartNeedsAnAddressOfTheUser(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	// address 4 belongs to someone else, and 0 is no address at all
+	for _, addressID := range []int{0, 4} {
+		order, err := service.CreateOrderFromCart(1, addressID, "PAC", types.PaymentCreditCard, "", nil)
+
+		assert.Equal(t, apperrors.NewValidationError("addressId", "choose one of your addresses to ship the order to"), err)
+		assert.Nil(t, order)
+	}
+	assert.Empty(t, db.orders)
+	assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+}
+
+func TestCreateOrderFromCartChargesShipping(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "DRONE", types.PaymentCreditCard, "", nil)
+	assert.Equal(t, apperrors.NewValidationError("shippingOption", "shipping option DRONE is not available for this address"), err)
+	assert.Nil(t, order)
+	assert.Empty(t, db.orders)
+
+	order, err = service.CreateOrderFromCart(1, 3, "SEDEX", types.PaymentCreditCard, "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 65.5, order.TotalAmount)
+	assert.Equal(t, "SEDEX", db.orders[order.ID].ShippingService)
+	assert.Equal(t, 15.5, db.orders[order.ID].ShippingCost)
+
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, 65.5, p.Amount)
+}
+
+func TestCreateOrderFromCartDeclinedPayment(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, payment.DeclinedToken, nil)
+
+	var appErr *apperrors.AppError
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.BAD, appErr.Type)
+	assert.Nil(t, order)
+	assert.Empty(t, db.orders)
+	assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+	assert.Len(t, db.cart, 2)
+}
+
+func TestCreateOrderFromCartReservedByOtherCarts(t *testing.T) {
+	db := newFakeCheckoutDB()
+	// 4 of the 5 units of product 2 sit in other carts, but this cart wants
+	// 2 of product 1 and only 1 of product 2
+	db.reserved[2] = 4
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+
+	// now all the 4 units left are held by other carts
+	db.cart = []*types.CartItem{{CartID: 1, ProductID: 2, Quantity: 1, PriceAtAdding: 30.0}}
+
+	order, err = service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+	var appErr *apperrors.AppError
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.BAD, appErr.Type)
+	assert.Nil(t, order)
+	assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+	assert.Len(t, db.orders, 1)
+}
+
+func TestCreateOrderFromCartRepricedItems(t *testing.T) {
+	db := newFakeCheckoutDB()
+	// product 1 went from 10.00 to 12.50 after it was added to the cart
+	db.prices[1] = 12.5
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+	var appErr *apperrors.AppError
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.CONFLICT, appErr.Type)
+	validation, ok := appErr.Details["validation"].(*types.CartValidation)
+	assert.True(t, ok)
+	assert.Equal(t, 55.0, validation.Total)
+	assert.Nil(t, order)
+	assert.Empty(t, db.orders)
+	assert.Len(t, db.cart, 2)
+	_, err = db.gateway.Status("fake_pay_1")
+	assert.ErrorIs(t, err, types.ErrPaymentNotFound)
+
+	// accepting the total the client saw before the price changed again
+	stale := 50.0
+	order, err = service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", &stale)
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.CONFLICT, appErr.Type)
+	assert.Nil(t, order)
+
+	accepted := 55.0
+	order, err = service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", &accepted)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 55.0, order.TotalAmount)
+	for _, item := range db.orderItems[order.ID] {
+		if item.ProductID == 1 {
+			assert.Equal(t, 12.5, item.Price)
+		}
+	}
+}
+
+func TestCreateOrderFromCartMultiUnitOffer(t *testing.T) {
+	db := newFakeCheckoutDB()
+	db.cart = []*types.CartItem{{CartID: 1, ProductID: 1, Quantity: 3, PriceAtAdding: 10.0}}
+	// buy 2 get 1 free
+	db.lineTotals = map[int]float64{1: 20.0}
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, order.TotalAmount)
+	assert.Equal(t, []*types.OrderItem{{OrderID: order.ID, ProductID: 1, Quantity: 3, BasePrice: 10.0, Price: 6.67}}, db.orderItems[order.ID])
+}
+
+func TestCreateOrderFromCartRedeemsCoupon(t *testing.T) {
+	withCoupon := func() *fakeCheckoutDB {
+		db := newFakeCheckoutDB()
+		db.coupon = &types.CartCoupon{ID: 7, Code: "SAVE10", Discount: 10.0, Valid: true, LineDiscounts: []float64{4.0, 6.0}}
+		db.couponUses = 1
+		return db
+	}
+
+	t.Run("refunds give back what was paid and cancelling releases the coupon", func(t *testing.T) {
+		db := withCoupon()
+		service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 4.0, db.orderItems[order.ID][0].Discount)
+		assert.Equal(t, 6.0, db.orderItems[order.ID][1].Discount)
+		assert.Equal(t, 0, db.couponUses)
+
+		// one of two units of 10.00 that shared 4.00 of the discount
+		refund, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items: []types.RefundItemPayload{{ProductID: 1, Quantity: 1}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 8.0, refund.Amount)
+		assert.Equal(t, 0, db.couponUses)
+
+		err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 32.0, db.refunds[1].Amount)
+		assert.Equal(t, 8.0, db.refunds[1].Items[0].Amount)
+		assert.Equal(t, 24.0, db.refunds[1].Items[1].Amount)
+		assert.Empty(t, db.redemptions)
+		assert.Equal(t, 1, db.couponUses)
+	})
+
+	t.Run("redeemed with the order", func(t *testing.T) {
+		db := withCoupon()
+		service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 40.0, order.TotalAmount)
+		assert.Equal(t, []*types.CouponRedemption{{CouponID: 7, UserID: 1, OrderID: order.ID, Amount: 10.0}}, db.redemptions)
+		assert.Nil(t, db.coupon)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, 40.0, p.Amount)
+	})
+
+	for _, step := range []string{"RedeemCoupon", "SetCoupon"} {
+		t.Run("rolled back when "+step+" fails", func(t *testing.T) {
+			db := withCoupon()
+			db.failOn = step
+			service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+			order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+			assert.Error(t, err)
+			assert.Nil(t, order)
+			assert.Empty(t, db.orders)
+			assert.Empty(t, db.redemptions)
+			assert.Equal(t, 1, db.couponUses)
+			assert.NotNil(t, db.coupon)
+			p, err := db.gateway.Status("fake_pay_1")
+			assert.NoError(t, err)
+			assert.Equal(t, types.PaymentVoided, p.Status)
+		})
+	}
+
+	t.Run("used up by another checkout", func(t *testing.T) {
+		db := withCoupon()
+		db.couponUses = 0
+		service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+		assert.Equal(t, apperrors.NewValidationError("coupon", "coupon is no longer available"), err)
+		assert.Nil(t, order)
+		assert.Empty(t, db.orders)
+		assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+	})
+
+	t.Run("no longer applies", func(t *testing.T) {
+		db := withCoupon()
+		db.coupon = &types.CartCoupon{ID: 7, Code: "SAVE10", Valid: false, Reason: "coupon has expired"}
+		service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+
+		assert.Equal(t, apperrors.NewValidationError("coupon", "coupon has expired"), err)
+		assert.Nil(t, order)
+		_, err = db.gateway.Status("fake_pay_1")
+		assert.ErrorIs(t, err, types.ErrPaymentNotFound)
+	})
+}
+
+func TestServiceUpdateOrderStatus(t *testing.T) {
+	paid := types.OrderPaid
+	pending := types.OrderPending
+
+	tests := []struct {
+		name          string
+		current       types.OrderStatus
+		status        types.OrderStatus
+		role          types.UserRole
+		mockSetup     func(*MockOrderStore, *MockProductStore, *MockNotificationStore, *MockPaymentGateway)
+		expectedError *apperrors.AppError
+	}{
+		{
+			name:    "Success - Customer cancels pending order and stock is returned",
+			current: types.OrderPending,
+			status:  types.OrderCancelled,
+			role:    types.RoleUser,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+				mos.On("UpdateOrderStatus", 1, types.OrderPending, types.OrderCancelled).Return(nil)
+				mos.On("AddOrderStatusHistory", 1, &pending, types.OrderCancelled, 7, "").Return(nil)
+				mos.On("GetOrderItems", 1).Return([]*types.OrderItem{{OrderID: 1, ProductID: 3, Quantity: 2}}, nil)
+				mps.On("UpdateStock", 3, 2).Return(nil)
+				mns.On("CreateNotification", mock.Anything, 7).Return(&types.Notification{}, nil)
+				mpg.On("Status", "pay_1").Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
+				mpg.On("Refund", "pay_1", 20.0).Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentVoided}, nil)
+			},
+		},
+		{
+			name:    "Success - Admin ships paid order",
+			current: types.OrderPaid,
+			status:  types.OrderShipped,
+			role:    types.RoleAdmin,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+				mos.On("UpdateOrderStatus", 1, types.OrderPaid, types.OrderShipped).Return(nil)
+				mos.On("AddOrderStatusHistory", 1, &paid, types.OrderShipped, 7, "").Return(nil)
+			},
+		},
+		{
+			name:    "Success - Admin marks order paid once captured",
+			current: types.OrderPending,
+			status:  types.OrderPaid,
+			role:    types.RoleAdmin,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+				mpg.On("Status", "pay_1").Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentCaptured}, nil)
+				mos.On("UpdateOrderStatus", 1, types.OrderPending, types.OrderPaid).Return(nil)
+				mos.On("AddOrderStatusHistory", 1, &pending, types.OrderPaid, 7, "").Return(nil)
+			},
+		},
+		{
+			name:    "Error - Order cannot be paid before capture",
+			current: types.OrderPending,
+			status:  types.OrderPaid,
+			role:    types.RoleAdmin,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+				mpg.On("Status", "pay_1").Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentAuthorized}, nil)
+			},
+			expectedError: apperrors.NewValidationError("status", ""),
+		},
+		{
+			name:    "Error - Customer cannot ship",
+			current: types.OrderPaid,
+			status:  types.OrderShipped,
+			role:    types.RoleUser,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+			},
+			expectedError: apperrors.NewForbiddenError(""),
+		},
+		{
+			name:    "Error - Customer cannot cancel paid order",
+			current: types.OrderPaid,
+			status:  types.OrderCancelled,
+			role:    types.RoleUser,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+			},
+			expectedError: apperrors.NewForbiddenError(""),
+		},
+		{
+			name:    "Error - Skipping states is not allowed",
+			current: types.OrderPending,
+			status:  types.OrderDelivered,
+			role:    types.RoleAdmin,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+			},
+			expectedError: apperrors.NewValidationError("status", ""),
+		},
+		{
+			name:    "Error - Cancelled order is final",
+			current: types.OrderCancelled,
+			status:  types.OrderPending,
+			role:    types.RoleAdmin,
+			mockSetup: func(mos *MockOrderStore, mps *MockProductStore, mns *MockNotificationStore, mpg *MockPaymentGateway) {
+			},
+			expectedError: apperrors.NewValidationError("status", ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderStore := new(MockOrderStore)
+			mockProductStore := new(MockProductStore)
+			mockNotificationStore := new(MockNotificationStore)
+			// only cancellations release the coupon of the order
+			mockCouponStore := new(MockCouponStore)
+			mockCouponStore.On("ReleaseRedemption", 1).Return(nil).Maybe()
+			mockUoW := &MockUnitOfWork{stores: &types.TxStores{
+				Orders:        mockOrderStore,
+				Products:      mockProductStore,
+				Notifications: mockNotificationStore,
+				Coupons:       mockCouponStore,
+			}}
+			mockGateway := new(MockPaymentGateway)
+			service := NewService(mockOrderStore, new(MockCartStore), new(MockCartService), mockProductStore, newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), mockGateway, testPixIssuer, mockUoW)
+
+			order := &types.OrderHistory{ID: 1, UserID: 7, Status: tt.current, PaymentID: "pay_1"}
+			// the capture is checked before the transaction locks the order
+			mockOrderStore.On("GetOrderByID", 1).Return(order, nil).Maybe()
+			mockOrderStore.On("GetOrderByIDForUpdate", 1).Return(order, nil).Maybe()
+			tt.mockSetup(mockOrderStore, mockProductStore, mockNotificationStore, mockGateway)
+
+			err := service.UpdateOrderStatus(1, tt.status, 7, tt.role)
+
+			if tt.expectedError != nil {
+				var appErr *apperrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.expectedError.Type, appErr.Type)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockOrderStore.AssertExpectations(t)
+			mockProductStore.AssertExpectations(t)
+			mockNotificationStore.AssertExpectations(t)
+			mockGateway.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelOrderRestocksVariants(t *testing.T) {
+	pending := types.OrderPending
+	mockOrderStore := new(MockOrderStore)
+	mockProductStore := new(MockProductStore)
+	mockVariantStore := new(MockVariantStore)
+	mockNotificationStore := new(MockNotificationStore)
+	mockGateway := new(MockPaymentGateway)
+	mockCouponStore := new(MockCouponStore)
+	mockUoW := &MockUnitOfWork{stores: &types.TxStores{
+		Orders:        mockOrderStore,
+		Products:      mockProductStore,
+		Variants:      mockVariantStore,
+		Notifications: mockNotificationStore,
+		Coupons:       mockCouponStore,
+	}}
+	service := NewService(mockOrderStore, new(MockCartStore), new(MockCartService), mockProductStore, newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), mockGateway, testPixIssuer, mockUoW)
+
+	mockOrderStore.On("GetOrderByIDForUpdate", 1).Return(&types.OrderHistory{ID: 1, UserID: 7, Status: types.OrderPending, PaymentID: "pay_1"}, nil)
+	mockOrderStore.On("UpdateOrderStatus", 1, types.OrderPending, types.OrderCancelled).Return(nil)
+	mockOrderStore.On("AddOrderStatusHistory", 1, &pending, types.OrderCancelled, 7, "").Return(nil)
+	mockOrderStore.On("GetOrderItems", 1).Return([]*types.OrderItem{
+		{OrderID: 1, ProductID: 3, VariantID: 9, VariantLabel: "M / Blue", Quantity: 2},
+		{OrderID: 1, ProductID: 4, Quantity: 1},
+	}, nil)
+	// the units of a variant go back to the variant, not to the product
+	mockVariantStore.On("UpdateVariantStock", 9, 2).Return(nil)
+	mockProductStore.On("UpdateStock", 4, 1).Return(nil)
+	mockNotificationStore.On("CreateNotification", mock.Anything, 7).Return(&types.Notification{}, nil)
+	mockCouponStore.On("ReleaseRedemption", 1).Return(nil)
+	mockGateway.On("Status", "pay_1").Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentAuthorized, Amount: 20.0}, nil)
+	mockGateway.On("Refund", "pay_1", 20.0).Return(&types.PaymentResult{ID: "pay_1", Status: types.PaymentVoided}, nil)
+
+	err := service.UpdateOrderStatus(1, types.OrderCancelled, 7, types.RoleUser)
+
+	assert.NoError(t, err)
+	mockOrderStore.AssertExpectations(t)
+	mockProductStore.AssertExpectations(t)
+	mockVariantStore.AssertExpectations(t)
+	mockCouponStore.AssertExpectations(t)
+	mockProductStore.AssertNotCalled(t, "UpdateStock", 3, mock.Anything)
+}
+
+func TestCancelOrderRestocksAndNotifies(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+
+	err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "out of stock at warehouse")
+
+	assert.NoError(t, err)
+	assert.Equal(t, types.OrderCancelled, db.orders[order.ID].Status)
+	assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+	assert.Len(t, db.history, 3)
+	assert.Equal(t, types.OrderCancelled, db.history[2].ToStatus)
+	assert.Equal(t, "out of stock at warehouse", db.history[2].Note)
+	assert.Len(t, db.notifications, 1)
+	assert.Equal(t, 1, db.notifications[0].UserID)
+	assert.Contains(t, db.notifications[0].Message, "out of stock at warehouse")
+
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentRefunded, p.Status)
+}
+
+func TestCancelPendingOrderVoidsAuthorization(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	auth, err := db.gateway.Authorize(&types.PaymentRequest{UserID: 1, Amount: 20.0, Method: types.PaymentCreditCard})
+	assert.NoError(t, err)
+	db.orders[1] = &types.OrderHistory{ID: 1, UserID: 1, TotalAmount: 20.0, Status: types.OrderPending, PaymentID: auth.ID}
+	db.orderItems[1] = []*types.OrderItem{{OrderID: 1, ProductID: 1, Quantity: 2, Price: 10.0}}
+
+	err = service.CancelOrder(1, 1, types.RoleUser, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, types.OrderCancelled, db.orders[1].Status)
+	assert.Equal(t, 7, db.stock[1])
+
+	p, err := db.gateway.Status(auth.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentVoided, p.Status)
+}
+
+func TestCancelOrderRollsBackOnFailure(t *testing.T) {
+	steps := []string{
+		"UpdateOrderStatus",
+		"AddOrderStatusHistory",
+		"GetOrderItems",
+		"UpdateStock:1",
+		"UpdateStock:2",
+		"CreateNotification",
+		"commit",
+	}
+
+	for _, step := range steps {
+		t.Run(step, func(t *testing.T) {
+			db := newFakeCheckoutDB()
+			service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+			order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+			assert.NoError(t, err)
+
+			if step == "commit" {
+				db.failCommit = true
+			} else {
+				db.failOn = step
+			}
+
+			err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "")
+
+			assert.Error(t, err)
+			assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+			assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+			assert.Len(t, db.history, 2)
+			assert.Empty(t, db.notifications)
+
+			// the payment is only released once the cancellation commits
+			p, err := db.gateway.Status(order.PaymentID)
+			assert.NoError(t, err)
+			assert.Equal(t, types.PaymentCaptured, p.Status)
+		})
+	}
+}
+
+func TestCancelOrderNotCancellable(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       types.OrderStatus
+		role         types.UserRole
+		expectedType apperrors.ErrorType
+	}{
+		{name: "Customer cannot cancel paid order", status: types.OrderPaid, role: types.RoleUser, expectedType: apperrors.FORBIDDEN},
+		{name: "Shipped order cannot be cancelled", status: types.OrderShipped, role: types.RoleAdmin, expectedType: apperrors.BAD},
+		{name: "Cancelled order cannot be cancelled again", status: types.OrderCancelled, role: types.RoleAdmin, expectedType: apperrors.BAD},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderStore := new(MockOrderStore)
+			mockProductStore := new(MockProductStore)
+			mockNotificationStore := new(MockNotificationStore)
+			mockUoW := &MockUnitOfWork{stores: &types.TxStores{
+				Orders:        mockOrderStore,
+				Products:      mockProductStore,
+				Notifications: mockNotificationStore,
+			}}
+			service := NewService(mockOrderStore, new(MockCartStore), new(MockCartService), mockProductStore, newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), new(MockPaymentGateway), testPixIssuer, mockUoW)
+
+			mockOrderStore.On("GetOrderByIDForUpdate", 1).Return(&types.OrderHistory{ID: 1, UserID: 7, Status: tt.status}, nil)
+
+			err := service.CancelOrder(1, 7, tt.role, "")
+
+			var appErr *apperrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, tt.expectedType, appErr.Type)
+
+			mockOrderStore.AssertExpectations(t)
+			mockProductStore.AssertNotCalled(t, "UpdateStock", mock.Anything, mock.Anything)
+			mockNotificationStore.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateOrderFromCartWithPix(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentPix, "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, types.OrderPending, order.Status)
+	assert.NotNil(t, order.Pix)
+	assert.Equal(t, 50.0, order.Pix.Amount)
+	assert.Contains(t, order.Pix.BRCode, "br.gov.bcb.pix")
+	assert.Contains(t, order.Pix.BRCode, "540550.00")
+	assert.NotEmpty(t, order.Pix.QRCodePNG)
+	assert.True(t, order.Pix.ExpiresAt.After(time.Now()))
+	assert.Len(t, db.pixCharges, 1)
+
+	// the customer has not paid yet, so nothing may be captured
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentAuthorized, p.Status)
+
+	fetched, err := service.GetOrderByID(order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, order.Pix.TxID, fetched.Pix.TxID)
+}
+
+func TestCreateOrderFromCartWithPixRollsBackOnFailure(t *testing.T) {
+	db := newFakeCheckoutDB()
+	db.failOn = "CreatePixCharge"
+	service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentPix, "", nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, order)
+	assert.Empty(t, db.orders)
+	assert.Empty(t, db.pixCharges)
+	assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+}
+
+func TestConfirmPixPayment(t *testing.T) {
+	setup := func(t *testing.T) (*fakeCheckoutDB, *Service, *types.OrderHistory) {
+		db := newFakeCheckoutDB()
+		service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentPix, "", nil)
+		assert.NoError(t, err)
+
+		return db, service, order
+	}
+
+	t.Run("Success - Notification captures the payment and marks the order paid", func(t *testing.T) {
+		db, service, order := setup(t)
+
+		err := service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+		assert.NotNil(t, db.pixCharges[order.Pix.TxID].PaidAt)
+		assert.Equal(t, "E123", db.pixCharges[order.Pix.TxID].EndToEndID)
+		assert.Equal(t, "PIX E123", db.history[len(db.history)-1].Note)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentCaptured, p.Status)
+
+		// PSPs retry notifications, the second one must be a no-op
+		err = service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+		assert.NoError(t, err)
+		assert.Len(t, db.history, 2)
+	})
+
+	t.Run("Error - Capture fails so the PSP notifies again", func(t *testing.T) {
+		db, service, order := setup(t)
+		service.gateway = &MockPaymentGateway{}
+		service.gateway.(*MockPaymentGateway).On("Capture", order.PaymentID).Return(nil, errors.New("gateway unavailable"))
+
+		err := service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+
+		assert.Error(t, err)
+		assert.Equal(t, types.OrderPending, db.orders[order.ID].Status)
+		assert.Nil(t, db.pixCharges[order.Pix.TxID].PaidAt)
+	})
+
+	t.Run("Success - PIX for a cancelled order is returned", func(t *testing.T) {
+		db, service, order := setup(t)
+		assert.NoError(t, service.CancelOrder(order.ID, 1, types.RoleUser, ""))
+
+		err := service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.OrderCancelled, db.orders[order.ID].Status)
+		assert.NotNil(t, db.pixCharges[order.Pix.TxID].PaidAt)
+		assert.Equal(t, 50.0, db.gateway.PixRefunded("E123"))
+		assert.Len(t, db.refunds, 1)
+		assert.Equal(t, 50.0, db.refunds[0].Amount)
+
+		// a retried notification does not return the PIX twice
+		err = service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+		assert.NoError(t, err)
+		assert.Equal(t, 50.0, db.gateway.PixRefunded("E123"))
+	})
+
+	t.Run("Success - PIX after the charge expired cancels the order and is returned", func(t *testing.T) {
+		db, service, order := setup(t)
+		db.pixCharges[order.Pix.TxID].ExpiresAt = time.Now().Add(-time.Minute)
+
+		err := service.ConfirmPixPayment(order.Pix.TxID, "E123", 50.0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.OrderCancelled, db.orders[order.ID].Status)
+		assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+		assert.Equal(t, 50.0, db.gateway.PixRefunded("E123"))
+		assert.Len(t, db.refunds, 1)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentVoided, p.Status)
+	})
+
+	t.Run("Error - Amount does not match the charge", func(t *testing.T) {
+		db, service, order := setup(t)
+
+		err := service.ConfirmPixPayment(order.Pix.TxID, "E123", 49.99)
+
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+		assert.Equal(t, types.OrderPending, db.orders[order.ID].Status)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentAuthorized, p.Status)
+	})
+
+	t.Run("Error - Unknown txid", func(t *testing.T) {
+		_, service, _ := setup(t)
+
+		err := service.ConfirmPixPayment("unknown", "E123", 50.0)
+
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.NotFound, appErr.Type)
+	})
+}
+
+func TestCancelExpiredPixOrders(t *testing.T) {
+	db := newFakeCheckoutDB()
+	service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentPix, "", nil)
+	assert.NoError(t, err)
+
+	cancelled, err := service.CancelExpiredPixOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cancelled)
+	assert.Equal(t, types.OrderPending, db.orders[order.ID].Status)
+
+	db.pixCharges[order.Pix.TxID].ExpiresAt = time.Now().Add(-time.Minute)
+
+	cancelled, err = service.CancelExpiredPixOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cancelled)
+	assert.Equal(t, types.OrderCancelled, db.orders[order.ID].Status)
+	assert.Equal(t, "PIX charge expired", db.history[len(db.history)-1].Note)
+	assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+
+	p, err := db.gateway.Status(order.PaymentID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.PaymentVoided, p.Status)
+
+	cancelled, err = service.CancelExpiredPixOrders()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cancelled)
+}
+
+func TestServiceRefundOrder(t *testing.T) {
+	setup := func(t *testing.T) (*fakeCheckoutDB, *Service, *types.OrderHistory) {
+		db := newFakeCheckoutDB()
+		service := NewService(new(MockOrderStore), new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+		assert.NoError(t, err)
+
+		return db, service, order
+	}
+
+	t.Run("Success - Partial refunds until the order is fully refunded", func(t *testing.T) {
+		db, service, order := setup(t)
+
+		refund, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items:  []types.RefundItemPayload{{ProductID: 1, Quantity: 1}},
+			Reason: "damaged",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 10.0, refund.Amount)
+		assert.Equal(t, 2, *refund.CreatedBy)
+		assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentPartiallyRefunded, p.Status)
+		assert.Equal(t, 10.0, p.Refunded)
+
+		// no items refunds what is left
+		refund, err = service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 40.0, refund.Amount)
+		assert.Len(t, refund.Items, 2)
+		assert.Equal(t, 1, refund.Items[0].Quantity)
+
+		p, err = db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+
+		_, err = service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{})
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+	})
+
+	t.Run("Success - Restock returns the refunded units", func(t *testing.T) {
+		db, service, order := setup(t)
+
+		_, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items:   []types.RefundItemPayload{{ProductID: 1, Quantity: 2}},
+			Restock: true,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]int{1: 5, 2: 4}, db.stock)
+		assert.True(t, db.refunds[0].Restocked)
+	})
+
+	t.Run("Success - Refunding the last units gives back the shipping", func(t *testing.T) {
+		db := newFakeCheckoutDB()
+		service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+		order, err := service.CreateOrderFromCart(1, 3, "SEDEX", types.PaymentCreditCard, "", nil)
+		assert.NoError(t, err)
+
+		refund, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items: []types.RefundItemPayload{{ProductID: 1, Quantity: 1}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 10.0, refund.Amount)
+
+		// listing every unit left is a full refund as well
+		refund, err = service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items: []types.RefundItemPayload{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 55.5, refund.Amount)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+		assert.Equal(t, 65.5, p.Refunded)
+	})
+
+	t.Run("Success - Cancelling refunds only what is left", func(t *testing.T) {
+		db, service, order := setup(t)
+
+		_, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items:   []types.RefundItemPayload{{ProductID: 2, Quantity: 1}},
+			Restock: true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]int{1: 3, 2: 5}, db.stock)
+
+		err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "")
+
+		assert.NoError(t, err)
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+		assert.Equal(t, 50.0, p.Refunded)
+
+		// the refunded unit was already restocked
+		assert.Equal(t, map[int]int{1: 5, 2: 5}, db.stock)
+
+		assert.Len(t, db.refunds, 2)
+		cancellation := db.refunds[1]
+		assert.Equal(t, 20.0, cancellation.Amount)
+		assert.Equal(t, "Order cancelled", cancellation.Reason)
+		assert.True(t, cancellation.Restocked)
+		assert.Equal(t, 2, *cancellation.CreatedBy)
+		assert.Len(t, cancellation.Items, 1)
+		assert.Equal(t, 1, cancellation.Items[0].ProductID)
+		assert.Equal(t, 2, cancellation.Items[0].Quantity)
+	})
+
+	t.Run("Success - Cancelling a pending order records no refund", func(t *testing.T) {
+		db, service, order := setup(t)
+		db.orders[order.ID].Status = types.OrderPending
+
+		err := service.CancelOrder(order.ID, 1, types.RoleUser, "")
+
+		assert.NoError(t, err)
+		assert.Empty(t, db.refunds)
+	})
+
+	errorCases := []struct {
+		name  string
+		items []types.RefundItemPayload
+	}{
+		{name: "Error - More units than were bought", items: []types.RefundItemPayload{{ProductID: 1, Quantity: 3}}},
+		{name: "Error - Product not in the order", items: []types.RefundItemPayload{{ProductID: 9, Quantity: 1}}},
+		{name: "Error - Product listed twice", items: []types.RefundItemPayload{{ProductID: 1, Quantity: 1}, {ProductID: 1, Quantity: 1}}},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, service, order := setup(t)
+
+			_, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{Items: tc.items})
+
+			var appErr *apperrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, apperrors.BAD, appErr.Type)
+			assert.Empty(t, db.refunds)
+		})
+	}
+
+	t.Run("Error - Unpaid order cannot be refunded", func(t *testing.T) {
+		db, service, _ := setup(t)
+		db.orders[9] = &types.OrderHistory{ID: 9, UserID: 1, TotalAmount: 20.0, Status: types.OrderPending}
+
+		_, err := service.RefundOrder(9, 2, &types.CreateRefundPayload{})
+
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+	})
+
+	for _, step := range []string{"CreateRefund", "UpdateStock:1", "commit"} {
+		t.Run("Rollback - "+step, func(t *testing.T) {
+			db, service, order := setup(t)
+			if step == "commit" {
+				db.failCommit = true
+			} else {
+				db.failOn = step
+			}
+
+			_, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{Restock: true})
+
+			assert.Error(t, err)
+			assert.Empty(t, db.refunds)
+			assert.Equal(t, map[int]int{1: 3, 2: 4}, db.stock)
+
+			p, err := db.gateway.Status(order.PaymentID)
+			assert.NoError(t, err)
+			assert.Equal(t, types.PaymentCaptured, p.Status)
+		})
+	}
+}
+
+func TestServiceShipments(t *testing.T) {
+	setup := func(t *testing.T) (*fakeCheckoutDB, *Service, *types.OrderHistory) {
+		db := newFakeCheckoutDB()
+		service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+		order, err := service.CreateOrderFromCart(1, 3, "PAC", types.PaymentCreditCard, "", nil)
+		assert.NoError(t, err)
+
+		return db, service, order
+	}
+
+	t.Run("Success - Split shipments ship the order with the last one", func(t *testing.T) {
+		db, service, order := setup(t)
+		notified := len(db.notifications)
+
+		first, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{
+			Carrier:      "Correios",
+			TrackingCode: "BR123",
+			Items:        []types.ShipmentItemPayload{{ProductID: 1, Quantity: 1}},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.ShipmentShipped, first.Status)
+		assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+		assert.Len(t, db.notifications, notified+1)
+		assert.Contains(t, db.notifications[notified].Message, "BR123")
+
+		// no items ships what is left
+		second, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*types.ShipmentItem{
+			{ShipmentID: second.ID, ProductID: 1, Quantity: 1},
+			{ShipmentID: second.ID, ProductID: 2, Quantity: 1},
+		}, second.Items)
+		assert.Equal(t, types.OrderShipped, db.orders[order.ID].Status)
+
+		fetched, err := service.GetOrderByID(order.ID)
+		assert.NoError(t, err)
+		assert.Len(t, fetched.Shipments, 2)
+
+		_, err = service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+	})
+
+	t.Run("Success - Refunded units are not shipped", func(t *testing.T) {
+		db, service, order := setup(t)
+		_, err := service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items: []types.RefundItemPayload{{ProductID: 2, Quantity: 1}},
+		})
+		assert.NoError(t, err)
+
+		shipment, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []*types.ShipmentItem{{ShipmentID: shipment.ID, ProductID: 1, Quantity: 2}}, shipment.Items)
+		assert.Equal(t, types.OrderShipped, db.orders[order.ID].Status)
+	})
+
+	t.Run("Success - Refunding the units left to ship ships the order", func(t *testing.T) {
+		db, service, order := setup(t)
+		_, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{
+			Carrier: "Correios",
+			Items:   []types.ShipmentItemPayload{{ProductID: 1, Quantity: 2}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+
+		_, err = service.RefundOrder(order.ID, 2, &types.CreateRefundPayload{
+			Items: []types.RefundItemPayload{{ProductID: 2, Quantity: 1}},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.OrderShipped, db.orders[order.ID].Status)
+	})
+
+	t.Run("Error - Partly shipped order cannot be cancelled", func(t *testing.T) {
+		db, service, order := setup(t)
+		_, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{
+			Carrier: "Correios",
+			Items:   []types.ShipmentItemPayload{{ProductID: 1, Quantity: 1}},
+		})
+		assert.NoError(t, err)
+
+		err = service.CancelOrder(order.ID, 2, types.RoleAdmin, "")
+
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+		assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+		assert.Empty(t, db.refunds)
+
+		p, err := db.gateway.Status(order.PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentCaptured, p.Status)
+	})
+
+	t.Run("Success - Delivering every shipment delivers the order", func(t *testing.T) {
+		db, service, order := setup(t)
+		first, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{
+			Carrier: "Correios",
+			Items:   []types.ShipmentItemPayload{{ProductID: 2, Quantity: 1}},
+		})
+		assert.NoError(t, err)
+		second, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+		assert.NoError(t, err)
+
+		delivered := types.ShipmentDelivered
+		updated, err := service.UpdateShipment(order.ID, first.ID, 2, &types.UpdateShipmentPayload{Status: &delivered})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, updated.DeliveredAt)
+		assert.Equal(t, types.OrderShipped, db.orders[order.ID].Status)
+
+		deliveredAt := time.Date(2025, 5, 20, 14, 0, 0, 0, time.UTC)
+		updated, err = service.UpdateShipment(order.ID, second.ID, 2, &types.UpdateShipmentPayload{DeliveredAt: &deliveredAt})
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.ShipmentDelivered, updated.Status)
+		assert.Equal(t, types.OrderDelivered, db.orders[order.ID].Status)
+
+		inTransit := types.ShipmentInTransit
+		_, err = service.UpdateShipment(order.ID, second.ID, 2, &types.UpdateShipmentPayload{Status: &inTransit})
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+	})
+
+	errorCases := []struct {
+		name  string
+		items []types.ShipmentItemPayload
+	}{
+		{name: "Error - More units than were bought", items: []types.ShipmentItemPayload{{ProductID: 1, Quantity: 3}}},
+		{name: "Error - Product not in the order", items: []types.ShipmentItemPayload{{ProductID: 9, Quantity: 1}}},
+		{name: "Error - Product listed twice", items: []types.ShipmentItemPayload{{ProductID: 1, Quantity: 1}, {ProductID: 1, Quantity: 1}}},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, service, order := setup(t)
+
+			_, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios", Items: tc.items})
+
+			var appErr *apperrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, apperrors.BAD, appErr.Type)
+			assert.Empty(t, db.shipments)
+		})
+	}
+
+	t.Run("Error - Unpaid order cannot be shipped", func(t *testing.T) {
+		db, service, _ := setup(t)
+		db.orders[9] = &types.OrderHistory{ID: 9, UserID: 1, TotalAmount: 20.0, Status: types.OrderPending}
+
+		_, err := service.CreateShipment(9, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+
+		var appErr *apperrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, apperrors.BAD, appErr.Type)
+	})
+
+	t.Run("Rollback - Notification fails", func(t *testing.T) {
+		db, service, order := setup(t)
+		db.failOn = "CreateNotification"
+
+		_, err := service.CreateShipment(order.ID, 2, &types.CreateShipmentPayload{Carrier: "Correios"})
+
+		assert.Error(t, err)
+		assert.Empty(t, db.shipments)
+		assert.Equal(t, types.OrderPaid, db.orders[order.ID].Status)
+	})
+}
+
+func TestServiceSearchOrders(t *testing.T) {
+	page := types.PageRequest{Limit: types.DefaultPageLimit}
+	shipped := types.OrderShipped
+
+	t.Run("Success - Orders come with their customers", func(t *testing.T) {
+		mockOrderStore := new(MockOrderStore)
+		filter := types.OrderFilter{Status: &shipped, Customer: "maria"}
+		mockOrderStore.On("SearchOrders", filter, page).Return(&types.Page[*types.OrderHistory]{Items: []*types.OrderHistory{
+			{ID: 2, UserID: 7, Status: types.OrderShipped},
+			{ID: 1, UserID: 8, Status: types.OrderShipped},
+		}}, nil)
+		mockOrderStore.On("GetOrderCustomers", []int{7, 8}).Return(map[int]*types.OrderCustomer{
+			7: {ID: 7, FullName: "Maria Souza", Email: "maria@example.com"},
+			8: {ID: 8, FullName: "Maria Lima", Email: "lima@example.com"},
+		}, nil)
+		service := NewService(mockOrderStore, new(MockCartStore), new(MockCartService), new(MockProductStore), newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), new(MockPaymentGateway), testPixIssuer, &MockUnitOfWork{})
+
+		orders, err := service.SearchOrders(filter, page)
+
+		assert.NoError(t, err)
+		assert.Len(t, orders.Items, 2)
+		assert.Equal(t, "Maria Souza", orders.Items[0].Customer.FullName)
+		assert.Equal(t, 8, orders.Items[1].Customer.ID)
+		mockOrderStore.AssertExpectations(t)
+	})
+
+	from := time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, -1)
+	unknownStatus := types.OrderStatus("LOST")
+	unknownMethod := types.PaymentMethod("CHEQUE")
+	minTotal, maxTotal := 100.0, 50.0
+	errorCases := []struct {
+		name   string
+		filter types.OrderFilter
+		field  string
+	}{
+		{name: "Error - Unknown status", filter: types.OrderFilter{Status: &unknownStatus}, field: "status"},
+		{name: "Error - Unknown payment method", filter: types.OrderFilter{PaymentMethod: &unknownMethod}, field: "paymentMethod"},
+		{name: "Error - Range ending before it starts", filter: types.OrderFilter{From: &from, To: &to}, field: "to"},
+		{name: "Error - Minimum total above the maximum", filter: types.OrderFilter{MinTotal: &minTotal, MaxTotal: &maxTotal}, field: "minTotal"},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockOrderStore := new(MockOrderStore)
+			service := NewService(mockOrderStore, new(MockCartStore), new(MockCartService), new(MockProductStore), newMockAddressStore(), newMockShippingService(), new(MockShipmentStore), new(MockPixStore), new(MockPaymentGateway), testPixIssuer, &MockUnitOfWork{})
+
+			orders, err := service.SearchOrders(tc.filter, page)
+
+			var appErr *apperrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, tc.field, appErr.Details["field"])
+			assert.Nil(t, orders)
+			mockOrderStore.AssertNotCalled(t, "SearchOrders", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestServiceBulkUpdateOrderStatus(t *testing.T) {
+	db := newFakeCheckoutDB()
+	db.orders[1] = &types.OrderHistory{ID: 1, UserID: 7, Status: types.OrderPaid}
+	db.orders[2] = &types.OrderHistory{ID: 2, UserID: 7, Status: types.OrderPending}
+	db.orders[3] = &types.OrderHistory{ID: 3, UserID: 8, Status: types.OrderPaid}
+	service := NewService(&fakeOrderStore{db: db}, new(MockCartStore), &fakeCartService{db: db}, new(MockProductStore), newMockAddressStore(), newMockShippingService(), &fakeShipmentStore{db: db}, &fakePixStore{db: db}, db.gateway, testPixIssuer, db)
+
+	results, err := service.BulkUpdateOrderStatus([]int{1, 2, 9, 3, 1}, types.OrderShipped, 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	assert.True(t, results[0].Updated)
+	assert.False(t, results[1].Updated)
+	assert.Equal(t, "BAD_REQUEST", results[1].Error)
+	assert.False(t, results[2].Updated)
+	assert.Equal(t, "ENTITY_NOT_FOUND", results[2].Error)
+	assert.True(t, results[3].Updated)
+
+	assert.Equal(t, types.OrderShipped, db.orders[1].Status)
+	assert.Equal(t, types.OrderPending, db.orders[2].Status)
+	assert.Equal(t, types.OrderShipped, db.orders[3].Status)
+	assert.Len(t, db.history, 2)
+
+	_, err = service.BulkUpdateOrderStatus([]int{1}, types.OrderStatus("LOST"), 2)
+	var appErr *apperrors.AppError
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, apperrors.BAD, appErr.Type)
+}
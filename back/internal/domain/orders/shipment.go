@@ -0,0 +1,289 @@
+package orders
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// CreateShipment ships the requested line quantities of a PAID order, or
+// everything not shipped yet when no items are given. Refunded units are
+// never shipped. The customer is notified, and the order moves to SHIPPED
+// with the shipment that leaves nothing more to ship.
+func (s *Service) CreateShipment(orderID int, userID int, payload *types.CreateShipmentPayload) (*types.Shipment, error) {
+	fmt.Printf("[ORDER SERVICE] Shipping order %d by user %d\n", orderID, userID)
+
+	var shipment *types.Shipment
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		order, err := tx.Orders.GetOrderByIDForUpdate(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		if order.Status != types.OrderPaid {
+			return apperrors.NewValidationError("status", fmt.Sprintf("order in status %s cannot be shipped", order.Status))
+		}
+
+		orderItems, err := tx.Orders.GetOrderItems(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order items: %v\n", err)
+			return fmt.Errorf("error getting order items: %w", err)
+		}
+
+		shipments, err := tx.Shipments.GetShipmentsByOrderID(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+			return fmt.Errorf("error getting shipments: %w", err)
+		}
+
+		unshipped := unshippedQuantities(orderItems, shipments)
+		items, err := buildShipmentItems(orderItems, unshipped, payload.Items)
+		if err != nil {
+			return err
+		}
+
+		shippedAt := time.Now()
+		if payload.ShippedAt != nil {
+			shippedAt = *payload.ShippedAt
+		}
+
+		shipment = &types.Shipment{
+			OrderID:      orderID,
+			Carrier:      payload.Carrier,
+			TrackingCode: payload.TrackingCode,
+			TrackingURL:  payload.TrackingURL,
+			Status:       types.ShipmentShipped,
+			Items:        items,
+			ShippedAt:    shippedAt,
+			CreatedBy:    &userID,
+		}
+
+		err = tx.Shipments.CreateShipment(shipment)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error creating shipment: %v\n", err)
+			return fmt.Errorf("error creating shipment: %w", err)
+		}
+
+		message := fmt.Sprintf("Your order #%d has shipped with %s.", orderID, shipment.Carrier)
+		if shipment.TrackingCode != "" {
+			message = fmt.Sprintf("%s Tracking code: %s", message, shipment.TrackingCode)
+		}
+
+		_, err = tx.Notifications.CreateNotification(&types.CreateNotificationPayload{
+			Title:   "Order shipped",
+			Message: message,
+		}, order.UserID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error notifying user %d: %v\n", order.UserID, err)
+			return fmt.Errorf("error notifying customer: %w", err)
+		}
+
+		return s.shipIfComplete(tx, order, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[ORDER SERVICE] Shipment %d created for order %d\n", shipment.ID, orderID)
+	return shipment, nil
+}
+
+// UpdateShipment changes the carrier, tracking or status of a shipment. A
+// delivered shipment stays delivered, and gets the delivery date when none is
+// given. A SHIPPED order moves to DELIVERED once all its shipments are.
+func (s *Service) UpdateShipment(orderID int, shipmentID int, userID int, payload *types.UpdateShipmentPayload) (*types.Shipment, error) {
+	fmt.Printf("[ORDER SERVICE] Updating shipment %d of order %d by user %d\n", shipmentID, orderID, userID)
+
+	if payload.Status != nil {
+		if err := payload.Status.Valid(); err != nil {
+			return nil, apperrors.NewValidationError("status", err.Error())
+		}
+	}
+
+	var shipment *types.Shipment
+	err := s.uow.Do(func(tx *types.TxStores) error {
+		order, err := tx.Orders.GetOrderByIDForUpdate(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting order: %v\n", err)
+			return err
+		}
+
+		shipment, err = tx.Shipments.GetShipment(orderID, shipmentID)
+		if err != nil {
+			return err
+		}
+
+		wasDelivered := shipment.Status == types.ShipmentDelivered
+		if wasDelivered && payload.Status != nil && *payload.Status != types.ShipmentDelivered {
+			return apperrors.NewValidationError("status", fmt.Sprintf("a delivered shipment cannot go back to %s", *payload.Status))
+		}
+
+		if payload.Carrier != nil {
+			shipment.Carrier = *payload.Carrier
+		}
+		if payload.TrackingCode != nil {
+			shipment.TrackingCode = *payload.TrackingCode
+		}
+		if payload.TrackingURL != nil {
+			shipment.TrackingURL = *payload.TrackingURL
+		}
+		if payload.Status != nil {
+			shipment.Status = *payload.Status
+		}
+
+		// a delivery date means the shipment arrived
+		if payload.DeliveredAt != nil {
+			shipment.Status = types.ShipmentDelivered
+			shipment.DeliveredAt = payload.DeliveredAt
+		}
+		if shipment.Status == types.ShipmentDelivered && shipment.DeliveredAt == nil {
+			now := time.Now()
+			shipment.DeliveredAt = &now
+		}
+
+		err = tx.Shipments.UpdateShipment(shipment)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error updating shipment: %v\n", err)
+			return fmt.Errorf("error updating shipment: %w", err)
+		}
+
+		if wasDelivered || shipment.Status != types.ShipmentDelivered || order.Status != types.OrderShipped {
+			return nil
+		}
+
+		shipments, err := tx.Shipments.GetShipmentsByOrderID(orderID)
+		if err != nil {
+			fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+			return fmt.Errorf("error getting shipments: %w", err)
+		}
+
+		for _, other := range shipments {
+			if other.Status != types.ShipmentDelivered {
+				return nil
+			}
+		}
+
+		return s.changeStatus(tx, order, types.OrderDelivered, userID, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// shipIfComplete moves a PAID order that has shipments to SHIPPED once
+// every unit not refunded has shipped. A shipment or a refund of the last
+// unshipped units can complete the order.
+func (s *Service) shipIfComplete(tx *types.TxStores, order *types.OrderHistory, userID int) error {
+	if order.Status != types.OrderPaid {
+		return nil
+	}
+
+	shipments, err := tx.Shipments.GetShipmentsByOrderID(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+		return fmt.Errorf("error getting shipments: %w", err)
+	}
+
+	if len(shipments) == 0 {
+		return nil
+	}
+
+	orderItems, err := tx.Orders.GetOrderItems(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting order items: %v\n", err)
+		return fmt.Errorf("error getting order items: %w", err)
+	}
+
+	for _, quantity := range unshippedQuantities(orderItems, shipments) {
+		if quantity > 0 {
+			return nil
+		}
+	}
+
+	return s.changeStatus(tx, order, types.OrderShipped, userID, "")
+}
+
+// checkNothingShipped refuses to cancel an order that has shipments, as the
+// units already sent cannot be restocked. What was not shipped is refunded
+// instead.
+func checkNothingShipped(tx *types.TxStores, order *types.OrderHistory) error {
+	shipments, err := tx.Shipments.GetShipmentsByOrderID(order.ID)
+	if err != nil {
+		fmt.Printf("[ORDER SERVICE] Error getting shipments: %v\n", err)
+		return fmt.Errorf("error getting shipments: %w", err)
+	}
+
+	if len(shipments) > 0 {
+		return apperrors.NewValidationError("status", "order has shipments and cannot be cancelled, refund the units not shipped instead")
+	}
+
+	return nil
+}
+
+// unshippedQuantities is what is left to ship of each order line: the units
+// neither refunded nor in a shipment already.
+func unshippedQuantities(orderItems []*types.OrderItem, shipments []*types.Shipment) map[lineKey]int {
+	unshipped := make(map[lineKey]int, len(orderItems))
+	for _, item := range orderItems {
+		unshipped[lineKey{item.ProductID, item.VariantID}] += item.Quantity - item.RefundedQuantity
+	}
+
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			unshipped[lineKey{item.ProductID, item.VariantID}] -= item.Quantity
+		}
+	}
+
+	for line, quantity := range unshipped {
+		unshipped[line] = max(quantity, 0)
+	}
+
+	return unshipped
+}
+
+// buildShipmentItems checks the requested quantities against what is left to
+// ship of each order line. No request means everything left ships.
+func buildShipmentItems(orderItems []*types.OrderItem, unshipped map[lineKey]int, requested []types.ShipmentItemPayload) ([]*types.ShipmentItem, error) {
+	var items []*types.ShipmentItem
+
+	if len(requested) == 0 {
+		for _, orderItem := range orderItems {
+			left := unshipped[lineKey{orderItem.ProductID, orderItem.VariantID}]
+			if left > 0 {
+				items = append(items, &types.ShipmentItem{ProductID: orderItem.ProductID, VariantID: orderItem.VariantID, Quantity: left})
+			}
+		}
+
+		if len(items) == 0 {
+			return nil, apperrors.NewValidationError("items", "everything in the order has already shipped")
+		}
+		return items, nil
+	}
+
+	seen := make(map[lineKey]bool, len(requested))
+	for _, req := range requested {
+		line := lineKey{req.ProductID, req.VariantID}
+		left, ok := unshipped[line]
+		if !ok {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("%s is not in the order", line))
+		}
+
+		if seen[line] {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("%s is listed more than once", line))
+		}
+		seen[line] = true
+
+		if req.Quantity > left {
+			return nil, apperrors.NewValidationError("items", fmt.Sprintf("only %d units of %s are left to ship", left, line))
+		}
+
+		items = append(items, &types.ShipmentItem{ProductID: req.ProductID, VariantID: req.VariantID, Quantity: req.Quantity})
+	}
+
+	return items, nil
+}
@@ -0,0 +1,42 @@
+package orders
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// orderTransitions lists, for each status, the statuses an order can move to
+// and the roles allowed to make that move. Anything not listed is rejected.
+var orderTransitions = map[types.OrderStatus]map[types.OrderStatus][]types.UserRole{
+	types.OrderPending: {
+		types.OrderPaid:      {types.RoleAdmin},
+		types.OrderCancelled: {types.RoleUser, types.RoleAdmin},
+	},
+	types.OrderPaid: {
+		types.OrderShipped:   {types.RoleAdmin},
+		types.OrderCancelled: {types.RoleAdmin},
+	},
+	types.OrderShipped: {
+		types.OrderDelivered: {types.RoleAdmin},
+	},
+	types.OrderDelivered: {
+		types.OrderCompleted: {types.RoleAdmin},
+	},
+}
+
+func checkTransition(from types.OrderStatus, to types.OrderStatus, role types.UserRole) error {
+	roles, ok := orderTransitions[from][to]
+	if !ok {
+		return apperrors.NewValidationError("status", fmt.Sprintf("cannot change order status from %s to %s", from, to))
+	}
+
+	for _, allowed := range roles {
+		if allowed == role {
+			return nil
+		}
+	}
+
+	return apperrors.NewForbiddenError(fmt.Sprintf("role %s cannot change order status from %s to %s", role, from, to))
+}
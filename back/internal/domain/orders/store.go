@@ -3,25 +3,45 @@ package orders
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 )
 
+// orderColumns are the columns scanOrder reads.
+const orderColumns = `
+	id, userId, totalAmount, status, paymentMethod, paymentId,
+	shippingStreet, shippingCity, shippingState, shippingPostalCode, shippingCountry,
+	shippingService, shippingCost, createdAt, updatedAt
+`
+
 type Store struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{db}
 }
 
-func (s *Store) CreateOrder(userID int, totalAmount float64, paymentMethod types.PaymentMethod, paymentID string) (*types.OrderHistory, error) {
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{tx}
+}
+
+func (s *Store) CreateOrder(userID int, totalAmount float64, paymentMethod types.PaymentMethod, paymentID string, shipping *types.ShippingAddress, option *types.ShippingOption) (*types.OrderHistory, error) {
 	query := `
-		INSERT INTO order_history (userId, totalAmount, status, paymentMethod, paymentId, createdAt, updatedAt)
-		VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+		INSERT INTO order_history (
+			userId, totalAmount, status, paymentMethod, paymentId,
+			shippingStreet, shippingCity, shippingState, shippingPostalCode, shippingCountry,
+			shippingService, shippingCost, createdAt, updatedAt
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
 	`
-	result, err := s.db.Exec(query, userID, totalAmount, types.OrderPending, paymentMethod, paymentID)
+	result, err := s.db.Exec(query, userID, totalAmount, types.OrderPending, paymentMethod, paymentID,
+		shipping.Street, shipping.City, shipping.State, shipping.PostalCode, shipping.Country,
+		option.ID, option.Price)
 	if err != nil {
 		return nil, fmt.Errorf("error creating order: %w", err)
 	}
@@ -40,63 +60,77 @@ func (s *Store) CreateOrder(userID int, totalAmount float64, paymentMethod types
 }
 
 func (s *Store) AddOrderItems(orderID int, items []*types.OrderItem) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("error beginning transaction: %w", err)
-	}
+	query := `
+		INSERT INTO order_items (orderId, productId, productTitle, variantId, quantity, basePrice, price, discount, variantLabel)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		for _, item := range items {
+			_, err := tx.Exec(query, orderID, item.ProductID, item.ProductTitle, item.VariantID, item.Quantity,
+				item.BasePrice, item.Price, item.Discount, item.VariantLabel)
+			if err != nil {
+				return fmt.Errorf("error adding order item: %w", err)
+			}
 		}
-	}()
+		return nil
+	})
+}
 
+func (s *Store) GetOrdersByUserID(userID int, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
+	after, args := database.NewestFirstAfter(page.After, "createdAt", "id")
 	query := `
-		INSERT INTO order_items (orderId, productId, quantity, price)
-		VALUES (?, ?, ?, ?)
+		SELECT ` + orderColumns + `
+		FROM order_history
+		WHERE userId = ? AND ` + after + `
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
 	`
+	args = append([]any{userID}, args...)
+	rows, err := s.db.Query(query, append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching orders: %w", err)
+	}
+	defer rows.Close()
 
-	for _, item := range items {
-		_, err = tx.Exec(query, orderID, item.ProductID, item.Quantity, item.Price)
+	var orders []*types.OrderHistory
+	for rows.Next() {
+		order, err := scanOrder(rows)
 		if err != nil {
-			return fmt.Errorf("error adding order item: %w", err)
+			return nil, fmt.Errorf("error scanning order: %w", err)
 		}
+		orders = append(orders, order)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
 	}
 
-	return nil
+	return types.NewPage(orders, page.Limit, func(o *types.OrderHistory) types.Cursor {
+		return types.Cursor{ID: o.ID, Time: &o.CreatedAt}
+	}), nil
 }
 
-func (s *Store) GetOrdersByUserID(userID int) ([]*types.OrderHistory, error) {
+func (s *Store) SearchOrders(filter types.OrderFilter, page types.PageRequest) (*types.Page[*types.OrderHistory], error) {
+	where, args := orderFilterConditions(filter)
+	after, afterArgs := database.NewestFirstAfter(page.After, "createdAt", "id")
 	query := `
-		SELECT id, userId, totalAmount, status, paymentMethod, paymentId, createdAt, updatedAt
+		SELECT ` + orderColumns + `
 		FROM order_history
-		WHERE userId = ?
-		ORDER BY createdAt DESC
+		WHERE ` + strings.Join(append(where, after), " AND ") + `
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
 	`
-	rows, err := s.db.Query(query, userID)
+	args = append(args, afterArgs...)
+	rows, err := s.db.Query(query, append(args, page.Limit+1)...)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching orders: %w", err)
+		return nil, fmt.Errorf("error searching orders: %w", err)
 	}
 	defer rows.Close()
 
 	var orders []*types.OrderHistory
 	for rows.Next() {
-		order := &types.OrderHistory{}
-		err := rows.Scan(
-			&order.ID,
-			&order.UserID,
-			&order.TotalAmount,
-			&order.Status,
-			&order.PaymentMethod,
-			&order.PaymentID,
-			&order.CreatedAt,
-			&order.UpdatedAt,
-		)
+		order, err := scanOrder(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning order: %w", err)
 		}
@@ -107,26 +141,102 @@ func (s *Store) GetOrdersByUserID(userID int) ([]*types.OrderHistory, error) {
 		return nil, fmt.Errorf("error iterating orders: %w", err)
 	}
 
-	return orders, nil
+	return types.NewPage(orders, page.Limit, func(o *types.OrderHistory) types.Cursor {
+		return types.Cursor{ID: o.ID, Time: &o.CreatedAt}
+	}), nil
+}
+
+// orderFilterConditions turns filter into the conditions of a WHERE clause
+// on order_history and their args.
+func orderFilterConditions(filter types.OrderFilter) ([]string, []any) {
+	var where []string
+	var args []any
+
+	if filter.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, *filter.Status)
+	}
+	if filter.PaymentMethod != nil {
+		where = append(where, "paymentMethod = ?")
+		args = append(args, *filter.PaymentMethod)
+	}
+	if filter.From != nil {
+		where = append(where, "createdAt >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		where = append(where, "createdAt < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.UserID != nil {
+		where = append(where, "userId = ?")
+		args = append(args, *filter.UserID)
+	}
+	if filter.Customer != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Customer) + "%"
+		where = append(where, "userId IN (SELECT id FROM users WHERE fullName LIKE ? OR email LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+	if filter.MinTotal != nil {
+		where = append(where, "totalAmount >= ?")
+		args = append(args, *filter.MinTotal)
+	}
+	if filter.MaxTotal != nil {
+		where = append(where, "totalAmount <= ?")
+		args = append(args, *filter.MaxTotal)
+	}
+
+	return where, args
+}
+
+// likeEscaper makes the wildcards of LIKE match themselves.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *Store) GetOrderCustomers(userIDs []int) (map[int]*types.OrderCustomer, error) {
+	in, args := database.InArgs(userIDs)
+	rows, err := s.db.Query(`
+		SELECT id, fullName, email, cpf
+		FROM users
+		WHERE id IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching customers: %w", err)
+	}
+	defer rows.Close()
+
+	customers := make(map[int]*types.OrderCustomer)
+	for rows.Next() {
+		customer := &types.OrderCustomer{}
+		if err := rows.Scan(&customer.ID, &customer.FullName, &customer.Email, &customer.Cpf); err != nil {
+			return nil, fmt.Errorf("error scanning customer: %w", err)
+		}
+		customers[customer.ID] = customer
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating customers: %w", err)
+	}
+
+	return customers, nil
 }
 
 func (s *Store) GetOrderByID(orderID int) (*types.OrderHistory, error) {
+	return s.getOrderByID(orderID, "")
+}
+
+// GetOrderByIDForUpdate locks the order row until the transaction ends, so
+// changes that depend on its refunds or status are serialized.
+func (s *Store) GetOrderByIDForUpdate(orderID int) (*types.OrderHistory, error) {
+	return s.getOrderByID(orderID, "FOR UPDATE")
+}
+
+func (s *Store) getOrderByID(orderID int, lock string) (*types.OrderHistory, error) {
 	query := `
-		SELECT id, userId, totalAmount, status, paymentMethod, paymentId, createdAt, updatedAt
+		SELECT ` + orderColumns + `
 		FROM order_history
 		WHERE id = ?
-	`
-	order := &types.OrderHistory{}
-	err := s.db.QueryRow(query, orderID).Scan(
-		&order.ID,
-		&order.UserID,
-		&order.TotalAmount,
-		&order.Status,
-		&order.PaymentMethod,
-		&order.PaymentID,
-		&order.CreatedAt,
-		&order.UpdatedAt,
-	)
+		` + lock
+	order, err := scanOrder(s.db.QueryRow(query, orderID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, apperrors.NewEntityNotFound("order", orderID)
@@ -138,30 +248,61 @@ func (s *Store) GetOrderByID(orderID int) (*types.OrderHistory, error) {
 }
 
 func (s *Store) GetOrderItems(orderID int) ([]*types.OrderItem, error) {
+	items, err := s.GetItemsForOrders([]int{orderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return items[orderID], nil
+}
+
+// GetItemsForOrders loads the items of every order in one query, mapped by
+// order id.
+func (s *Store) GetItemsForOrders(orderIDs []int) (map[int][]*types.OrderItem, error) {
+	in, args := database.InArgs(orderIDs)
 	query := `
-		SELECT orderId, productId, quantity, price
-		FROM order_items
-		WHERE orderId = ?
+		SELECT oi.orderId, oi.productId, oi.productTitle, oi.variantId, oi.variantLabel, oi.quantity, oi.basePrice, oi.price, oi.discount,
+			COALESCE((
+				SELECT SUM(ri.quantity)
+				FROM refund_items ri
+				WHERE ri.orderId = oi.orderId AND ri.productId = oi.productId AND ri.variantId = oi.variantId
+			), 0),
+			COALESCE((
+				SELECT SUM(ri.quantity)
+				FROM refund_items ri
+				JOIN refunds r ON r.id = ri.refundId
+				WHERE ri.orderId = oi.orderId AND ri.productId = oi.productId AND ri.variantId = oi.variantId
+					AND r.restocked = TRUE
+			), 0)
+		FROM order_items oi
+		WHERE oi.orderId IN (` + in + `)
 	`
-	rows, err := s.db.Query(query, orderID)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching order items: %w", err)
 	}
 	defer rows.Close()
 
-	var items []*types.OrderItem
+	items := make(map[int][]*types.OrderItem)
 	for rows.Next() {
 		item := &types.OrderItem{}
 		err := rows.Scan(
 			&item.OrderID,
 			&item.ProductID,
+			&item.ProductTitle,
+			&item.VariantID,
+			&item.VariantLabel,
 			&item.Quantity,
+			&item.BasePrice,
 			&item.Price,
+			&item.Discount,
+			&item.RefundedQuantity,
+			&item.RestockedQuantity,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning order item: %w", err)
 		}
-		items = append(items, item)
+		items[item.OrderID] = append(items[item.OrderID], item)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -171,13 +312,16 @@ func (s *Store) GetOrderItems(orderID int) ([]*types.OrderItem, error) {
 	return items, nil
 }
 
-func (s *Store) UpdateOrderStatus(orderID int, status types.OrderStatus) error {
+// UpdateOrderStatus moves the order from one status to another. The update
+// only applies while the order is still in the from status, so two
+// concurrent transitions cannot both succeed.
+func (s *Store) UpdateOrderStatus(orderID int, from types.OrderStatus, to types.OrderStatus) error {
 	query := `
 		UPDATE order_history
 		SET status = ?, updatedAt = NOW()
-		WHERE id = ?
+		WHERE id = ? AND status = ?
 	`
-	result, err := s.db.Exec(query, status, orderID)
+	result, err := s.db.Exec(query, to, orderID, from)
 	if err != nil {
 		return fmt.Errorf("error updating order status: %w", err)
 	}
@@ -188,30 +332,102 @@ func (s *Store) UpdateOrderStatus(orderID int, status types.OrderStatus) error {
 	}
 
 	if rowsAffected == 0 {
-		return apperrors.NewEntityNotFound("order", orderID)
+		if _, err := s.GetOrderByID(orderID); err != nil {
+			return err
+		}
+		return apperrors.NewConflictError("status", fmt.Sprintf("order %d is no longer %s", orderID, from))
 	}
 
 	return nil
 }
 
-func (s *Store) GetOrdersWithItems(userID int) ([]*types.OrderWithItems, error) {
-	orders, err := s.GetOrdersByUserID(userID)
+func (s *Store) AddOrderStatusHistory(orderID int, from *types.OrderStatus, to types.OrderStatus, changedBy int, note string) error {
+	query := `
+		INSERT INTO order_status_history (orderId, fromStatus, toStatus, changedBy, note, createdAt)
+		VALUES (?, ?, ?, NULLIF(?, 0), NULLIF(?, ''), NOW())
+	`
+	_, err := s.db.Exec(query, orderID, from, to, changedBy, note)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("error adding order status history: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetOrderStatusHistory(orderID int) ([]*types.OrderStatusHistory, error) {
+	query := `
+		SELECT id, orderId, fromStatus, toStatus, changedBy, COALESCE(note, ''), createdAt
+		FROM order_status_history
+		WHERE orderId = ?
+		ORDER BY createdAt ASC, id ASC
+	`
+	rows, err := s.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order status history: %w", err)
 	}
+	defer rows.Close()
 
-	var ordersWithItems []*types.OrderWithItems
-	for _, order := range orders {
-		items, err := s.GetOrderItems(order.ID)
+	var history []*types.OrderStatusHistory
+	for rows.Next() {
+		entry := &types.OrderStatusHistory{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.OrderID,
+			&entry.FromStatus,
+			&entry.ToStatus,
+			&entry.ChangedBy,
+			&entry.Note,
+			&entry.CreatedAt,
+		)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error scanning order status history: %w", err)
 		}
+		history = append(history, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order status history: %w", err)
+	}
+
+	return history, nil
+}
+
+func (s *Store) GetOrdersWithItems(userID int, page types.PageRequest) (*types.Page[*types.OrderWithItems], error) {
+	orders, err := s.GetOrdersByUserID(userID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	ordersWithItems := &types.Page[*types.OrderWithItems]{
+		Items:      make([]*types.OrderWithItems, 0, len(orders.Items)),
+		NextCursor: orders.NextCursor,
+	}
+	if len(orders.Items) == 0 {
+		return ordersWithItems, nil
+	}
+
+	orderIDs := make([]int, 0, len(orders.Items))
+	for _, order := range orders.Items {
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	items, err := s.GetItemsForOrders(orderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	refunded, err := s.GetRefundedAmounts(orderIDs)
+	if err != nil {
+		return nil, err
+	}
 
+	for _, order := range orders.Items {
 		orderWithItems := &types.OrderWithItems{
-			Order: *order,
-			Items: items,
+			Order:          *order,
+			Items:          items[order.ID],
+			RefundedAmount: refunded[order.ID],
 		}
-		ordersWithItems = append(ordersWithItems, orderWithItems)
+		ordersWithItems.Items = append(ordersWithItems.Items, orderWithItems)
 	}
 
 	return ordersWithItems, nil
@@ -228,10 +444,185 @@ func (s *Store) GetOrderWithItems(orderID int) (*types.OrderWithItems, error) {
 		return nil, err
 	}
 
+	refunded, err := s.GetRefundedAmount(orderID)
+	if err != nil {
+		return nil, err
+	}
+
 	orderWithItems := &types.OrderWithItems{
-		Order: *order,
-		Items: items,
+		Order:          *order,
+		Items:          items,
+		RefundedAmount: refunded,
 	}
 
 	return orderWithItems, nil
 }
+
+func (s *Store) CreateRefund(refund *types.Refund) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		result, err := tx.Exec(`
+			INSERT INTO refunds (orderId, amount, reason, restocked, createdBy, createdAt)
+			VALUES (?, ?, NULLIF(?, ''), ?, ?, NOW())
+		`, refund.OrderID, refund.Amount, refund.Reason, refund.Restocked, refund.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("error creating refund: %w", err)
+		}
+
+		refundID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("error getting refund ID: %w", err)
+		}
+		refund.ID = int(refundID)
+
+		for _, item := range refund.Items {
+			item.RefundID = refund.ID
+			_, err := tx.Exec(`
+				INSERT INTO refund_items (refundId, orderId, productId, variantId, quantity, amount)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, item.RefundID, refund.OrderID, item.ProductID, item.VariantID, item.Quantity, item.Amount)
+			if err != nil {
+				return fmt.Errorf("error adding refund item: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) GetRefundsByOrderID(orderID int) ([]*types.Refund, error) {
+	rows, err := s.db.Query(`
+		SELECT id, orderId, amount, COALESCE(reason, ''), restocked, createdBy, createdAt
+		FROM refunds
+		WHERE orderId = ?
+		ORDER BY createdAt, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []*types.Refund
+	byID := make(map[int]*types.Refund)
+	for rows.Next() {
+		refund := &types.Refund{Items: []*types.RefundItem{}}
+		err := rows.Scan(
+			&refund.ID,
+			&refund.OrderID,
+			&refund.Amount,
+			&refund.Reason,
+			&refund.Restocked,
+			&refund.CreatedBy,
+			&refund.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+		byID[refund.ID] = refund
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating refunds: %w", err)
+	}
+
+	itemRows, err := s.db.Query(`
+		SELECT refundId, productId, variantId, quantity, amount
+		FROM refund_items
+		WHERE orderId = ?
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refund items: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		item := &types.RefundItem{}
+		err := itemRows.Scan(&item.RefundID, &item.ProductID, &item.VariantID, &item.Quantity, &item.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning refund item: %w", err)
+		}
+		if refund, ok := byID[item.RefundID]; ok {
+			refund.Items = append(refund.Items, item)
+		}
+	}
+
+	if err = itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating refund items: %w", err)
+	}
+
+	return refunds, nil
+}
+
+func (s *Store) GetRefundedAmount(orderID int) (float64, error) {
+	var refunded float64
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0)
+		FROM refunds
+		WHERE orderId = ?
+	`, orderID).Scan(&refunded)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching refunded amount: %w", err)
+	}
+
+	return refunded, nil
+}
+
+// GetRefundedAmounts sums the refunds of every order in one query, mapped by
+// order id. Orders without refunds are left out.
+func (s *Store) GetRefundedAmounts(orderIDs []int) (map[int]float64, error) {
+	in, args := database.InArgs(orderIDs)
+	rows, err := s.db.Query(`
+		SELECT orderId, SUM(amount)
+		FROM refunds
+		WHERE orderId IN (`+in+`)
+		GROUP BY orderId
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refunded amounts: %w", err)
+	}
+	defer rows.Close()
+
+	refunded := make(map[int]float64)
+	for rows.Next() {
+		var orderID int
+		var amount float64
+		if err := rows.Scan(&orderID, &amount); err != nil {
+			return nil, fmt.Errorf("error scanning refunded amount: %w", err)
+		}
+		refunded[orderID] = amount
+	}
+
+	return refunded, rows.Err()
+}
+
+// scanOrder reads the orderColumns of a row. Orders placed before addresses
+// were kept have no shipping address.
+func scanOrder(row interface{ Scan(...any) error }) (*types.OrderHistory, error) {
+	order := &types.OrderHistory{}
+	shipping := &types.ShippingAddress{}
+	err := row.Scan(
+		&order.ID,
+		&order.UserID,
+		&order.TotalAmount,
+		&order.Status,
+		&order.PaymentMethod,
+		&order.PaymentID,
+		&shipping.Street,
+		&shipping.City,
+		&shipping.State,
+		&shipping.PostalCode,
+		&shipping.Country,
+		&order.ShippingService,
+		&order.ShippingCost,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if shipping.Street != "" {
+		order.ShippingAddress = shipping
+	}
+	return order, nil
+}
@@ -0,0 +1,51 @@
+package orders
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// PixSweeper periodically cancels the PENDING orders whose PIX charge expired
+// unpaid, so their stock and authorization are not held forever. A PIX that
+// still arrives for one of them is returned by ConfirmPixPayment.
+type PixSweeper struct {
+	service  types.OrderService
+	interval time.Duration
+}
+
+func NewPixSweeper(service types.OrderService, interval time.Duration) *PixSweeper {
+	return &PixSweeper{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *PixSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+func (s *PixSweeper) Sweep() {
+	cancelled, err := s.service.CancelExpiredPixOrders()
+	if err != nil {
+		fmt.Printf("[PIX SWEEPER] Error cancelling expired pix orders: %v\n", err)
+		return
+	}
+
+	if cancelled > 0 {
+		fmt.Printf("[PIX SWEEPER] Cancelled %d orders with expired pix charges\n", cancelled)
+	}
+}
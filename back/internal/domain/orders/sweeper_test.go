@@ -0,0 +1,43 @@
+package orders
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPixSweeperRunsUntilCancelled(t *testing.T) {
+	sweeps := make(chan struct{}, 10)
+	service := new(MockOrderService)
+	service.On("CancelExpiredPixOrders").Return(1, nil).Run(func(_ mock.Arguments) {
+		select {
+		case sweeps <- struct{}{}:
+		default:
+		}
+	})
+	sweeper := NewPixSweeper(service, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweeper.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sweeps:
+		case <-time.After(time.Second):
+			t.Fatal("sweeper did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop")
+	}
+}
@@ -0,0 +1,63 @@
+package orders
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/cart"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/coupon"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/notification"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/payment"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/product"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/reservation"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/shipment"
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/variant"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type UnitOfWork struct {
+	db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+func (u *UnitOfWork) Do(fn func(stores *types.TxStores) error) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	stores := &types.TxStores{
+		Orders:        NewTxStore(tx),
+		Carts:         cart.NewTxStore(tx),
+		Products:      product.NewTxStore(tx),
+		Variants:      variant.NewTxStore(tx),
+		Notifications: notification.NewTxStore(tx),
+		Pix:           payment.NewTxStore(tx),
+		Reservations:  reservation.NewTxStore(tx),
+		Coupons:       coupon.NewTxStore(tx),
+		Shipments:     shipment.NewTxStore(tx),
+	}
+
+	if err = fn(stores); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var errStep = errors.New("step failed")
+
+// checkoutSteps write an order the way checkout does, each through the store
+// bound to the transaction. expect registers what a step runs, failing it
+// when fail is set.
+var checkoutSteps = []struct {
+	name   string
+	run    func(tx *types.TxStores) error
+	expect func(db sqlmock.Sqlmock, fail bool)
+}{
+	{
+		name: "status history",
+		run: func(tx *types.TxStores) error {
+			return tx.Orders.AddOrderStatusHistory(1, nil, types.OrderPending, 7, "")
+		},
+		expect: func(db sqlmock.Sqlmock, fail bool) {
+			exec := db.ExpectExec("INSERT INTO order_status_history")
+			if fail {
+				exec.WillReturnError(errStep)
+				return
+			}
+			exec.WillReturnResult(sqlmock.NewResult(1, 1))
+		},
+	},
+	{
+		name: "order items",
+		run: func(tx *types.TxStores) error {
+			return tx.Orders.AddOrderItems(1, []*types.OrderItem{{ProductID: 1, Quantity: 2, BasePrice: 10, Price: 10}})
+		},
+		expect: func(db sqlmock.Sqlmock, fail bool) {
+			exec := db.ExpectExec("INSERT INTO order_items")
+			if fail {
+				exec.WillReturnError(errStep)
+				return
+			}
+			exec.WillReturnResult(sqlmock.NewResult(1, 1))
+		},
+	},
+	{
+		name: "stock",
+		run: func(tx *types.TxStores) error {
+			return tx.Products.UpdateStock(1, -2)
+		},
+		expect: func(db sqlmock.Sqlmock, fail bool) {
+			db.ExpectQuery("SELECT stock_quantity, version FROM inventory").
+				WillReturnRows(sqlmock.NewRows([]string{"stock_quantity", "version"}).AddRow(5, 3))
+			exec := db.ExpectExec("UPDATE inventory SET stock_quantity")
+			if fail {
+				exec.WillReturnError(errStep)
+				return
+			}
+			exec.WillReturnResult(sqlmock.NewResult(0, 1))
+		},
+	},
+}
+
+func runCheckoutSteps(tx *types.TxStores) error {
+	for _, step := range checkoutSteps {
+		if err := step.run(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestUnitOfWorkCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	for _, step := range checkoutSteps {
+		step.expect(mock, false)
+	}
+	mock.ExpectCommit()
+
+	err = NewUnitOfWork(db).Do(runCheckoutSteps)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnitOfWorkRollsBackWhenAStepFails(t *testing.T) {
+	for failing, step := range checkoutSteps {
+		t.Run(step.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectBegin()
+			for _, done := range checkoutSteps[:failing] {
+				done.expect(mock, false)
+			}
+			step.expect(mock, true)
+			// a Commit would not be expected, so it would fail the test too
+			mock.ExpectRollback()
+
+			err = NewUnitOfWork(db).Do(runCheckoutSteps)
+
+			assert.ErrorIs(t, err, errStep)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUnitOfWorkRollsBackOnPanic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	checkoutSteps[0].expect(mock, false)
+	mock.ExpectRollback()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		NewUnitOfWork(db).Do(func(tx *types.TxStores) error {
+			if err := checkoutSteps[0].run(tx); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnitOfWorkReportsAFailedCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(errStep)
+
+	err = NewUnitOfWork(db).Do(func(tx *types.TxStores) error { return nil })
+
+	assert.ErrorIs(t, err, errStep)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
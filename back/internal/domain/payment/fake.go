@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// DeclinedToken makes the fake gateway refuse the authorization, so the
+// declined path can be exercised without a real provider.
+const DeclinedToken = "tok_declined"
+
+// FakeGateway is an in-memory PaymentGateway for tests and local development.
+// Every authorization succeeds unless the token is DeclinedToken.
+type FakeGateway struct {
+	mu       sync.Mutex
+	payments map[string]*types.PaymentResult
+	nextID   int
+	// pixRefunds holds what was returned of each PIX by its endToEndId
+	pixRefunds map[string]float64
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		payments:   make(map[string]*types.PaymentResult),
+		nextID:     1,
+		pixRefunds: make(map[string]float64),
+	}
+}
+
+func (g *FakeGateway) Authorize(req *types.PaymentRequest) (*types.PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("[FakeGateway] invalid amount %.2f", req.Amount)
+	}
+
+	if req.Token == DeclinedToken {
+		return nil, types.ErrPaymentDeclined
+	}
+
+	payment := &types.PaymentResult{
+		ID:     fmt.Sprintf("fake_pay_%d", g.nextID),
+		Status: types.PaymentAuthorized,
+		Amount: req.Amount,
+	}
+	g.payments[payment.ID] = payment
+	g.nextID++
+
+	return copyResult(payment), nil
+}
+
+func (g *FakeGateway) Capture(paymentID string) (*types.PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[paymentID]
+	if !ok {
+		return nil, types.ErrPaymentNotFound
+	}
+
+	switch payment.Status {
+	case types.PaymentAuthorized:
+		payment.Status = types.PaymentCaptured
+	case types.PaymentCaptured:
+		// capturing twice is a no-op, as with real providers
+	default:
+		return nil, fmt.Errorf("[FakeGateway] cannot capture payment %s in status %s", paymentID, payment.Status)
+	}
+
+	return copyResult(payment), nil
+}
+
+// Refund voids an authorized payment, or refunds amount of a captured one.
+// A captured payment can be refunded in parts until nothing is left.
+func (g *FakeGateway) Refund(paymentID string, amount float64) (*types.PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[paymentID]
+	if !ok {
+		return nil, types.ErrPaymentNotFound
+	}
+
+	switch payment.Status {
+	case types.PaymentAuthorized:
+		payment.Status = types.PaymentVoided
+	case types.PaymentCaptured, types.PaymentPartiallyRefunded:
+		remaining := math.Round((payment.Amount-payment.Refunded)*100) / 100
+		if amount <= 0 || amount > remaining {
+			return nil, fmt.Errorf("[FakeGateway] invalid refund amount %.2f for payment %s", amount, paymentID)
+		}
+		payment.Refunded = math.Round((payment.Refunded+amount)*100) / 100
+		payment.Status = types.PaymentPartiallyRefunded
+		if payment.Refunded == payment.Amount {
+			payment.Status = types.PaymentRefunded
+		}
+	default:
+		return nil, fmt.Errorf("[FakeGateway] cannot refund payment %s in status %s", paymentID, payment.Status)
+	}
+
+	return copyResult(payment), nil
+}
+
+func (g *FakeGateway) Status(paymentID string) (*types.PaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[paymentID]
+	if !ok {
+		return nil, types.ErrPaymentNotFound
+	}
+
+	return copyResult(payment), nil
+}
+
+func (g *FakeGateway) RefundPix(endToEndID string, amount float64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if endToEndID == "" || amount <= 0 {
+		return fmt.Errorf("[FakeGateway] invalid pix refund of %.2f for %q", amount, endToEndID)
+	}
+
+	g.pixRefunds[endToEndID] = math.Round((g.pixRefunds[endToEndID]+amount)*100) / 100
+	return nil
+}
+
+// PixRefunded returns how much of the PIX with endToEndID was returned.
+func (g *FakeGateway) PixRefunded(endToEndID string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.pixRefunds[endToEndID]
+}
+
+func copyResult(p *types.PaymentResult) *types.PaymentResult {
+	c := *p
+	return &c
+}
@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeGateway(t *testing.T) {
+	t.Run("Authorize, capture and refund", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		p, err := g.Authorize(&types.PaymentRequest{UserID: 1, Amount: 50.0, Method: types.PaymentCreditCard, Token: "tok_visa"})
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentAuthorized, p.Status)
+
+		p, err = g.Capture(p.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentCaptured, p.Status)
+
+		p, err = g.Refund(p.ID, 50.0)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+
+		p, err = g.Status(p.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+	})
+
+	t.Run("Refund before capture voids the authorization", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		p, _ := g.Authorize(&types.PaymentRequest{Amount: 10.0})
+		p, err := g.Refund(p.ID, 10.0)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentVoided, p.Status)
+
+		_, err = g.Capture(p.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("Declined token", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		p, err := g.Authorize(&types.PaymentRequest{Amount: 10.0, Token: DeclinedToken})
+		assert.ErrorIs(t, err, types.ErrPaymentDeclined)
+		assert.Nil(t, p)
+	})
+
+	t.Run("Refund more than captured", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		p, _ := g.Authorize(&types.PaymentRequest{Amount: 10.0})
+		g.Capture(p.ID)
+
+		_, err := g.Refund(p.ID, 10.01)
+		assert.Error(t, err)
+	})
+
+	t.Run("Partial refunds", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		p, _ := g.Authorize(&types.PaymentRequest{Amount: 30.0})
+		g.Capture(p.ID)
+
+		p, err := g.Refund(p.ID, 10.0)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentPartiallyRefunded, p.Status)
+		assert.Equal(t, 10.0, p.Refunded)
+
+		_, err = g.Refund(p.ID, 20.01)
+		assert.Error(t, err)
+
+		p, err = g.Refund(p.ID, 20.0)
+		assert.NoError(t, err)
+		assert.Equal(t, types.PaymentRefunded, p.Status)
+		assert.Equal(t, 30.0, p.Refunded)
+	})
+
+	t.Run("Unknown payment", func(t *testing.T) {
+		g := NewFakeGateway()
+
+		_, err := g.Status("pix-123")
+		assert.ErrorIs(t, err, types.ErrPaymentNotFound)
+	})
+}
@@ -0,0 +1,17 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// NewGateway returns the payment provider configured by name.
+func NewGateway(name string) (types.PaymentGateway, error) {
+	switch name {
+	case "fake":
+		return NewFakeGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", name)
+	}
+}
@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	txidAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	// the BR Code only has room for 25 characters in the txid field
+	txidLength = 25
+	qrCodeSize = 256
+)
+
+// PixIssuer creates PIX charges payable to the store's PIX key.
+type PixIssuer struct {
+	key          string
+	merchantName string
+	merchantCity string
+	ttl          time.Duration
+	now          func() time.Time
+}
+
+func NewPixIssuer(key string, merchantName string, merchantCity string, ttl time.Duration) *PixIssuer {
+	return &PixIssuer{
+		key:          key,
+		merchantName: merchantName,
+		merchantCity: merchantCity,
+		ttl:          ttl,
+		now:          time.Now,
+	}
+}
+
+func (p *PixIssuer) NewCharge(orderID int, amount float64) (*types.PixCharge, error) {
+	txid, err := newTxID()
+	if err != nil {
+		return nil, fmt.Errorf("[NewCharge] error generating txid: %v", err)
+	}
+
+	return &types.PixCharge{
+		OrderID:   orderID,
+		TxID:      txid,
+		BRCode:    BuildBRCode(p.key, p.merchantName, p.merchantCity, amount, txid),
+		Amount:    amount,
+		ExpiresAt: p.now().Add(p.ttl),
+	}, nil
+}
+
+func (p *PixIssuer) QRCode(brCode string) ([]byte, error) {
+	png, err := qrcode.Encode(brCode, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("[QRCode] error encoding qr code: %v", err)
+	}
+
+	return png, nil
+}
+
+// BuildBRCode returns the EMV "copia e cola" payload of a PIX charge as
+// specified in the Banco Central BR Code manual.
+func BuildBRCode(key string, merchantName string, merchantCity string, amount float64, txid string) string {
+	var b strings.Builder
+
+	b.WriteString(emvField("00", "01"))
+	// 12 marks the code as single use
+	b.WriteString(emvField("01", "12"))
+	b.WriteString(emvField("26", emvField("00", "br.gov.bcb.pix")+emvField("01", key)))
+	b.WriteString(emvField("52", "0000"))
+	b.WriteString(emvField("53", "986"))
+	b.WriteString(emvField("54", fmt.Sprintf("%.2f", amount)))
+	b.WriteString(emvField("58", "BR"))
+	b.WriteString(emvField("59", emvText(merchantName, 25)))
+	b.WriteString(emvField("60", emvText(merchantCity, 15)))
+	b.WriteString(emvField("62", emvField("05", txid)))
+
+	// the checksum covers its own id and length
+	b.WriteString("6304")
+	b.WriteString(fmt.Sprintf("%04X", crc16(b.String())))
+
+	return b.String()
+}
+
+func emvField(id string, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// emvText strips accents from s and keeps up to max printable ASCII
+// characters, since the BR Code does not allow accents in the merchant fields.
+func emvText(s string, max int) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if b.Len() == max {
+			break
+		}
+		if r >= ' ' && r <= '~' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// crc16 is CRC-16/CCITT-FALSE, the checksum used by the BR Code.
+func crc16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+func newTxID() (string, error) {
+	buf := make([]byte, txidLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	for i := range buf {
+		buf[i] = txidAlphabet[int(buf[i])%len(txidAlphabet)]
+	}
+
+	return string(buf), nil
+}
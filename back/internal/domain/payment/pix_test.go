@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	// example from the Banco Central BR Code manual
+	payload := "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***6304"
+
+	assert.Equal(t, "1D3D", fmt.Sprintf("%04X", crc16(payload)))
+}
+
+func TestBuildBRCode(t *testing.T) {
+	code := BuildBRCode("pix@ecommerce.local", "Loja São João do Comércio Online", "São Paulo", 1234.5, "ABC123")
+
+	assert.Equal(t, "000201"+
+		"010212"+
+		"26410014br.gov.bcb.pix0119pix@ecommerce.local"+
+		"52040000"+
+		"5303986"+
+		"54071234.50"+
+		"5802BR"+
+		"5925Loja Sao Joao do Comercio"+
+		"6009Sao Paulo"+
+		"62100506ABC123"+
+		"6304", code[:len(code)-4])
+
+	// the checksum must match the rest of the payload
+	assert.Equal(t, fmt.Sprintf("%04X", crc16(code[:len(code)-4])), code[len(code)-4:])
+}
+
+func TestPixIssuerNewCharge(t *testing.T) {
+	issuer := NewPixIssuer("pix@ecommerce.local", "ECOMMERCE", "SAO PAULO", 30*time.Minute)
+	now := time.Date(2025, 4, 14, 10, 0, 0, 0, time.UTC)
+	issuer.now = func() time.Time { return now }
+
+	charge, err := issuer.NewCharge(7, 99.9)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 7, charge.OrderID)
+	assert.Equal(t, 99.9, charge.Amount)
+	assert.Len(t, charge.TxID, txidLength)
+	assert.Regexp(t, "^[A-Za-z0-9]+$", charge.TxID)
+	assert.Contains(t, charge.BRCode, "0525"+charge.TxID)
+	assert.Equal(t, now.Add(30*time.Minute), charge.ExpiresAt)
+
+	other, err := issuer.NewCharge(8, 99.9)
+	assert.NoError(t, err)
+	assert.NotEqual(t, charge.TxID, other.TxID)
+
+	png, err := issuer.QRCode(charge.BRCode)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(png, []byte("\x89PNG")))
+}
@@ -0,0 +1,148 @@
+package payment
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body, keyed with
+// the secret shared with the PSP.
+const SignatureHeader = "X-Webhook-Signature"
+
+type Handler struct {
+	orderService  types.OrderService
+	pixStore      types.PixStore
+	gateway       types.PaymentGateway
+	webhookSecret []byte
+}
+
+func NewHandler(orderService types.OrderService, pixStore types.PixStore, gateway types.PaymentGateway, webhookSecret string) *Handler {
+	return &Handler{
+		orderService:  orderService,
+		pixStore:      pixStore,
+		gateway:       gateway,
+		webhookSecret: []byte(webhookSecret),
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	// called by the PSP, which authenticates with the signature instead of a JWT
+	router.HandleFunc("/payments/pix/webhook",
+		utils.Compose(
+			h.handlePixWebhook,
+			middleware.ErrorHandler,
+		)).Methods(http.MethodPost)
+
+	// the simulator only makes sense against the in-memory gateway
+	if _, ok := h.gateway.(*FakeGateway); !ok {
+		return
+	}
+
+	authRouter := router.PathPrefix("").Subrouter()
+	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
+
+	authRouter.HandleFunc("/payments/pix/simulate/{txid}",
+		utils.Compose(
+			h.handleSimulatePixPayment,
+			middleware.ErrorHandler,
+		)).Methods(http.MethodPost)
+}
+
+func (h *Handler) handlePixWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		panic(apperrors.NewValidationError("body", err.Error()))
+	}
+
+	if !h.validSignature(body, r.Header.Get(SignatureHeader)) {
+		panic(apperrors.NewUnauthorizedError("invalid webhook signature"))
+	}
+
+	var payload types.PixWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		panic(apperrors.NewValidationError("invalid payload", err.Error()))
+	}
+
+	for _, pix := range payload.Pix {
+		amount, err := strconv.ParseFloat(pix.Valor, 64)
+		if err != nil {
+			panic(apperrors.NewValidationError("valor", fmt.Sprintf("invalid amount %q", pix.Valor)))
+		}
+
+		if err := h.orderService.ConfirmPixPayment(pix.TxID, pix.EndToEndID, amount); err != nil {
+			panic(err)
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Webhook processed"})
+}
+
+// handleSimulatePixPayment plays the PSP for local development: it delivers the
+// signed webhook a real PSP would, and the payment is captured while the
+// webhook is handled.
+func (h *Handler) handleSimulatePixPayment(w http.ResponseWriter, r *http.Request) {
+	txid := mux.Vars(r)["txid"]
+
+	charge, err := h.pixStore.GetPixChargeByTxID(txid)
+	if err != nil {
+		panic(err)
+	}
+
+	order, err := h.orderService.GetOrderByID(charge.OrderID)
+	if err != nil {
+		panic(err)
+	}
+
+	if order.UserID != auth.GetUserIDFromContext(r.Context()) {
+		auth.Forbidden(w)
+		return
+	}
+
+	body, err := json.Marshal(types.PixWebhookPayload{
+		Pix: []types.PixWebhookPayment{{
+			EndToEndID: fmt.Sprintf("E%031d", time.Now().UnixNano()),
+			TxID:       charge.TxID,
+			Valor:      fmt.Sprintf("%.2f", charge.Amount),
+			Horario:    time.Now().UTC().Format(time.RFC3339),
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	webhook, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/payments/pix/webhook", bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	webhook.Header.Set(SignatureHeader, Sign(h.webhookSecret, body))
+
+	h.handlePixWebhook(w, webhook)
+}
+
+func (h *Handler) validSignature(body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(h.webhookSecret, body)), []byte(strings.ToLower(signature)))
+}
+
+// Sign returns the signature the webhook expects for body.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
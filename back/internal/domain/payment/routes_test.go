@@ -0,0 +1,224 @@
+package payment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testJWTSecret     = "secret"
+	testWebhookSecret = "webhook-secret"
+)
+
+type MockOrderService struct {
+	types.OrderService
+	mock.Mock
+}
+
+func (m *MockOrderService) GetOrderByID(orderID int) (*types.OrderHistory, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.OrderHistory), args.Error(1)
+}
+
+func (m *MockOrderService) ConfirmPixPayment(txid string, endToEndID string, amount float64) error {
+	args := m.Called(txid, endToEndID, amount)
+	return args.Error(0)
+}
+
+type MockPixStore struct {
+	types.PixStore
+	mock.Mock
+}
+
+func (m *MockPixStore) GetPixChargeByTxID(txid string) (*types.PixCharge, error) {
+	args := m.Called(txid)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.PixCharge), args.Error(1)
+}
+
+// MockGateway stands for any real provider
+type MockGateway struct {
+	types.PaymentGateway
+}
+
+type MockUserStore struct {
+	types.UserStore
+	mock.Mock
+}
+
+func (m *MockUserStore) GetUserByID(id int) (*types.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.User), args.Error(1)
+}
+
+func createTestToken(userID int) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"userId":   strconv.Itoa(userID),
+		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"userRole": string(types.RoleUser),
+	})
+
+	signedToken, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		panic(err)
+	}
+	return signedToken
+}
+
+func TestPixWebhook(t *testing.T) {
+	body := `{"pix":[{"endToEndId":"E1234","txid":"TX1","valor":"50.00","horario":"2025-04-14T10:00:00Z"}]}`
+
+	tests := []struct {
+		name           string
+		body           string
+		signature      string
+		mockSetup      func(*MockOrderService)
+		expectedStatus int
+	}{
+		{
+			name:      "Success - Signed notification confirms the payment",
+			body:      body,
+			signature: Sign([]byte(testWebhookSecret), []byte(body)),
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("ConfirmPixPayment", "TX1", "E1234", 50.0).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Error - Missing signature",
+			body:           body,
+			signature:      "",
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Error - Signed with another secret",
+			body:           body,
+			signature:      Sign([]byte("other"), []byte(body)),
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Error - Body changed after signing",
+			body:           strings.Replace(body, "50.00", "0.01", 1),
+			signature:      Sign([]byte(testWebhookSecret), []byte(body)),
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Error - Invalid amount",
+			body:           `{"pix":[{"endToEndId":"E1234","txid":"TX1","valor":"fifty"}]}`,
+			signature:      Sign([]byte(testWebhookSecret), []byte(`{"pix":[{"endToEndId":"E1234","txid":"TX1","valor":"fifty"}]}`)),
+			mockSetup:      func(mos *MockOrderService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "Error - Unknown charge",
+			body:      body,
+			signature: Sign([]byte(testWebhookSecret), []byte(body)),
+			mockSetup: func(mos *MockOrderService) {
+				mos.On("ConfirmPixPayment", "TX1", "E1234", 50.0).Return(apperrors.NewEntityNotFound("pix charge", "TX1"))
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockOrderService)
+			tt.mockSetup(mockService)
+
+			handler := NewHandler(mockService, new(MockPixStore), NewFakeGateway(), testWebhookSecret)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, new(MockUserStore))
+
+			req := httptest.NewRequest("POST", "/payments/pix/webhook", strings.NewReader(tt.body))
+			req.Header.Set(SignatureHeader, tt.signature)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSimulatePixPayment(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         int
+		expectedStatus int
+	}{
+		{name: "Success - Owner pays the charge", userID: 1, expectedStatus: http.StatusOK},
+		{name: "Error - Charge of another customer", userID: 2, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakeGateway()
+			auth, err := gateway.Authorize(&types.PaymentRequest{UserID: 1, Amount: 50.0, Method: types.PaymentPix})
+			assert.NoError(t, err)
+
+			mockService := new(MockOrderService)
+			mockPixStore := new(MockPixStore)
+			mockUserStore := new(MockUserStore)
+			mockUserStore.On("GetUserByID", tt.userID).Return(&types.User{ID: tt.userID, Role: types.RoleUser}, nil)
+			mockPixStore.On("GetPixChargeByTxID", "TX1").Return(&types.PixCharge{OrderID: 7, TxID: "TX1", Amount: 50.0}, nil)
+			mockService.On("GetOrderByID", 7).Return(&types.OrderHistory{ID: 7, UserID: 1, PaymentID: auth.ID}, nil)
+			if tt.expectedStatus == http.StatusOK {
+				mockService.On("ConfirmPixPayment", "TX1", mock.Anything, 50.0).Return(nil)
+			}
+
+			handler := NewHandler(mockService, mockPixStore, gateway, testWebhookSecret)
+			router := mux.NewRouter()
+			handler.RegisterRoutes(router, mockUserStore)
+
+			req := httptest.NewRequest("POST", "/payments/pix/simulate/TX1", nil)
+			req.Header.Set("Authorization", "Bearer "+createTestToken(tt.userID))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+
+			// capturing is left to ConfirmPixPayment, as for a real PSP
+			p, err := gateway.Status(auth.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, types.PaymentAuthorized, p.Status)
+		})
+	}
+}
+
+func TestSimulatorOnlyWithFakeGateway(t *testing.T) {
+	handler := NewHandler(new(MockOrderService), new(MockPixStore), &MockGateway{}, testWebhookSecret)
+	router := mux.NewRouter()
+	handler.RegisterRoutes(router, new(MockUserStore))
+
+	req := httptest.NewRequest("POST", "/payments/pix/simulate/TX1", nil)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
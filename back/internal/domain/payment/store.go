@@ -0,0 +1,160 @@
+package payment
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) CreatePixCharge(charge *types.PixCharge) error {
+	res, err := s.db.Exec(`
+		INSERT INTO pix_charges (orderId, txid, brCode, amount, expiresAt)
+		VALUES (?, ?, ?, ?, ?)
+	`, charge.OrderID, charge.TxID, charge.BRCode, charge.Amount, charge.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("[CreatePixCharge] error creating pix charge for order %d: %v", charge.OrderID, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("[CreatePixCharge] error getting pix charge ID: %v", err)
+	}
+	charge.ID = int(id)
+
+	return nil
+}
+
+func (s *Store) GetPixChargeByOrderID(orderID int) (*types.PixCharge, error) {
+	row := s.db.QueryRow(`
+		SELECT id, orderId, txid, brCode, amount, expiresAt, paidAt, COALESCE(endToEndId, ''), createdAt
+		FROM pix_charges
+		WHERE orderId = ?
+	`, orderID)
+
+	charge, err := scanRowIntoPixCharge(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.NewEntityNotFound("pix charge", orderID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GetPixChargeByOrderID] error getting pix charge of order %d: %v", orderID, err)
+	}
+
+	return charge, nil
+}
+
+func (s *Store) GetPixChargeByTxID(txid string) (*types.PixCharge, error) {
+	row := s.db.QueryRow(`
+		SELECT id, orderId, txid, brCode, amount, expiresAt, paidAt, COALESCE(endToEndId, ''), createdAt
+		FROM pix_charges
+		WHERE txid = ?
+	`, txid)
+
+	charge, err := scanRowIntoPixCharge(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperrors.NewEntityNotFound("pix charge", txid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GetPixChargeByTxID] error getting pix charge %s: %v", txid, err)
+	}
+
+	return charge, nil
+}
+
+func (s *Store) MarkPixChargePaid(txid string, endToEndID string) error {
+	res, err := s.db.Exec(`
+		UPDATE pix_charges
+		SET paidAt = NOW(), endToEndId = ?
+		WHERE txid = ? AND paidAt IS NULL
+	`, endToEndID, txid)
+	if err != nil {
+		return fmt.Errorf("[MarkPixChargePaid] error updating pix charge %s: %v", txid, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("[MarkPixChargePaid] error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.NewConflictError("txid", fmt.Sprintf("pix charge %s is already paid", txid))
+	}
+
+	return nil
+}
+
+func (s *Store) GetExpiredPixCharges(limit int) ([]*types.PixCharge, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.orderId, c.txid, c.brCode, c.amount, c.expiresAt, c.paidAt, COALESCE(c.endToEndId, ''), c.createdAt
+		FROM pix_charges c
+		JOIN order_history o ON o.id = c.orderId
+		WHERE c.paidAt IS NULL AND c.expiresAt < NOW() AND o.status = ?
+		ORDER BY c.expiresAt, c.id
+		LIMIT ?
+	`, types.OrderPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[GetExpiredPixCharges] error getting expired pix charges: %v", err)
+	}
+	defer rows.Close()
+
+	var charges []*types.PixCharge
+	for rows.Next() {
+		charge, err := scanRowIntoPixCharge(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[GetExpiredPixCharges] error scanning pix charge: %v", err)
+		}
+		charges = append(charges, charge)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetExpiredPixCharges] error iterating pix charges: %v", err)
+	}
+
+	return charges, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRowIntoPixCharge(row scanner) (*types.PixCharge, error) {
+	charge := new(types.PixCharge)
+	var paidAt sql.NullTime
+
+	err := row.Scan(
+		&charge.ID,
+		&charge.OrderID,
+		&charge.TxID,
+		&charge.BRCode,
+		&charge.Amount,
+		&charge.ExpiresAt,
+		&paidAt,
+		&charge.EndToEndID,
+		&charge.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if paidAt.Valid {
+		charge.PaidAt = &paidAt.Time
+	}
+
+	return charge, nil
+}
@@ -0,0 +1,171 @@
+// Package pricing works out what a product costs with its active discounts.
+// Product listings and the cart both price through Price, so a customer sees
+// the same price wherever they look.
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+// Price resolves the best price of quantity units of a product sold at
+// basePrice. Every exclusive discount is tried alone, and the stackable ones
+// are tried together; the cheapest of these wins, and on a tie the one found
+// first, in order of priority.
+//
+// Within a stack, unit discounts apply in order of priority, each on the
+// price the one before left. Buy X get Y offers apply after them, on the
+// discounted unit price.
+func Price(basePrice float64, quantity int, discounts []*types.ProductDiscount) *types.PriceBreakdown {
+	var exclusive, stackable []*types.ProductDiscount
+	for _, discount := range byPriority(discounts) {
+		if discount.Stackable {
+			stackable = append(stackable, discount)
+		} else {
+			exclusive = append(exclusive, discount)
+		}
+	}
+
+	best := apply(basePrice, quantity, nil)
+	for _, discount := range exclusive {
+		if candidate := apply(basePrice, quantity, []*types.ProductDiscount{discount}); candidate.Total < best.Total {
+			best = candidate
+		}
+	}
+
+	if len(stackable) > 0 {
+		if candidate := apply(basePrice, quantity, stackable); candidate.Total < best.Total {
+			best = candidate
+		}
+	}
+
+	return best
+}
+
+// UnitPrice is the price of a single unit of a product.
+func UnitPrice(basePrice float64, discounts []*types.ProductDiscount) float64 {
+	return Price(basePrice, 1, discounts).UnitPrice
+}
+
+// DiscountPercentage is how much below basePrice price is, as a percentage.
+func DiscountPercentage(basePrice float64, price float64) float64 {
+	if basePrice <= 0 || price >= basePrice {
+		return 0
+	}
+	return utils.RoundCents((basePrice - price) / basePrice * 100)
+}
+
+func apply(basePrice float64, quantity int, discounts []*types.ProductDiscount) *types.PriceBreakdown {
+	breakdown := &types.PriceBreakdown{
+		BasePrice: basePrice,
+		Quantity:  quantity,
+		Applied:   []*types.AppliedDiscount{},
+	}
+
+	unit := basePrice
+	for _, discount := range discounts {
+		var discounted float64
+		switch discount.Type {
+		case types.DiscountBuyXGetY:
+			continue
+		case types.DiscountFixedPrice:
+			if discount.FixedPrice == nil {
+				continue
+			}
+			discounted = math.Min(unit, *discount.FixedPrice)
+		default:
+			discounted = unit * (1 - discount.DiscountPercent/100)
+		}
+
+		if discounted < unit {
+			breakdown.Applied = append(breakdown.Applied, applied(discount, (unit-discounted)*float64(quantity)))
+			unit = discounted
+		}
+	}
+
+	breakdown.UnitPrice = utils.RoundCents(unit)
+	total := breakdown.UnitPrice * float64(quantity)
+
+	for _, discount := range discounts {
+		if discount.Type != types.DiscountBuyXGetY || discount.BuyQuantity == nil || discount.GetQuantity == nil {
+			continue
+		}
+
+		buy, get := *discount.BuyQuantity, *discount.GetQuantity
+		if buy+get <= 0 {
+			continue
+		}
+
+		discountedUnits := quantity / (buy + get) * get
+		savings := float64(discountedUnits) * breakdown.UnitPrice * discount.DiscountPercent / 100
+		if savings > 0 {
+			breakdown.Applied = append(breakdown.Applied, applied(discount, savings))
+			total -= savings
+		}
+	}
+
+	breakdown.Total = utils.RoundCents(math.Max(total, 0))
+	breakdown.Savings = utils.RoundCents(basePrice*float64(quantity) - breakdown.Total)
+	return breakdown
+}
+
+func applied(discount *types.ProductDiscount, savings float64) *types.AppliedDiscount {
+	discountType := discount.Type
+	if discountType == "" {
+		discountType = types.DiscountPercentage
+	}
+
+	return &types.AppliedDiscount{
+		DiscountID:  discount.ID,
+		CampaignID:  discount.CampaignID,
+		Campaign:    discount.CampaignName,
+		Type:        discountType,
+		Description: describe(discount),
+		Savings:     utils.RoundCents(savings),
+	}
+}
+
+func describe(discount *types.ProductDiscount) string {
+	switch discount.Type {
+	case types.DiscountFixedPrice:
+		return fmt.Sprintf("now %.2f", *discount.FixedPrice)
+	case types.DiscountBuyXGetY:
+		if discount.DiscountPercent >= 100 {
+			return fmt.Sprintf("buy %d get %d free", *discount.BuyQuantity, *discount.GetQuantity)
+		}
+		return fmt.Sprintf("buy %d get %d at %s%% off", *discount.BuyQuantity, *discount.GetQuantity, percent(discount.DiscountPercent))
+	default:
+		return fmt.Sprintf("%s%% off", percent(discount.DiscountPercent))
+	}
+}
+
+// percent drops the decimals of whole percentages
+func percent(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// byPriority sorts a copy of discounts from the highest priority down, the
+// oldest first among equals, and product discounts before campaigns.
+func byPriority(discounts []*types.ProductDiscount) []*types.ProductDiscount {
+	sorted := append([]*types.ProductDiscount{}, discounts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		if (sorted[i].CampaignID == nil) != (sorted[j].CampaignID == nil) {
+			return sorted[i].CampaignID == nil
+		}
+		if sorted[i].CampaignID != nil {
+			return *sorted[i].CampaignID < *sorted[j].CampaignID
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
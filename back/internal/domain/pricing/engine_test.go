@@ -0,0 +1,192 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		basePrice float64
+		quantity  int
+		discounts []*types.ProductDiscount
+		expected  *types.PriceBreakdown
+	}{
+		{
+			name:      "No discounts",
+			basePrice: 10.0,
+			quantity:  2,
+			expected:  &types.PriceBreakdown{BasePrice: 10.0, Quantity: 2, UnitPrice: 10.0, Total: 20.0, Applied: []*types.AppliedDiscount{}},
+		},
+		{
+			name:      "Best exclusive discount wins",
+			basePrice: 100.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 10},
+				{ID: 2, Type: types.DiscountPercentage, DiscountPercent: 25},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 100.0, Quantity: 1, UnitPrice: 75.0, Total: 75.0, Savings: 25.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 2, Type: types.DiscountPercentage, Description: "25% off", Savings: 25.0}},
+			},
+		},
+		{
+			name:      "Stack beats an exclusive discount",
+			basePrice: 100.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 25},
+				{ID: 3, DiscountPercent: 10, Stackable: true},
+				{ID: 2, DiscountPercent: 20, Stackable: true, Priority: 1},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 100.0, Quantity: 1, UnitPrice: 72.0, Total: 72.0, Savings: 28.0,
+				Applied: []*types.AppliedDiscount{
+					{DiscountID: 2, Type: types.DiscountPercentage, Description: "20% off", Savings: 20.0},
+					{DiscountID: 3, Type: types.DiscountPercentage, Description: "10% off", Savings: 8.0},
+				},
+			},
+		},
+		{
+			name:      "Exclusive discount beats a stack",
+			basePrice: 100.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 30},
+				{ID: 2, DiscountPercent: 20, Stackable: true},
+				{ID: 3, DiscountPercent: 10, Stackable: true},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 100.0, Quantity: 1, UnitPrice: 70.0, Total: 70.0, Savings: 30.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 1, Type: types.DiscountPercentage, Description: "30% off", Savings: 30.0}},
+			},
+		},
+		{
+			name:      "Priority breaks a tie",
+			basePrice: 50.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 20},
+				{ID: 2, DiscountPercent: 20, Priority: 5},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 50.0, Quantity: 1, UnitPrice: 40.0, Total: 40.0, Savings: 10.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 2, Type: types.DiscountPercentage, Description: "20% off", Savings: 10.0}},
+			},
+		},
+		{
+			name:      "Campaign beats a product discount",
+			basePrice: 100.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 10},
+				{CampaignID: intPtr(4), CampaignName: "Electronics week", DiscountPercent: 20},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 100.0, Quantity: 1, UnitPrice: 80.0, Total: 80.0, Savings: 20.0,
+				Applied: []*types.AppliedDiscount{{
+					CampaignID: intPtr(4), Campaign: "Electronics week", Type: types.DiscountPercentage, Description: "20% off", Savings: 20.0,
+				}},
+			},
+		},
+		{
+			name:      "Product discount wins a tie with a campaign",
+			basePrice: 100.0,
+			quantity:  1,
+			discounts: []*types.ProductDiscount{
+				{CampaignID: intPtr(4), CampaignName: "Electronics week", DiscountPercent: 20},
+				{ID: 7, DiscountPercent: 20},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 100.0, Quantity: 1, UnitPrice: 80.0, Total: 80.0, Savings: 20.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 7, Type: types.DiscountPercentage, Description: "20% off", Savings: 20.0}},
+			},
+		},
+		{
+			name:      "Fixed price",
+			basePrice: 50.0,
+			quantity:  2,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, DiscountPercent: 10},
+				{ID: 2, Type: types.DiscountFixedPrice, FixedPrice: floatPtr(39.9)},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 50.0, Quantity: 2, UnitPrice: 39.9, Total: 79.8, Savings: 20.2,
+				Applied: []*types.AppliedDiscount{{DiscountID: 2, Type: types.DiscountFixedPrice, Description: "now 39.90", Savings: 20.2}},
+			},
+		},
+		{
+			name:      "Buy X get Y free",
+			basePrice: 10.0,
+			quantity:  7,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, Type: types.DiscountBuyXGetY, DiscountPercent: 100, BuyQuantity: intPtr(2), GetQuantity: intPtr(1)},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 10.0, Quantity: 7, UnitPrice: 10.0, Total: 50.0, Savings: 20.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 1, Type: types.DiscountBuyXGetY, Description: "buy 2 get 1 free", Savings: 20.0}},
+			},
+		},
+		{
+			name:      "Buy X get Y at a discount",
+			basePrice: 10.0,
+			quantity:  4,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, Type: types.DiscountBuyXGetY, DiscountPercent: 50, BuyQuantity: intPtr(1), GetQuantity: intPtr(1)},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 10.0, Quantity: 4, UnitPrice: 10.0, Total: 30.0, Savings: 10.0,
+				Applied: []*types.AppliedDiscount{{DiscountID: 1, Type: types.DiscountBuyXGetY, Description: "buy 1 get 1 at 50% off", Savings: 10.0}},
+			},
+		},
+		{
+			name:      "Buy X get Y short of the quantity",
+			basePrice: 10.0,
+			quantity:  2,
+			discounts: []*types.ProductDiscount{
+				{ID: 1, Type: types.DiscountBuyXGetY, DiscountPercent: 100, BuyQuantity: intPtr(2), GetQuantity: intPtr(1)},
+			},
+			expected: &types.PriceBreakdown{BasePrice: 10.0, Quantity: 2, UnitPrice: 10.0, Total: 20.0, Applied: []*types.AppliedDiscount{}},
+		},
+		{
+			name:      "Buy X get Y stacked on a percentage",
+			basePrice: 10.0,
+			quantity:  3,
+			discounts: []*types.ProductDiscount{
+				{ID: 2, Type: types.DiscountBuyXGetY, DiscountPercent: 100, BuyQuantity: intPtr(2), GetQuantity: intPtr(1), Stackable: true},
+				{ID: 1, DiscountPercent: 10, Stackable: true},
+			},
+			expected: &types.PriceBreakdown{
+				BasePrice: 10.0, Quantity: 3, UnitPrice: 9.0, Total: 18.0, Savings: 12.0,
+				Applied: []*types.AppliedDiscount{
+					{DiscountID: 1, Type: types.DiscountPercentage, Description: "10% off", Savings: 3.0},
+					{DiscountID: 2, Type: types.DiscountBuyXGetY, Description: "buy 2 get 1 free", Savings: 9.0},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Price(tt.basePrice, tt.quantity, tt.discounts))
+		})
+	}
+}
+
+func TestDiscountPercentage(t *testing.T) {
+	assert.Equal(t, 25.0, DiscountPercentage(40.0, 30.0))
+	assert.Equal(t, 0.0, DiscountPercentage(40.0, 40.0))
+	assert.Equal(t, 0.0, DiscountPercentage(0, 0))
+}
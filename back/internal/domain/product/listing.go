@@ -0,0 +1,131 @@
+package product
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/pricing"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// priceBatch is how many products RefreshPrices prices at a time.
+const priceBatch = 500
+
+// ListProducts returns the page of the listing asked for along with its
+// facets. The store filters and sorts on the prices RefreshPrices stored, and
+// the products of the page are then priced afresh.
+func (p *ProductService) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) *types.ProductListing {
+	if _, err := p.userStore.GetUserByID(userID); err != nil {
+		panic(apperrors.NewEntityNotFound("user", userID))
+	}
+
+	if err := validateFilter(filter); err != nil {
+		panic(err)
+	}
+
+	facets, err := p.productStore.GetProductFacets(filter)
+	if err != nil {
+		panic(fmt.Errorf("failed to get facets: %w", err))
+	}
+
+	if filter.CategoryID != nil && !hasCategory(facets, *filter.CategoryID) {
+		panic(apperrors.NewEntityNotFound("category", *filter.CategoryID))
+	}
+
+	products, err := p.productStore.ListProducts(userID, filter, page)
+	if err != nil {
+		panic(fmt.Errorf("failed to list products: %w", err))
+	}
+	p.priceSimpleProducts(products.Items)
+
+	return &types.ProductListing{Page: products, Facets: facets}
+}
+
+// RefreshPrices prices every product with its active discounts, a batch at a
+// time, and stores the prices for the listing.
+func (p *ProductService) RefreshPrices() (int, error) {
+	priced := 0
+	for afterID := 0; ; {
+		prices, err := p.productStore.GetBasePrices(afterID, priceBatch)
+		if err != nil {
+			return priced, err
+		}
+		if len(prices) == 0 {
+			return priced, nil
+		}
+
+		if err := p.savePrices(prices); err != nil {
+			return priced, err
+		}
+
+		priced += len(prices)
+		afterID = prices[len(prices)-1].ProductID
+	}
+}
+
+// RefreshProductPrices prices the products of productIDs a batch at a time.
+// The ones that no longer exist are left out.
+func (p *ProductService) RefreshProductPrices(productIDs []int) error {
+	for start := 0; start < len(productIDs); start += priceBatch {
+		prices, err := p.productStore.GetBasePricesForProducts(productIDs[start:min(start+priceBatch, len(productIDs))])
+		if err != nil {
+			return err
+		}
+
+		if err := p.savePrices(prices); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savePrices prices base prices with their active discounts and stores them.
+func (p *ProductService) savePrices(prices []*types.ProductPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int, 0, len(prices))
+	for _, price := range prices {
+		productIDs = append(productIDs, price.ProductID)
+	}
+
+	discounts, err := p.discountStore.GetActiveDiscountsForProducts(productIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get discounts: %w", err)
+	}
+
+	for _, price := range prices {
+		price.Price = pricing.UnitPrice(price.BasePrice, discounts[price.ProductID])
+		price.OnSale = len(discounts[price.ProductID]) > 0
+	}
+
+	return p.productStore.SavePrices(prices)
+}
+
+func validateFilter(filter types.ProductFilter) error {
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return apperrors.NewValidationError("minPrice", "minPrice can't be above maxPrice")
+	}
+
+	if filter.MinRating != nil && (*filter.MinRating < 0 || *filter.MinRating > 5) {
+		return apperrors.NewValidationError("minRating", "minRating must be between 0 and 5")
+	}
+
+	switch filter.Sort {
+	case "", types.SortPriceAsc, types.SortPriceDesc, types.SortRating, types.SortNewest, types.SortBestSelling:
+	default:
+		return apperrors.NewValidationError("sort", fmt.Sprintf("unknown sort %q", filter.Sort))
+	}
+
+	return nil
+}
+
+func hasCategory(facets *types.ProductFacets, categoryID int) bool {
+	for _, category := range facets.Categories {
+		if category.ID == categoryID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// PriceRefresher periodically stores the price of every product for the
+// listing, so discounts and campaigns that start or end show up in its
+// filters and order within an interval.
+type PriceRefresher struct {
+	service  types.ProductService
+	interval time.Duration
+}
+
+func NewPriceRefresher(service types.ProductService, interval time.Duration) *PriceRefresher {
+	return &PriceRefresher{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run refreshes the prices at once, then every interval until ctx is done.
+func (r *PriceRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.Refresh()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *PriceRefresher) Refresh() {
+	priced, err := r.service.RefreshPrices()
+	if err != nil {
+		fmt.Printf("[PRICE REFRESHER] Error refreshing prices after %d products: %v\n", priced, err)
+	}
+}
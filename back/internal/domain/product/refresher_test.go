@@ -0,0 +1,43 @@
+package product
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPriceRefresherRunsUntilCancelled(t *testing.T) {
+	refreshes := make(chan struct{}, 10)
+	service := new(MockProductServiceForRoutes)
+	service.On("RefreshPrices").Return(3, nil).Run(func(_ mock.Arguments) {
+		select {
+		case refreshes <- struct{}{}:
+		default:
+		}
+	})
+	refresher := NewPriceRefresher(service, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		refresher.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-refreshes:
+		case <-time.After(time.Second):
+			t.Fatal("refresher did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresher did not stop")
+	}
+}
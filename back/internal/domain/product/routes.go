@@ -2,6 +2,8 @@ package product
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
@@ -35,6 +37,14 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	authRouter := router.PathPrefix("").Subrouter()
 	authRouter.Use(auth.WithJwtAuthMiddleware(h.userStore))
 
+	// registered ahead of /product/{productID}, which would take "search"
+	// for an id
+	authRouter.HandleFunc("/product/search",
+		utils.Compose(
+			h.handleSearchProducts,
+			middleware.ErrorHandler,
+		)).Methods(http.MethodGet)
+
 	authRouter.HandleFunc("/product/{productID}",
 		utils.Compose(
 			h.handleGetProductById,
@@ -90,7 +100,74 @@ func (h *Handler) handleGetProductDetails(w http.ResponseWriter, r *http.Request
 func (h *Handler) handleGetAllSimpleProducts(w http.ResponseWriter, r *http.Request) {
 	userID := auth.GetUserIDFromContext(r.Context())
 
-	products := h.productService.GetSimpleProducts(userID)
+	listing := h.productService.ListProducts(userID, parseProductFilter(r), parsePageRequest(r))
+
+	utils.WriteJson(w, http.StatusOK, listing)
+}
+
+// parseProductFilter reads the listing filters from the query string:
+// minPrice, maxPrice, categoryId, minRating, inStock, onSale and sort.
+func parseProductFilter(r *http.Request) types.ProductFilter {
+	query := r.URL.Query()
+
+	filter := types.ProductFilter{
+		MinPrice:  parseFloatParam(query, "minPrice"),
+		MaxPrice:  parseFloatParam(query, "maxPrice"),
+		MinRating: parseFloatParam(query, "minRating"),
+		InStock:   parseBoolParam(query, "inStock"),
+		OnSale:    parseBoolParam(query, "onSale"),
+		Sort:      types.ProductSort(query.Get("sort")),
+	}
+
+	if value := query.Get("categoryId"); value != "" {
+		categoryID, err := strconv.Atoi(value)
+		if err != nil {
+			panic(apperrors.NewValidationError("categoryId", "categoryId must be a number"))
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	return filter
+}
+
+func parsePageRequest(r *http.Request) types.PageRequest {
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	return page
+}
+
+func parseFloatParam(query url.Values, name string) *float64 {
+	value := query.Get(name)
+	if value == "" {
+		return nil
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		panic(apperrors.NewValidationError(name, name+" must be a positive number"))
+	}
+	return &number
+}
+
+func parseBoolParam(query url.Values, name string) bool {
+	value := query.Get(name)
+	if value == "" {
+		return false
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		panic(apperrors.NewValidationError(name, name+" must be true or false"))
+	}
+	return flag
+}
+
+func (h *Handler) handleSearchProducts(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	products := h.productService.SearchProducts(userID, r.URL.Query().Get("q"))
 
 	utils.WriteJson(w, http.StatusOK, products)
 }
@@ -144,7 +221,7 @@ func (h *Handler) handleGetProductById(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
-	products := h.productService.GetProducts()
+	products := h.productService.GetProducts(parsePageRequest(r))
 
 	utils.WriteJson(w, http.StatusOK, products)
 }
@@ -152,7 +229,7 @@ func (h *Handler) handleGetProducts(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleGetProductsByCategoryID(w http.ResponseWriter, r *http.Request) {
 	categoryID := utils.GetParamIdfromPath(r, "categoryID")
 
-	products := h.productService.GetProductsByCategoryID(categoryID)
+	products := h.productService.GetProductsByCategoryID(categoryID, parseBoolParam(r.URL.Query(), "includeSubcategories"))
 
 	utils.WriteJson(w, http.StatusOK, products)
 }
@@ -3,14 +3,18 @@ package product
 import (
 	"bytes"
 	"context"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database/dbtest"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
 	types "github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
@@ -30,12 +34,12 @@ type MockProductStoreForRoutes struct {
 	mock.Mock
 }
 
-func (m *MockProductStoreForRoutes) GetProducts() ([]*types.Product, error) {
-	args := m.Called()
+func (m *MockProductStoreForRoutes) GetProducts(page types.PageRequest) (*types.Page[*types.Product], error) {
+	args := m.Called(page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.Product), args.Error(1)
+	return args.Get(0).(*types.Page[*types.Product]), args.Error(1)
 }
 
 func (m *MockProductStoreForRoutes) CreateProduct(payload types.CreateProductPayload) error {
@@ -80,6 +84,14 @@ func (m *MockProductStoreForRoutes) GetImagesForProducts(productIDs []int) (map[
 	return args.Get(0).(map[int][]types.ProductImage), args.Error(1)
 }
 
+func (m *MockProductStoreForRoutes) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.ProductDimensions), args.Error(1)
+}
+
 func (m *MockProductStoreForRoutes) UpdateProduct(productID int, payload types.UpdateProductPayload) error {
 	args := m.Called(productID, payload)
 	return args.Error(0)
@@ -90,8 +102,8 @@ func (m *MockProductStoreForRoutes) DeleteProduct(productID int) error {
 	return args.Error(0)
 }
 
-func (m *MockProductStoreForRoutes) GetProductsByCategory(categoryID int) ([]*types.Product, error) {
-	args := m.Called(categoryID)
+func (m *MockProductStoreForRoutes) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	args := m.Called(categoryID, includeSubcategories)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -106,14 +118,59 @@ func (m *MockProductStoreForRoutes) GetProductDetails(userID int, productID int)
 	return args.Get(0).(*types.ProductDetails), args.Error(1)
 }
 
-func (m *MockProductStoreForRoutes) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObject, error) {
-	args := m.Called(userID)
+func (m *MockProductStoreForRoutes) GetSimpleProductDetails(userID int, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStoreForRoutes) SearchProducts(userID int, text string, limit int) (*[]*types.SimpleProductObject, error) {
+	args := m.Called(userID, text, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.SimpleProductObject), args.Error(1)
 }
 
+func (m *MockProductStoreForRoutes) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStoreForRoutes) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductFacets), args.Error(1)
+}
+
+func (m *MockProductStoreForRoutes) GetBasePrices(afterID int, limit int) ([]*types.ProductPrice, error) {
+	args := m.Called(afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStoreForRoutes) GetBasePricesForProducts(productIDs []int) ([]*types.ProductPrice, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStoreForRoutes) SavePrices(prices []*types.ProductPrice) error {
+	args := m.Called(prices)
+	return args.Error(0)
+}
+
 // Mock for UserStore
 type MockUserStoreForRoutes struct {
 	mock.Mock
@@ -161,16 +218,16 @@ func (m *MockProductServiceForRoutes) GetProductByID(productID int) *types.Produ
 	return args.Get(0).(*types.Product)
 }
 
-func (m *MockProductServiceForRoutes) GetProducts() []*types.Product {
-	args := m.Called()
+func (m *MockProductServiceForRoutes) GetProducts(page types.PageRequest) *types.Page[*types.Product] {
+	args := m.Called(page)
 	if args.Get(0) == nil {
 		return nil
 	}
-	return args.Get(0).([]*types.Product)
+	return args.Get(0).(*types.Page[*types.Product])
 }
 
-func (m *MockProductServiceForRoutes) GetProductsByCategoryID(categoryID int) []*types.Product {
-	args := m.Called(categoryID)
+func (m *MockProductServiceForRoutes) GetProductsByCategoryID(categoryID int, includeSubcategories bool) []*types.Product {
+	args := m.Called(categoryID, includeSubcategories)
 	if args.Get(0) == nil {
 		return nil
 	}
@@ -185,14 +242,40 @@ func (m *MockProductServiceForRoutes) GetProductDetails(userID int, productID in
 	return args.Get(0).(*types.ProductDetails)
 }
 
-func (m *MockProductServiceForRoutes) GetSimpleProducts(userID int) *[]*types.SimpleProductObject {
-	args := m.Called(userID)
+func (m *MockProductServiceForRoutes) GetSimpleProducts(userID int, page types.PageRequest) *types.Page[*types.SimpleProductObject] {
+	args := m.Called(userID, page)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject])
+}
+
+func (m *MockProductServiceForRoutes) SearchProducts(userID int, text string) *[]*types.SimpleProductObject {
+	args := m.Called(userID, text)
 	if args.Get(0) == nil {
 		return nil
 	}
 	return args.Get(0).(*[]*types.SimpleProductObject)
 }
 
+func (m *MockProductServiceForRoutes) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) *types.ProductListing {
+	args := m.Called(userID, filter, page)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).(*types.ProductListing)
+}
+
+func (m *MockProductServiceForRoutes) RefreshPrices() (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockProductServiceForRoutes) RefreshProductPrices(productIDs []int) error {
+	args := m.Called(productIDs)
+	return args.Error(0)
+}
+
 func (m *MockProductServiceForRoutes) CreateProductWithImages(payload types.CreateProductWithImagesPayload) *types.Product {
 	args := m.Called(payload)
 	if args.Get(0) == nil {
@@ -450,3 +533,153 @@ func TestHandleCreateProductWithImages(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 }
+
+func TestHandleGetAllSimpleProducts(t *testing.T) {
+	mockProductService := new(MockProductServiceForRoutes)
+	handler := NewHandler(new(MockProductStoreForRoutes), new(MockUserStoreForRoutes), mockProductService)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/product/all/details",
+		utils.Compose(handler.handleGetAllSimpleProducts, middleware.ErrorHandler)).Methods(http.MethodGet)
+
+	t.Run("Reads the filters from the query", func(t *testing.T) {
+		minPrice, categoryID := 10.0, 3
+		mockProductService.On("ListProducts", 123, types.ProductFilter{
+			MinPrice:   &minPrice,
+			CategoryID: &categoryID,
+			OnSale:     true,
+			Sort:       types.SortPriceDesc,
+		}, types.PageRequest{Limit: 5}).Return(&types.ProductListing{
+			Page:   &types.Page[*types.SimpleProductObject]{Items: []*types.SimpleProductObject{}},
+			Facets: &types.ProductFacets{},
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/product/all/details?minPrice=10&categoryId=3&onSale=true&sort=price_desc&limit=5", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userKey, 123))
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockProductService.AssertExpectations(t)
+	})
+
+	t.Run("Malformed filter", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/product/all/details?inStock=maybe", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userKey, 123))
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestProductListsQueryBudget(t *testing.T) {
+	// the queries must not grow with the number of products listed
+	const products = 50
+	const budget = 3
+
+	db := dbtest.New(t)
+	var productRows, imageRows, categoryRows [][]driver.Value
+	for id := int64(1); id <= products; id++ {
+		productRows = append(productRows, []driver.Value{
+			id, "Product", "Description", 10.0, time.Now(), time.Now(), int64(5), int64(1), int64(5), nil,
+		})
+		imageRows = append(imageRows, []driver.Value{id, id, "image.png", int64(0)})
+		categoryRows = append(categoryRows, []driver.Value{id, int64(1), "Category", "category.png", nil})
+	}
+	db.On("FROM products p", productRows...)
+	db.On("FROM product_images", imageRows...)
+	db.On("FROM product_categories", categoryRows...)
+
+	store := NewStore(db.DB)
+	service := NewProductService(store, new(MockUserStore), new(MockDiscountStore), new(MockRatingStore), noVariants())
+	handler := NewHandler(store, new(MockUserStoreForRoutes), service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/product",
+		utils.Compose(handler.handleGetProducts, middleware.ErrorHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/product/category/{categoryID}",
+		utils.Compose(handler.handleGetProductsByCategoryID, middleware.ErrorHandler)).Methods(http.MethodGet)
+
+	for _, url := range []string{"/product?limit=" + strconv.Itoa(products), "/product/category/1"} {
+		t.Run(url, func(t *testing.T) {
+			db.Reset()
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), `"categories":[{"id":1`)
+			db.AssertQueryBudget(t, budget)
+		})
+	}
+}
+
+func TestProductListingQueries(t *testing.T) {
+	// the listing is filtered, counted and paged in SQL rather than over
+	// the whole catalog
+	const products = 50
+	const budget = 4
+
+	db := dbtest.New(t)
+	var listRows [][]driver.Value
+	for id := int64(1); id <= products; id++ {
+		listRows = append(listRows, []driver.Value{
+			id, "Product", 10.0, 10.0, false, 4.0, false, "image.png", time.Now(), int64(1),
+		})
+	}
+	db.On("category_ancestors", []driver.Value{int64(1), int64(products)})
+	db.On("SELECT l.price, l.rating", []driver.Value{
+		10.0, 10.0, int64(products), int64(0), int64(0), int64(0), int64(0),
+		int64(products), int64(products), int64(products), int64(products), int64(products), int64(0),
+	})
+	db.On("FROM categories ORDER BY id", []driver.Value{int64(1), "Category", "category.png", nil})
+	db.On("AS main_image", listRows...)
+
+	userStore := new(MockUserStore)
+	userStore.On("GetUserByID", 123).Return(&types.User{ID: 123}, nil)
+	discountStore := new(MockDiscountStore)
+	discountStore.On("GetActiveDiscountsForProducts", mock.Anything).Return(map[int][]*types.ProductDiscount{}, nil)
+
+	store := NewStore(db.DB)
+	service := NewProductService(store, userStore, discountStore, new(MockRatingStore), noVariants())
+	handler := NewHandler(store, new(MockUserStoreForRoutes), service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/product/all/details",
+		utils.Compose(handler.handleGetAllSimpleProducts, middleware.ErrorHandler)).Methods(http.MethodGet)
+
+	list := func(url string) map[string]any {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req = req.WithContext(context.WithValue(req.Context(), userKey, 123))
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var body map[string]any
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return body
+	}
+
+	db.Reset()
+	first := list("/product/all/details?sort=price_asc&minRating=3&limit=20")
+	db.AssertQueryBudget(t, budget)
+	assert.Len(t, first["items"], 20)
+	assert.Contains(t, fmt.Sprint(first["facets"]), "count:50")
+
+	db.Reset()
+	list("/product/all/details?sort=price_asc&minRating=3&limit=20&cursor=" + first["nextCursor"].(string))
+	var listing string
+	for _, query := range db.Queries() {
+		if strings.Contains(query, "AS main_image") {
+			listing = query
+		}
+	}
+	assert.Contains(t, listing, "l.rating >= ?")
+	assert.Contains(t, listing, "(l.price > ? OR (l.price = ? AND l.id > ?))")
+	assert.Contains(t, listing, "ORDER BY l.price, l.id LIMIT ?")
+}
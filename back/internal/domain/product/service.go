@@ -2,29 +2,41 @@ package product
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/domain/pricing"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
 )
 
+const (
+	// minSearchLength matches the shortest word the FULLTEXT indexes keep
+	minSearchLength = 3
+	searchLimit     = 50
+)
+
 type ProductService struct {
 	productStore  types.ProductStore
 	userStore     types.UserStore
 	discountStore types.ProductDiscountStore
 	ratingStore   types.ProductRatingStore
+	variantStore  types.VariantStore
 }
 
 func NewProductService(
 	productStore types.ProductStore,
 	userStore types.UserStore,
 	discountStore types.ProductDiscountStore,
-	ratingStore types.ProductRatingStore) *ProductService {
+	ratingStore types.ProductRatingStore,
+	variantStore types.VariantStore) *ProductService {
 
 	return &ProductService{
 		productStore:  productStore,
 		userStore:     userStore,
 		discountStore: discountStore,
 		ratingStore:   ratingStore,
+		variantStore:  variantStore,
 	}
 }
 
@@ -40,25 +52,107 @@ func (p *ProductService) GetProductDetails(userID int, productID int) *types.Pro
 		return nil
 	}
 
+	discounts, err := p.discountStore.GetActiveDiscounts(productID)
+	if err != nil {
+		panic(fmt.Errorf("failed to get discounts: %w", err))
+	}
+
+	details.Pricing = pricing.Price(details.BasePrice, 1, discounts)
+	details.Price = details.Pricing.UnitPrice
+	details.DiscountPercentage = pricing.DiscountPercentage(details.BasePrice, details.Price)
+
+	p.withVariants(details, discounts)
+
 	return details
 }
 
-func (p *ProductService) GetSimpleProducts(userID int) *[]*types.SimpleProductObject {
+// withVariants adds the option matrix of the product to its details, each
+// variant priced with the discounts of the product.
+func (p *ProductService) withVariants(details *types.ProductDetails, discounts []*types.ProductDiscount) {
+	options, err := p.variantStore.GetOptions(*details.ID)
+	if err != nil {
+		panic(fmt.Errorf("failed to get options: %w", err))
+	}
+
+	variants, err := p.variantStore.GetVariants(*details.ID)
+	if err != nil {
+		panic(fmt.Errorf("failed to get variants: %w", err))
+	}
+
+	details.Options = options
+	details.Variants = make([]*types.VariantDetails, 0, len(variants))
+	for _, variant := range variants {
+		basePrice := details.BasePrice
+		if variant.PriceOverride != nil {
+			basePrice = *variant.PriceOverride
+		}
+
+		breakdown := pricing.Price(basePrice, 1, discounts)
+		details.Variants = append(details.Variants, &types.VariantDetails{
+			ProductVariant: variant,
+			Price:          breakdown.UnitPrice,
+			BasePrice:      basePrice,
+			InStock:        variant.AvailableQuantity > 0,
+			Pricing:        breakdown,
+		})
+	}
+}
+
+func (p *ProductService) GetSimpleProducts(userID int, page types.PageRequest) *types.Page[*types.SimpleProductObject] {
 	if _, err := p.userStore.GetUserByID(userID); err != nil {
 		panic(apperrors.NewEntityNotFound("user", userID))
 	}
 
-	products, err := p.productStore.GetSimpleProductDetails(userID)
+	products, err := p.productStore.GetSimpleProductDetails(userID, page)
 	if err != nil {
 		panic(fmt.Errorf("failed to get simple products: %w", err))
 		return nil
 	}
 
+	p.priceSimpleProducts(products.Items)
+	return products
+}
+
+func (p *ProductService) SearchProducts(userID int, text string) *[]*types.SimpleProductObject {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) < minSearchLength {
+		panic(apperrors.NewValidationError("q", fmt.Sprintf("search needs at least %d characters", minSearchLength)))
+	}
+
+	if _, err := p.userStore.GetUserByID(userID); err != nil {
+		panic(apperrors.NewEntityNotFound("user", userID))
+	}
+
+	products, err := p.productStore.SearchProducts(userID, text, searchLimit)
+	if err != nil {
+		panic(fmt.Errorf("failed to search products: %w", err))
+	}
+
+	p.priceSimpleProducts(*products)
 	return products
 }
 
-func (p *ProductService) GetProducts() []*types.Product {
-	products, err := p.productStore.GetProducts()
+// priceSimpleProducts prices products with their discounts, loaded at once.
+func (p *ProductService) priceSimpleProducts(products []*types.SimpleProductObject) {
+	productIDs := make([]int, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+
+	discounts, err := p.discountStore.GetActiveDiscountsForProducts(productIDs)
+	if err != nil {
+		panic(fmt.Errorf("failed to get discounts: %w", err))
+	}
+
+	for _, product := range products {
+		product.Pricing = pricing.Price(product.BasePrice, 1, discounts[product.ID])
+		product.Price = product.Pricing.UnitPrice
+		product.OnSale = len(discounts[product.ID]) > 0
+	}
+}
+
+func (p *ProductService) GetProducts(page types.PageRequest) *types.Page[*types.Product] {
+	products, err := p.productStore.GetProducts(page)
 	if err != nil {
 		panic(err)
 		return nil
@@ -76,8 +170,8 @@ func (p *ProductService) GetProductByID(productID int) *types.Product {
 	return product
 }
 
-func (p *ProductService) GetProductsByCategoryID(categoryID int) []*types.Product {
-	products, err := p.productStore.GetProductsByCategory(categoryID)
+func (p *ProductService) GetProductsByCategoryID(categoryID int, includeSubcategories bool) []*types.Product {
+	products, err := p.productStore.GetProductsByCategory(categoryID, includeSubcategories)
 	if err != nil {
 		panic(apperrors.NewEntityNotFound("Category", categoryID))
 		return nil
@@ -97,6 +191,7 @@ func (p *ProductService) CreateProductWithImages(payload types.CreateProductWith
 		panic(err)
 		return nil
 	}
+	p.refreshPrice(createdProduct.ID)
 
 	return createdProduct
 }
@@ -123,10 +218,19 @@ func (p *ProductService) UpdateProductById(productID int, payload types.UpdatePr
 		panic(err)
 		return nil
 	}
+	p.refreshPrice(productID)
 
 	return updatedProduct
 }
 
+// refreshPrice stores the listing price of a product just written. The
+// write stands when this fails, and PriceRefresher prices it later.
+func (p *ProductService) refreshPrice(productID int) {
+	if err := p.RefreshProductPrices([]int{productID}); err != nil {
+		fmt.Printf("[PRODUCT SERVICE] ERROR refreshing price of product %d: %v\n", productID, err)
+	}
+}
+
 func (p *ProductService) DeleteProduct(productID int) {
 	_, err := p.productStore.GetProductByID(productID)
 	if err != nil {
@@ -61,12 +61,12 @@ type MockProductStore struct {
 	mock.Mock
 }
 
-func (m *MockProductStore) GetProducts() ([]*types.Product, error) {
-	args := m.Called()
+func (m *MockProductStore) GetProducts(page types.PageRequest) (*types.Page[*types.Product], error) {
+	args := m.Called(page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.Product), args.Error(1)
+	return args.Get(0).(*types.Page[*types.Product]), args.Error(1)
 }
 
 func (m *MockProductStore) CreateProduct(payload types.CreateProductPayload) error {
@@ -111,6 +111,14 @@ func (m *MockProductStore) GetImagesForProducts(productIDs []int) (map[int][]typ
 	return args.Get(0).(map[int][]types.ProductImage), args.Error(1)
 }
 
+func (m *MockProductStore) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.ProductDimensions), args.Error(1)
+}
+
 func (m *MockProductStore) UpdateProduct(productID int, payload types.UpdateProductPayload) error {
 	args := m.Called(productID, payload)
 	return args.Error(0)
@@ -121,8 +129,8 @@ func (m *MockProductStore) DeleteProduct(productID int) error {
 	return args.Error(0)
 }
 
-func (m *MockProductStore) GetProductsByCategory(categoryID int) ([]*types.Product, error) {
-	args := m.Called(categoryID)
+func (m *MockProductStore) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	args := m.Called(categoryID, includeSubcategories)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -137,14 +145,59 @@ func (m *MockProductStore) GetProductDetails(userID int, productID int) (*types.
 	return args.Get(0).(*types.ProductDetails), args.Error(1)
 }
 
-func (m *MockProductStore) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObject, error) {
-	args := m.Called(userID)
+func (m *MockProductStore) GetSimpleProductDetails(userID int, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) SearchProducts(userID int, text string, limit int) (*[]*types.SimpleProductObject, error) {
+	args := m.Called(userID, text, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*[]*types.SimpleProductObject), args.Error(1)
 }
 
+func (m *MockProductStore) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	args := m.Called(userID, filter, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Page[*types.SimpleProductObject]), args.Error(1)
+}
+
+func (m *MockProductStore) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductFacets), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePrices(afterID int, limit int) ([]*types.ProductPrice, error) {
+	args := m.Called(afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) GetBasePricesForProducts(productIDs []int) ([]*types.ProductPrice, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductPrice), args.Error(1)
+}
+
+func (m *MockProductStore) SavePrices(prices []*types.ProductPrice) error {
+	args := m.Called(prices)
+	return args.Error(0)
+}
+
 // Mock para UserStore
 type MockUserStore struct {
 	mock.Mock
@@ -214,6 +267,22 @@ func (m *MockDiscountStore) GetActiveDiscounts(userID int) ([]*types.ProductDisc
 	return args.Get(0).([]*types.ProductDiscount), args.Error(1)
 }
 
+func (m *MockDiscountStore) GetDiscountCalendar(start time.Time, end time.Time) ([]*types.CalendarDiscount, error) {
+	args := m.Called(start, end)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.CalendarDiscount), args.Error(1)
+}
+
+func (m *MockDiscountStore) GetActiveDiscountsForProducts(productIDs []int) (map[int][]*types.ProductDiscount, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]*types.ProductDiscount), args.Error(1)
+}
+
 func (m *MockDiscountStore) GetDiscountsByDateRange(userID int, startDate, endDate time.Time) ([]*types.ProductDiscount, error) {
 	args := m.Called(userID, startDate, endDate)
 	if args.Get(0) == nil {
@@ -291,12 +360,12 @@ func (m *MockRatingStore) GetRating(ratingID int) (*types.ProductRating, error)
 	return args.Get(0).(*types.ProductRating), args.Error(1)
 }
 
-func (m *MockRatingStore) GetRatingsByProduct(productID int) ([]*types.ProductRating, error) {
-	args := m.Called(productID)
+func (m *MockRatingStore) GetRatingsByProduct(productID int, page types.PageRequest) (*types.Page[*types.ProductRating], error) {
+	args := m.Called(productID, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*types.ProductRating), args.Error(1)
+	return args.Get(0).(*types.Page[*types.ProductRating]), args.Error(1)
 }
 
 func (m *MockRatingStore) GetRatingsByUser(userID int) ([]*types.ProductRating, error) {
@@ -315,13 +384,44 @@ func (m *MockRatingStore) UpdateRating(ratingID int, payload *types.UpdateProduc
 	return args.Get(0).(*types.ProductRating), args.Error(1)
 }
 
+// MockVariantStore mocks the parts of the VariantStore interface the product
+// page uses
+type MockVariantStore struct {
+	types.VariantStore
+	mock.Mock
+}
+
+func (m *MockVariantStore) GetOptions(productID int) ([]*types.ProductOption, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductOption), args.Error(1)
+}
+
+func (m *MockVariantStore) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductVariant), args.Error(1)
+}
+
+// noVariants is a variant store for products sold without variants
+func noVariants() *MockVariantStore {
+	m := new(MockVariantStore)
+	m.On("GetOptions", mock.Anything).Return([]*types.ProductOption{}, nil)
+	m.On("GetVariants", mock.Anything).Return([]*types.ProductVariant{}, nil)
+	return m
+}
+
 func TestGetProductByID(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockUserStore := new(MockUserStore)
 	mockDiscountStore := new(MockDiscountStore)
 	mockRatingStore := new(MockRatingStore)
 
-	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 	t.Run("Success - Returns product", func(t *testing.T) {
 		// Set up mock product
@@ -379,7 +479,7 @@ func TestGetProducts(t *testing.T) {
 	mockDiscountStore := new(MockDiscountStore)
 	mockRatingStore := new(MockRatingStore)
 
-	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 	t.Run("Success - Returns products list", func(t *testing.T) {
 		// Setup mock products
@@ -399,31 +499,32 @@ func TestGetProducts(t *testing.T) {
 		}
 
 		// Configure mock behavior
-		mockProductStore.On("GetProducts").Return(mockProducts, nil)
+		page := types.PageRequest{Limit: types.DefaultPageLimit}
+		mockProductStore.On("GetProducts", page).Return(&types.Page[*types.Product]{Items: mockProducts}, nil)
 
 		// Call the service method
-		result := service.GetProducts()
+		result := service.GetProducts(page)
 
 		// Assert expectations
 		assert.NotNil(t, result)
-		assert.Len(t, result, 2)
-		assert.Equal(t, "Product 1", result[0].Title)
-		assert.Equal(t, "Product 2", result[1].Title)
+		assert.Len(t, result.Items, 2)
+		assert.Equal(t, "Product 1", result.Items[0].Title)
+		assert.Equal(t, "Product 2", result.Items[1].Title)
 		mockProductStore.AssertExpectations(t)
 	})
 
 	t.Run("Failure - Error retrieving products", func(t *testing.T) {
 		// Create a separate mock store just for this test
 		failMockProductStore := new(MockProductStore)
-		failService := NewProductService(failMockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+		failService := NewProductService(failMockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 		// Configure mock behavior
 		mockError := fmt.Errorf("database error")
-		failMockProductStore.On("GetProducts").Return(nil, mockError)
+		failMockProductStore.On("GetProducts", mock.Anything).Return(nil, mockError)
 
 		// Define a function that will be executed to capture the panic
 		panicFunc := func() {
-			failService.GetProducts()
+			failService.GetProducts(types.PageRequest{Limit: types.DefaultPageLimit})
 		}
 
 		// Test that the function panics
@@ -439,7 +540,7 @@ func TestGetProductDetails(t *testing.T) {
 	mockDiscountStore := new(MockDiscountStore)
 	mockRatingStore := new(MockRatingStore)
 
-	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 	t.Run("Success - Returns product details", func(t *testing.T) {
 		// Setup mock user and product
@@ -467,6 +568,8 @@ func TestGetProductDetails(t *testing.T) {
 		// Configure mock behavior
 		mockUserStore.On("GetUserByID", userID).Return(mockUser, nil)
 		mockProductStore.On("GetProductDetails", userID, productID).Return(productDetails, nil)
+		mockDiscountStore.On("GetActiveDiscounts", productID).
+			Return([]*types.ProductDiscount{{ID: 1, ProductID: productID, DiscountPercent: 10}}, nil)
 
 		// Call the service method
 		result := service.GetProductDetails(userID, productID)
@@ -480,8 +583,40 @@ func TestGetProductDetails(t *testing.T) {
 		assert.Equal(t, 10.0, result.DiscountPercentage)
 		assert.True(t, result.IsFavorite)
 		assert.Equal(t, 4.5, result.AverageRating)
+		assert.Equal(t, "10% off", result.Pricing.Applied[0].Description)
 		mockUserStore.AssertExpectations(t)
 		mockProductStore.AssertExpectations(t)
+		mockDiscountStore.AssertExpectations(t)
+	})
+
+	t.Run("Success - Variants are priced with the discounts of the product", func(t *testing.T) {
+		userID := 1
+		productID := 2
+		override := 150.0
+		mockVariantStore := new(MockVariantStore)
+		service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, mockVariantStore)
+
+		mockUserStore.On("GetUserByID", userID).Return(&types.User{ID: userID}, nil)
+		mockProductStore.On("GetProductDetails", userID, productID).
+			Return(&types.ProductDetails{ID: &productID, Title: "T-Shirt", BasePrice: 100.0}, nil)
+		mockDiscountStore.On("GetActiveDiscounts", productID).
+			Return([]*types.ProductDiscount{{ID: 2, ProductID: productID, DiscountPercent: 10}}, nil)
+		mockVariantStore.On("GetOptions", productID).Return([]*types.ProductOption{{ID: 1, ProductID: productID, Name: "Size"}}, nil)
+		mockVariantStore.On("GetVariants", productID).Return([]*types.ProductVariant{
+			{ID: 1, ProductID: productID, SKU: "TSHIRT-M", Label: "M", AvailableQuantity: 3},
+			{ID: 2, ProductID: productID, SKU: "TSHIRT-XL", Label: "XL", PriceOverride: &override},
+		}, nil)
+
+		result := service.GetProductDetails(userID, productID)
+
+		assert.Len(t, result.Options, 1)
+		assert.Len(t, result.Variants, 2)
+		assert.Equal(t, 90.0, result.Variants[0].Price)
+		assert.True(t, result.Variants[0].InStock)
+		assert.Equal(t, 150.0, result.Variants[1].BasePrice)
+		assert.Equal(t, 135.0, result.Variants[1].Price)
+		assert.False(t, result.Variants[1].InStock)
+		mockVariantStore.AssertExpectations(t)
 	})
 
 	t.Run("Failure - User not found", func(t *testing.T) {
@@ -525,13 +660,202 @@ func TestGetProductDetails(t *testing.T) {
 	})
 }
 
+func TestGetSimpleProducts(t *testing.T) {
+	mockProductStore := new(MockProductStore)
+	mockUserStore := new(MockUserStore)
+	mockDiscountStore := new(MockDiscountStore)
+
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, new(MockRatingStore), noVariants())
+
+	products := &types.Page[*types.SimpleProductObject]{Items: []*types.SimpleProductObject{
+		{ID: 1, Title: "Mug", BasePrice: 20.0},
+		{ID: 2, Title: "Plate", BasePrice: 15.0},
+	}}
+	page := types.PageRequest{Limit: types.DefaultPageLimit}
+	fixedPrice := 12.0
+	mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+	mockProductStore.On("GetSimpleProductDetails", 1, page).Return(products, nil)
+	// the discounts of every product are loaded at once
+	mockDiscountStore.On("GetActiveDiscountsForProducts", []int{1, 2}).Return(map[int][]*types.ProductDiscount{
+		2: {{ID: 3, ProductID: 2, Type: types.DiscountFixedPrice, FixedPrice: &fixedPrice}},
+	}, nil)
+
+	result := service.GetSimpleProducts(1, page)
+
+	assert.Equal(t, 20.0, result.Items[0].Price)
+	assert.Empty(t, result.Items[0].Pricing.Applied)
+	assert.Equal(t, 12.0, result.Items[1].Price)
+	assert.Equal(t, "now 12.00", result.Items[1].Pricing.Applied[0].Description)
+	mockDiscountStore.AssertExpectations(t)
+}
+
+func TestSearchProducts(t *testing.T) {
+	t.Run("Prices the matches", func(t *testing.T) {
+		mockProductStore := new(MockProductStore)
+		mockUserStore := new(MockUserStore)
+		mockDiscountStore := new(MockDiscountStore)
+		service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, new(MockRatingStore), noVariants())
+
+		products := &[]*types.SimpleProductObject{{ID: 4, Title: "Café torrado", BasePrice: 30.0}}
+		mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+		mockProductStore.On("SearchProducts", 1, "cafe", searchLimit).Return(products, nil)
+		mockDiscountStore.On("GetActiveDiscountsForProducts", []int{4}).Return(map[int][]*types.ProductDiscount{
+			4: {{ID: 1, ProductID: 4, DiscountPercent: 10}},
+		}, nil)
+
+		result := service.SearchProducts(1, "  cafe ")
+
+		assert.Len(t, *result, 1)
+		assert.Equal(t, 27.0, (*result)[0].Price)
+		mockProductStore.AssertExpectations(t)
+	})
+
+	t.Run("Search too short", func(t *testing.T) {
+		mockProductStore := new(MockProductStore)
+		service := NewProductService(mockProductStore, new(MockUserStore), new(MockDiscountStore), new(MockRatingStore), noVariants())
+
+		func() {
+			defer func() {
+				assert.Equal(t, apperrors.NewValidationError("q", "search needs at least 3 characters"), recover())
+			}()
+			service.SearchProducts(1, " ab ")
+		}()
+		mockProductStore.AssertNotCalled(t, "SearchProducts", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestListProducts(t *testing.T) {
+	electronics := 1
+	filter := types.ProductFilter{CategoryID: &electronics, Sort: types.SortPriceAsc}
+	firstPage := types.PageRequest{Limit: types.DefaultPageLimit}
+	next := "next"
+
+	setup := func() (*ProductService, *MockProductStore) {
+		mockProductStore := new(MockProductStore)
+		mockUserStore := new(MockUserStore)
+		mockDiscountStore := new(MockDiscountStore)
+		mockUserStore.On("GetUserByID", 1).Return(&types.User{ID: 1}, nil)
+		mockProductStore.On("GetProductFacets", mock.Anything).Return(&types.ProductFacets{
+			Categories: []*types.CategoryFacet{{ID: 1, Name: "Electronics", Count: 2}},
+			InStock:    2,
+		}, nil)
+		mockProductStore.On("ListProducts", 1, filter, firstPage).Return(&types.Page[*types.SimpleProductObject]{
+			Items: []*types.SimpleProductObject{
+				{ID: 1, Title: "Phone", BasePrice: 100.0, Price: 80.0, OnSale: true},
+				{ID: 3, Title: "TV", BasePrice: 600.0, Price: 600.0},
+			},
+			NextCursor: &next,
+		}, nil)
+		mockDiscountStore.On("GetActiveDiscountsForProducts", []int{1, 3}).Return(map[int][]*types.ProductDiscount{
+			1: {{ID: 1, ProductID: 1, DiscountPercent: 25}},
+		}, nil)
+		return NewProductService(mockProductStore, mockUserStore, mockDiscountStore, new(MockRatingStore), noVariants()), mockProductStore
+	}
+
+	t.Run("Prices the page the store lists", func(t *testing.T) {
+		service, _ := setup()
+
+		listing := service.ListProducts(1, filter, firstPage)
+
+		assert.Len(t, listing.Items, 2)
+		// the price stored for the listing gives way to the current one
+		assert.Equal(t, 75.0, listing.Items[0].Price)
+		assert.NotNil(t, listing.Items[0].Pricing)
+		assert.False(t, listing.Items[1].OnSale)
+		assert.Equal(t, &next, listing.NextCursor)
+		assert.Equal(t, 2, listing.Facets.InStock)
+	})
+
+	t.Run("Minimum price above the maximum", func(t *testing.T) {
+		minPrice, maxPrice := 100.0, 50.0
+		service, store := setup()
+
+		defer func() {
+			assert.Equal(t, apperrors.NewValidationError("minPrice", "minPrice can't be above maxPrice"), recover())
+			store.AssertNotCalled(t, "ListProducts", mock.Anything, mock.Anything, mock.Anything)
+		}()
+		service.ListProducts(1, types.ProductFilter{MinPrice: &minPrice, MaxPrice: &maxPrice}, firstPage)
+	})
+
+	t.Run("Unknown sort", func(t *testing.T) {
+		service, _ := setup()
+
+		defer func() {
+			assert.Equal(t, apperrors.NewValidationError("sort", `unknown sort "cheapest"`), recover())
+		}()
+		service.ListProducts(1, types.ProductFilter{Sort: "cheapest"}, firstPage)
+	})
+
+	t.Run("Unknown category", func(t *testing.T) {
+		categoryID := 99
+		service, store := setup()
+
+		assertPanicsWithEntityNotFound(t, "category", 99, func() {
+			service.ListProducts(1, types.ProductFilter{CategoryID: &categoryID}, firstPage)
+		})
+		store.AssertNotCalled(t, "ListProducts", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRefreshPrices(t *testing.T) {
+	mockProductStore := new(MockProductStore)
+	mockDiscountStore := new(MockDiscountStore)
+	mockProductStore.On("GetBasePrices", 0, priceBatch).Return([]*types.ProductPrice{
+		{ProductID: 1, BasePrice: 100.0},
+		{ProductID: 2, BasePrice: 30.0},
+	}, nil)
+	mockProductStore.On("GetBasePrices", 2, priceBatch).Return([]*types.ProductPrice{}, nil)
+	mockDiscountStore.On("GetActiveDiscountsForProducts", []int{1, 2}).Return(map[int][]*types.ProductDiscount{
+		1: {{ID: 1, ProductID: 1, DiscountPercent: 20}},
+	}, nil)
+	mockProductStore.On("SavePrices", []*types.ProductPrice{
+		{ProductID: 1, BasePrice: 100.0, Price: 80.0, OnSale: true},
+		{ProductID: 2, BasePrice: 30.0, Price: 30.0},
+	}).Return(nil)
+	service := NewProductService(mockProductStore, new(MockUserStore), mockDiscountStore, new(MockRatingStore), noVariants())
+
+	priced, err := service.RefreshPrices()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, priced)
+	mockProductStore.AssertExpectations(t)
+}
+
+func TestRefreshProductPrices(t *testing.T) {
+	mockProductStore := new(MockProductStore)
+	mockDiscountStore := new(MockDiscountStore)
+	// product 3 no longer exists
+	mockProductStore.On("GetBasePricesForProducts", []int{2, 3}).Return([]*types.ProductPrice{
+		{ProductID: 2, BasePrice: 50.0},
+	}, nil)
+	mockDiscountStore.On("GetActiveDiscountsForProducts", []int{2}).Return(map[int][]*types.ProductDiscount{
+		2: {{ID: 1, ProductID: 2, DiscountPercent: 10}},
+	}, nil)
+	mockProductStore.On("SavePrices", []*types.ProductPrice{
+		{ProductID: 2, BasePrice: 50.0, Price: 45.0, OnSale: true},
+	}).Return(nil)
+	service := NewProductService(mockProductStore, new(MockUserStore), mockDiscountStore, new(MockRatingStore), noVariants())
+
+	err := service.RefreshProductPrices([]int{2, 3})
+
+	assert.NoError(t, err)
+	mockProductStore.AssertExpectations(t)
+	mockDiscountStore.AssertExpectations(t)
+}
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, "camiseta* azul*", searchTerms("camiseta  azul"))
+	assert.Equal(t, "cafe* moido*", searchTerms(`+cafe -"moido"`))
+	assert.Equal(t, "", searchTerms(" *** "))
+}
+
 func TestCreateProductWithImages(t *testing.T) {
 	mockProductStore := new(MockProductStore)
 	mockUserStore := new(MockUserStore)
 	mockDiscountStore := new(MockDiscountStore)
 	mockRatingStore := new(MockRatingStore)
 
-	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 	t.Run("Success - Creates product with images", func(t *testing.T) {
 		// Setup payload for product creation
@@ -595,6 +919,9 @@ func TestCreateProductWithImages(t *testing.T) {
 
 		// Configure mock behavior
 		mockProductStore.On("CreateProductWithImages", payload).Return(mockProduct, nil)
+		mockProductStore.On("GetBasePricesForProducts", []int{1}).Return([]*types.ProductPrice{{ProductID: 1, BasePrice: 149.99}}, nil)
+		mockDiscountStore.On("GetActiveDiscountsForProducts", []int{1}).Return(map[int][]*types.ProductDiscount{}, nil)
+		mockProductStore.On("SavePrices", []*types.ProductPrice{{ProductID: 1, BasePrice: 149.99, Price: 149.99}}).Return(nil)
 
 		// Call the service method
 		result := service.CreateProductWithImages(payload)
@@ -659,7 +986,7 @@ func TestDeleteProduct(t *testing.T) {
 	mockDiscountStore := new(MockDiscountStore)
 	mockRatingStore := new(MockRatingStore)
 
-	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore)
+	service := NewProductService(mockProductStore, mockUserStore, mockDiscountStore, mockRatingStore, noVariants())
 
 	t.Run("Success - Deletes product", func(t *testing.T) {
 		// Setup mock product
@@ -3,42 +3,79 @@ package product
 import (
 	"database/sql"
 	"fmt"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	types "github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"strings"
+	"time"
 )
 
 type Store struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetProducts() ([]*types.Product, error) {
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+// availableQuantity is the stock of inventory row i not held by an active
+// cart reservation.
+const availableQuantity = `i.stock_quantity - COALESCE((
+			SELECT SUM(r.quantity)
+			FROM inventory_reservations r
+			WHERE r.productId = i.product_id AND r.variantId = 0 AND r.expiresAt > NOW()
+		), 0)`
+
+// variantsAvailableQuantity adds up the units left of the variants of p, and
+// is NULL for a product sold without variants.
+const variantsAvailableQuantity = `(
+			SELECT SUM(v.stock_quantity - COALESCE((
+				SELECT SUM(r.quantity)
+				FROM inventory_reservations r
+				WHERE r.variantId = v.id AND r.expiresAt > NOW()
+			), 0))
+			FROM product_variants v
+			WHERE v.productId = p.id AND v.deletedAt IS NULL
+		)`
+
+func (s *Store) GetProducts(page types.PageRequest) (*types.Page[*types.Product], error) {
 	// find products
 	rows, err := s.db.Query(
-		`SELECT p.*, i.stock_quantity, i.version
+		`SELECT p.*, i.stock_quantity, i.version, `+availableQuantity+`, i.max_purchase_quantity
 		FROM products p
 		INNER JOIN inventory i ON p.id = i.product_id
-		`)
+		WHERE p.id > ?
+		ORDER BY p.id
+		LIMIT ?
+		`, page.AfterID(), page.Limit+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get products: %w", err)
 	}
 	defer rows.Close()
 
 	// get products and their inventory
-	productIds := make([]int, 0)
 	products := make([]*types.Product, 0)
 	for rows.Next() {
 		p, err := scanRowsIntoProduct(rows)
 		if err != nil {
 			return nil, err
 		}
-		productIds = append(productIds, p.ID)
 		products = append(products, p)
 	}
 
+	productPage := types.NewPage(products, page.Limit, func(p *types.Product) types.Cursor {
+		return types.Cursor{ID: p.ID}
+	})
+
+	productIds := make([]int, 0, len(productPage.Items))
+	for _, p := range productPage.Items {
+		productIds = append(productIds, p.ID)
+	}
+
 	// find images
 	if len(productIds) > 0 {
 		images, err := s.GetImagesForProducts(productIds)
@@ -46,24 +83,43 @@ func (s *Store) GetProducts() ([]*types.Product, error) {
 			return nil, fmt.Errorf("failed to get images: %w", err)
 		}
 
-		for _, p := range products {
+		for _, p := range productPage.Items {
 			p.Images = images[p.ID]
 		}
 	}
 
 	// find categories
-	for _, p := range products {
-		cat, err := s.GetProductCategories(p.ID)
+	if len(productIds) > 0 {
+		categories, err := s.GetCategoriesForProducts(productIds)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get categories: %w", err)
 		}
-		p.Categories = cat
+
+		for _, p := range productPage.Items {
+			p.Categories = categories[p.ID]
+		}
 	}
 
-	return products, nil
+	return productPage, nil
 }
 
-func (s *Store) GetProductsByCategory(categoryID int) ([]*types.Product, error) {
+// categorySubtree selects the id of a category and of all its descendants.
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION
+		SELECT c.id FROM categories c JOIN subtree s ON c.parentCategoryId = s.id
+	)
+	SELECT id FROM subtree`
+
+// GetProductsByCategory lists the products of the category, and with
+// includeSubcategories those of its descendants too.
+func (s *Store) GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*types.Product, error) {
+	categories := "?"
+	if includeSubcategories {
+		categories = categorySubtree
+	}
+
 	rows, err := s.db.Query(`
         SELECT 
             p.id, 
@@ -73,11 +129,17 @@ func (s *Store) GetProductsByCategory(categoryID int) ([]*types.Product, error)
             p.createdAt, 
             p.updatedAt,
             i.stock_quantity, 
-            i.version
+            i.version,
+            `+availableQuantity+`,
+            i.max_purchase_quantity
         FROM products p
         INNER JOIN inventory i ON p.id = i.product_id
-        INNER JOIN product_categories pc ON p.id = pc.productId
-        WHERE pc.categoryId = ?
+        WHERE p.id IN (
+            SELECT pc.productId
+            FROM product_categories pc
+            WHERE pc.categoryId IN (`+categories+`)
+        )
+        ORDER BY p.id
     `, categoryID)
 
 	if err != nil {
@@ -100,6 +162,8 @@ func (s *Store) GetProductsByCategory(categoryID int) ([]*types.Product, error)
 			&p.UpdatedAt,
 			&p.Inventory.StockQuantity,
 			&p.Inventory.Version,
+			&p.Inventory.AvailableQuantity,
+			&p.Inventory.MaxPurchaseQuantity,
 		)
 		if err != nil {
 			return nil, err
@@ -116,29 +180,25 @@ func (s *Store) GetProductsByCategory(categoryID int) ([]*types.Product, error)
 			return nil, fmt.Errorf("failed to get images: %w", err)
 		}
 
+		categories, err := s.GetCategoriesForProducts(productIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get categories: %w", err)
+		}
+
 		for _, p := range products {
 			p.Images = imagesMap[p.ID]
-
-			categories, err := s.GetProductCategories(p.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get categories for product %d: %w", p.ID, err)
-			}
-			p.Categories = categories
+			p.Categories = categories[p.ID]
 		}
 	}
 	return products, nil
 }
 
 func (s *Store) GetImagesForProducts(productIDs []int) (map[int][]types.ProductImage, error) {
+	in, args := database.InArgs(productIDs)
 	query := `
         SELECT id, productId, imageUrl, sortOrder 
         FROM product_images 
-        WHERE productId IN (?` + strings.Repeat(",?", len(productIDs)-1) + `)`
-
-	args := make([]interface{}, len(productIDs))
-	for i, id := range productIDs {
-		args[i] = id
-	}
+        WHERE productId IN (` + in + `)`
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
@@ -160,6 +220,32 @@ func (s *Store) GetImagesForProducts(productIDs []int) (map[int][]types.ProductI
 	return imagesMap, nil
 }
 
+func (s *Store) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	in, args := database.InArgs(productIDs)
+	rows, err := s.db.Query(`
+		SELECT productId, weightGrams, lengthCm, widthCm, heightCm
+		FROM product_dimensions
+		WHERE productId IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dimensions: %w", err)
+	}
+	defer rows.Close()
+
+	dimensions := make(map[int]*types.ProductDimensions)
+	for rows.Next() {
+		var productID int
+		d := new(types.ProductDimensions)
+		err := rows.Scan(&productID, &d.WeightGrams, &d.LengthCm, &d.WidthCm, &d.HeightCm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dimensions: %w", err)
+		}
+		dimensions[productID] = d
+	}
+
+	return dimensions, rows.Err()
+}
+
 func (s *Store) GetProductByID(productID int) (*types.Product, error) {
 	// find products
 	row := s.db.QueryRow(`SELECT * FROM products WHERE id = ?`, productID)
@@ -197,135 +283,159 @@ func (s *Store) GetProductByID(productID int) (*types.Product, error) {
 	}
 	product.Categories = categories
 
+	dimensions, err := s.GetDimensionsForProducts([]int{productID})
+	if err != nil {
+		return nil, err
+	}
+	product.Dimensions = dimensions[productID]
+
 	return product, nil
 }
 
 func (s *Store) GetProductCategories(productID int) ([]types.Category, error) {
+	categories, err := s.GetCategoriesForProducts([]int{productID})
+	if err != nil {
+		return nil, err
+	}
+
+	return categories[productID], nil
+}
+
+// GetCategoriesForProducts loads the categories of every product in one
+// query, mapped by product id.
+func (s *Store) GetCategoriesForProducts(productIDs []int) (map[int][]types.Category, error) {
+	in, args := database.InArgs(productIDs)
 	rows, err := s.db.Query(`
-        SELECT c.id, c.name, c.imageUrl, c.parentCategoryId 
+        SELECT pc.productId, c.id, c.name, c.imageUrl, c.parentCategoryId 
         FROM product_categories pc
         JOIN categories c ON pc.categoryId = c.id
-        WHERE pc.productId = ?
-    `, productID)
+        WHERE pc.productId IN (`+in+`)
+    `, args...)
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var categories []types.Category
+	categoriesMap := make(map[int][]types.Category)
 	for rows.Next() {
+		var productID int
 		var c types.Category
-		err := rows.Scan(&c.ID, &c.Name, &c.ImageUrl, &c.ParentCategoryId)
+		err := rows.Scan(&productID, &c.ID, &c.Name, &c.ImageUrl, &c.ParentCategoryId)
 		if err != nil {
 			return nil, err
 		}
-		categories = append(categories, c)
+		categoriesMap[productID] = append(categoriesMap[productID], c)
 	}
 
-	return categories, nil
+	return categoriesMap, rows.Err()
 }
 
 func (s *Store) CreateProduct(product types.CreateProductPayload) error {
-	// create transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// create product
-	res, err := tx.Exec(
-		`INSERT INTO products (title, description, basePrice) VALUES (?, ?, ?)`,
-		product.Title, product.Description, product.BasePrice)
-	if err != nil {
-		return err
-	}
-
-	// get product id
-	productID, err := res.LastInsertId()
-	if err != nil {
-		return err
-	}
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		// create product
+		res, err := tx.Exec(
+			`INSERT INTO products (title, description, basePrice) VALUES (?, ?, ?)`,
+			product.Title, product.Description, product.BasePrice)
+		if err != nil {
+			return err
+		}
 
-	// create inventory
-	_, err = tx.Exec(
-		`INSERT INTO inventory (product_id, stock_quantity) VALUES (?, ?)`,
-		productID, product.StockQuantity,
-	)
-	if err != nil {
-		return err
-	}
+		// get product id
+		productID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
 
-	// add categories
-	for _, categoryID := range product.CategoryIDs {
-		_, err := tx.Exec(
-			`INSERT INTO product_categories (productId, categoryId) VALUES (?, ?)`,
-			productID, categoryID,
+		// create inventory
+		_, err = tx.Exec(
+			`INSERT INTO inventory (product_id, stock_quantity, max_purchase_quantity) VALUES (?, ?, NULLIF(?, 0))`,
+			productID, product.StockQuantity, maxPurchaseQuantity(product.MaxPurchaseQuantity),
 		)
 		if err != nil {
-			return fmt.Errorf("failed to add category: %w", err)
+			return err
 		}
-	}
-
-	return tx.Commit()
-}
 
-func (s *Store) CreateProductWithImages(payload types.CreateProductWithImagesPayload) (*types.Product, error) {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
+		if product.Dimensions != nil {
+			if err := setDimensions(tx, int(productID), product.Dimensions); err != nil {
+				return err
+			}
+		}
 
-	// create product
-	res, err := tx.Exec(
-		`INSERT INTO products (title, description, basePrice) VALUES (?, ?, ?)`,
-		payload.Title, payload.Description, payload.BasePrice,
-	)
-	if err != nil {
-		return nil, err
-	}
+		// add categories
+		for _, categoryID := range product.CategoryIDs {
+			_, err := tx.Exec(
+				`INSERT INTO product_categories (productId, categoryId) VALUES (?, ?)`,
+				productID, categoryID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to add category: %w", err)
+			}
+		}
 
-	// get product id
-	productID, err := res.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
+		return nil
+	})
+}
 
-	// create inventory
-	_, err = tx.Exec(
-		`INSERT INTO inventory (product_id, stock_quantity) VALUES (?, ?)`,
-		productID, payload.StockQuantity,
-	)
-	if err != nil {
-		return nil, err
-	}
+func (s *Store) CreateProductWithImages(payload types.CreateProductWithImagesPayload) (*types.Product, error) {
+	var productID int64
 
-	// create product images
-	for _, img := range payload.Images {
-		_, err := tx.Exec(
-			`INSERT INTO product_images (productId, imageUrl, sortOrder) VALUES (?, ?, ?)`,
-			productID, img.ImageUrl, img.SortOrder,
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		// create product
+		res, err := tx.Exec(
+			`INSERT INTO products (title, description, basePrice) VALUES (?, ?, ?)`,
+			payload.Title, payload.Description, payload.BasePrice,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
-	}
 
-	// add categories
-	for _, categoryID := range payload.CategoryIDs {
-		_, err := tx.Exec(
-			`INSERT INTO product_categories (productId, categoryId) VALUES (?, ?)`,
-			productID, categoryID,
+		// get product id
+		productID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// create inventory
+		_, err = tx.Exec(
+			`INSERT INTO inventory (product_id, stock_quantity, max_purchase_quantity) VALUES (?, ?, NULLIF(?, 0))`,
+			productID, payload.StockQuantity, maxPurchaseQuantity(payload.MaxPurchaseQuantity),
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to add category: %w", err)
+			return err
+		}
+
+		// create product images
+		for _, img := range payload.Images {
+			_, err := tx.Exec(
+				`INSERT INTO product_images (productId, imageUrl, sortOrder) VALUES (?, ?, ?)`,
+				productID, img.ImageUrl, img.SortOrder,
+			)
+			if err != nil {
+				return err
+			}
 		}
-	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
+		if payload.Dimensions != nil {
+			if err := setDimensions(tx, int(productID), payload.Dimensions); err != nil {
+				return err
+			}
+		}
+
+		// add categories
+		for _, categoryID := range payload.CategoryIDs {
+			_, err := tx.Exec(
+				`INSERT INTO product_categories (productId, categoryId) VALUES (?, ?)`,
+				productID, categoryID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to add category: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -334,42 +444,34 @@ func (s *Store) CreateProductWithImages(payload types.CreateProductWithImagesPay
 }
 
 func (s *Store) UpdateStock(productID int, quantityChange int) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	var currentStock, version int
-	err = tx.QueryRow(
-		`SELECT stock_quantity, version FROM inventory WHERE product_id = ? FOR UPDATE`,
-		productID,
-	).Scan(&currentStock, &version)
-	if err != nil {
-		return err
-	}
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		var currentStock, version int
+		err := tx.QueryRow(
+			`SELECT stock_quantity, version FROM inventory WHERE product_id = ? FOR UPDATE`,
+			productID,
+		).Scan(&currentStock, &version)
+		if err != nil {
+			return err
+		}
 
-	newStock := currentStock + quantityChange
-	if newStock < 0 {
-		return fmt.Errorf("insufficient stock")
-	}
+		newStock := currentStock + quantityChange
+		if newStock < 0 {
+			return types.ErrInsufficientStock
+		}
 
-	_, err = tx.Exec(
-		`UPDATE inventory SET stock_quantity = ?, version = version + 1 WHERE product_id = ? AND version = ?`,
-		newStock, productID, version,
-	)
-	if err != nil {
+		_, err = tx.Exec(
+			`UPDATE inventory SET stock_quantity = ?, version = version + 1 WHERE product_id = ? AND version = ?`,
+			newStock, productID, version,
+		)
 		return err
-	}
-
-	return tx.Commit()
+	})
 }
 
 func (s *Store) GetInventory(productID int) (*types.Inventory, error) {
-	const query = `
-        SELECT product_id, stock_quantity, version 
-        FROM inventory 
-        WHERE product_id = ?
+	query := `
+        SELECT i.product_id, i.stock_quantity, i.version, ` + availableQuantity + `, i.max_purchase_quantity
+        FROM inventory i
+        WHERE i.product_id = ?
     `
 
 	var inventory types.Inventory
@@ -378,6 +480,8 @@ func (s *Store) GetInventory(productID int) (*types.Inventory, error) {
 		&inventory.ProductID,
 		&inventory.StockQuantity,
 		&inventory.Version,
+		&inventory.AvailableQuantity,
+		&inventory.MaxPurchaseQuantity,
 	)
 	switch {
 	case err == sql.ErrNoRows:
@@ -390,59 +494,83 @@ func (s *Store) GetInventory(productID int) (*types.Inventory, error) {
 }
 
 func (s *Store) UpdateProduct(productID int, payload types.UpdateProductPayload) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		// update product data
+		if err := updateProductDetails(tx, productID, payload); err != nil {
+			return err
+		}
 
-	// update product data
-	if err := updateProductDetails(tx, productID, payload); err != nil {
-		return err
-	}
+		// update images
+		if payload.Images != nil {
+			if err := updateProductImages(tx, productID, payload.Images); err != nil {
+				return err
+			}
+		}
 
-	// update images
-	if payload.Images != nil {
-		if err := updateProductImages(tx, productID, payload.Images); err != nil {
-			return err
+		// update purchase cap
+		if payload.MaxPurchaseQuantity != nil {
+			_, err := tx.Exec(
+				`UPDATE inventory SET max_purchase_quantity = NULLIF(?, 0) WHERE product_id = ?`,
+				*payload.MaxPurchaseQuantity, productID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to update max purchase quantity: %w", err)
+			}
 		}
-	}
 
-	// commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		if payload.Dimensions != nil {
+			if err := setDimensions(tx, productID, payload.Dimensions); err != nil {
+				return err
+			}
+		}
 
-	return nil
+		return nil
+	})
 }
 
-func (s *Store) DeleteProduct(productID int) error {
-	tx, err := s.db.Begin()
+func setDimensions(tx database.DBTX, productID int, dimensions *types.ProductDimensions) error {
+	_, err := tx.Exec(`
+		INSERT INTO product_dimensions (productId, weightGrams, lengthCm, widthCm, heightCm)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			weightGrams = VALUES(weightGrams),
+			lengthCm = VALUES(lengthCm),
+			widthCm = VALUES(widthCm),
+			heightCm = VALUES(heightCm)
+	`, productID, dimensions.WeightGrams, dimensions.LengthCm, dimensions.WidthCm, dimensions.HeightCm)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to set dimensions: %w", err)
 	}
-	defer tx.Rollback()
+	return nil
+}
 
-	// deletes product
-	result, err := tx.Exec("DELETE FROM products WHERE id = ?", productID)
-	if err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+// maxPurchaseQuantity turns an absent cap into zero, which is stored as NULL
+func maxPurchaseQuantity(max *int) int {
+	if max == nil {
+		return 0
 	}
+	return *max
+}
 
-	// verify if product exists
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows // product not found
-	}
+func (s *Store) DeleteProduct(productID int) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		// deletes product
+		result, err := tx.Exec("DELETE FROM products WHERE id = ?", productID)
+		if err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		// verify if product exists
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to check rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows // product not found
+		}
 
-	return nil
+		return nil
+	})
 }
 
 func (s *Store) GetProductDetails(userID int, productID int) (*types.ProductDetails, error) {
@@ -452,24 +580,20 @@ func (s *Store) GetProductDetails(userID int, productID int) (*types.ProductDeta
             p.title,
             p.description,
             p.basePrice,
-            COALESCE(MAX(CASE WHEN NOW() BETWEEN d.startDate AND d.endDate THEN d.discountPercent END), 0) AS discount,
             COALESCE(AVG(r.rating), 0) AS avg_rating,
             EXISTS(SELECT 1 FROM user_favorites uf WHERE uf.userId = ? AND uf.productId = p.id) AS is_favorite
         FROM products p
-        LEFT JOIN product_discounts d ON p.id = d.productId
         LEFT JOIN product_ratings r ON p.id = r.productId
         WHERE p.id = ?
         GROUP BY p.id
     `
 	var detail types.ProductDetails
-	var discount float64
 
 	err := s.db.QueryRow(query, userID, productID).Scan(
 		&detail.ID,
 		&detail.Title,
 		&detail.Description,
 		&detail.BasePrice,
-		&discount,
 		&detail.AverageRating,
 		&detail.IsFavorite,
 	)
@@ -477,8 +601,8 @@ func (s *Store) GetProductDetails(userID int, productID int) (*types.ProductDeta
 		return nil, fmt.Errorf("failed to get product details: %w", err)
 	}
 
-	detail.DiscountPercentage = discount
-	detail.Price = detail.BasePrice * (1 - discount/100)
+	// the price is left to the pricing engine
+	detail.Price = detail.BasePrice
 
 	images, err := s.GetImagesForProducts([]int{productID})
 	if err != nil {
@@ -491,38 +615,638 @@ func (s *Store) GetProductDetails(userID int, productID int) (*types.ProductDeta
 	return &detail, nil
 }
 
-func (s *Store) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObject, error) {
-	query := `
-        SELECT 
+// simpleProductColumns are the columns scanSimpleProducts reads. They need
+// the user id as the first argument of the query.
+const simpleProductColumns = `
             p.id,
             p.title,
             p.basePrice,
-            COALESCE(MAX(CASE WHEN NOW() BETWEEN d.startDate AND d.endDate THEN d.discountPercent END), 0) AS discount,
             COALESCE(AVG(r.rating), 0) AS avg_rating,
             EXISTS(SELECT 1 FROM user_favorites uf WHERE uf.userId = ? AND uf.productId = p.id) AS is_favorite,
             (SELECT imageUrl FROM product_images WHERE productId = p.id ORDER BY sortOrder LIMIT 1) AS main_image
+`
+
+func (s *Store) GetSimpleProductDetails(userID int, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	query := `
+        SELECT ` + simpleProductColumns + `
         FROM products p
-        LEFT JOIN product_discounts d ON p.id = d.productId
         LEFT JOIN product_ratings r ON p.id = r.productId
+        WHERE p.id > ?
         GROUP BY p.id
+        ORDER BY p.id
+        LIMIT ?
     `
-	rows, err := s.db.Query(query, userID)
+	rows, err := s.db.Query(query, userID, page.AfterID(), page.Limit+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query simple products: %w", err)
 	}
 	defer rows.Close()
 
-	var products []*types.SimpleProductObject
+	products, err := scanSimpleProducts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewPage(*products, page.Limit, func(p *types.SimpleProductObject) types.Cursor {
+		return types.Cursor{ID: p.ID}
+	}), nil
+}
+
+// SearchProducts matches the words of text against the title and description
+// of the products, most relevant first. A match in the title counts twice.
+func (s *Store) SearchProducts(userID int, text string, limit int) (*[]*types.SimpleProductObject, error) {
+	terms := searchTerms(text)
+	if terms == "" {
+		products := []*types.SimpleProductObject{}
+		return &products, nil
+	}
+
+	query := `
+        SELECT ` + simpleProductColumns + `
+        FROM products p
+        LEFT JOIN product_ratings r ON p.id = r.productId
+        WHERE MATCH(p.title, p.description) AGAINST(? IN BOOLEAN MODE)
+        GROUP BY p.id
+        ORDER BY
+            MATCH(p.title) AGAINST(? IN BOOLEAN MODE) * 2
+            + MATCH(p.title, p.description) AGAINST(? IN BOOLEAN MODE) DESC,
+            p.id
+        LIMIT ?
+    `
+	rows, err := s.db.Query(query, userID, terms, terms, terms, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSimpleProducts(rows)
+}
+
+// listedProducts is a row per product with what the listing filters and
+// sorts on. The price is the one RefreshPrices last stored, or the base price
+// of a product it has not priced yet. The units sold leave out those
+// refunded.
+const listedProducts = `(
+        SELECT
+            p.id,
+            p.title,
+            p.basePrice,
+            COALESCE(pp.price, p.basePrice) AS price,
+            COALESCE(pp.onSale, FALSE) AS onSale,
+            COALESCE(AVG(r.rating), 0) AS rating,
+            p.createdAt,
+            COALESCE(
+                ` + variantsAvailableQuantity + `,
+                (SELECT ` + availableQuantity + ` FROM inventory i WHERE i.product_id = p.id),
+                0
+            ) AS available,
+            COALESCE((
+                SELECT SUM(oi.quantity)
+                FROM order_items oi
+                JOIN order_history o ON o.id = oi.orderId
+                WHERE oi.productId = p.id
+                AND o.status IN ('PAID', 'SHIPPED', 'DELIVERED', 'COMPLETED')
+            ), 0) - COALESCE((
+                SELECT SUM(ri.quantity)
+                FROM refund_items ri
+                JOIN order_history o ON o.id = ri.orderId
+                WHERE ri.productId = p.id
+                AND o.status IN ('PAID', 'SHIPPED', 'DELIVERED', 'COMPLETED')
+            ), 0) AS sold
+        FROM products p
+        LEFT JOIN product_prices pp ON pp.productId = p.id
+        LEFT JOIN product_ratings r ON r.productId = p.id
+        GROUP BY p.id, pp.price, pp.onSale
+    ) l`
+
+// priceRangeBounds split the price facet, the last range having no upper
+// bound
+var priceRangeBounds = []float64{0, 50, 100, 200, 500}
+
+var ratingFacetSteps = []float64{4, 3, 2, 1}
+
+// facet is a filter of the listing, counted under every filter but its own.
+type facet int
+
+const (
+	noFacet facet = iota
+	categoryFacet
+	priceFacet
+	ratingFacet
+	stockFacet
+	saleFacet
+)
+
+var listingFacets = []facet{categoryFacet, priceFacet, ratingFacet, stockFacet, saleFacet}
+
+// facetCondition is the condition filter sets on the listed products l for
+// f, TRUE when it sets none. A category takes in its whole subtree; UNION
+// rather than UNION ALL stops the walk should the categories ever loop.
+func facetCondition(filter types.ProductFilter, f facet) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+
+	switch f {
+	case categoryFacet:
+		if filter.CategoryID != nil {
+			conditions = append(conditions, `l.id IN (
+                SELECT pc.productId
+                FROM product_categories pc
+                WHERE pc.categoryId IN (
+                    WITH RECURSIVE subcategories (id) AS (
+                        SELECT id FROM categories WHERE id = ?
+                        UNION
+                        SELECT c.id FROM categories c JOIN subcategories s ON c.parentCategoryId = s.id
+                    )
+                    SELECT id FROM subcategories
+                )
+            )`)
+			args = append(args, *filter.CategoryID)
+		}
+	case priceFacet:
+		if filter.MinPrice != nil {
+			conditions = append(conditions, "l.price >= ?")
+			args = append(args, *filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			conditions = append(conditions, "l.price <= ?")
+			args = append(args, *filter.MaxPrice)
+		}
+	case ratingFacet:
+		if filter.MinRating != nil {
+			conditions = append(conditions, "l.rating >= ?")
+			args = append(args, *filter.MinRating)
+		}
+	case stockFacet:
+		if filter.InStock {
+			conditions = append(conditions, "l.available > 0")
+		}
+	case saleFacet:
+		if filter.OnSale {
+			conditions = append(conditions, "l.onSale")
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "TRUE", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// listingConditions joins the conditions of every facet but skip.
+func listingConditions(filter types.ProductFilter, skip facet) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	for _, f := range listingFacets {
+		if f == skip {
+			continue
+		}
+		condition, conditionArgs := facetCondition(filter, f)
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// listingOrder returns the ORDER BY of sortBy and the condition on l that
+// starts the page after cursor, if any. Products equal under sortBy, and all
+// of them when there is no sort, follow their IDs, so that every product has
+// a place a cursor can point at.
+func listingOrder(sortBy types.ProductSort, after *types.Cursor) (string, string, []any) {
+	column, direction := "", ""
+	switch sortBy {
+	case types.SortPriceAsc:
+		column = "l.price"
+	case types.SortPriceDesc:
+		column, direction = "l.price", " DESC"
+	case types.SortRating:
+		column, direction = "l.rating", " DESC"
+	case types.SortNewest:
+		column, direction = "l.createdAt", " DESC"
+	case types.SortBestSelling:
+		column, direction = "l.sold", " DESC"
+	}
+
+	if column == "" {
+		if after == nil {
+			return "l.id", "", nil
+		}
+		return "l.id", "l.id > ?", []any{after.ID}
+	}
+
+	order := column + direction + ", l.id"
+	if after == nil {
+		return order, "", nil
+	}
+
+	var value any = 0.0
+	if after.Value != nil {
+		value = *after.Value
+	}
+	if sortBy == types.SortNewest {
+		value = time.Time{}
+		if after.Time != nil {
+			value = *after.Time
+		}
+	}
+
+	operator := ">"
+	if direction != "" {
+		operator = "<"
+	}
+	where := fmt.Sprintf("(%s %s ? OR (%s = ? AND l.id > ?))", column, operator, column)
+	return order, where, []any{value, value, after.ID}
+}
+
+// listedProduct keeps the sort keys of a listed product for its cursor.
+type listedProduct struct {
+	*types.SimpleProductObject
+	createdAt time.Time
+	sold      int
+}
+
+func (p *listedProduct) cursor(sortBy types.ProductSort) types.Cursor {
+	cursor := types.Cursor{ID: p.ID}
+
+	var value float64
+	switch sortBy {
+	case types.SortPriceAsc, types.SortPriceDesc:
+		value = p.Price
+	case types.SortRating:
+		value = p.AverageRating
+	case types.SortBestSelling:
+		value = float64(p.sold)
+	case types.SortNewest:
+		cursor.Time = &p.createdAt
+		return cursor
+	default:
+		return cursor
+	}
+
+	cursor.Value = &value
+	return cursor
+}
+
+// ListProducts filters, sorts and pages the products in one query. The
+// products carry the stored price, which the pricing engine may revise.
+func (s *Store) ListProducts(userID int, filter types.ProductFilter, page types.PageRequest) (*types.Page[*types.SimpleProductObject], error) {
+	conditions, args := listingConditions(filter, noFacet)
+	order, after, afterArgs := listingOrder(filter.Sort, page.After)
+	if after != "" {
+		conditions += " AND " + after
+		args = append(args, afterArgs...)
+	}
+
+	query := `
+        SELECT
+            l.id,
+            l.title,
+            l.basePrice,
+            l.price,
+            l.onSale,
+            l.rating,
+            EXISTS(SELECT 1 FROM user_favorites uf WHERE uf.userId = ? AND uf.productId = l.id) AS is_favorite,
+            (SELECT imageUrl FROM product_images WHERE productId = l.id ORDER BY sortOrder LIMIT 1) AS main_image,
+            l.createdAt,
+            l.sold
+        FROM ` + listedProducts + `
+        WHERE ` + conditions + `
+        ORDER BY ` + order + `
+        LIMIT ?
+    `
+	args = append([]any{userID}, args...)
+	rows, err := s.db.Query(query, append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list products: %w", err)
+	}
+	defer rows.Close()
+
+	listed := []*listedProduct{}
+	for rows.Next() {
+		product := &listedProduct{SimpleProductObject: new(types.SimpleProductObject)}
+		var imageUrl sql.NullString
+
+		err := rows.Scan(
+			&product.ID,
+			&product.Title,
+			&product.BasePrice,
+			&product.Price,
+			&product.OnSale,
+			&product.AverageRating,
+			&product.IsFavorite,
+			&imageUrl,
+			&product.createdAt,
+			&product.sold,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan listed product: %w", err)
+		}
+
+		product.Image = types.ProductImage{ImageUrl: imageUrl.String}
+		listed = append(listed, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read listed products: %w", err)
+	}
+
+	listedPage := types.NewPage(listed, page.Limit, func(product *listedProduct) types.Cursor {
+		return product.cursor(filter.Sort)
+	})
+
+	products := &types.Page[*types.SimpleProductObject]{
+		Items:      make([]*types.SimpleProductObject, 0, len(listedPage.Items)),
+		NextCursor: listedPage.NextCursor,
+	}
+	for _, product := range listedPage.Items {
+		products.Items = append(products.Items, product.SimpleProductObject)
+	}
+
+	return products, nil
+}
+
+// GetProductFacets counts the products each filter option would list, in
+// three queries: one for the price, rating, stock and sale facets, one for
+// the categories, and the categories themselves.
+func (s *Store) GetProductFacets(filter types.ProductFilter) (*types.ProductFacets, error) {
+	facets := &types.ProductFacets{
+		Price:   &types.PriceFacet{Ranges: make([]*types.PriceRangeFacet, len(priceRangeBounds))},
+		Ratings: make([]*types.RatingFacet, len(ratingFacetSteps)),
+	}
+
+	// each product is flagged with the facets it passes, and every count
+	// asks for the flags of all the others
+	flags := []string{"inCategory", "inPrice", "inRating", "inStock", "inSale"}
+	flagConditions := []string{}
+	flagArgs := []any{}
+	for i, f := range listingFacets {
+		condition, args := facetCondition(filter, f)
+		flagConditions = append(flagConditions, "("+condition+") AS "+flags[i])
+		flagArgs = append(flagArgs, args...)
+	}
+	but := func(skip facet) string {
+		others := []string{}
+		for i, f := range listingFacets {
+			if f != skip {
+				others = append(others, "f."+flags[i])
+			}
+		}
+		return strings.Join(others, " AND ")
+	}
+
+	columns := []string{
+		"MIN(CASE WHEN " + but(priceFacet) + " THEN f.price END)",
+		"MAX(CASE WHEN " + but(priceFacet) + " THEN f.price END)",
+	}
+	args := []any{}
+	for i, min := range priceRangeBounds {
+		facets.Price.Ranges[i] = &types.PriceRangeFacet{Min: min}
+		if i+1 == len(priceRangeBounds) {
+			columns = append(columns, "COALESCE(SUM("+but(priceFacet)+" AND f.price >= ?), 0)")
+			args = append(args, min)
+			continue
+		}
+
+		max := priceRangeBounds[i+1]
+		facets.Price.Ranges[i].Max = &max
+		columns = append(columns, "COALESCE(SUM("+but(priceFacet)+" AND f.price >= ? AND f.price < ?), 0)")
+		args = append(args, min, max)
+	}
+	for i, step := range ratingFacetSteps {
+		facets.Ratings[i] = &types.RatingFacet{MinRating: step}
+		columns = append(columns, "COALESCE(SUM("+but(ratingFacet)+" AND f.rating >= ?), 0)")
+		args = append(args, step)
+	}
+	columns = append(columns,
+		"COALESCE(SUM("+but(stockFacet)+" AND f.available > 0), 0)",
+		"COALESCE(SUM("+but(saleFacet)+" AND f.onSale), 0)",
+	)
+
+	query := `
+        SELECT ` + strings.Join(columns, ",\n            ") + `
+        FROM (
+            SELECT l.price, l.rating, l.available, l.onSale,
+                ` + strings.Join(flagConditions, ",\n                ") + `
+            FROM ` + listedProducts + `
+        ) f
+    `
+	var minPrice, maxPrice sql.NullFloat64
+	dest := []any{&minPrice, &maxPrice}
+	for _, priceRange := range facets.Price.Ranges {
+		dest = append(dest, &priceRange.Count)
+	}
+	for _, rating := range facets.Ratings {
+		dest = append(dest, &rating.Count)
+	}
+	dest = append(dest, &facets.InStock, &facets.OnSale)
+
+	err := s.db.QueryRow(query, append(args, flagArgs...)...).Scan(dest...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count product facets: %w", err)
+	}
+	facets.Price.Min = minPrice.Float64
+	facets.Price.Max = maxPrice.Float64
+
+	categoryCounts, err := s.countProductsByCategory(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := s.getCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	facets.Categories = make([]*types.CategoryFacet, 0, len(categories))
+	for _, category := range categories {
+		facets.Categories = append(facets.Categories, &types.CategoryFacet{
+			ID:               category.ID,
+			Name:             category.Name,
+			ParentCategoryId: category.ParentCategoryId,
+			Count:            categoryCounts[category.ID],
+		})
+	}
+
+	return facets, nil
+}
+
+// countProductsByCategory counts the products listed under every filter but
+// the category in each category, counting those of its subcategories too.
+func (s *Store) countProductsByCategory(filter types.ProductFilter) (map[int]int, error) {
+	conditions, args := listingConditions(filter, categoryFacet)
+
+	rows, err := s.db.Query(`
+        WITH RECURSIVE category_ancestors (categoryId, ancestorId) AS (
+            SELECT id, id FROM categories
+            UNION
+            SELECT ca.categoryId, c.parentCategoryId
+            FROM category_ancestors ca
+            JOIN categories c ON c.id = ca.ancestorId
+            WHERE c.parentCategoryId IS NOT NULL
+        )
+        SELECT ca.ancestorId, COUNT(DISTINCT l.id)
+        FROM `+listedProducts+`
+        JOIN product_categories pc ON pc.productId = l.id
+        JOIN category_ancestors ca ON ca.categoryId = pc.categoryId
+        WHERE `+conditions+`
+        GROUP BY ca.ancestorId
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count products by category: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var categoryID, count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan category count: %w", err)
+		}
+		counts[categoryID] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// GetBasePrices returns the base prices of up to limit products after
+// afterID, in ID order.
+func (s *Store) GetBasePrices(afterID int, limit int) ([]*types.ProductPrice, error) {
+	rows, err := s.db.Query(`
+        SELECT id, basePrice
+        FROM products
+        WHERE id > ?
+        ORDER BY id
+        LIMIT ?
+    `, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query base prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []*types.ProductPrice{}
+	for rows.Next() {
+		price := new(types.ProductPrice)
+		if err := rows.Scan(&price.ProductID, &price.BasePrice); err != nil {
+			return nil, fmt.Errorf("failed to scan base price: %w", err)
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// GetBasePricesForProducts returns the base prices of the products of
+// productIDs that exist, in ID order.
+func (s *Store) GetBasePricesForProducts(productIDs []int) ([]*types.ProductPrice, error) {
+	prices := []*types.ProductPrice{}
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(productIDs)), ",")
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(`
+        SELECT id, basePrice
+        FROM products
+        WHERE id IN (`+placeholders+`)
+        ORDER BY id
+    `, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query base prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		price := new(types.ProductPrice)
+		if err := rows.Scan(&price.ProductID, &price.BasePrice); err != nil {
+			return nil, fmt.Errorf("failed to scan base price: %w", err)
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, rows.Err()
+}
+
+// SavePrices stores the prices the listing filters and sorts on.
+func (s *Store) SavePrices(prices []*types.ProductPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(prices))
+	args := make([]any, 0, len(prices)*3)
+	for _, price := range prices {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, price.ProductID, price.Price, price.OnSale)
+	}
+
+	_, err := s.db.Exec(`
+        INSERT INTO product_prices (productId, price, onSale)
+        VALUES `+strings.Join(values, ", ")+`
+        ON DUPLICATE KEY UPDATE price = VALUES(price), onSale = VALUES(onSale)
+    `, args...)
+	if err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) getCategories() ([]types.Category, error) {
+	rows, err := s.db.Query(`SELECT id, name, imageUrl, parentCategoryId FROM categories ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []types.Category{}
+	for rows.Next() {
+		var category types.Category
+		var imageUrl sql.NullString
+		if err := rows.Scan(&category.ID, &category.Name, &imageUrl, &category.ParentCategoryId); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		category.ImageUrl = imageUrl.String
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// searchTerms turns text into a boolean mode search where every word is
+// optional and also matches as a prefix, so "cami" finds "camiseta". The
+// operators of boolean mode are dropped from the words.
+func searchTerms(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		word = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`+-<>()~*"@`, r) {
+				return -1
+			}
+			return r
+		}, word)
+		if word != "" {
+			terms = append(terms, word+"*")
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+func scanSimpleProducts(rows *sql.Rows) (*[]*types.SimpleProductObject, error) {
+	products := []*types.SimpleProductObject{}
 	for rows.Next() {
 		var sp types.SimpleProductObject
-		var discount float64
-		var imageUrl string
+		var imageUrl sql.NullString
 
 		err := rows.Scan(
 			&sp.ID,
 			&sp.Title,
 			&sp.BasePrice,
-			&discount,
 			&sp.AverageRating,
 			&sp.IsFavorite,
 			&imageUrl,
@@ -531,12 +1255,12 @@ func (s *Store) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObje
 			return nil, fmt.Errorf("failed to scan simple product: %w", err)
 		}
 
-		// Calcular preço final
-		sp.Price = sp.BasePrice * (1 - discount/100)
+		// the price is left to the pricing engine
+		sp.Price = sp.BasePrice
 
 		// Construir objeto de imagem
 		sp.Image = types.ProductImage{
-			ImageUrl:  imageUrl,
+			ImageUrl:  imageUrl.String,
 			SortOrder: 0,
 		}
 
@@ -546,7 +1270,7 @@ func (s *Store) GetSimpleProductDetails(userID int) (*[]*types.SimpleProductObje
 	return &products, nil
 }
 
-func updateProductImages(tx *sql.Tx, productID int, images []types.ImageUpdatePayload) error {
+func updateProductImages(tx database.DBTX, productID int, images []types.ImageUpdatePayload) error {
 	// get current images
 	currentImages, err := getCurrentImages(tx, productID)
 	if err != nil {
@@ -599,7 +1323,7 @@ func updateProductImages(tx *sql.Tx, productID int, images []types.ImageUpdatePa
 	return nil
 }
 
-func getCurrentImages(tx *sql.Tx, productID int) (map[int]types.ProductImage, error) {
+func getCurrentImages(tx database.DBTX, productID int) (map[int]types.ProductImage, error) {
 	images := make(map[int]types.ProductImage)
 
 	rows, err := tx.Query("SELECT id, imageUrl, sortOrder FROM product_images WHERE productId = ?", productID)
@@ -620,7 +1344,7 @@ func getCurrentImages(tx *sql.Tx, productID int) (map[int]types.ProductImage, er
 	return images, nil
 }
 
-func deleteImages(tx *sql.Tx, ids []int) error {
+func deleteImages(tx database.DBTX, ids []int) error {
 	if len(ids) == 0 {
 		return nil
 	}
@@ -635,7 +1359,7 @@ func deleteImages(tx *sql.Tx, ids []int) error {
 	return err
 }
 
-func updateImages(tx *sql.Tx, images []types.ImageUpdatePayload) error {
+func updateImages(tx database.DBTX, images []types.ImageUpdatePayload) error {
 	for _, img := range images {
 		_, err := tx.Exec(
 			"UPDATE product_images SET imageUrl = ?, sortOrder = ? WHERE id = ?",
@@ -648,7 +1372,7 @@ func updateImages(tx *sql.Tx, images []types.ImageUpdatePayload) error {
 	return nil
 }
 
-func createImages(tx *sql.Tx, productID int, images []types.ImageUpdatePayload) error {
+func createImages(tx database.DBTX, productID int, images []types.ImageUpdatePayload) error {
 	for _, img := range images {
 		_, err := tx.Exec(
 			"INSERT INTO product_images (productId, imageUrl, sortOrder) VALUES (?, ?, ?)",
@@ -661,7 +1385,7 @@ func createImages(tx *sql.Tx, productID int, images []types.ImageUpdatePayload)
 	return nil
 }
 
-func updateProductDetails(tx *sql.Tx, productID int, payload types.UpdateProductPayload) error {
+func updateProductDetails(tx database.DBTX, productID int, payload types.UpdateProductPayload) error {
 	query := "UPDATE products SET"
 	args := make([]interface{}, 0)
 	updates := make([]string, 0)
@@ -702,6 +1426,8 @@ func scanRowsIntoProduct(rows *sql.Rows) (*types.Product, error) {
 		&product.UpdatedAt,
 		&product.Inventory.StockQuantity,
 		&product.Inventory.Version,
+		&product.Inventory.AvailableQuantity,
+		&product.Inventory.MaxPurchaseQuantity,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan product: %w", err)
@@ -101,8 +101,14 @@ func (h *Handler) HandleGetProductRatings(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	page, err := utils.ParsePageRequest(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	// get ratings
-	ratings, err := h.store.GetRatingsByProduct(productID)
+	ratings, err := h.store.GetRatingsByProduct(productID, page)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -3,6 +3,7 @@ package rating
 import (
 	"database/sql"
 	"fmt"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 )
 
@@ -39,21 +40,31 @@ func (s *Store) CreateRating(payload *types.CreateProductRatingPayload, userID i
 	return rating, nil
 }
 
-func (s *Store) GetRatingsByProduct(productID int) ([]*types.ProductRating, error) {
+func (s *Store) GetRatingsByProduct(productID int, page types.PageRequest) (*types.Page[*types.ProductRating], error) {
+	after, args := database.NewestFirstAfter(page.After, "createdAt", "id")
 	query := `
 		SELECT id, userId, productId, rating, comment, createdAt
 		FROM product_ratings
-		WHERE productId = ?
-		ORDER BY createdAt DESC
+		WHERE productId = ? AND ` + after + `
+		ORDER BY createdAt DESC, id DESC
+		LIMIT ?
 	`
 
-	rows, err := s.db.Query(query, productID)
+	args = append([]any{productID}, args...)
+	rows, err := s.db.Query(query, append(args, page.Limit+1)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	return scanRatings(rows)
+	ratings, err := scanRatings(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewPage(ratings, page.Limit, func(r *types.ProductRating) types.Cursor {
+		return types.Cursor{ID: r.ID, Time: &r.CreatedAt}
+	}), nil
 }
 
 func (s *Store) GetRatingsByUser(userID int) ([]*types.ProductRating, error) {
@@ -0,0 +1,144 @@
+package reservation
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) ReserveStock(cartID int, productID int, variantID int, quantity int, expiresAt time.Time) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		available, err := getAvailableStock(tx, productID, variantID, cartID)
+		if err != nil {
+			return err
+		}
+
+		var reserved int
+		err = tx.QueryRow(`
+			SELECT quantity
+			FROM inventory_reservations
+			WHERE cartId = ? AND productId = ? AND variantId = ? AND expiresAt > NOW()
+		`, cartID, productID, variantID).Scan(&reserved)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("[ReserveStock] error getting reservation of cart %d: %v", cartID, err)
+		}
+
+		if quantity > reserved && quantity > available {
+			return types.ErrInsufficientStock
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO inventory_reservations (cartId, productId, variantId, quantity, expiresAt)
+			VALUES (?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), expiresAt = VALUES(expiresAt)
+		`, cartID, productID, variantID, quantity, expiresAt)
+		if err != nil {
+			return fmt.Errorf("[ReserveStock] error reserving product %d for cart %d: %v", productID, cartID, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) ReleaseReservation(cartID int, productID int, variantID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM inventory_reservations
+		WHERE cartId = ? AND productId = ? AND variantId = ?
+	`, cartID, productID, variantID)
+	if err != nil {
+		return fmt.Errorf("[ReleaseReservation] error releasing product %d for cart %d: %v", productID, cartID, err)
+	}
+
+	return nil
+}
+
+func (s *Store) ReleaseCartReservations(cartID int) error {
+	_, err := s.db.Exec(`DELETE FROM inventory_reservations WHERE cartId = ?`, cartID)
+	if err != nil {
+		return fmt.Errorf("[ReleaseCartReservations] error releasing reservations of cart %d: %v", cartID, err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetAvailableStock(productID int, variantID int, exceptCartID int) (int, error) {
+	return getAvailableStock(s.db, productID, variantID, exceptCartID)
+}
+
+func (s *Store) DeleteExpiredReservations() (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM inventory_reservations WHERE expiresAt <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("[DeleteExpiredReservations] error deleting expired reservations: %v", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// getAvailableStock locks the inventory row of productID, or the row of the
+// variant, so inside a transaction no one else can reserve or sell its units
+// until it ends.
+func getAvailableStock(db database.DBTX, productID int, variantID int, exceptCartID int) (int, error) {
+	if variantID != 0 {
+		return getAvailableVariantStock(db, productID, variantID, exceptCartID)
+	}
+
+	var available int
+	err := db.QueryRow(`
+		SELECT i.stock_quantity - COALESCE((
+			SELECT SUM(r.quantity)
+			FROM inventory_reservations r
+			WHERE r.productId = i.product_id AND r.variantId = 0 AND r.cartId <> ? AND r.expiresAt > NOW()
+		), 0)
+		FROM inventory i
+		WHERE i.product_id = ?
+		FOR UPDATE
+	`, exceptCartID, productID).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("[GetAvailableStock] inventory not found for product ID %d", productID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[GetAvailableStock] error getting available stock of product %d: %v", productID, err)
+	}
+
+	return available, nil
+}
+
+func getAvailableVariantStock(db database.DBTX, productID int, variantID int, exceptCartID int) (int, error) {
+	var available int
+	err := db.QueryRow(`
+		SELECT v.stock_quantity - COALESCE((
+			SELECT SUM(r.quantity)
+			FROM inventory_reservations r
+			WHERE r.variantId = v.id AND r.cartId <> ? AND r.expiresAt > NOW()
+		), 0)
+		FROM product_variants v
+		WHERE v.id = ? AND v.productId = ? AND v.deletedAt IS NULL
+		FOR UPDATE
+	`, exceptCartID, variantID, productID).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, apperrors.NewEntityNotFound("variant", variantID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("[GetAvailableStock] error getting available stock of variant %d: %v", variantID, err)
+	}
+
+	return available, nil
+}
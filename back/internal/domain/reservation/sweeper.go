@@ -0,0 +1,51 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// Sweeper periodically deletes expired reservations. Expired rows are already
+// ignored when computing available stock, so the sweeper only keeps the table
+// small and a late sweep never oversells.
+type Sweeper struct {
+	store    types.ReservationStore
+	interval time.Duration
+}
+
+func NewSweeper(store types.ReservationStore, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		store:    store,
+		interval: interval,
+	}
+}
+
+// Run sweeps every interval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+func (s *Sweeper) Sweep() {
+	deleted, err := s.store.DeleteExpiredReservations()
+	if err != nil {
+		fmt.Printf("[RESERVATION SWEEPER] Error deleting expired reservations: %v\n", err)
+		return
+	}
+
+	if deleted > 0 {
+		fmt.Printf("[RESERVATION SWEEPER] Deleted %d expired reservations\n", deleted)
+	}
+}
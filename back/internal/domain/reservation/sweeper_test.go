@@ -0,0 +1,49 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type countingStore struct {
+	types.ReservationStore
+	sweeps chan struct{}
+}
+
+func (c *countingStore) DeleteExpiredReservations() (int64, error) {
+	select {
+	case c.sweeps <- struct{}{}:
+	default:
+	}
+	return 1, nil
+}
+
+func TestSweeperRunsUntilCancelled(t *testing.T) {
+	store := &countingStore{sweeps: make(chan struct{}, 10)}
+	sweeper := NewSweeper(store, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sweeper.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-store.sweeps:
+		case <-time.After(time.Second):
+			t.Fatal("sweeper did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop")
+	}
+}
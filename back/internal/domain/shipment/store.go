@@ -0,0 +1,155 @@
+package shipment
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) CreateShipment(shipment *types.Shipment) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		result, err := tx.Exec(`
+			INSERT INTO shipments (orderId, carrier, trackingCode, trackingUrl, status, shippedAt, createdBy)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, shipment.OrderID, shipment.Carrier, shipment.TrackingCode, shipment.TrackingURL,
+			shipment.Status, shipment.ShippedAt, shipment.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("[CreateShipment] error creating shipment: %v", err)
+		}
+
+		shipmentID, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateShipment] error getting shipment ID: %v", err)
+		}
+		shipment.ID = int(shipmentID)
+
+		for _, item := range shipment.Items {
+			item.ShipmentID = shipment.ID
+			_, err := tx.Exec(`
+				INSERT INTO shipment_items (shipmentId, orderId, productId, variantId, quantity)
+				VALUES (?, ?, ?, ?, ?)
+			`, item.ShipmentID, shipment.OrderID, item.ProductID, item.VariantID, item.Quantity)
+			if err != nil {
+				return fmt.Errorf("[CreateShipment] error adding shipment item: %v", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) GetShipment(orderID int, shipmentID int) (*types.Shipment, error) {
+	shipments, err := s.GetShipmentsByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, shipment := range shipments {
+		if shipment.ID == shipmentID {
+			return shipment, nil
+		}
+	}
+
+	return nil, apperrors.NewEntityNotFound("shipment", shipmentID)
+}
+
+func (s *Store) GetShipmentsByOrderID(orderID int) ([]*types.Shipment, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id, orderId, carrier, trackingCode, trackingUrl, status,
+			shippedAt, deliveredAt, createdBy, createdAt, updatedAt
+		FROM shipments
+		WHERE orderId = ?
+		ORDER BY shippedAt, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetShipmentsByOrderID] error getting shipments: %v", err)
+	}
+	defer rows.Close()
+
+	shipments := make([]*types.Shipment, 0)
+	byID := make(map[int]*types.Shipment)
+	for rows.Next() {
+		shipment := &types.Shipment{Items: []*types.ShipmentItem{}}
+		err := rows.Scan(
+			&shipment.ID,
+			&shipment.OrderID,
+			&shipment.Carrier,
+			&shipment.TrackingCode,
+			&shipment.TrackingURL,
+			&shipment.Status,
+			&shipment.ShippedAt,
+			&shipment.DeliveredAt,
+			&shipment.CreatedBy,
+			&shipment.CreatedAt,
+			&shipment.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[GetShipmentsByOrderID] error scanning shipment: %v", err)
+		}
+		shipments = append(shipments, shipment)
+		byID[shipment.ID] = shipment
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetShipmentsByOrderID] error reading shipments: %v", err)
+	}
+
+	if len(shipments) == 0 {
+		return shipments, nil
+	}
+
+	itemRows, err := s.db.Query(`
+		SELECT shipmentId, productId, variantId, quantity
+		FROM shipment_items
+		WHERE orderId = ?
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetShipmentsByOrderID] error getting shipment items: %v", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		item := new(types.ShipmentItem)
+		err := itemRows.Scan(&item.ShipmentID, &item.ProductID, &item.VariantID, &item.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("[GetShipmentsByOrderID] error scanning shipment item: %v", err)
+		}
+		if shipment, ok := byID[item.ShipmentID]; ok {
+			shipment.Items = append(shipment.Items, item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetShipmentsByOrderID] error reading shipment items: %v", err)
+	}
+
+	return shipments, nil
+}
+
+func (s *Store) UpdateShipment(shipment *types.Shipment) error {
+	_, err := s.db.Exec(`
+		UPDATE shipments
+		SET carrier = ?, trackingCode = ?, trackingUrl = ?, status = ?, deliveredAt = ?
+		WHERE id = ? AND orderId = ?
+	`, shipment.Carrier, shipment.TrackingCode, shipment.TrackingURL, shipment.Status, shipment.DeliveredAt,
+		shipment.ID, shipment.OrderID)
+	if err != nil {
+		return fmt.Errorf("[UpdateShipment] error updating shipment %d: %v", shipment.ID, err)
+	}
+	return nil
+}
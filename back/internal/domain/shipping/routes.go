@@ -0,0 +1,104 @@
+package shipping
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	shippingService types.ShippingService
+}
+
+func NewHandler(shippingService types.ShippingService) *Handler {
+	return &Handler{shippingService: shippingService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	// options need an address to ship to, so guest carts can't get them
+	authRouter := router.PathPrefix("").Subrouter()
+	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
+
+	authRouter.HandleFunc("/cart/shipping-options", h.getShippingOptions).Methods("GET")
+
+	adminRouter := router.PathPrefix("").Subrouter()
+	adminRouter.Use(
+		auth.WithJwtAuthMiddleware(userStore),
+		auth.WithAdminAuthMiddleware())
+
+	adminRouter.HandleFunc("/shipping/rates", h.getShippingRates).Methods("GET")
+	adminRouter.HandleFunc("/shipping/rates", h.createShippingRate).Methods("POST")
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	adminRouter.HandleFunc("/shipping/rates/{rateId}",
+		utils.Compose(h.deleteShippingRate, middleware.ErrorHandler)).Methods("DELETE")
+}
+
+func (h *Handler) getShippingOptions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.GetUserIDFromContext(r.Context())
+
+	addressID, err := strconv.Atoi(r.URL.Query().Get("addressId"))
+	if err != nil || addressID <= 0 {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "addressId must be the id of one of your addresses"})
+		return
+	}
+
+	options, err := h.shippingService.GetShippingOptions(userID, addressID)
+	if err != nil {
+		fmt.Printf("[SHIPPING HANDLER] ERROR getting shipping options for user %d: %v\n", userID, err)
+		utils.WriteServiceError(w, err, "Failed to get shipping options")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, options)
+}
+
+func (h *Handler) getShippingRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.shippingService.GetShippingRates()
+	if err != nil {
+		fmt.Printf("[SHIPPING HANDLER] ERROR getting shipping rates: %v\n", err)
+		utils.WriteServiceError(w, err, "Failed to get shipping rates")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, rates)
+}
+
+func (h *Handler) createShippingRate(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateShippingRatePayload
+	if err := utils.ParseJson(r, &payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return
+	}
+
+	rate, err := h.shippingService.CreateShippingRate(payload)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to create shipping rate")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, rate)
+}
+
+func (h *Handler) deleteShippingRate(w http.ResponseWriter, r *http.Request) {
+	rateID := utils.GetParamIdfromPath(r, "rateId")
+
+	if err := h.shippingService.DeleteShippingRate(rateID); err != nil {
+		fmt.Printf("[SHIPPING HANDLER] ERROR deleting shipping rate %d: %v\n", rateID, err)
+		utils.WriteServiceError(w, err, "Failed to delete shipping rate")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Shipping rate deleted successfully"})
+}
@@ -0,0 +1,125 @@
+package shipping
+
+import (
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// defaultDimensions stand in for a product whose dimensions were never set:
+// the smallest box the carriers take, holding a light item.
+var defaultDimensions = types.ProductDimensions{
+	WeightGrams: 300,
+	LengthCm:    16,
+	WidthCm:     11,
+	HeightCm:    6,
+}
+
+type Service struct {
+	calculator   types.ShippingCalculator
+	rateStore    types.ShippingRateStore
+	productStore types.ProductStore
+	addressStore types.AddressStore
+	cartService  types.CartService
+}
+
+func NewService(
+	calculator types.ShippingCalculator,
+	rateStore types.ShippingRateStore,
+	productStore types.ProductStore,
+	addressStore types.AddressStore,
+	cartService types.CartService,
+) *Service {
+	return &Service{
+		calculator:   calculator,
+		rateStore:    rateStore,
+		productStore: productStore,
+		addressStore: addressStore,
+		cartService:  cartService,
+	}
+}
+
+func (s *Service) GetShippingOptions(userID int, addressID int) ([]*types.ShippingOption, error) {
+	address, err := s.addressStore.GetAddress(userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartService.ValidateCart(types.UserCart(userID))
+	if err != nil {
+		fmt.Printf("[SHIPPING SERVICE] Error validating cart of user %d: %v\n", userID, err)
+		return nil, fmt.Errorf("error validating cart: %w", err)
+	}
+
+	return s.QuoteCart(address.ShippingAddress(), cart)
+}
+
+func (s *Service) QuoteCart(destination *types.ShippingAddress, cart *types.CartValidation) ([]*types.ShippingOption, error) {
+	if len(cart.Items) == 0 {
+		return nil, apperrors.NewValidationError("cart", "cart is empty")
+	}
+
+	// variants of a product ship in the same package, so their units add up
+	quantities := make(map[int]int)
+	productIDs := make([]int, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	dimensions, err := s.productStore.GetDimensionsForProducts(productIDs)
+	if err != nil {
+		fmt.Printf("[SHIPPING SERVICE] Error getting product dimensions: %v\n", err)
+		return nil, fmt.Errorf("error getting product dimensions: %w", err)
+	}
+
+	parcels := make([]*types.Parcel, 0, len(productIDs))
+	for _, productID := range productIDs {
+		parcel := &types.Parcel{Dimensions: defaultDimensions, Quantity: quantities[productID]}
+		if d, ok := dimensions[productID]; ok {
+			parcel.Dimensions = *d
+		}
+		parcels = append(parcels, parcel)
+	}
+
+	return s.calculator.Quote(&types.ShippingQuoteRequest{
+		Destination: destination,
+		Parcels:     parcels,
+		Subtotal:    cart.Total,
+	})
+}
+
+func (s *Service) GetShippingRates() ([]*types.ShippingRate, error) {
+	return s.rateStore.GetShippingRates()
+}
+
+func (s *Service) CreateShippingRate(payload types.CreateShippingRatePayload) (*types.ShippingRate, error) {
+	if payload.State != nil && payload.PostalCodeFrom != nil {
+		return nil, apperrors.NewValidationError("state", "a rate covers either a state or a range of CEPs")
+	}
+
+	if payload.PostalCodeFrom != nil && *payload.PostalCodeFrom > *payload.PostalCodeTo {
+		return nil, apperrors.NewValidationError("postalCodeTo", "the range of CEPs ends before it starts")
+	}
+
+	rate, err := s.rateStore.CreateShippingRate(payload)
+	if err != nil {
+		fmt.Printf("[SHIPPING SERVICE] Error creating shipping rate: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Printf("[SHIPPING SERVICE] Created shipping rate %d for service %s\n", rate.ID, rate.Service)
+	return rate, nil
+}
+
+func (s *Service) DeleteShippingRate(rateID int) error {
+	if err := s.rateStore.DeleteShippingRate(rateID); err != nil {
+		return err
+	}
+
+	fmt.Printf("[SHIPPING SERVICE] Deleted shipping rate %d\n", rateID)
+	return nil
+}
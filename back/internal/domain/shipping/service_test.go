@@ -0,0 +1,137 @@
+package shipping
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCalculator struct {
+	mock.Mock
+}
+
+func (m *MockCalculator) Quote(req *types.ShippingQuoteRequest) ([]*types.ShippingOption, error) {
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ShippingOption), args.Error(1)
+}
+
+type MockProductStore struct {
+	types.ProductStore
+	mock.Mock
+}
+
+func (m *MockProductStore) GetDimensionsForProducts(productIDs []int) (map[int]*types.ProductDimensions, error) {
+	args := m.Called(productIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*types.ProductDimensions), args.Error(1)
+}
+
+type MockShippingRateStore struct {
+	types.ShippingRateStore
+	mock.Mock
+}
+
+func (m *MockShippingRateStore) CreateShippingRate(payload types.CreateShippingRatePayload) (*types.ShippingRate, error) {
+	args := m.Called(payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ShippingRate), args.Error(1)
+}
+
+func TestQuoteCart(t *testing.T) {
+	destination := &types.ShippingAddress{State: "SP", PostalCode: "01310-100"}
+	boxed := &types.ProductDimensions{WeightGrams: 800, LengthCm: 30, WidthCm: 20, HeightCm: 10}
+	options := []*types.ShippingOption{{ID: "PAC", Name: "PAC", Price: 12, MinDays: 2, MaxDays: 4}}
+
+	t.Run("Variants of a product ship together", func(t *testing.T) {
+		productStore := new(MockProductStore)
+		// product 2 has no dimensions set
+		productStore.On("GetDimensionsForProducts", []int{1, 2}).Return(map[int]*types.ProductDimensions{1: boxed}, nil)
+		calculator := new(MockCalculator)
+		calculator.On("Quote", &types.ShippingQuoteRequest{
+			Destination: destination,
+			Parcels: []*types.Parcel{
+				{Dimensions: *boxed, Quantity: 3},
+				{Dimensions: defaultDimensions, Quantity: 1},
+			},
+			Subtotal: 90,
+		}).Return(options, nil)
+
+		service := NewService(calculator, nil, productStore, nil, nil)
+		quoted, err := service.QuoteCart(destination, &types.CartValidation{
+			Items: []*types.CartItemValidation{
+				{ProductID: 1, VariantID: 10, Quantity: 1},
+				{ProductID: 2, Quantity: 1},
+				{ProductID: 1, VariantID: 11, Quantity: 2},
+			},
+			Subtotal: 100,
+			Total:    90,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, options, quoted)
+		calculator.AssertExpectations(t)
+	})
+
+	t.Run("Empty cart", func(t *testing.T) {
+		service := NewService(new(MockCalculator), nil, new(MockProductStore), nil, nil)
+		quoted, err := service.QuoteCart(destination, &types.CartValidation{})
+
+		assert.Equal(t, apperrors.NewValidationError("cart", "cart is empty"), err)
+		assert.Nil(t, quoted)
+	})
+}
+
+func TestCreateShippingRate(t *testing.T) {
+	tests := []struct {
+		name          string
+		payload       types.CreateShippingRatePayload
+		expectedError error
+	}{
+		{
+			name:    "Rate for a state",
+			payload: types.CreateShippingRatePayload{Service: "PAC", State: ptr("SP")},
+		},
+		{
+			name:    "Rate for a range of CEPs",
+			payload: types.CreateShippingRatePayload{Service: "PAC", PostalCodeFrom: ptr("01000000"), PostalCodeTo: ptr("05999999")},
+		},
+		{
+			name:          "State and range of CEPs",
+			payload:       types.CreateShippingRatePayload{Service: "PAC", State: ptr("SP"), PostalCodeFrom: ptr("01000000"), PostalCodeTo: ptr("05999999")},
+			expectedError: apperrors.NewValidationError("state", "a rate covers either a state or a range of CEPs"),
+		},
+		{
+			name:          "Range ending before it starts",
+			payload:       types.CreateShippingRatePayload{Service: "PAC", PostalCodeFrom: ptr("05999999"), PostalCodeTo: ptr("01000000")},
+			expectedError: apperrors.NewValidationError("postalCodeTo", "the range of CEPs ends before it starts"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockShippingRateStore)
+			store.On("CreateShippingRate", tt.payload).Return(&types.ShippingRate{ID: 1, Service: tt.payload.Service}, nil)
+
+			rate, err := NewService(nil, store, nil, nil, nil).CreateShippingRate(tt.payload)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, rate)
+				store.AssertNotCalled(t, "CreateShippingRate", tt.payload)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, rate.ID)
+			}
+		})
+	}
+}
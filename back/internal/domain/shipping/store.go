@@ -0,0 +1,111 @@
+package shipping
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetShippingRates() ([]*types.ShippingRate, error) {
+	return s.getShippingRates(`1 = 1`)
+}
+
+func (s *Store) CreateShippingRate(payload types.CreateShippingRatePayload) (*types.ShippingRate, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO shipping_rates (
+			service, serviceName, state, postalCodeFrom, postalCodeTo,
+			maxWeightGrams, price, minDays, maxDays, freeAbove
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, payload.Service, payload.ServiceName, payload.State, payload.PostalCodeFrom, payload.PostalCodeTo,
+		payload.MaxWeightGrams, payload.Price, payload.MinDays, payload.MaxDays, payload.FreeAbove)
+	if err != nil {
+		return nil, fmt.Errorf("[CreateShippingRate] error inserting shipping rate: %v", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("[CreateShippingRate] error getting shipping rate ID: %v", err)
+	}
+
+	rates, err := s.getShippingRates(`id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, apperrors.NewEntityNotFound("shipping rate", int(id))
+	}
+
+	return rates[0], nil
+}
+
+func (s *Store) DeleteShippingRate(rateID int) error {
+	res, err := s.db.Exec(`DELETE FROM shipping_rates WHERE id = ?`, rateID)
+	if err != nil {
+		return fmt.Errorf("[DeleteShippingRate] error deleting shipping rate %d: %v", rateID, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("[DeleteShippingRate] error checking rows affected: %v", err)
+	}
+	if affected == 0 {
+		return apperrors.NewEntityNotFound("shipping rate", rateID)
+	}
+
+	return nil
+}
+
+func (s *Store) getShippingRates(where string, args ...any) ([]*types.ShippingRate, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			id, service, serviceName, state, postalCodeFrom, postalCodeTo,
+			maxWeightGrams, price, minDays, maxDays, freeAbove, createdAt
+		FROM shipping_rates
+		WHERE `+where+`
+		ORDER BY service, maxWeightGrams, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[GetShippingRates] error getting shipping rates: %v", err)
+	}
+	defer rows.Close()
+
+	rates := make([]*types.ShippingRate, 0)
+	for rows.Next() {
+		rate := new(types.ShippingRate)
+		err := rows.Scan(
+			&rate.ID,
+			&rate.Service,
+			&rate.ServiceName,
+			&rate.State,
+			&rate.PostalCodeFrom,
+			&rate.PostalCodeTo,
+			&rate.MaxWeightGrams,
+			&rate.Price,
+			&rate.MinDays,
+			&rate.MaxDays,
+			&rate.FreeAbove,
+			&rate.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("[GetShippingRates] error scanning shipping rate: %v", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetShippingRates] error reading shipping rates: %v", err)
+	}
+
+	return rates, nil
+}
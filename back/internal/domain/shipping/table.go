@@ -0,0 +1,155 @@
+// Package shipping prices the delivery of a cart and manages the rate table
+// it is priced from.
+package shipping
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// volumetricDivisor turns the cubic centimeters of a package into grams.
+// Carriers bill a light but bulky package by the room it takes, at 6000 cm³
+// per kilogram.
+const volumetricDivisor = 6.0
+
+// Zones a rate can cover, from the least to the most specific.
+const (
+	zoneAnywhere = iota
+	zoneState
+	zonePostalCodeRange
+)
+
+// TableRateCalculator prices shipping from the rates of a ShippingRateStore.
+// For each service it takes the rates of the most specific zone holding the
+// destination, and among them the lightest bracket the parcels fit in.
+type TableRateCalculator struct {
+	store types.ShippingRateStore
+}
+
+func NewTableRateCalculator(store types.ShippingRateStore) *TableRateCalculator {
+	return &TableRateCalculator{store: store}
+}
+
+func (c *TableRateCalculator) Quote(req *types.ShippingQuoteRequest) ([]*types.ShippingOption, error) {
+	rates, err := c.store.GetShippingRates()
+	if err != nil {
+		return nil, fmt.Errorf("error getting shipping rates: %w", err)
+	}
+
+	state := strings.ToUpper(strings.TrimSpace(req.Destination.State))
+	postalCode := digits(req.Destination.PostalCode)
+
+	zones := make(map[string]int)
+	candidates := make(map[string][]*types.ShippingRate)
+	for _, rate := range rates {
+		zone, ok := matchZone(rate, state, postalCode)
+		if !ok {
+			continue
+		}
+
+		current, seen := zones[rate.Service]
+		switch {
+		case !seen || zone > current:
+			zones[rate.Service] = zone
+			candidates[rate.Service] = []*types.ShippingRate{rate}
+		case zone == current:
+			candidates[rate.Service] = append(candidates[rate.Service], rate)
+		}
+	}
+
+	weight := BillableWeight(req.Parcels)
+
+	options := make([]*types.ShippingOption, 0, len(candidates))
+	for _, serviceRates := range candidates {
+		rate := lightestFitting(serviceRates, weight)
+		if rate == nil {
+			continue
+		}
+		options = append(options, newOption(rate, req.Subtotal))
+	}
+
+	sort.Slice(options, func(i, j int) bool {
+		if options[i].Price != options[j].Price {
+			return options[i].Price < options[j].Price
+		}
+		if options[i].MaxDays != options[j].MaxDays {
+			return options[i].MaxDays < options[j].MaxDays
+		}
+		return options[i].ID < options[j].ID
+	})
+
+	return options, nil
+}
+
+// BillableWeight adds up the parcels in grams, each package weighing the
+// larger of its weight and its volumetric weight.
+func BillableWeight(parcels []*types.Parcel) int {
+	total := 0.0
+	for _, parcel := range parcels {
+		d := parcel.Dimensions
+		volumetric := d.LengthCm * d.WidthCm * d.HeightCm / volumetricDivisor
+		total += math.Max(float64(d.WeightGrams), volumetric) * float64(parcel.Quantity)
+	}
+	return int(math.Ceil(total))
+}
+
+// matchZone tells whether rate covers the destination and how specifically.
+func matchZone(rate *types.ShippingRate, state string, postalCode string) (int, bool) {
+	switch {
+	case rate.PostalCodeFrom != nil && rate.PostalCodeTo != nil:
+		// CEPs of the same length compare in order as strings
+		in := len(postalCode) == 8 && *rate.PostalCodeFrom <= postalCode && postalCode <= *rate.PostalCodeTo
+		return zonePostalCodeRange, in
+	case rate.State != nil:
+		return zoneState, strings.EqualFold(*rate.State, state)
+	default:
+		return zoneAnywhere, true
+	}
+}
+
+// lightestFitting is the rate of the lowest weight bracket that takes weight,
+// nil when the parcels are too heavy for every bracket.
+func lightestFitting(rates []*types.ShippingRate, weight int) *types.ShippingRate {
+	var best *types.ShippingRate
+	for _, rate := range rates {
+		if rate.MaxWeightGrams < weight {
+			continue
+		}
+		if best == nil || rate.MaxWeightGrams < best.MaxWeightGrams {
+			best = rate
+		}
+	}
+	return best
+}
+
+func newOption(rate *types.ShippingRate, subtotal float64) *types.ShippingOption {
+	option := &types.ShippingOption{
+		ID:      rate.Service,
+		Name:    rate.ServiceName,
+		Price:   rate.Price,
+		MinDays: rate.MinDays,
+		MaxDays: rate.MaxDays,
+	}
+
+	if rate.FreeAbove != nil && subtotal >= *rate.FreeAbove {
+		option.Price = 0
+		option.Free = true
+	}
+
+	return option
+}
+
+// digits keeps the digits of a CEP, so 01310-100 reads 01310100.
+func digits(postalCode string) string {
+	var b strings.Builder
+	for _, r := range postalCode {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package shipping
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRateStore struct {
+	types.ShippingRateStore
+	rates []*types.ShippingRate
+}
+
+func (f *fakeRateStore) GetShippingRates() ([]*types.ShippingRate, error) {
+	return f.rates, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testRates ship PAC anywhere, cheaper within the state of São Paulo and
+// cheaper still to the city, where it is free from 200. SEDEX only ships
+// within the state.
+var testRates = []*types.ShippingRate{
+	{ID: 1, Service: "PAC", ServiceName: "PAC", MaxWeightGrams: 1000, Price: 30, MinDays: 6, MaxDays: 12},
+	{ID: 2, Service: "PAC", ServiceName: "PAC", MaxWeightGrams: 5000, Price: 45, MinDays: 6, MaxDays: 12},
+	{ID: 3, Service: "PAC", ServiceName: "PAC", State: ptr("SP"), MaxWeightGrams: 1000, Price: 20, MinDays: 4, MaxDays: 8},
+	{ID: 4, Service: "PAC", ServiceName: "PAC", State: ptr("SP"), MaxWeightGrams: 5000, Price: 28, MinDays: 4, MaxDays: 8},
+	{ID: 5, Service: "PAC", ServiceName: "PAC", PostalCodeFrom: ptr("01000000"), PostalCodeTo: ptr("05999999"),
+		MaxWeightGrams: 5000, Price: 12, MinDays: 2, MaxDays: 4, FreeAbove: ptr(200.0)},
+	{ID: 6, Service: "SEDEX", ServiceName: "SEDEX", State: ptr("SP"), MaxWeightGrams: 2000, Price: 25, MinDays: 1, MaxDays: 2},
+}
+
+func TestTableRateCalculatorQuote(t *testing.T) {
+	small := []*types.Parcel{{Dimensions: types.ProductDimensions{WeightGrams: 400, LengthCm: 16, WidthCm: 11, HeightCm: 6}, Quantity: 2}}
+	heavy := []*types.Parcel{{Dimensions: types.ProductDimensions{WeightGrams: 1500, LengthCm: 16, WidthCm: 11, HeightCm: 6}, Quantity: 2}}
+	// 40x30x30 cm weighs 6 kg by volume, more than any bracket
+	bulky := []*types.Parcel{{Dimensions: types.ProductDimensions{WeightGrams: 500, LengthCm: 40, WidthCm: 30, HeightCm: 30}, Quantity: 1}}
+
+	tests := []struct {
+		name        string
+		destination *types.ShippingAddress
+		parcels     []*types.Parcel
+		subtotal    float64
+		expected    []*types.ShippingOption
+	}{
+		{
+			name:        "CEP range beats the state",
+			destination: &types.ShippingAddress{State: "SP", PostalCode: "01310-100"},
+			parcels:     small,
+			subtotal:    100,
+			expected: []*types.ShippingOption{
+				{ID: "PAC", Name: "PAC", Price: 12, MinDays: 2, MaxDays: 4},
+				{ID: "SEDEX", Name: "SEDEX", Price: 25, MinDays: 1, MaxDays: 2},
+			},
+		},
+		{
+			name:        "Free above the threshold",
+			destination: &types.ShippingAddress{State: "SP", PostalCode: "01310100"},
+			parcels:     small,
+			subtotal:    200,
+			expected: []*types.ShippingOption{
+				{ID: "PAC", Name: "PAC", Price: 0, MinDays: 2, MaxDays: 4, Free: true},
+				{ID: "SEDEX", Name: "SEDEX", Price: 25, MinDays: 1, MaxDays: 2},
+			},
+		},
+		{
+			name:        "State outside the CEP range",
+			destination: &types.ShippingAddress{State: "sp", PostalCode: "13010-000"},
+			parcels:     small,
+			subtotal:    100,
+			expected: []*types.ShippingOption{
+				{ID: "PAC", Name: "PAC", Price: 20, MinDays: 4, MaxDays: 8},
+				{ID: "SEDEX", Name: "SEDEX", Price: 25, MinDays: 1, MaxDays: 2},
+			},
+		},
+		{
+			name:        "Anywhere else",
+			destination: &types.ShippingAddress{State: "RJ", PostalCode: "20040-020"},
+			parcels:     small,
+			subtotal:    100,
+			expected: []*types.ShippingOption{
+				{ID: "PAC", Name: "PAC", Price: 30, MinDays: 6, MaxDays: 12},
+			},
+		},
+		{
+			name:        "Heavier bracket, too heavy for SEDEX",
+			destination: &types.ShippingAddress{State: "SP", PostalCode: "13010-000"},
+			parcels:     heavy,
+			subtotal:    100,
+			expected: []*types.ShippingOption{
+				{ID: "PAC", Name: "PAC", Price: 28, MinDays: 4, MaxDays: 8},
+			},
+		},
+		{
+			name:        "Too bulky for every bracket",
+			destination: &types.ShippingAddress{State: "RJ", PostalCode: "20040-020"},
+			parcels:     bulky,
+			subtotal:    100,
+			expected:    []*types.ShippingOption{},
+		},
+	}
+
+	calculator := NewTableRateCalculator(&fakeRateStore{rates: testRates})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := calculator.Quote(&types.ShippingQuoteRequest{
+				Destination: tt.destination,
+				Parcels:     tt.parcels,
+				Subtotal:    tt.subtotal,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, options)
+		})
+	}
+}
+
+func TestBillableWeight(t *testing.T) {
+	parcels := []*types.Parcel{
+		// 16x11x6 cm weighs 176 g by volume, less than the item
+		{Dimensions: types.ProductDimensions{WeightGrams: 300, LengthCm: 16, WidthCm: 11, HeightCm: 6}, Quantity: 2},
+		// 30x20x10 cm weighs 1000 g by volume, more than the item
+		{Dimensions: types.ProductDimensions{WeightGrams: 200, LengthCm: 30, WidthCm: 20, HeightCm: 10}, Quantity: 1},
+	}
+
+	assert.Equal(t, 1600, BillableWeight(parcels))
+}
@@ -14,11 +14,12 @@ import (
 )
 
 type Handler struct {
-	store types.UserStore
+	store       types.UserStore
+	cartService types.CartService
 }
 
-func NewHandler(store types.UserStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store types.UserStore, cartService types.CartService) *Handler {
+	return &Handler{store: store, cartService: cartService}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
@@ -57,7 +58,12 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteJson(w, http.StatusOK, map[string]string{"token": token})
+	response := map[string]interface{}{"token": token}
+	if merge := h.mergeGuestCart(r, u.ID); merge != nil {
+		response["cartMerge"] = merge
+	}
+
+	utils.WriteJson(w, http.StatusOK, response)
 }
 
 func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
@@ -103,7 +109,17 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteJson(w, http.StatusCreated, user.Sanitize())
+	response := user.Sanitize()
+	if r.Header.Get(types.GuestCartHeader) != "" {
+		created, err := h.store.GetUserByEmail(user.Email)
+		if err != nil {
+			fmt.Printf("[USER HANDLER] ERROR getting created user to merge the guest cart: %v\n", err)
+		} else if merge := h.mergeGuestCart(r, created.ID); merge != nil {
+			response["cartMerge"] = merge
+		}
+	}
+
+	utils.WriteJson(w, http.StatusCreated, response)
 }
 
 func (h *Handler) HandleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
@@ -126,3 +142,21 @@ func (h *Handler) HandleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteJson(w, http.StatusOK, userDTO)
 }
+
+// mergeGuestCart folds the guest cart sent along with the request into the
+// cart of userID. Signing in must not fail because of the cart, so errors are
+// only logged and the guest cart is left as it was.
+func (h *Handler) mergeGuestCart(r *http.Request, userID int) *types.CartMerge {
+	token := r.Header.Get(types.GuestCartHeader)
+	if token == "" {
+		return nil
+	}
+
+	merge, err := h.cartService.MergeGuestCart(token, userID)
+	if err != nil {
+		fmt.Printf("[USER HANDLER] ERROR merging guest cart of user %d: %v\n", userID, err)
+		return nil
+	}
+
+	return merge
+}
@@ -60,6 +60,19 @@ func (m *MockUserStore) CreateUser(user types.User) error {
 	return args.Error(0)
 }
 
+type MockCartService struct {
+	types.CartService
+	mock.Mock
+}
+
+func (m *MockCartService) MergeGuestCart(token string, userID int) (*types.CartMerge, error) {
+	args := m.Called(token, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.CartMerge), args.Error(1)
+}
+
 // Helper function to create a JWT token for tests
 func createTestToken(userID int, role types.UserRole) string {
 	// Test key
@@ -139,7 +152,7 @@ func TestHandleLogin(t *testing.T) {
 			mockStore := new(MockUserStore)
 			tt.setupMock(mockStore)
 
-			handler := NewHandler(mockStore)
+			handler := NewHandler(mockStore, new(MockCartService))
 
 			// Create request
 			payloadBytes, _ := json.Marshal(tt.payload)
@@ -224,7 +237,7 @@ func TestHandleRegister(t *testing.T) {
 			mockStore := new(MockUserStore)
 			tt.setupMock(mockStore)
 
-			handler := NewHandler(mockStore)
+			handler := NewHandler(mockStore, new(MockCartService))
 
 			// Create request
 			payloadBytes, _ := json.Marshal(tt.payload)
@@ -245,6 +258,92 @@ func TestHandleRegister(t *testing.T) {
 	}
 }
 
+func TestGuestCartMergedOnSignIn(t *testing.T) {
+	hashedPassword, _ := auth.HashPassword("password123")
+	user := &types.User{ID: 7, Email: "test@email.com", Password: hashedPassword, Role: types.RoleUser}
+	merge := &types.CartMerge{
+		MergedItems: 2,
+		Adjustments: []*types.CartMergeAdjustment{{ProductID: 3, Requested: 4, Quantity: 2, Reason: "out of stock"}},
+	}
+
+	t.Run("Login reports the merge", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockCarts := new(MockCartService)
+		mockStore.On("GetUserByEmail", "test@email.com").Return(user, nil)
+		mockCarts.On("MergeGuestCart", "guest-token", 7).Return(merge, nil)
+
+		payload, _ := json.Marshal(types.LoginUserPayload{Email: "test@email.com", Password: "password123"})
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(payload))
+		req.Header.Set(types.GuestCartHeader, "guest-token")
+		rr := httptest.NewRecorder()
+
+		NewHandler(mockStore, mockCarts).HandleLogin(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response struct {
+			Token     string          `json:"token"`
+			CartMerge types.CartMerge `json:"cartMerge"`
+		}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Token)
+		assert.Equal(t, *merge, response.CartMerge)
+		mockCarts.AssertExpectations(t)
+	})
+
+	t.Run("Login succeeds when the merge fails", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockCarts := new(MockCartService)
+		mockStore.On("GetUserByEmail", "test@email.com").Return(user, nil)
+		mockCarts.On("MergeGuestCart", "expired-token", 7).Return(nil, fmt.Errorf("cart not found"))
+
+		payload, _ := json.Marshal(types.LoginUserPayload{Email: "test@email.com", Password: "password123"})
+		req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(payload))
+		req.Header.Set(types.GuestCartHeader, "expired-token")
+		rr := httptest.NewRecorder()
+
+		NewHandler(mockStore, mockCarts).HandleLogin(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.NotContains(t, response, "cartMerge")
+		mockCarts.AssertExpectations(t)
+	})
+
+	t.Run("Register merges into the new user's cart", func(t *testing.T) {
+		mockStore := new(MockUserStore)
+		mockCarts := new(MockCartService)
+		mockStore.On("GetUserByEmail", "new@email.com").Return(nil, fmt.Errorf("user not found")).Once()
+		mockStore.On("GetUserByCPF", "12345678901").Return(nil, fmt.Errorf("user not found"))
+		mockStore.On("CreateUser", mock.AnythingOfType("types.User")).Return(nil)
+		mockStore.On("GetUserByEmail", "new@email.com").Return(&types.User{ID: 8, Email: "new@email.com"}, nil).Once()
+		mockCarts.On("MergeGuestCart", "guest-token", 8).Return(merge, nil)
+
+		payload, _ := json.Marshal(types.RegisterUserPayload{
+			FullName: "Test User",
+			Email:    "new@email.com",
+			Cpf:      "12345678901",
+			Password: "password123",
+		})
+		req, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(payload))
+		req.Header.Set(types.GuestCartHeader, "guest-token")
+		rr := httptest.NewRecorder()
+
+		NewHandler(mockStore, mockCarts).HandleRegister(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var response struct {
+			Email     string          `json:"email"`
+			CartMerge types.CartMerge `json:"cartMerge"`
+		}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, "new@email.com", response.Email)
+		assert.Equal(t, *merge, response.CartMerge)
+		mockStore.AssertExpectations(t)
+		mockCarts.AssertExpectations(t)
+	})
+}
+
 // Testable handler that allows injection of the ID retrieval function
 type TestableHandler struct {
 	store                types.UserStore
@@ -362,7 +461,7 @@ func TestHandleGetCurrentUser(t *testing.T) {
 
 func TestRegisterRoutes(t *testing.T) {
 	mockStore := new(MockUserStore)
-	handler := NewHandler(mockStore)
+	handler := NewHandler(mockStore, new(MockCartService))
 
 	router := mux.NewRouter()
 	handler.RegisterRoutes(router)
@@ -0,0 +1,154 @@
+package variant
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/middleware/auth"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/utils"
+)
+
+type Handler struct {
+	variantService types.VariantService
+}
+
+func NewHandler(variantService types.VariantService) *Handler {
+	return &Handler{variantService: variantService}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router, userStore types.UserStore) {
+	authRouter := router.PathPrefix("").Subrouter()
+	authRouter.Use(auth.WithJwtAuthMiddleware(userStore))
+
+	// GetParamIdfromPath panics on a malformed id, which ErrorHandler turns into a 400
+	authRouter.HandleFunc("/product/{productID}/variants",
+		utils.Compose(h.getProductVariants, middleware.ErrorHandler)).Methods("GET")
+
+	adminRouter := router.PathPrefix("").Subrouter()
+	adminRouter.Use(
+		auth.WithJwtAuthMiddleware(userStore),
+		auth.WithAdminAuthMiddleware())
+
+	adminRouter.HandleFunc("/product/{productID}/options",
+		utils.Compose(h.createOption, middleware.ErrorHandler)).Methods("POST")
+	adminRouter.HandleFunc("/product/{productID}/options/{optionID}",
+		utils.Compose(h.deleteOption, middleware.ErrorHandler)).Methods("DELETE")
+	adminRouter.HandleFunc("/product/{productID}/variants",
+		utils.Compose(h.createVariant, middleware.ErrorHandler)).Methods("POST")
+	adminRouter.HandleFunc("/product/{productID}/variants/{variantID}",
+		utils.Compose(h.updateVariant, middleware.ErrorHandler)).Methods("PUT")
+	adminRouter.HandleFunc("/product/{productID}/variants/{variantID}",
+		utils.Compose(h.deleteVariant, middleware.ErrorHandler)).Methods("DELETE")
+}
+
+func (h *Handler) getProductVariants(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+
+	variants, err := h.variantService.GetProductVariants(productID)
+	if err != nil {
+		fmt.Printf("[VARIANT HANDLER] ERROR getting variants of product %d: %v\n", productID, err)
+		utils.WriteServiceError(w, err, "Failed to get variants")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, variants)
+}
+
+func (h *Handler) createOption(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+
+	var payload types.CreateProductOptionPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	option, err := h.variantService.CreateOption(productID, payload)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to create option")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, option)
+}
+
+func (h *Handler) deleteOption(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+	optionID := utils.GetParamIdfromPath(r, "optionID")
+
+	if err := h.variantService.DeleteOption(productID, optionID); err != nil {
+		fmt.Printf("[VARIANT HANDLER] ERROR deleting option %d: %v\n", optionID, err)
+		utils.WriteServiceError(w, err, "Failed to delete option")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Option deleted successfully"})
+}
+
+func (h *Handler) createVariant(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+
+	var payload types.CreateVariantPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	variant, err := h.variantService.CreateVariant(productID, payload)
+	if err != nil {
+		utils.WriteServiceError(w, err, "Failed to create variant")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, variant)
+}
+
+func (h *Handler) updateVariant(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+	variantID := utils.GetParamIdfromPath(r, "variantID")
+
+	var payload types.UpdateVariantPayload
+	if !parsePayload(w, r, &payload) {
+		return
+	}
+
+	variant, err := h.variantService.UpdateVariant(productID, variantID, payload)
+	if err != nil {
+		fmt.Printf("[VARIANT HANDLER] ERROR updating variant %d: %v\n", variantID, err)
+		utils.WriteServiceError(w, err, "Failed to update variant")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, variant)
+}
+
+func (h *Handler) deleteVariant(w http.ResponseWriter, r *http.Request) {
+	productID := utils.GetParamIdfromPath(r, "productID")
+	variantID := utils.GetParamIdfromPath(r, "variantID")
+
+	if err := h.variantService.DeleteVariant(productID, variantID); err != nil {
+		fmt.Printf("[VARIANT HANDLER] ERROR deleting variant %d: %v\n", variantID, err)
+		utils.WriteServiceError(w, err, "Failed to delete variant")
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, map[string]string{"message": "Variant deleted successfully"})
+}
+
+// parsePayload decodes and validates the body into payload, answering with a
+// 400 when it can't.
+func parsePayload(w http.ResponseWriter, r *http.Request, payload any) bool {
+	if err := utils.ParseJson(r, payload); err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		return false
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, utils.FormatValidationError(err.(validator.ValidationErrors)))
+		return false
+	}
+
+	return true
+}
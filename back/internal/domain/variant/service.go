@@ -0,0 +1,214 @@
+package variant
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+type Service struct {
+	store        types.VariantStore
+	productStore types.ProductStore
+}
+
+func NewService(store types.VariantStore, productStore types.ProductStore) *Service {
+	return &Service{store: store, productStore: productStore}
+}
+
+func (s *Service) GetProductVariants(productID int) (*types.ProductVariants, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+
+	options, err := s.store.GetOptions(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := s.store.GetVariants(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ProductVariants{Options: options, Variants: variants}, nil
+}
+
+// CreateOption refuses to add an option once the product has variants, as
+// none of them would have a value for it.
+func (s *Service) CreateOption(productID int, payload types.CreateProductOptionPayload) (*types.ProductOption, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNoVariants(productID); err != nil {
+		return nil, err
+	}
+
+	options, err := s.store.GetOptions(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		if option.Name == payload.Name {
+			return nil, apperrors.NewConflictError("name", fmt.Sprintf("the product already has an option %s", payload.Name))
+		}
+	}
+
+	for i, value := range payload.Values {
+		if slices.Contains(payload.Values[:i], value) {
+			return nil, apperrors.NewValidationError("values", fmt.Sprintf("value %s is listed more than once", value))
+		}
+	}
+
+	option, err := s.store.CreateOption(productID, payload)
+	if err != nil {
+		fmt.Printf("[VARIANT SERVICE] ERROR creating option %s of product %d: %v\n", payload.Name, productID, err)
+		return nil, err
+	}
+
+	fmt.Printf("[VARIANT SERVICE] Created option %d (%s) of product %d\n", option.ID, option.Name, productID)
+	return option, nil
+}
+
+// DeleteOption refuses to delete an option once the product has variants,
+// which would leave them without a value for it.
+func (s *Service) DeleteOption(productID int, optionID int) error {
+	if err := s.checkNoVariants(productID); err != nil {
+		return err
+	}
+
+	return s.store.DeleteOption(productID, optionID)
+}
+
+func (s *Service) CreateVariant(productID int, payload types.CreateVariantPayload) (*types.ProductVariant, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSKU(payload.SKU, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOptionValues(productID, payload.OptionValueIDs); err != nil {
+		return nil, err
+	}
+
+	variant, err := s.store.CreateVariant(productID, payload)
+	if err != nil {
+		fmt.Printf("[VARIANT SERVICE] ERROR creating variant %s of product %d: %v\n", payload.SKU, productID, err)
+		return nil, err
+	}
+
+	fmt.Printf("[VARIANT SERVICE] Created variant %d (%s) of product %d\n", variant.ID, variant.SKU, productID)
+	return variant, nil
+}
+
+func (s *Service) UpdateVariant(productID int, variantID int, payload types.UpdateVariantPayload) (*types.ProductVariant, error) {
+	if payload.SKU != nil {
+		if err := s.checkSKU(*payload.SKU, variantID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.store.UpdateVariant(productID, variantID, payload)
+}
+
+func (s *Service) DeleteVariant(productID int, variantID int) error {
+	if err := s.store.DeleteVariant(productID, variantID); err != nil {
+		return err
+	}
+
+	fmt.Printf("[VARIANT SERVICE] Deleted variant %d of product %d\n", variantID, productID)
+	return nil
+}
+
+func (s *Service) checkProduct(productID int) error {
+	if _, err := s.productStore.GetProductByID(productID); err != nil {
+		return apperrors.NewEntityNotFound("product", productID)
+	}
+	return nil
+}
+
+func (s *Service) checkNoVariants(productID int) error {
+	variants, err := s.store.GetVariants(productID)
+	if err != nil {
+		return err
+	}
+
+	if len(variants) > 0 {
+		return apperrors.NewConflictError("options", "the options of a product can't change once it has variants")
+	}
+	return nil
+}
+
+// checkSKU makes sure no variant other than variantID goes by sku.
+func (s *Service) checkSKU(sku string, variantID int) error {
+	existing, err := s.store.GetVariantBySKU(sku)
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) && appErr.Type == apperrors.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.ID != variantID {
+		return apperrors.NewConflictError("sku", fmt.Sprintf("sku %s is taken", sku))
+	}
+	return nil
+}
+
+// checkOptionValues makes sure valueIDs hold exactly one value of each
+// option of the product, in a combination no other variant has.
+func (s *Service) checkOptionValues(productID int, valueIDs []int) error {
+	options, err := s.store.GetOptions(productID)
+	if err != nil {
+		return err
+	}
+
+	if len(options) == 0 {
+		return apperrors.NewValidationError("optionValueIds", "the product has no options, create them first")
+	}
+
+	optionOf := make(map[int]int)
+	for _, option := range options {
+		for _, value := range option.Values {
+			optionOf[value.ID] = option.ID
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, valueID := range valueIDs {
+		optionID, ok := optionOf[valueID]
+		if !ok {
+			return apperrors.NewValidationError("optionValueIds", fmt.Sprintf("value %d is not an option value of the product", valueID))
+		}
+		if seen[optionID] {
+			return apperrors.NewValidationError("optionValueIds", "a variant takes one value of each option")
+		}
+		seen[optionID] = true
+	}
+
+	if len(seen) != len(options) {
+		return apperrors.NewValidationError("optionValueIds", "a variant takes one value of each option")
+	}
+
+	variants, err := s.store.GetVariants(productID)
+	if err != nil {
+		return err
+	}
+
+	combination := slices.Sorted(slices.Values(valueIDs))
+	for _, variant := range variants {
+		if slices.Equal(combination, slices.Sorted(slices.Values(variant.OptionValueIDs))) {
+			return apperrors.NewConflictError("optionValueIds",
+				fmt.Sprintf("variant %s already has these options", variant.SKU))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package variant
+
+import (
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockVariantStore struct {
+	types.VariantStore
+	mock.Mock
+}
+
+func (m *MockVariantStore) GetOptions(productID int) ([]*types.ProductOption, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductOption), args.Error(1)
+}
+
+func (m *MockVariantStore) CreateOption(productID int, payload types.CreateProductOptionPayload) (*types.ProductOption, error) {
+	args := m.Called(productID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductOption), args.Error(1)
+}
+
+func (m *MockVariantStore) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*types.ProductVariant), args.Error(1)
+}
+
+func (m *MockVariantStore) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	args := m.Called(sku)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductVariant), args.Error(1)
+}
+
+func (m *MockVariantStore) CreateVariant(productID int, payload types.CreateVariantPayload) (*types.ProductVariant, error) {
+	args := m.Called(productID, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.ProductVariant), args.Error(1)
+}
+
+type MockProductStore struct {
+	types.ProductStore
+	mock.Mock
+}
+
+func (m *MockProductStore) GetProductByID(productID int) (*types.Product, error) {
+	args := m.Called(productID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.Product), args.Error(1)
+}
+
+// Size (S, M) and Color (Blue), with a variant for M / Blue
+func newMockStore() *MockVariantStore {
+	store := new(MockVariantStore)
+	store.On("GetOptions", 1).Return([]*types.ProductOption{
+		{ID: 1, ProductID: 1, Name: "Size", Values: []*types.ProductOptionValue{{ID: 10, OptionID: 1, Value: "S"}, {ID: 11, OptionID: 1, Value: "M"}}},
+		{ID: 2, ProductID: 1, Name: "Color", Values: []*types.ProductOptionValue{{ID: 20, OptionID: 2, Value: "Blue"}}},
+	}, nil)
+	store.On("GetVariants", 1).Return([]*types.ProductVariant{
+		{ID: 5, ProductID: 1, SKU: "TSHIRT-M-BLUE", OptionValueIDs: []int{11, 20}},
+	}, nil)
+	return store
+}
+
+func newMockProductStore() *MockProductStore {
+	store := new(MockProductStore)
+	store.On("GetProductByID", 1).Return(&types.Product{ID: 1, BasePrice: 100.0}, nil)
+	store.On("GetProductByID", 99).Return(nil, apperrors.NewEntityNotFound("product", 99))
+	return store
+}
+
+func TestCreateVariant(t *testing.T) {
+	tests := []struct {
+		name          string
+		productID     int
+		sku           string
+		valueIDs      []int
+		expectedError error
+	}{
+		{
+			name:      "Success",
+			productID: 1,
+			sku:       "TSHIRT-S-BLUE",
+			valueIDs:  []int{20, 10},
+		},
+		{
+			name:          "Product not found",
+			productID:     99,
+			sku:           "TSHIRT-S-BLUE",
+			valueIDs:      []int{10, 20},
+			expectedError: apperrors.NewEntityNotFound("product", 99),
+		},
+		{
+			name:          "SKU is taken",
+			productID:     1,
+			sku:           "TSHIRT-M-BLUE",
+			valueIDs:      []int{10, 20},
+			expectedError: apperrors.NewConflictError("sku", "sku TSHIRT-M-BLUE is taken"),
+		},
+		{
+			name:          "Value of another product",
+			productID:     1,
+			sku:           "TSHIRT-S-BLUE",
+			valueIDs:      []int{10, 30},
+			expectedError: apperrors.NewValidationError("optionValueIds", "value 30 is not an option value of the product"),
+		},
+		{
+			name:          "Two values of one option",
+			productID:     1,
+			sku:           "TSHIRT-S-BLUE",
+			valueIDs:      []int{10, 11},
+			expectedError: apperrors.NewValidationError("optionValueIds", "a variant takes one value of each option"),
+		},
+		{
+			name:          "Missing a value of an option",
+			productID:     1,
+			sku:           "TSHIRT-S-BLUE",
+			valueIDs:      []int{10},
+			expectedError: apperrors.NewValidationError("optionValueIds", "a variant takes one value of each option"),
+		},
+		{
+			name:          "Combination already has a variant",
+			productID:     1,
+			sku:           "TSHIRT-M-BLUE-2",
+			valueIDs:      []int{20, 11},
+			expectedError: apperrors.NewConflictError("optionValueIds", "variant TSHIRT-M-BLUE already has these options"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			payload := types.CreateVariantPayload{SKU: tt.sku, OptionValueIDs: tt.valueIDs}
+			store.On("GetVariantBySKU", "TSHIRT-M-BLUE").Return(&types.ProductVariant{ID: 5, SKU: "TSHIRT-M-BLUE"}, nil)
+			store.On("GetVariantBySKU", mock.Anything).Return(nil, apperrors.NewEntityNotFound("variant", tt.sku))
+			store.On("CreateVariant", tt.productID, payload).Return(&types.ProductVariant{ID: 6, ProductID: tt.productID, SKU: tt.sku}, nil)
+
+			variant, err := NewService(store, newMockProductStore()).CreateVariant(tt.productID, payload)
+
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, variant)
+				store.AssertNotCalled(t, "CreateVariant", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 6, variant.ID)
+			}
+		})
+	}
+}
+
+func TestCreateOption(t *testing.T) {
+	t.Run("Options are fixed once the product has variants", func(t *testing.T) {
+		_, err := NewService(newMockStore(), newMockProductStore()).
+			CreateOption(1, types.CreateProductOptionPayload{Name: "Material", Values: []string{"Cotton"}})
+
+		assert.Equal(t, apperrors.NewConflictError("options", "the options of a product can't change once it has variants"), err)
+	})
+
+	t.Run("Option names are unique within the product", func(t *testing.T) {
+		store := new(MockVariantStore)
+		store.On("GetVariants", 1).Return([]*types.ProductVariant{}, nil)
+		store.On("GetOptions", 1).Return([]*types.ProductOption{{ID: 1, ProductID: 1, Name: "Size"}}, nil)
+
+		_, err := NewService(store, newMockProductStore()).
+			CreateOption(1, types.CreateProductOptionPayload{Name: "Size", Values: []string{"L"}})
+
+		assert.Equal(t, apperrors.NewConflictError("name", "the product already has an option Size"), err)
+	})
+
+	t.Run("Values are listed once", func(t *testing.T) {
+		store := new(MockVariantStore)
+		store.On("GetVariants", 1).Return([]*types.ProductVariant{}, nil)
+		store.On("GetOptions", 1).Return([]*types.ProductOption{}, nil)
+
+		_, err := NewService(store, newMockProductStore()).
+			CreateOption(1, types.CreateProductOptionPayload{Name: "Color", Values: []string{"Red", "Blue", "Red"}})
+
+		assert.Equal(t, apperrors.NewValidationError("values", "value Red is listed more than once"), err)
+		store.AssertNotCalled(t, "CreateOption", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		store := new(MockVariantStore)
+		payload := types.CreateProductOptionPayload{Name: "Color", Values: []string{"Red", "Blue"}}
+		store.On("GetVariants", 1).Return([]*types.ProductVariant{}, nil)
+		store.On("GetOptions", 1).Return([]*types.ProductOption{{ID: 1, ProductID: 1, Name: "Size"}}, nil)
+		store.On("CreateOption", 1, payload).Return(&types.ProductOption{ID: 2, ProductID: 1, Name: "Color"}, nil)
+
+		option, err := NewService(store, newMockProductStore()).CreateOption(1, payload)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, option.ID)
+		store.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,510 @@
+package variant
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// variantColumns are the columns scanVariant reads. The available quantity
+// leaves out the units held by active reservations.
+const variantColumns = `
+	v.id,
+	v.productId,
+	v.sku,
+	v.price,
+	v.stock_quantity,
+	v.stock_quantity - COALESCE((
+		SELECT SUM(r.quantity)
+		FROM inventory_reservations r
+		WHERE r.variantId = v.id AND r.expiresAt > NOW()
+	), 0),
+	v.version
+`
+
+type Store struct {
+	db database.DBTX
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// NewTxStore returns a store whose queries all run inside tx.
+func NewTxStore(tx *sql.Tx) *Store {
+	return &Store{db: tx}
+}
+
+func (s *Store) GetOptions(productID int) ([]*types.ProductOption, error) {
+	rows, err := s.db.Query(`
+		SELECT id, productId, name, sortOrder
+		FROM product_options
+		WHERE productId = ?
+		ORDER BY sortOrder, id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetOptions] error getting options of product %d: %v", productID, err)
+	}
+	defer rows.Close()
+
+	options := make([]*types.ProductOption, 0)
+	byID := make(map[int]*types.ProductOption)
+	for rows.Next() {
+		option := &types.ProductOption{Values: []*types.ProductOptionValue{}}
+		if err := rows.Scan(&option.ID, &option.ProductID, &option.Name, &option.SortOrder); err != nil {
+			return nil, fmt.Errorf("[GetOptions] error scanning option: %v", err)
+		}
+		options = append(options, option)
+		byID[option.ID] = option
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetOptions] error reading options: %v", err)
+	}
+
+	// a value is available while a variant with it has units left
+	valueRows, err := s.db.Query(`
+		SELECT ov.id, ov.optionId, ov.value, ov.sortOrder,
+			EXISTS(
+				SELECT 1
+				FROM product_variant_values vv
+				JOIN product_variants v ON v.id = vv.variantId
+				WHERE vv.optionValueId = ov.id AND v.deletedAt IS NULL AND v.stock_quantity > COALESCE((
+					SELECT SUM(r.quantity)
+					FROM inventory_reservations r
+					WHERE r.variantId = v.id AND r.expiresAt > NOW()
+				), 0)
+			)
+		FROM product_option_values ov
+		JOIN product_options o ON o.id = ov.optionId
+		WHERE o.productId = ?
+		ORDER BY ov.sortOrder, ov.id
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetOptions] error getting option values of product %d: %v", productID, err)
+	}
+	defer valueRows.Close()
+
+	for valueRows.Next() {
+		value := new(types.ProductOptionValue)
+		if err := valueRows.Scan(&value.ID, &value.OptionID, &value.Value, &value.SortOrder, &value.Available); err != nil {
+			return nil, fmt.Errorf("[GetOptions] error scanning option value: %v", err)
+		}
+		if option, ok := byID[value.OptionID]; ok {
+			option.Values = append(option.Values, value)
+		}
+	}
+	if err := valueRows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetOptions] error reading option values: %v", err)
+	}
+
+	return options, nil
+}
+
+func (s *Store) CreateOption(productID int, payload types.CreateProductOptionPayload) (*types.ProductOption, error) {
+	var optionID int
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		res, err := tx.Exec(`
+			INSERT INTO product_options (productId, name, sortOrder)
+			VALUES (?, ?, ?)
+		`, productID, payload.Name, payload.SortOrder)
+		if err != nil {
+			return fmt.Errorf("[CreateOption] error inserting option %s: %v", payload.Name, err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateOption] error getting option ID: %v", err)
+		}
+		optionID = int(id)
+
+		// values keep the order they were given in
+		for i, value := range payload.Values {
+			_, err = tx.Exec(`
+				INSERT INTO product_option_values (optionId, value, sortOrder)
+				VALUES (?, ?, ?)
+			`, optionID, value, i)
+			if err != nil {
+				return fmt.Errorf("[CreateOption] error inserting value %s: %v", value, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := s.GetOptions(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		if option.ID == optionID {
+			return option, nil
+		}
+	}
+
+	return nil, apperrors.NewEntityNotFound("option", optionID)
+}
+
+func (s *Store) DeleteOption(productID int, optionID int) error {
+	res, err := s.db.Exec(`DELETE FROM product_options WHERE id = ? AND productId = ?`, optionID, productID)
+	if err != nil {
+		return fmt.Errorf("[DeleteOption] error deleting option %d: %v", optionID, err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return apperrors.NewEntityNotFound("option", optionID)
+	}
+
+	return nil
+}
+
+func (s *Store) GetVariants(productID int) ([]*types.ProductVariant, error) {
+	return s.getVariants(`v.productId = ?`, productID)
+}
+
+func (s *Store) GetVariant(productID int, variantID int) (*types.ProductVariant, error) {
+	variants, err := s.getVariants(`v.productId = ? AND v.id = ?`, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(variants) == 0 {
+		return nil, apperrors.NewEntityNotFound("variant", variantID)
+	}
+
+	return variants[0], nil
+}
+
+func (s *Store) GetVariantBySKU(sku string) (*types.ProductVariant, error) {
+	variants, err := s.getVariants(`v.sku = ?`, sku)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(variants) == 0 {
+		return nil, apperrors.NewEntityNotFound("variant", sku)
+	}
+
+	return variants[0], nil
+}
+
+func (s *Store) CreateVariant(productID int, payload types.CreateVariantPayload) (*types.ProductVariant, error) {
+	var variantID int
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		res, err := tx.Exec(`
+			INSERT INTO product_variants (productId, sku, price, stock_quantity)
+			VALUES (?, ?, ?, ?)
+		`, productID, payload.SKU, payload.Price, payload.StockQuantity)
+		if err != nil {
+			return fmt.Errorf("[CreateVariant] error inserting variant %s: %v", payload.SKU, err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("[CreateVariant] error getting variant ID: %v", err)
+		}
+		variantID = int(id)
+
+		for _, valueID := range payload.OptionValueIDs {
+			_, err = tx.Exec(`
+				INSERT INTO product_variant_values (variantId, optionValueId)
+				VALUES (?, ?)
+			`, variantID, valueID)
+			if err != nil {
+				return fmt.Errorf("[CreateVariant] error adding value %d to variant: %v", valueID, err)
+			}
+		}
+
+		return insertImages(tx, variantID, payload.Images)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetVariant(productID, variantID)
+}
+
+func (s *Store) UpdateVariant(productID int, variantID int, payload types.UpdateVariantPayload) (*types.ProductVariant, error) {
+	err := database.InTx(s.db, func(tx database.DBTX) error {
+		var id int
+		err := tx.QueryRow(`
+			SELECT id FROM product_variants WHERE id = ? AND productId = ? AND deletedAt IS NULL FOR UPDATE
+		`, variantID, productID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewEntityNotFound("variant", variantID)
+		}
+		if err != nil {
+			return fmt.Errorf("[UpdateVariant] error locking variant %d: %v", variantID, err)
+		}
+
+		sets := []string{}
+		args := []any{}
+		if payload.SKU != nil {
+			sets = append(sets, "sku = ?")
+			args = append(args, *payload.SKU)
+		}
+		if payload.Price != nil {
+			// a price of zero removes the override
+			sets = append(sets, "price = NULLIF(?, 0)")
+			args = append(args, *payload.Price)
+		}
+		if payload.StockQuantity != nil {
+			sets = append(sets, "stock_quantity = ?", "version = version + 1")
+			args = append(args, *payload.StockQuantity)
+		}
+
+		if len(sets) > 0 {
+			args = append(args, variantID)
+			_, err = tx.Exec(`UPDATE product_variants SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...)
+			if err != nil {
+				return fmt.Errorf("[UpdateVariant] error updating variant %d: %v", variantID, err)
+			}
+		}
+
+		if payload.Images != nil {
+			_, err = tx.Exec(`DELETE FROM product_variant_images WHERE variantId = ?`, variantID)
+			if err != nil {
+				return fmt.Errorf("[UpdateVariant] error deleting images of variant %d: %v", variantID, err)
+			}
+
+			return insertImages(tx, variantID, payload.Images)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetVariant(productID, variantID)
+}
+
+// DeleteVariant only marks the variant as deleted, as orders that sold it
+// still put their units back in its stock when cancelled or refunded.
+func (s *Store) DeleteVariant(productID int, variantID int) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		res, err := tx.Exec(`
+			UPDATE product_variants
+			SET deletedAt = NOW()
+			WHERE id = ? AND productId = ? AND deletedAt IS NULL
+		`, variantID, productID)
+		if err != nil {
+			return fmt.Errorf("[DeleteVariant] error deleting variant %d: %v", variantID, err)
+		}
+
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return apperrors.NewEntityNotFound("variant", variantID)
+		}
+
+		// carts refer to variants by id alone, so they are cleared by hand
+		_, err = tx.Exec(`DELETE FROM inventory_reservations WHERE variantId = ?`, variantID)
+		if err != nil {
+			return fmt.Errorf("[DeleteVariant] error releasing reservations of variant %d: %v", variantID, err)
+		}
+
+		_, err = tx.Exec(`DELETE FROM cart_items WHERE variantId = ?`, variantID)
+		if err != nil {
+			return fmt.Errorf("[DeleteVariant] error removing variant %d from carts: %v", variantID, err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateVariantStock changes the stock of the variant by quantityChange,
+// failing with ErrInsufficientStock when it would go below zero. Deleted
+// variants still take back the units of cancelled orders.
+func (s *Store) UpdateVariantStock(variantID int, quantityChange int) error {
+	return database.InTx(s.db, func(tx database.DBTX) error {
+		var stock, version int
+		err := tx.QueryRow(`
+			SELECT stock_quantity, version FROM product_variants WHERE id = ? FOR UPDATE
+		`, variantID).Scan(&stock, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.NewEntityNotFound("variant", variantID)
+		}
+		if err != nil {
+			return fmt.Errorf("[UpdateVariantStock] error getting stock of variant %d: %v", variantID, err)
+		}
+
+		if stock+quantityChange < 0 {
+			return types.ErrInsufficientStock
+		}
+
+		_, err = tx.Exec(`
+			UPDATE product_variants
+			SET stock_quantity = ?, version = version + 1
+			WHERE id = ? AND version = ?
+		`, stock+quantityChange, variantID, version)
+		if err != nil {
+			return fmt.Errorf("[UpdateVariantStock] error updating stock of variant %d: %v", variantID, err)
+		}
+
+		return nil
+	})
+}
+
+// getVariants loads the live variants matching where along with their option
+// values and images, in three queries.
+func (s *Store) getVariants(where string, args ...any) ([]*types.ProductVariant, error) {
+	rows, err := s.db.Query(`SELECT `+variantColumns+` FROM product_variants v WHERE v.deletedAt IS NULL AND `+where+` ORDER BY v.id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("[GetVariants] error getting variants: %v", err)
+	}
+	defer rows.Close()
+
+	variants := make([]*types.ProductVariant, 0)
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("[GetVariants] error scanning variant: %v", err)
+		}
+		variants = append(variants, variant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("[GetVariants] error reading variants: %v", err)
+	}
+
+	if len(variants) == 0 {
+		return variants, nil
+	}
+
+	if err := s.loadValues(variants); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadImages(variants); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+// loadValues sets the option values of the variants and labels them with the
+// values in the order of their options.
+func (s *Store) loadValues(variants []*types.ProductVariant) error {
+	byID := make(map[int]*types.ProductVariant, len(variants))
+	ids := make([]int, 0, len(variants))
+	for _, variant := range variants {
+		byID[variant.ID] = variant
+		ids = append(ids, variant.ID)
+	}
+
+	in, args := database.InArgs(ids)
+	rows, err := s.db.Query(`
+		SELECT vv.variantId, ov.id, ov.value
+		FROM product_variant_values vv
+		JOIN product_option_values ov ON ov.id = vv.optionValueId
+		JOIN product_options o ON o.id = ov.optionId
+		WHERE vv.variantId IN (`+in+`)
+		ORDER BY o.sortOrder, o.id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("[GetVariants] error getting variant values: %v", err)
+	}
+	defer rows.Close()
+
+	labels := make(map[int][]string, len(variants))
+	for rows.Next() {
+		var variantID, valueID int
+		var value string
+		if err := rows.Scan(&variantID, &valueID, &value); err != nil {
+			return fmt.Errorf("[GetVariants] error scanning variant value: %v", err)
+		}
+		if variant, ok := byID[variantID]; ok {
+			variant.OptionValueIDs = append(variant.OptionValueIDs, valueID)
+			labels[variantID] = append(labels[variantID], value)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("[GetVariants] error reading variant values: %v", err)
+	}
+
+	for _, variant := range variants {
+		variant.Label = strings.Join(labels[variant.ID], " / ")
+	}
+
+	return nil
+}
+
+func (s *Store) loadImages(variants []*types.ProductVariant) error {
+	byID := make(map[int]*types.ProductVariant, len(variants))
+	ids := make([]int, 0, len(variants))
+	for _, variant := range variants {
+		byID[variant.ID] = variant
+		ids = append(ids, variant.ID)
+	}
+
+	in, args := database.InArgs(ids)
+	rows, err := s.db.Query(`
+		SELECT id, variantId, imageUrl, sortOrder
+		FROM product_variant_images
+		WHERE variantId IN (`+in+`)
+		ORDER BY sortOrder, id
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("[GetVariants] error getting variant images: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image types.VariantImage
+		if err := rows.Scan(&image.ID, &image.VariantID, &image.ImageUrl, &image.SortOrder); err != nil {
+			return fmt.Errorf("[GetVariants] error scanning variant image: %v", err)
+		}
+		if variant, ok := byID[image.VariantID]; ok {
+			variant.Images = append(variant.Images, image)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("[GetVariants] error reading variant images: %v", err)
+	}
+
+	return nil
+}
+
+func insertImages(tx database.DBTX, variantID int, images []types.ImagePayload) error {
+	for _, image := range images {
+		_, err := tx.Exec(`
+			INSERT INTO product_variant_images (variantId, imageUrl, sortOrder)
+			VALUES (?, ?, ?)
+		`, variantID, image.ImageUrl, image.SortOrder)
+		if err != nil {
+			return fmt.Errorf("error adding image to variant %d: %v", variantID, err)
+		}
+	}
+
+	return nil
+}
+
+func scanVariant(rows *sql.Rows) (*types.ProductVariant, error) {
+	variant := &types.ProductVariant{OptionValueIDs: []int{}, Images: []types.VariantImage{}}
+	var price sql.NullFloat64
+
+	err := rows.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.SKU,
+		&price,
+		&variant.StockQuantity,
+		&variant.AvailableQuantity,
+		&variant.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if price.Valid {
+		variant.PriceOverride = &price.Float64
+	}
+
+	return variant, nil
+}
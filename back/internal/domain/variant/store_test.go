@@ -0,0 +1,28 @@
+package variant
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/database/dbtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteVariantKeepsTheRow(t *testing.T) {
+	db := dbtest.New(t)
+	db.On("SELECT stock_quantity, version FROM product_variants", []driver.Value{int64(3), int64(1)})
+	store := NewStore(db.DB)
+
+	assert.NoError(t, store.DeleteVariant(1, 5))
+	queries := db.Queries()
+	assert.Contains(t, queries[0], "SET deletedAt = NOW()")
+	for _, query := range queries {
+		assert.False(t, strings.HasPrefix(query, "DELETE FROM product_variants"), query)
+	}
+
+	// a cancelled order still puts its units back in the deleted variant
+	db.Reset()
+	assert.NoError(t, store.UpdateVariantStock(5, 2))
+	assert.NotContains(t, db.Queries()[0], "deletedAt")
+}
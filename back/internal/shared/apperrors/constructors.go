@@ -38,4 +38,26 @@ func NewConflictError(field string, reason string) *AppError {
 	}
 }
 
+func NewForbiddenError(reason string) *AppError {
+	return &AppError{
+		Type:    FORBIDDEN,
+		Code:    "FORBIDDEN",
+		Message: "Forbidden Request error",
+		Details: map[string]interface{}{
+			"reason": reason,
+		},
+	}
+}
+
+func NewUnauthorizedError(reason string) *AppError {
+	return &AppError{
+		Type:    Unauthorized,
+		Code:    "UNAUTHORIZED",
+		Message: "Unauthorized Request error",
+		Details: map[string]interface{}{
+			"reason": reason,
+		},
+	}
+}
+
 // TODO ANOTHER ONES
@@ -1,6 +1,9 @@
 package apperrors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ErrorType string
 
@@ -28,3 +31,21 @@ func (e *AppError) Error() string {
 func (e *AppError) Unwrap() error {
 	return e.Cause
 }
+
+// StatusCode maps the error type to the HTTP status returned to clients
+func (e *AppError) StatusCode() int {
+	switch e.Type {
+	case NotFound:
+		return http.StatusNotFound
+	case BAD:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case FORBIDDEN:
+		return http.StatusForbidden
+	case CONFLICT:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so a store can run the
+// same queries on its own connection or inside a caller's transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type txBeginner interface {
+	Begin() (*sql.Tx, error)
+}
+
+// InTx runs fn inside a transaction. When conn is already a transaction fn
+// joins it, and committing or rolling back is left to whoever began it.
+func InTx(conn DBTX, fn func(tx DBTX) error) error {
+	db, ok := conn.(txBeginner)
+	if !ok {
+		return fn(conn)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// NewestFirstAfter returns the condition picking the rows that come after
+// the cursor in a list ordered by timeColumn then idColumn, both descending.
+// Every row comes after a missing cursor.
+func NewestFirstAfter(after *types.Cursor, timeColumn, idColumn string) (string, []any) {
+	if after == nil || after.Time == nil {
+		return "TRUE", nil
+	}
+
+	condition := fmt.Sprintf("(%[1]s < ? OR (%[1]s = ? AND %[2]s < ?))", timeColumn, idColumn)
+	return condition, []any{*after.Time, *after.Time, after.ID}
+}
+
+// InArgs returns the placeholders and args of an IN (...) list of ids, which
+// must not be empty.
+func InArgs(ids []int) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "?" + strings.Repeat(",?", len(ids)-1), args
+}
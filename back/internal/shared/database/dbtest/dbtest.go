@@ -0,0 +1,152 @@
+// Package dbtest fakes a database for tests that care about the queries a
+// code path issues, such as how many an endpoint needs, rather than about
+// the database behind them.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// DB records every query run through it and answers them with the rows
+// registered with On. Queries without an answer get no rows.
+type DB struct {
+	*sql.DB
+
+	mu      sync.Mutex
+	queries []string
+	answers []answer
+}
+
+type answer struct {
+	match string
+	rows  [][]driver.Value
+}
+
+// New opens a DB, closed when the test ends.
+func New(t testing.TB) *DB {
+	db := &DB{}
+	db.DB = sql.OpenDB(connector{db})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// On answers the queries containing match with rows. The first match
+// registered wins.
+func (db *DB) On(match string, rows ...[]driver.Value) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.answers = append(db.answers, answer{match: match, rows: rows})
+}
+
+// Queries returns the queries run since the last Reset.
+func (db *DB) Queries() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.queries...)
+}
+
+func (db *DB) Reset() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = nil
+}
+
+// AssertQueryBudget fails the test when more than budget queries ran since
+// the last Reset, listing them.
+func (db *DB) AssertQueryBudget(t testing.TB, budget int) bool {
+	t.Helper()
+
+	queries := db.Queries()
+	if len(queries) <= budget {
+		return true
+	}
+
+	t.Errorf("ran %d queries, over the budget of %d:\n%s", len(queries), budget, strings.Join(queries, "\n"))
+	return false
+}
+
+func (db *DB) run(query string) [][]driver.Value {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.queries = append(db.queries, strings.Join(strings.Fields(query), " "))
+	for _, a := range db.answers {
+		if strings.Contains(query, a.match) {
+			return a.rows
+		}
+	}
+	return nil
+}
+
+type connector struct{ db *DB }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn{c.db}, nil }
+func (c connector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, fmt.Errorf("dbtest: open a DB with New")
+}
+
+type conn struct{ db *DB }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{c.db, query}, nil }
+func (c conn) Close() error                              { return nil }
+func (c conn) Begin() (driver.Tx, error)                 { return tx{}, nil }
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec([]driver.Value) (driver.Result, error) {
+	s.db.run(s.query)
+	return driver.RowsAffected(1), nil
+}
+
+func (s stmt) Query([]driver.Value) (driver.Rows, error) {
+	return &rows{values: s.db.run(s.query)}, nil
+}
+
+type rows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	columns := make([]string, len(r.values[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return columns
+}
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
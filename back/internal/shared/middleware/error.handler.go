@@ -33,17 +33,7 @@ func handleError(w http.ResponseWriter, err interface{}) {
 }
 
 func writeAppError(w http.ResponseWriter, e *apperrors.AppError) {
-	status := http.StatusInternalServerError
-	switch e.Type {
-	case apperrors.NotFound:
-		status = http.StatusNotFound
-	case apperrors.BAD:
-		status = http.StatusBadRequest
-	case apperrors.Unauthorized:
-		status = http.StatusUnauthorized
-	}
-
-	utils.WriteJson(w, status, map[string]interface{}{
+	utils.WriteJson(w, e.StatusCode(), map[string]interface{}{
 		"error":   e.Code,
 		"message": e.Message,
 		"details": e.Details,
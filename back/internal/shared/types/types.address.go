@@ -0,0 +1,80 @@
+package types
+
+import "time"
+
+type AddressStore interface {
+	GetAddresses(userID int) ([]*Address, error)
+	// GetAddress returns a NotFound error when addressID is not an address
+	// of userID.
+	GetAddress(userID int, addressID int) (*Address, error)
+	// CreateAddress makes the new address the default when the payload asks
+	// for it, or when the user has no other address.
+	CreateAddress(userID int, payload CreateAddressPayload) (*Address, error)
+	UpdateAddress(userID int, addressID int, payload UpdateAddressPayload) (*Address, error)
+	// DeleteAddress passes the default on to the newest address left.
+	DeleteAddress(userID int, addressID int) error
+	SetDefaultAddress(userID int, addressID int) error
+}
+
+type AddressService interface {
+	GetAddresses(userID int) ([]*Address, error)
+	GetAddress(userID int, addressID int) (*Address, error)
+	CreateAddress(userID int, payload CreateAddressPayload) (*Address, error)
+	UpdateAddress(userID int, addressID int, payload UpdateAddressPayload) (*Address, error)
+	DeleteAddress(userID int, addressID int) error
+	SetDefaultAddress(userID int, addressID int) (*Address, error)
+}
+
+// Address is a shipping address of a user. Each user with addresses has
+// exactly one default.
+type Address struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"userId"`
+	Street     string    `json:"street"`
+	City       string    `json:"city"`
+	State      string    `json:"state"`
+	PostalCode string    `json:"postalCode"`
+	Country    string    `json:"country"`
+	IsDefault  bool      `json:"isDefault"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// ShippingAddress is the copy of an address an order keeps.
+type ShippingAddress struct {
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postalCode"`
+	Country    string `json:"country"`
+}
+
+func (a *Address) ShippingAddress() *ShippingAddress {
+	return &ShippingAddress{
+		Street:     a.Street,
+		City:       a.City,
+		State:      a.State,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+	}
+}
+
+type CreateAddressPayload struct {
+	Street string `json:"street" validate:"required,max=255"`
+	City   string `json:"city" validate:"required,max=100"`
+	// State is the UF of the state, or its name
+	State string `json:"state" validate:"required,max=50"`
+	// PostalCode is a CEP, with or without the dash
+	PostalCode string `json:"postalCode" validate:"required"`
+	// Country defaults to Brasil
+	Country   string `json:"country,omitempty" validate:"omitempty,max=50"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+type UpdateAddressPayload struct {
+	Street     *string `json:"street,omitempty" validate:"omitempty,min=1,max=255"`
+	City       *string `json:"city,omitempty" validate:"omitempty,min=1,max=100"`
+	State      *string `json:"state,omitempty" validate:"omitempty,min=1,max=50"`
+	PostalCode *string `json:"postalCode,omitempty"`
+	Country    *string `json:"country,omitempty" validate:"omitempty,min=1,max=50"`
+}
@@ -0,0 +1,66 @@
+package types
+
+import "time"
+
+// CampaignScope is what a campaign discounts.
+type CampaignScope string
+
+const (
+	// CampaignCategory discounts the products of a category and of all the
+	// categories below it
+	CampaignCategory CampaignScope = "CATEGORY"
+	// CampaignProducts discounts a list of products
+	CampaignProducts CampaignScope = "PRODUCTS"
+	// CampaignStorewide discounts the whole catalog
+	CampaignStorewide CampaignScope = "STORE"
+)
+
+type CampaignStore interface {
+	CreateCampaign(payload CreateCampaignPayload) (*Campaign, error)
+	GetCampaigns() ([]*Campaign, error)
+	GetCampaignByID(campaignID int) (*Campaign, error)
+	DeleteCampaign(campaignID int) error
+}
+
+type CampaignService interface {
+	CreateCampaign(payload CreateCampaignPayload) (*Campaign, error)
+	GetCampaigns() ([]*Campaign, error)
+	GetCampaignByID(campaignID int) (*Campaign, error)
+	DeleteCampaign(campaignID int) error
+}
+
+// Campaign is a discount run over many products at once. While it runs, every
+// product it targets is priced as if it had the discount of its own.
+type Campaign struct {
+	ID         int           `json:"id"`
+	Name       string        `json:"name"`
+	Scope      CampaignScope `json:"scope"`
+	CategoryID *int          `json:"categoryId"`
+	ProductIDs []int         `json:"productIds"`
+	// a campaign spans products of different prices, so it can't set a
+	// fixed price
+	Type            DiscountType `json:"type"`
+	DiscountPercent float64      `json:"discountPercent"`
+	BuyQuantity     *int         `json:"buyQuantity,omitempty"`
+	GetQuantity     *int         `json:"getQuantity,omitempty"`
+	Stackable       bool         `json:"stackable"`
+	Priority        int          `json:"priority"`
+	StartDate       time.Time    `json:"startDate"`
+	EndDate         time.Time    `json:"endDate"`
+	CreatedAt       time.Time    `json:"createdAt"`
+}
+
+type CreateCampaignPayload struct {
+	Name            string        `json:"name" validate:"required,max=100"`
+	Scope           CampaignScope `json:"scope" validate:"required,oneof=CATEGORY PRODUCTS STORE"`
+	CategoryID      *int          `json:"categoryId" validate:"required_if=Scope CATEGORY"`
+	ProductIDs      []int         `json:"productIds" validate:"required_if=Scope PRODUCTS"`
+	Type            DiscountType  `json:"type" validate:"omitempty,oneof=PERCENTAGE BUY_X_GET_Y"`
+	DiscountPercent float64       `json:"discountPercent" validate:"required,gt=0,lte=100"`
+	BuyQuantity     *int          `json:"buyQuantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,gt=0"`
+	GetQuantity     *int          `json:"getQuantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,gt=0"`
+	Stackable       bool          `json:"stackable"`
+	Priority        int           `json:"priority"`
+	StartDate       time.Time     `json:"startDate" validate:"required"`
+	EndDate         time.Time     `json:"endDate" validate:"required"`
+}
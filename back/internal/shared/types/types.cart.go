@@ -1,27 +1,97 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// GuestCartHeader carries the token of a guest cart on requests made
+// without a user token.
+const GuestCartHeader = "X-Cart-Token"
+
+// CartOwner identifies a cart: the cart of a signed in user, or a guest cart
+// by its opaque token.
+type CartOwner struct {
+	UserID     int
+	GuestToken string
+}
+
+func UserCart(userID int) CartOwner {
+	return CartOwner{UserID: userID}
+}
+
+func GuestCart(token string) CartOwner {
+	return CartOwner{GuestToken: token}
+}
+
+func (o CartOwner) IsGuest() bool {
+	return o.UserID == 0
+}
+
+// String describes the owner in logs without leaking the guest token.
+func (o CartOwner) String() string {
+	if o.IsGuest() {
+		return "guest"
+	}
+	return fmt.Sprintf("user %d", o.UserID)
+}
 
 type CartStore interface {
 	CreateCart(userID int) error
-	GetMyCartItems(userID int) (*[]*CartItem, error)
-	AddItemToCart(productID int, userID int, price float64) (*CartItem, error)
-	RemoveItemFromCart(productID int, userID int) error
-	GetTotal(userID int) (float64, error)
-	GetCartID(userID int) (int, error)
-	GetCartItem(userID int, productID int) (*CartItem, error)
-	RemoveOneItemFromCart(userID int, productID int) error
-	RemoveItemsFromCart(userID int) error
+	CreateGuestCart(token string) (int, error)
+	// DeleteCart removes the cart with its items and reservations.
+	DeleteCart(cartID int) error
+	// DeleteIdleGuestCarts deletes, like DeleteCart, the guest carts that
+	// were neither changed nor given an item since idleSince.
+	DeleteIdleGuestCarts(idleSince time.Time) (int64, error)
+	GetMyCartItems(owner CartOwner) (*[]*CartItem, error)
+	// AddItemToCart adds a unit of the variant of productID, labeled
+	// variantLabel. A variantID of zero stands for a product sold without
+	// variants, here and in every method taking one.
+	AddItemToCart(productID int, variantID int, variantLabel string, owner CartOwner, price float64) (*CartItem, error)
+	// SetItemQuantity sets the quantity of the variant of productID in the
+	// cart, adding it at price when the cart does not hold it yet.
+	SetItemQuantity(productID int, variantID int, variantLabel string, owner CartOwner, quantity int, price float64) (*CartItem, error)
+	RemoveItemFromCart(productID int, variantID int, owner CartOwner) error
+	GetTotal(owner CartOwner) (float64, error)
+	// GetCartID creates the cart of a user on first use. Guest carts only
+	// exist once CreateGuestCart made them.
+	GetCartID(owner CartOwner) (int, error)
+	GetCartItem(owner CartOwner, productID int, variantID int) (*CartItem, error)
+	RemoveOneItemFromCart(owner CartOwner, productID int, variantID int) error
+	RemoveItemsFromCart(owner CartOwner) error
+	// SetCoupon applies the coupon to the cart. A couponID of zero removes
+	// the applied coupon.
+	SetCoupon(owner CartOwner, couponID int) error
+	// GetCouponID is zero when the cart has no coupon.
+	GetCouponID(owner CartOwner) (int, error)
 }
 
 type CartService interface {
 	CreateCart(userID int) error
-	GetMyCartItems(userID int) (*[]*CartItem, error)
-	AddItemToCart(productID int, userID int) (*CartItem, error)
-	RemoveItemFromCart(productID int, userID int) error
-	GetTotal(userID int) (float64, error)
-	RemoveEntireItemFromCart(productID int, userID int) error
-	RemoveItemsFromCart(userID int) error
+	// CreateGuestCart returns the token that identifies the new cart.
+	CreateGuestCart() (string, error)
+	// MergeGuestCart moves the items of the guest cart into the cart of
+	// userID and deletes the guest cart, all or nothing.
+	MergeGuestCart(token string, userID int) (*CartMerge, error)
+	GetMyCartItems(owner CartOwner) (*[]*CartItem, error)
+	// AddItemToCart adds a unit of the variant of productID. A product with
+	// variants can only be added as one of them, and a variantID of zero
+	// stands for a product sold without variants.
+	AddItemToCart(productID int, variantID int, owner CartOwner) (*CartItem, error)
+	UpdateItemQuantity(productID int, variantID int, owner CartOwner, quantity int) (*CartItem, error)
+	RemoveItemFromCart(productID int, variantID int, owner CartOwner) error
+	// GetTotal is the cart total less the discount of its coupon.
+	GetTotal(owner CartOwner) (float64, error)
+	RemoveEntireItemFromCart(productID int, variantID int, owner CartOwner) error
+	RemoveItemsFromCart(owner CartOwner) error
+	// ValidateCart checks every item of the cart against the current price
+	// of the product and the stock the cart can still get.
+	ValidateCart(owner CartOwner) (*CartValidation, error)
+	// ApplyCoupon applies the coupon with code to the cart when it gives a
+	// discount on it, and returns the cart validated with the coupon.
+	ApplyCoupon(owner CartOwner, code string) (*CartValidation, error)
+	RemoveCoupon(owner CartOwner) error
 }
 
 type Cart struct {
@@ -31,12 +101,89 @@ type Cart struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+type UpdateCartItemPayload struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
 type CartItem struct {
 	CartID        int       `json:"cartId"`
 	ProductID     int       `json:"productId"`
+	VariantID     int       `json:"variantId,omitempty"`
 	ProductTitle  string    `json:"productTitle"`
+	VariantLabel  string    `json:"variantLabel,omitempty"`
 	Quantity      int       `json:"quantity"`
 	ProductImage  string    `json:"productImage"`
 	PriceAtAdding float64   `json:"priceAtAdding"`
 	AddedAt       time.Time `json:"addedAt"`
 }
+
+// CartValidation is the cart as it would be checked out now.
+type CartValidation struct {
+	Items []*CartItemValidation `json:"items"`
+	// Subtotal is the cart total at current prices
+	Subtotal float64 `json:"subtotal"`
+	// Discount is what the coupon takes off the subtotal when it is valid
+	Discount float64     `json:"discount"`
+	Coupon   *CartCoupon `json:"coupon,omitempty"`
+	// Total is what the cart costs, the subtotal less the discount
+	Total        float64 `json:"total"`
+	PriceChanged bool    `json:"priceChanged"`
+	OutOfStock   bool    `json:"outOfStock"`
+}
+
+type CartItemValidation struct {
+	ProductID     int     `json:"productId"`
+	VariantID     int     `json:"variantId,omitempty"`
+	ProductTitle  string  `json:"productTitle"`
+	VariantLabel  string  `json:"variantLabel,omitempty"`
+	Quantity      int     `json:"quantity"`
+	PriceAtAdding float64 `json:"priceAtAdding"`
+	CurrentPrice  float64 `json:"currentPrice"`
+	// LineTotal is what the units cost now, multi unit offers included
+	LineTotal         float64         `json:"lineTotal"`
+	AvailableQuantity int             `json:"availableQuantity"`
+	PriceChanged      bool            `json:"priceChanged"`
+	OutOfStock        bool            `json:"outOfStock"`
+	Pricing           *PriceBreakdown `json:"pricing"`
+	// CouponDiscount is the part of the coupon discount taken off the line
+	CouponDiscount float64 `json:"couponDiscount"`
+}
+
+// CartCoupon is the coupon applied to a cart. A coupon stops being valid when
+// the cart no longer meets its conditions, or it reached its usage limits.
+type CartCoupon struct {
+	ID       int     `json:"id"`
+	Code     string  `json:"code"`
+	Discount float64 `json:"discount"`
+	Valid    bool    `json:"valid"`
+	Reason   string  `json:"reason,omitempty"`
+	// LineDiscounts splits Discount over the validated items, in order
+	LineDiscounts []float64 `json:"-"`
+}
+
+// Item returns the validation of the variant of productID, or nil when the
+// cart did not hold it when it was validated.
+func (v *CartValidation) Item(productID int, variantID int) *CartItemValidation {
+	for _, item := range v.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			return item
+		}
+	}
+	return nil
+}
+
+// CartMerge tells what happened to the guest items folded into a user's cart.
+type CartMerge struct {
+	MergedItems int `json:"mergedItems"`
+	// Adjustments lists the items that could not keep every unit
+	Adjustments []*CartMergeAdjustment `json:"adjustments"`
+}
+
+type CartMergeAdjustment struct {
+	ProductID    int    `json:"productId"`
+	VariantID    int    `json:"variantId,omitempty"`
+	ProductTitle string `json:"productTitle"`
+	Requested    int    `json:"requested"`
+	Quantity     int    `json:"quantity"`
+	Reason       string `json:"reason"`
+}
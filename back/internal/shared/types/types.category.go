@@ -1,11 +1,23 @@
 package types
 
 type CategoryStore interface {
-	GetCategories() (*[]Category, error)
+	GetCategories(page PageRequest) (*Page[Category], error)
+	// GetAllCategories loads every category, to build the tree from
+	GetAllCategories() ([]Category, error)
 	GetCategoryByID(int) (*Category, error)
 	CreateCategory(CreateCategoryPayload) (*Category, error)
 	UpdateCategory(int, UpdateCategoryPayload) (*Category, error)
-	DeleteCategory(int) error
+	// DeleteCategory deletes the category, and its subcategories with
+	// CategoryDeleteCascade. With CategoryDeleteReparent they move up to the
+	// parent of the category.
+	DeleteCategory(categoryID int, mode CategoryDeleteMode) error
+}
+
+type CategoryService interface {
+	GetCategoryTree() ([]*CategoryNode, error)
+	GetBreadcrumbs(categoryID int) ([]Category, error)
+	UpdateCategory(categoryID int, payload UpdateCategoryPayload) (*Category, error)
+	DeleteCategory(categoryID int, mode CategoryDeleteMode) error
 }
 
 type Category struct {
@@ -26,3 +38,18 @@ type UpdateCategoryPayload struct {
 	ImageUrl         string `json:"imageUrl" validate:"required"`
 	ParentCategoryId *int   `json:"parentCategoryId"`
 }
+
+// CategoryNode is a category with its subcategories, nested.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryDeleteMode tells what becomes of the subcategories of a deleted
+// category.
+type CategoryDeleteMode string
+
+const (
+	CategoryDeleteCascade  CategoryDeleteMode = "cascade"
+	CategoryDeleteReparent CategoryDeleteMode = "reparent"
+)
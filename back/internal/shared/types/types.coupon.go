@@ -0,0 +1,111 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCouponUnavailable is returned when a coupon reached one of its usage
+// limits before it could be redeemed.
+var ErrCouponUnavailable = errors.New("coupon unavailable")
+
+type CouponType string
+
+const (
+	CouponPercentage CouponType = "PERCENTAGE"
+	CouponFixed      CouponType = "FIXED"
+)
+
+type CouponStore interface {
+	CreateCoupon(payload CreateCouponPayload) (*Coupon, error)
+	GetCoupons() ([]*Coupon, error)
+	GetCouponByID(couponID int) (*Coupon, error)
+	// GetCouponByCode matches code regardless of case.
+	GetCouponByCode(code string) (*Coupon, error)
+	DeleteCoupon(couponID int) error
+	// CountRedemptions returns how many times the coupon was redeemed in
+	// total and by userID.
+	CountRedemptions(couponID int, userID int) (total int, byUser int, err error)
+	// RedeemCoupon records the redemption unless it would exceed a usage limit
+	// of the coupon, in which case it fails with ErrCouponUnavailable.
+	RedeemCoupon(redemption *CouponRedemption) error
+	// ReleaseRedemption gives back the use of a coupon redeemed by orderID,
+	// once the order is cancelled or fully refunded.
+	ReleaseRedemption(orderID int) error
+}
+
+type CouponService interface {
+	CreateCoupon(payload CreateCouponPayload) (*Coupon, error)
+	GetCoupons() ([]*Coupon, error)
+	GetCouponByID(couponID int) (*Coupon, error)
+	GetCouponByCode(code string) (*Coupon, error)
+	DeleteCoupon(couponID int) error
+	// Evaluate works out the discount coupon gives on lines. userID is zero
+	// for guests, whose per-user limit is only checked once they sign in.
+	Evaluate(coupon *Coupon, userID int, lines []CouponLine) (*CouponEvaluation, error)
+}
+
+type Coupon struct {
+	ID    int        `json:"id"`
+	Code  string     `json:"code"`
+	Type  CouponType `json:"type"`
+	Value float64    `json:"value"`
+	// MinCartValue is the subtotal the cart needs before the coupon applies
+	MinCartValue   float64    `json:"minCartValue"`
+	MaxUses        *int       `json:"maxUses"`
+	MaxUsesPerUser *int       `json:"maxUsesPerUser"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+	// ProductIDs and CategoryIDs restrict the coupon to those items. A coupon
+	// without either applies to the whole cart.
+	ProductIDs  []int     `json:"productIds"`
+	CategoryIDs []int     `json:"categoryIds"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Restricted tells whether the coupon only applies to some of the items.
+func (c *Coupon) Restricted() bool {
+	return len(c.ProductIDs) > 0 || len(c.CategoryIDs) > 0
+}
+
+// CouponLine is a cart item as a coupon sees it.
+type CouponLine struct {
+	ProductID   int
+	CategoryIDs []int
+	Quantity    int
+	UnitPrice   float64
+}
+
+// CouponEvaluation is the discount of a coupon on a cart. Reason tells why
+// the coupon does not apply, and is empty when it does.
+type CouponEvaluation struct {
+	Discount float64
+	// LineDiscounts is the part of Discount taken off each line, in the
+	// order the lines were evaluated
+	LineDiscounts []float64
+	Reason        string
+}
+
+type CouponRedemption struct {
+	ID        int       `json:"id"`
+	CouponID  int       `json:"couponId"`
+	UserID    int       `json:"userId"`
+	OrderID   int       `json:"orderId"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateCouponPayload struct {
+	Code           string     `json:"code" validate:"required,min=3,max=50"`
+	Type           CouponType `json:"type" validate:"required,oneof=PERCENTAGE FIXED"`
+	Value          float64    `json:"value" validate:"required,gt=0"`
+	MinCartValue   float64    `json:"minCartValue" validate:"min=0"`
+	MaxUses        *int       `json:"maxUses,omitempty" validate:"omitempty,gt=0"`
+	MaxUsesPerUser *int       `json:"maxUsesPerUser,omitempty" validate:"omitempty,gt=0"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	ProductIDs     []int      `json:"productIds"`
+	CategoryIDs    []int      `json:"categoryIds"`
+}
+
+type ApplyCouponPayload struct {
+	Code string `json:"code" validate:"required"`
+}
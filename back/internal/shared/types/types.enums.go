@@ -48,20 +48,39 @@ type OrderStatus string
 
 const (
 	OrderPending   OrderStatus = "PENDING"
+	OrderPaid      OrderStatus = "PAID"
+	OrderShipped   OrderStatus = "SHIPPED"
+	OrderDelivered OrderStatus = "DELIVERED"
 	OrderCompleted OrderStatus = "COMPLETED"
 	OrderCancelled OrderStatus = "CANCELLED"
-	OrderShipped   OrderStatus = "SHIPPED"
 )
 
 func (s OrderStatus) Valid() error {
 	switch s {
-	case OrderPending, OrderCompleted, OrderCancelled, OrderShipped:
+	case OrderPending, OrderPaid, OrderShipped, OrderDelivered, OrderCompleted, OrderCancelled:
 		return nil
 	default:
 		return fmt.Errorf("invalid order status: %s", s)
 	}
 }
 
+type ShipmentStatus string
+
+const (
+	ShipmentShipped   ShipmentStatus = "SHIPPED"
+	ShipmentInTransit ShipmentStatus = "IN_TRANSIT"
+	ShipmentDelivered ShipmentStatus = "DELIVERED"
+)
+
+func (s ShipmentStatus) Valid() error {
+	switch s {
+	case ShipmentShipped, ShipmentInTransit, ShipmentDelivered:
+		return nil
+	default:
+		return fmt.Errorf("invalid shipment status: %s", s)
+	}
+}
+
 type PaymentMethod string
 
 const (
@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+type InvoiceStore interface {
+	// GetInvoiceByOrderID returns a NotFound error when the order has no
+	// invoice yet.
+	GetInvoiceByOrderID(orderID int) (*Invoice, error)
+	// GetUninvoicedOrderIDs returns up to limit orders in one of statuses
+	// that have no invoice yet, oldest first.
+	GetUninvoicedOrderIDs(statuses []OrderStatus, limit int) ([]int, error)
+	// CreateInvoice numbers invoice after the last one issued, has render
+	// draw its PDF with that number and saves it. It all happens in one
+	// transaction, so no number is ever skipped.
+	CreateInvoice(invoice *Invoice, render func(invoice *Invoice) ([]byte, error)) error
+}
+
+type InvoiceService interface {
+	// GetInvoice returns the invoice of the order, or a NotFound error while
+	// it has not been issued. Only the customer who placed the order and
+	// admins get it.
+	GetInvoice(orderID int, userID int, role UserRole) (*Invoice, error)
+	// IssuePendingInvoices issues the invoices of the orders paid for since
+	// it last ran and returns how many it issued.
+	IssuePendingInvoices() (int, error)
+}
+
+// Invoice is the document issued for a paid order.
+type Invoice struct {
+	ID          int     `json:"id"`
+	Number      int     `json:"number"`
+	OrderID     int     `json:"orderId"`
+	UserID      int     `json:"userId"`
+	TotalAmount float64 `json:"totalAmount"`
+	// PDF is the document as it was rendered when the invoice was issued
+	PDF      []byte    `json:"-"`
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
+// InvoiceIssuer is the company that issues the invoices.
+type InvoiceIssuer struct {
+	Name    string
+	CNPJ    string
+	Address string
+}
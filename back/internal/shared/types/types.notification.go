@@ -3,16 +3,16 @@ package types
 import "time"
 
 type NotificationService interface {
-	GetMyNotifications(userID int) *[]Notification
-	GetNotifications() *[]Notification
+	GetMyNotifications(userID int, page PageRequest) *Page[Notification]
+	GetNotifications(page PageRequest) *Page[Notification]
 	GetNotificationByID(notificationID int) *Notification
 	CreateNotification(payload *CreateNotificationPayload, userID int) *Notification
 	DeleteNotification(notificationID int)
 }
 
 type NotificationStore interface {
-	GetMyNotifications(userID int) (*[]Notification, error)
-	GetNotifications() (*[]Notification, error)
+	GetMyNotifications(userID int, page PageRequest) (*Page[Notification], error)
+	GetNotifications(page PageRequest) (*Page[Notification], error)
 	GetNotificationByID(notificationID int) (*Notification, error)
 	CreateNotification(payload *CreateNotificationPayload, userID int) (*Notification, error)
 	DeleteNotification(notificationID int) error
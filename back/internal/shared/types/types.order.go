@@ -1,35 +1,128 @@
 package types
 
+import "time"
+
 type OrderStore interface {
-	CreateOrder(userID int, totalAmount float64, paymentMethod PaymentMethod, paymentID string) (*OrderHistory, error)
+	CreateOrder(userID int, totalAmount float64, paymentMethod PaymentMethod, paymentID string, shipping *ShippingAddress, option *ShippingOption) (*OrderHistory, error)
 	AddOrderItems(orderID int, items []*OrderItem) error
-	GetOrdersByUserID(userID int) ([]*OrderHistory, error)
+	GetOrdersByUserID(userID int, page PageRequest) (*Page[*OrderHistory], error)
 	GetOrderByID(orderID int) (*OrderHistory, error)
+	// GetOrderByIDForUpdate locks the order until the transaction ends.
+	GetOrderByIDForUpdate(orderID int) (*OrderHistory, error)
 	GetOrderItems(orderID int) ([]*OrderItem, error)
-	UpdateOrderStatus(orderID int, status OrderStatus) error
-	GetOrdersWithItems(userID int) ([]*OrderWithItems, error)
+	UpdateOrderStatus(orderID int, from OrderStatus, to OrderStatus) error
+	GetOrdersWithItems(userID int, page PageRequest) (*Page[*OrderWithItems], error)
 	GetOrderWithItems(orderID int) (*OrderWithItems, error)
+	AddOrderStatusHistory(orderID int, from *OrderStatus, to OrderStatus, changedBy int, note string) error
+	GetOrderStatusHistory(orderID int) ([]*OrderStatusHistory, error)
+	CreateRefund(refund *Refund) error
+	GetRefundsByOrderID(orderID int) ([]*Refund, error)
+	GetRefundedAmount(orderID int) (float64, error)
+	// SearchOrders lists the orders of every customer matching filter,
+	// newest first.
+	SearchOrders(filter OrderFilter, page PageRequest) (*Page[*OrderHistory], error)
+	// GetOrderCustomers loads the users of userIDs, mapped by id.
+	GetOrderCustomers(userIDs []int) (map[int]*OrderCustomer, error)
 }
 
 type OrderService interface {
-	CreateOrderFromCart(userID int, paymentMethod PaymentMethod, paymentID string) (*OrderHistory, error)
-	GetOrdersByUserID(userID int) ([]*OrderHistory, error)
+	// CreateOrderFromCart ships the order to addressID, one of the user's
+	// addresses, with the shipping option of that ID.
+	CreateOrderFromCart(userID int, addressID int, shippingOption string, paymentMethod PaymentMethod, paymentToken string, acceptedTotal *float64) (*OrderHistory, error)
+	GetOrdersByUserID(userID int, page PageRequest) (*Page[*OrderHistory], error)
 	GetOrderByID(orderID int) (*OrderHistory, error)
 	GetOrderWithItems(orderID int) (*OrderWithItems, error)
-	GetOrdersWithItems(userID int) ([]*OrderWithItems, error)
-	UpdateOrderStatus(orderID int, status OrderStatus) error
+	GetOrdersWithItems(userID int, page PageRequest) (*Page[*OrderWithItems], error)
+	UpdateOrderStatus(orderID int, status OrderStatus, userID int, role UserRole) error
+	CancelOrder(orderID int, userID int, role UserRole, reason string) error
+	GetOrderStatusHistory(orderID int) ([]*OrderStatusHistory, error)
+	ConfirmPixPayment(txid string, endToEndID string, amount float64) error
+	CancelExpiredPixOrders() (int, error)
+	RefundOrder(orderID int, userID int, payload *CreateRefundPayload) (*Refund, error)
+	GetRefunds(orderID int) ([]*Refund, error)
+	// CreateShipment ships units of a PAID order. The order becomes SHIPPED
+	// once every unit not refunded has shipped.
+	CreateShipment(orderID int, userID int, payload *CreateShipmentPayload) (*Shipment, error)
+	// UpdateShipment changes the tracking of a shipment. The order becomes
+	// DELIVERED once every shipment of a SHIPPED order is delivered.
+	UpdateShipment(orderID int, shipmentID int, userID int, payload *UpdateShipmentPayload) (*Shipment, error)
+	SearchOrders(filter OrderFilter, page PageRequest) (*Page[*AdminOrder], error)
+	GetOrderDetails(orderID int) (*OrderDetails, error)
+	// BulkUpdateOrderStatus moves each order on its own, so one order that
+	// cannot move does not hold back the others.
+	BulkUpdateOrderStatus(orderIDs []int, status OrderStatus, userID int) ([]*OrderStatusUpdateResult, error)
 }
 
 type OrderWithItems struct {
-	Order OrderHistory `json:"order"`
-	Items []*OrderItem `json:"items"`
+	Order          OrderHistory `json:"order"`
+	Items          []*OrderItem `json:"items"`
+	RefundedAmount float64      `json:"refundedAmount"`
 }
 
 type CreateOrderPayload struct {
-	PaymentMethod PaymentMethod `json:"paymentMethod" validate:"required"`
-	PaymentID     string        `json:"paymentId"`
+	// AddressID is the address of the user the order ships to
+	AddressID int `json:"addressId" validate:"required"`
+	// ShippingOption is the ID of an option from GET /cart/shipping-options
+	ShippingOption string        `json:"shippingOption" validate:"required"`
+	PaymentMethod  PaymentMethod `json:"paymentMethod" validate:"required"`
+	// PaymentID is the token the client got from the payment provider. The
+	// order itself stores the gateway's payment ID.
+	PaymentID string `json:"paymentId"`
+	// AcceptedTotal confirms the total from GET /cart/validate when prices
+	// changed since the items were added to the cart
+	AcceptedTotal *float64 `json:"acceptedTotal,omitempty"`
 }
 
 type UpdateOrderStatusPayload struct {
 	Status OrderStatus `json:"status" validate:"required"`
 }
+
+type CancelOrderPayload struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+// OrderFilter narrows the orders of every customer listed to admins. From is
+// inclusive and To exclusive.
+type OrderFilter struct {
+	Status        *OrderStatus
+	PaymentMethod *PaymentMethod
+	From          *time.Time
+	To            *time.Time
+	UserID        *int
+	// Customer matches part of the customer's name or email
+	Customer string
+	MinTotal *float64
+	MaxTotal *float64
+}
+
+// OrderCustomer is the user who placed an order, as admins see them.
+type OrderCustomer struct {
+	ID       int    `json:"id"`
+	FullName string `json:"fullName"`
+	Email    string `json:"email"`
+	Cpf      string `json:"cpf"`
+}
+
+type AdminOrder struct {
+	OrderHistory
+	Customer *OrderCustomer `json:"customer"`
+}
+
+type OrderDetails struct {
+	OrderWithItems
+	Customer *OrderCustomer `json:"customer"`
+}
+
+type BulkUpdateOrderStatusPayload struct {
+	OrderIDs []int       `json:"orderIds" validate:"required,min=1,max=100,dive,gt=0"`
+	Status   OrderStatus `json:"status" validate:"required"`
+}
+
+// OrderStatusUpdateResult tells whether one order of a bulk update moved,
+// and why not when it did not.
+type OrderStatusUpdateResult struct {
+	OrderID int                    `json:"orderId"`
+	Updated bool                   `json:"updated"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
@@ -9,13 +9,49 @@ type OrderHistory struct {
 	Status        OrderStatus   `json:"status"`
 	PaymentMethod PaymentMethod `json:"paymentMethod"`
 	PaymentID     string        `json:"paymentId"`
-	CreatedAt     time.Time     `json:"createdAt"`
-	UpdatedAt     time.Time     `json:"updatedAt"`
+	// ShippingAddress is the address the order ships to as it was when the
+	// order was placed, nil for orders placed before addresses were kept
+	ShippingAddress *ShippingAddress `json:"shippingAddress,omitempty"`
+	// ShippingService is the shipping option chosen at checkout, and
+	// ShippingCost what it added to TotalAmount
+	ShippingService string    `json:"shippingService,omitempty"`
+	ShippingCost    float64   `json:"shippingCost"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+	// Pix is only set while a PIX order is waiting for payment
+	Pix *PixCharge `json:"pix,omitempty"`
+	// Shipments track the packages the order went out in, and are only
+	// loaded for a single order
+	Shipments []*Shipment `json:"shipments,omitempty"`
+}
+
+type OrderStatusHistory struct {
+	ID         int          `json:"id"`
+	OrderID    int          `json:"orderId"`
+	FromStatus *OrderStatus `json:"fromStatus"`
+	ToStatus   OrderStatus  `json:"toStatus"`
+	ChangedBy  *int         `json:"changedBy"`
+	Note       string       `json:"note"`
+	CreatedAt  time.Time    `json:"createdAt"`
 }
 
 type OrderItem struct {
-	OrderID   int     `json:"orderId"`
-	ProductID int     `json:"productId"`
-	Quantity  int     `json:"quantity"`
+	OrderID   int `json:"orderId"`
+	ProductID int `json:"productId"`
+	// ProductTitle is the title of the product when the order was placed
+	ProductTitle string `json:"productTitle"`
+	// VariantID is zero for a product sold without variants
+	VariantID    int    `json:"variantId,omitempty"`
+	VariantLabel string `json:"variantLabel,omitempty"`
+	Quantity     int    `json:"quantity"`
+	// BasePrice is the price of a unit before the product's discounts, and
+	// Price what it sold for with them
+	BasePrice float64 `json:"basePrice"`
 	Price     float64 `json:"price"`
+	// Discount is the part of the order's coupon discount taken off the
+	// line, over all its units
+	Discount         float64 `json:"discount"`
+	RefundedQuantity int     `json:"refundedQuantity"`
+	// RestockedQuantity is the part of RefundedQuantity returned to stock
+	RestockedQuantity int `json:"restockedQuantity"`
 }
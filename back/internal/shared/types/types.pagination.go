@@ -0,0 +1,83 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest asks for at most Limit items following After, from the first
+// item when After is nil.
+type PageRequest struct {
+	After *Cursor
+	Limit int
+}
+
+// AfterID is the ID of the cursor, 0 when there is none, for lists in ID
+// order.
+func (p PageRequest) AfterID() int {
+	if p.After == nil {
+		return 0
+	}
+	return p.After.ID
+}
+
+// Cursor holds the sort key of the last item of a page: its ID, plus the
+// time or value the list is ordered by, if any. Clients get it encoded and
+// hand it back untouched.
+type Cursor struct {
+	ID    int        `json:"id"`
+	Time  *time.Time `json:"t,omitempty"`
+	Value *float64   `json:"v,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := new(Cursor)
+	if err := json.Unmarshal(b, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+// Page is the envelope of every paginated list. NextCursor is null on the
+// last page.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+// NewPage builds a page out of items fetched with one extra item past limit,
+// the extra item telling that another page follows.
+func NewPage[T any](items []T, limit int, cursorOf func(T) Cursor) *Page[T] {
+	page := &Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next := cursorOf(items[limit-1]).Encode()
+		page.NextCursor = &next
+	}
+
+	return page
+}
@@ -0,0 +1,52 @@
+package types
+
+import "errors"
+
+var (
+	// ErrPaymentDeclined is returned by a PaymentGateway when the provider
+	// refuses to authorize a payment.
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrPaymentNotFound is returned when the provider does not know the
+	// payment ID, e.g. for orders placed before payments went through a gateway.
+	ErrPaymentNotFound = errors.New("payment not found")
+)
+
+// PaymentGateway is implemented by each payment provider. Amounts are in the
+// same unit as OrderHistory.TotalAmount.
+type PaymentGateway interface {
+	Authorize(req *PaymentRequest) (*PaymentResult, error)
+	Capture(paymentID string) (*PaymentResult, error)
+	Refund(paymentID string, amount float64) (*PaymentResult, error)
+	Status(paymentID string) (*PaymentResult, error)
+	// RefundPix returns a PIX received when no payment was open to capture
+	// it, e.g. one paid after its order was cancelled. The PIX is identified
+	// by the endToEndId the PSP reported.
+	RefundPix(endToEndID string, amount float64) error
+}
+
+type PaymentStatus string
+
+const (
+	PaymentAuthorized PaymentStatus = "AUTHORIZED"
+	PaymentCaptured   PaymentStatus = "CAPTURED"
+	// PaymentPartiallyRefunded is a captured payment with part of it refunded
+	PaymentPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
+	PaymentRefunded          PaymentStatus = "REFUNDED"
+	PaymentVoided            PaymentStatus = "VOIDED"
+	PaymentFailed            PaymentStatus = "FAILED"
+)
+
+type PaymentRequest struct {
+	UserID int
+	Amount float64
+	Method PaymentMethod
+	// Token is what the client received from the provider, e.g. a card token
+	Token string
+}
+
+type PaymentResult struct {
+	ID       string        `json:"id"`
+	Status   PaymentStatus `json:"status"`
+	Amount   float64       `json:"amount"`
+	Refunded float64       `json:"refunded"`
+}
@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+type PixStore interface {
+	CreatePixCharge(charge *PixCharge) error
+	GetPixChargeByOrderID(orderID int) (*PixCharge, error)
+	GetPixChargeByTxID(txid string) (*PixCharge, error)
+	MarkPixChargePaid(txid string, endToEndID string) error
+	// GetExpiredPixCharges returns the unpaid charges past their expiration
+	// whose order is still PENDING, oldest first.
+	GetExpiredPixCharges(limit int) ([]*PixCharge, error)
+}
+
+// PixIssuer builds the BR Code and QR image customers pay a PIX charge with.
+type PixIssuer interface {
+	NewCharge(orderID int, amount float64) (*PixCharge, error)
+	QRCode(brCode string) ([]byte, error)
+}
+
+type PixCharge struct {
+	ID         int        `json:"-"`
+	OrderID    int        `json:"orderId"`
+	TxID       string     `json:"txid"`
+	BRCode     string     `json:"brCode"`
+	Amount     float64    `json:"amount"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	PaidAt     *time.Time `json:"paidAt"`
+	EndToEndID string     `json:"endToEndId,omitempty"`
+	// QRCodePNG is rendered from BRCode on read and never stored
+	QRCodePNG []byte    `json:"qrCodePng,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PixWebhookPayload follows the notification body PSPs send for received PIX
+// payments, where valor is the amount as a decimal string.
+type PixWebhookPayload struct {
+	Pix []PixWebhookPayment `json:"pix"`
+}
+
+type PixWebhookPayment struct {
+	EndToEndID string `json:"endToEndId"`
+	TxID       string `json:"txid"`
+	Valor      string `json:"valor"`
+	Horario    string `json:"horario"`
+}
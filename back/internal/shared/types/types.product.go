@@ -3,31 +3,57 @@ package types
 import "time"
 
 type ProductStore interface {
-	GetProducts() ([]*Product, error)
+	GetProducts(page PageRequest) (*Page[*Product], error)
 	CreateProduct(CreateProductPayload) error
 	GetProductByID(productID int) (*Product, error)
 	CreateProductWithImages(CreateProductWithImagesPayload) (*Product, error)
 	UpdateStock(productID int, quantityChange int) error
 	GetInventory(productID int) (*Inventory, error)
 	GetImagesForProducts(productIDs []int) (map[int][]ProductImage, error)
+	// GetDimensionsForProducts leaves out the products whose dimensions
+	// were never set.
+	GetDimensionsForProducts(productIDs []int) (map[int]*ProductDimensions, error)
 	UpdateProduct(productID int, payload UpdateProductPayload) error
 	DeleteProduct(productID int) error
-	GetProductsByCategory(categoryID int) ([]*Product, error)
+	GetProductsByCategory(categoryID int, includeSubcategories bool) ([]*Product, error)
 	GetProductDetails(userID int, productID int) (*ProductDetails, error)
-	GetSimpleProductDetails(userID int) (*[]*SimpleProductObject, error)
+	GetSimpleProductDetails(userID int, page PageRequest) (*Page[*SimpleProductObject], error)
+	// SearchProducts returns at most limit products matching text, the most
+	// relevant first.
+	SearchProducts(userID int, text string, limit int) (*[]*SimpleProductObject, error)
+	// ListProducts filters, sorts and pages the products in SQL, on the
+	// prices SavePrices stored.
+	ListProducts(userID int, filter ProductFilter, page PageRequest) (*Page[*SimpleProductObject], error)
+	GetProductFacets(filter ProductFilter) (*ProductFacets, error)
+	GetBasePrices(afterID int, limit int) ([]*ProductPrice, error)
+	GetBasePricesForProducts(productIDs []int) ([]*ProductPrice, error)
+	SavePrices(prices []*ProductPrice) error
 }
 
 type ProductService interface {
 	GetProductDetails(userID int, productID int) *ProductDetails
-	GetSimpleProducts(userID int) *[]*SimpleProductObject
-	GetProducts() []*Product
+	GetSimpleProducts(userID int, page PageRequest) *Page[*SimpleProductObject]
+	SearchProducts(userID int, text string) *[]*SimpleProductObject
+	ListProducts(userID int, filter ProductFilter, page PageRequest) *ProductListing
+	ListingPricer
+	GetProducts(page PageRequest) *Page[*Product]
 	GetProductByID(productID int) *Product
-	GetProductsByCategoryID(categoryID int) []*Product
+	GetProductsByCategoryID(categoryID int, includeSubcategories bool) []*Product
 	CreateProductWithImages(payload CreateProductWithImagesPayload) *Product
 	UpdateProductById(productID int, payload UpdateProductPayload) *Product
 	DeleteProduct(productID int)
 }
 
+// ListingPricer keeps the prices the product listing filters and sorts on.
+type ListingPricer interface {
+	// RefreshPrices works out the price of every product for the listing
+	// to filter and sort on, returning how many it priced.
+	RefreshPrices() (int, error)
+	// RefreshProductPrices prices only the products of productIDs, for a
+	// write that changes their price to show up in the listing at once.
+	RefreshProductPrices(productIDs []int) error
+}
+
 type Product struct {
 	ID          int            `json:"id"`
 	Title       string         `json:"title"`
@@ -36,14 +62,30 @@ type Product struct {
 	Inventory   Inventory      `json:"inventory"`
 	Images      []ProductImage `json:"images"`
 	Categories  []Category     `json:"categories"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
+	// Dimensions are nil until they are set
+	Dimensions *ProductDimensions `json:"dimensions"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+}
+
+// ProductDimensions are the weight and size of a product packed for
+// shipping. Variants ship in the package of their product.
+type ProductDimensions struct {
+	WeightGrams int     `json:"weightGrams" validate:"required,gt=0"`
+	LengthCm    float64 `json:"lengthCm" validate:"required,gt=0"`
+	WidthCm     float64 `json:"widthCm" validate:"required,gt=0"`
+	HeightCm    float64 `json:"heightCm" validate:"required,gt=0"`
 }
 
 type Inventory struct {
 	ProductID     int `json:"productId"`
 	StockQuantity int `json:"stockQuantity"`
-	Version       int `json:"-"`
+	// AvailableQuantity is StockQuantity minus the units reserved by carts
+	AvailableQuantity int `json:"availableQuantity"`
+	// MaxPurchaseQuantity caps the units of the product a cart can hold, nil
+	// when there is no cap
+	MaxPurchaseQuantity *int `json:"maxPurchaseQuantity"`
+	Version             int  `json:"-"`
 }
 
 type ProductImage struct {
@@ -59,6 +101,9 @@ type CreateProductPayload struct {
 	BasePrice     float64 `json:"basePrice" validate:"required,gt=0"`
 	StockQuantity int     `json:"stockQuantity" validate:"required,min=0"`
 	CategoryIDs   []int   `json:"categoryIds" validate:"required,min=1"`
+	// MaxPurchaseQuantity of zero or nil means no cap
+	MaxPurchaseQuantity *int               `json:"maxPurchaseQuantity,omitempty" validate:"omitempty,min=0"`
+	Dimensions          *ProductDimensions `json:"dimensions,omitempty" validate:"omitempty"`
 }
 
 type CreateProductWithImagesPayload struct {
@@ -68,6 +113,9 @@ type CreateProductWithImagesPayload struct {
 	StockQuantity int            `json:"stockQuantity" validate:"required,min=0"`
 	Images        []ImagePayload `json:"images" validate:"required,min=1,dive"`
 	CategoryIDs   []int          `json:"categoryIds" validate:"required,min=1"`
+	// MaxPurchaseQuantity of zero or nil means no cap
+	MaxPurchaseQuantity *int               `json:"maxPurchaseQuantity,omitempty" validate:"omitempty,min=0"`
+	Dimensions          *ProductDimensions `json:"dimensions,omitempty" validate:"omitempty"`
 }
 
 type UpdateProductPayload struct {
@@ -75,6 +123,9 @@ type UpdateProductPayload struct {
 	Description *string              `json:"description,omitempty" validate:"omitempty,max=1000"`
 	BasePrice   *float64             `json:"basePrice,omitempty" validate:"omitempty,gt=0"`
 	Images      []ImageUpdatePayload `json:"images,omitempty" validate:"omitempty,dive"`
+	// MaxPurchaseQuantity of zero removes the cap
+	MaxPurchaseQuantity *int               `json:"maxPurchaseQuantity,omitempty" validate:"omitempty,min=0"`
+	Dimensions          *ProductDimensions `json:"dimensions,omitempty" validate:"omitempty"`
 }
 
 type ImagePayload struct {
@@ -99,6 +150,11 @@ type ProductDetails struct {
 	IsFavorite         bool           `json:"isFavorite"`
 	AverageRating      float64        `json:"averageRating"`
 	Images             []ProductImage `json:"images"`
+	// Pricing explains Price, the price of one unit
+	Pricing *PriceBreakdown `json:"pricing"`
+	// Options and Variants are empty for a product sold without variants
+	Options  []*ProductOption  `json:"options"`
+	Variants []*VariantDetails `json:"variants"`
 }
 
 type SimpleProductObject struct {
@@ -109,4 +165,84 @@ type SimpleProductObject struct {
 	AverageRating float64      `json:"averageRating"`
 	Image         ProductImage `json:"image"`
 	IsFavorite    bool         `json:"isFavorite"`
+	// OnSale is set when a discount or campaign runs on the product
+	OnSale bool `json:"onSale"`
+	// Pricing explains Price, the price of one unit
+	Pricing *PriceBreakdown `json:"pricing"`
+}
+
+// ProductPrice is the price the pricing engine works out for a product with
+// its active discounts, stored for the listing to filter and sort on.
+type ProductPrice struct {
+	ProductID int
+	BasePrice float64
+	Price     float64
+	OnSale    bool
+}
+
+type ProductSort string
+
+const (
+	SortPriceAsc    ProductSort = "price_asc"
+	SortPriceDesc   ProductSort = "price_desc"
+	SortRating      ProductSort = "rating"
+	SortNewest      ProductSort = "newest"
+	SortBestSelling ProductSort = "best_selling"
+)
+
+// ProductFilter narrows a product listing. Prices are compared with the
+// discounted price last refreshed, and a category includes its
+// subcategories.
+type ProductFilter struct {
+	MinPrice   *float64
+	MaxPrice   *float64
+	CategoryID *int
+	MinRating  *float64
+	InStock    bool
+	OnSale     bool
+	Sort       ProductSort
+}
+
+// ProductListing is a page of the listing, with facets counted over every
+// matching product.
+type ProductListing struct {
+	*Page[*SimpleProductObject]
+	Facets *ProductFacets `json:"facets"`
+}
+
+// ProductFacets count the products each filter option would list. Each
+// facet applies every filter but its own, so picking an option yields the
+// count shown next to it.
+type ProductFacets struct {
+	Categories []*CategoryFacet `json:"categories"`
+	Price      *PriceFacet      `json:"price"`
+	Ratings    []*RatingFacet   `json:"ratings"`
+	InStock    int              `json:"inStock"`
+	OnSale     int              `json:"onSale"`
+}
+
+type CategoryFacet struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	ParentCategoryId *int   `json:"parentCategoryId"`
+	Count            int    `json:"count"`
+}
+
+// PriceFacet spans the prices of the products, and counts them by range. The
+// last range has no Max.
+type PriceFacet struct {
+	Min    float64            `json:"min"`
+	Max    float64            `json:"max"`
+	Ranges []*PriceRangeFacet `json:"ranges"`
+}
+
+type PriceRangeFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+type RatingFacet struct {
+	MinRating float64 `json:"minRating"`
+	Count     int     `json:"count"`
 }
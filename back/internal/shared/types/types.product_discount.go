@@ -9,27 +9,120 @@ type ProductDiscountStore interface {
 	GetDiscoutsByID(int) (*ProductDiscount, error)
 	GetDiscountsByProduct(int) ([]*ProductDiscount, error)
 	GetActiveDiscounts(int) ([]*ProductDiscount, error)
+	// GetActiveDiscounts and GetActiveDiscountsForProducts include the
+	// campaigns running on the products, as discounts with a CampaignID.
+	// GetActiveDiscountsForProducts maps each of productIDs to its active
+	// discounts. Products without any are left out of the map.
+	GetActiveDiscountsForProducts(productIDs []int) (map[int][]*ProductDiscount, error)
 	GetDiscountsByDateRange(int, time.Time, time.Time) ([]*ProductDiscount, error)
+	// GetDiscountCalendar lists the discounts of every product that run at
+	// some point between start and end.
+	GetDiscountCalendar(start time.Time, end time.Time) ([]*CalendarDiscount, error)
 }
 
+type ProductDiscountService interface {
+	CreateDiscount(*CreateProductDiscountPayload) (*ProductDiscount, error)
+	UpdateDiscount(int, *UpdateProductDiscountPayload) (*ProductDiscount, error)
+	DeleteDiscount(int) error
+	GetDiscountByID(int) (*ProductDiscount, error)
+	GetDiscountsByProduct(int) ([]*ProductDiscount, error)
+	GetActiveDiscounts(int) ([]*ProductDiscount, error)
+	GetDiscountCalendar(start time.Time, end time.Time) (*DiscountCalendar, error)
+}
+
+type DiscountType string
+
+const (
+	// DiscountPercentage takes DiscountPercent off the unit price
+	DiscountPercentage DiscountType = "PERCENTAGE"
+	// DiscountFixedPrice sells the product at FixedPrice
+	DiscountFixedPrice DiscountType = "FIXED_PRICE"
+	// DiscountBuyXGetY takes DiscountPercent off GetQuantity units for every
+	// BuyQuantity units bought. A DiscountPercent of 100 makes them free.
+	DiscountBuyXGetY DiscountType = "BUY_X_GET_Y"
+)
+
 type ProductDiscount struct {
-	ID              int       `json:"id"`
-	ProductID       int       `json:"productId"`
-	DiscountPercent float64   `json:"discountPercent"`
-	StartDate       time.Time `json:"startDate"`
-	EndDate         time.Time `json:"endDate"`
-	CreatedAt       time.Time `json:"createdAt"`
+	ID        int          `json:"id"`
+	ProductID int          `json:"productId"`
+	Type      DiscountType `json:"type"`
+	// DiscountPercent is unused by fixed price discounts
+	DiscountPercent float64  `json:"discountPercent"`
+	FixedPrice      *float64 `json:"fixedPrice,omitempty"`
+	BuyQuantity     *int     `json:"buyQuantity,omitempty"`
+	GetQuantity     *int     `json:"getQuantity,omitempty"`
+	// Stackable discounts combine with each other, an exclusive one is
+	// applied alone. Priority orders how stacked discounts are applied and
+	// breaks ties between equally good prices.
+	Stackable bool      `json:"stackable"`
+	Priority  int       `json:"priority"`
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	CreatedAt time.Time `json:"createdAt"`
+	// CampaignID is set, and ID is zero, when the discount comes from a
+	// campaign the product is part of
+	CampaignID   *int   `json:"campaignId,omitempty"`
+	CampaignName string `json:"campaignName,omitempty"`
 }
 
 type CreateProductDiscountPayload struct {
-	ProductID       int       `json:"productId" validate:"required"`
-	DiscountPercent float64   `json:"discountPercent" validate:"required"`
-	StartDate       time.Time `json:"startDate" validate:"required"`
-	EndDate         time.Time `json:"endDate" validate:"required"`
+	ProductID       int          `json:"productId" validate:"required"`
+	Type            DiscountType `json:"type" validate:"omitempty,oneof=PERCENTAGE FIXED_PRICE BUY_X_GET_Y"`
+	DiscountPercent float64      `json:"discountPercent" validate:"required_unless=Type FIXED_PRICE,omitempty,gt=0,lte=100"`
+	FixedPrice      *float64     `json:"fixedPrice,omitempty" validate:"required_if=Type FIXED_PRICE,omitempty,gte=0"`
+	BuyQuantity     *int         `json:"buyQuantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,gt=0"`
+	GetQuantity     *int         `json:"getQuantity,omitempty" validate:"required_if=Type BUY_X_GET_Y,omitempty,gt=0"`
+	Stackable       bool         `json:"stackable"`
+	Priority        int          `json:"priority"`
+	StartDate       time.Time    `json:"startDate" validate:"required"`
+	EndDate         time.Time    `json:"endDate" validate:"required"`
 }
 
 type UpdateProductDiscountPayload struct {
-	DiscountPercent *float64   `json:"discountPercent,omitempty" validate:"omitempty"`
+	DiscountPercent *float64   `json:"discountPercent,omitempty" validate:"omitempty,gt=0,lte=100"`
+	FixedPrice      *float64   `json:"fixedPrice,omitempty" validate:"omitempty,gte=0"`
+	BuyQuantity     *int       `json:"buyQuantity,omitempty" validate:"omitempty,gt=0"`
+	GetQuantity     *int       `json:"getQuantity,omitempty" validate:"omitempty,gt=0"`
+	Stackable       *bool      `json:"stackable,omitempty"`
+	Priority        *int       `json:"priority,omitempty"`
 	StartDate       *time.Time `json:"startDate,omitempty" validate:"omitempty"`
 	EndDate         *time.Time `json:"endDate,omitempty" validate:"omitempty"`
 }
+
+// PriceBreakdown explains how the price of a quantity of a product was
+// reached from its base price.
+type PriceBreakdown struct {
+	BasePrice float64 `json:"basePrice"`
+	Quantity  int     `json:"quantity"`
+	// UnitPrice is the price of one unit with the per unit discounts
+	UnitPrice float64 `json:"unitPrice"`
+	// Total is the price of all the units, including multi unit offers
+	Total   float64            `json:"total"`
+	Savings float64            `json:"savings"`
+	Applied []*AppliedDiscount `json:"applied"`
+}
+
+type AppliedDiscount struct {
+	DiscountID  int          `json:"discountId"`
+	CampaignID  *int         `json:"campaignId,omitempty"`
+	Campaign    string       `json:"campaign,omitempty"`
+	Type        DiscountType `json:"type"`
+	Description string       `json:"description"`
+	Savings     float64      `json:"savings"`
+}
+
+// CalendarDiscount is a discount listed alongside the product it applies to.
+type CalendarDiscount struct {
+	*ProductDiscount
+	ProductTitle string `json:"productTitle"`
+}
+
+// DiscountCalendar splits the discounts running within Start and End by
+// whether they have yet to start, are running or are over.
+type DiscountCalendar struct {
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+	Upcoming []*CalendarDiscount `json:"upcoming"`
+	Active   []*CalendarDiscount `json:"active"`
+	Expired  []*CalendarDiscount `json:"expired"`
+}
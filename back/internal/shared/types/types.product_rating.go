@@ -4,7 +4,7 @@ import "time"
 
 type ProductRatingStore interface {
 	CreateRating(*CreateProductRatingPayload, int, int) (*ProductRating, error)
-	GetRatingsByProduct(int) ([]*ProductRating, error)
+	GetRatingsByProduct(productID int, page PageRequest) (*Page[*ProductRating], error)
 	GetRatingsByUser(int) ([]*ProductRating, error)
 	GetRating(int) (*ProductRating, error)
 	GetAverageRating(productID int) (float64, error)
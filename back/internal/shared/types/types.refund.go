@@ -0,0 +1,37 @@
+package types
+
+import "time"
+
+type Refund struct {
+	ID        int           `json:"id"`
+	OrderID   int           `json:"orderId"`
+	Amount    float64       `json:"amount"`
+	Reason    string        `json:"reason"`
+	Restocked bool          `json:"restocked"`
+	CreatedBy *int          `json:"createdBy"`
+	Items     []*RefundItem `json:"items"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+type RefundItem struct {
+	RefundID  int     `json:"refundId"`
+	ProductID int     `json:"productId"`
+	VariantID int     `json:"variantId,omitempty"`
+	Quantity  int     `json:"quantity"`
+	Amount    float64 `json:"amount"`
+}
+
+// CreateRefundPayload refunds the given line quantities, or everything not
+// refunded yet when Items is empty.
+type CreateRefundPayload struct {
+	Items   []RefundItemPayload `json:"items" validate:"dive"`
+	Reason  string              `json:"reason" validate:"max=255"`
+	Restock bool                `json:"restock"`
+}
+
+type RefundItemPayload struct {
+	ProductID int `json:"productId" validate:"required"`
+	// VariantID picks the line of a variant of the product
+	VariantID int `json:"variantId,omitempty"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
@@ -0,0 +1,37 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInsufficientStock is returned when the units asked for are not available
+// to sell, either because they are out of stock or held by other carts.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ReservationStore holds units of a product for a cart so they cannot be sold
+// to someone else until the reservation expires or the cart checks out. The
+// units of a variant come out of the stock of the variant, and a variantID of
+// zero stands for a product sold without variants.
+type ReservationStore interface {
+	// ReserveStock sets the units cartID holds of the variant of productID to
+	// quantity and renews the reservation until expiresAt. Growing a
+	// reservation beyond what is available fails with ErrInsufficientStock;
+	// shrinking one never fails.
+	ReserveStock(cartID int, productID int, variantID int, quantity int, expiresAt time.Time) error
+	ReleaseReservation(cartID int, productID int, variantID int) error
+	ReleaseCartReservations(cartID int) error
+	// GetAvailableStock is the stock of the variant of productID minus the
+	// units held by active reservations of carts other than exceptCartID.
+	GetAvailableStock(productID int, variantID int, exceptCartID int) (int, error)
+	DeleteExpiredReservations() (int64, error)
+}
+
+type Reservation struct {
+	CartID    int       `json:"cartId"`
+	ProductID int       `json:"productId"`
+	VariantID int       `json:"variantId,omitempty"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+type ShipmentStore interface {
+	// CreateShipment saves shipment with its items and sets its ID.
+	CreateShipment(shipment *Shipment) error
+	// GetShipment returns a NotFound error when shipmentID is not a
+	// shipment of orderID.
+	GetShipment(orderID int, shipmentID int) (*Shipment, error)
+	GetShipmentsByOrderID(orderID int) ([]*Shipment, error)
+	UpdateShipment(shipment *Shipment) error
+}
+
+// Shipment is a package sent to the customer with some or all of the units of
+// an order.
+type Shipment struct {
+	ID           int             `json:"id"`
+	OrderID      int             `json:"orderId"`
+	Carrier      string          `json:"carrier"`
+	TrackingCode string          `json:"trackingCode"`
+	TrackingURL  string          `json:"trackingUrl"`
+	Status       ShipmentStatus  `json:"status"`
+	Items        []*ShipmentItem `json:"items"`
+	ShippedAt    time.Time       `json:"shippedAt"`
+	// DeliveredAt is nil until the shipment is delivered
+	DeliveredAt *time.Time `json:"deliveredAt"`
+	CreatedBy   *int       `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+type ShipmentItem struct {
+	ShipmentID int `json:"shipmentId"`
+	ProductID  int `json:"productId"`
+	VariantID  int `json:"variantId,omitempty"`
+	Quantity   int `json:"quantity"`
+}
+
+// CreateShipmentPayload ships the given line quantities, or every unit not
+// shipped yet when Items is empty.
+type CreateShipmentPayload struct {
+	Carrier      string                `json:"carrier" validate:"required,max=50"`
+	TrackingCode string                `json:"trackingCode" validate:"max=100"`
+	TrackingURL  string                `json:"trackingUrl" validate:"omitempty,url,max=512"`
+	Items        []ShipmentItemPayload `json:"items" validate:"dive"`
+	// ShippedAt defaults to now
+	ShippedAt *time.Time `json:"shippedAt,omitempty"`
+}
+
+type ShipmentItemPayload struct {
+	ProductID int `json:"productId" validate:"required"`
+	// VariantID picks the line of a variant of the product
+	VariantID int `json:"variantId,omitempty"`
+	Quantity  int `json:"quantity" validate:"required,gt=0"`
+}
+
+type UpdateShipmentPayload struct {
+	Carrier      *string         `json:"carrier,omitempty" validate:"omitempty,min=1,max=50"`
+	TrackingCode *string         `json:"trackingCode,omitempty" validate:"omitempty,max=100"`
+	TrackingURL  *string         `json:"trackingUrl,omitempty" validate:"omitempty,url,max=512"`
+	Status       *ShipmentStatus `json:"status,omitempty"`
+	// DeliveredAt defaults to now when the status becomes DELIVERED
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+}
@@ -0,0 +1,92 @@
+package types
+
+import "time"
+
+// ShippingCalculator prices the shipping of a set of parcels. Each carrier or
+// pricing scheme implements it.
+type ShippingCalculator interface {
+	// Quote returns the options that can deliver the parcels to the
+	// destination, the cheapest first. A service that can't take the
+	// parcels is left out.
+	Quote(req *ShippingQuoteRequest) ([]*ShippingOption, error)
+}
+
+type ShippingRateStore interface {
+	GetShippingRates() ([]*ShippingRate, error)
+	CreateShippingRate(payload CreateShippingRatePayload) (*ShippingRate, error)
+	DeleteShippingRate(rateID int) error
+}
+
+type ShippingService interface {
+	// GetShippingOptions quotes the cart of the user for delivery to
+	// addressID, one of the user's addresses.
+	GetShippingOptions(userID int, addressID int) ([]*ShippingOption, error)
+	// QuoteCart quotes the items of a validated cart for delivery to
+	// destination. Free shipping thresholds are checked against the cart
+	// total.
+	QuoteCart(destination *ShippingAddress, cart *CartValidation) ([]*ShippingOption, error)
+	GetShippingRates() ([]*ShippingRate, error)
+	CreateShippingRate(payload CreateShippingRatePayload) (*ShippingRate, error)
+	DeleteShippingRate(rateID int) error
+}
+
+type ShippingQuoteRequest struct {
+	Destination *ShippingAddress
+	Parcels     []*Parcel
+	// Subtotal is what the items cost, for free shipping thresholds
+	Subtotal float64
+}
+
+// Parcel is Quantity packages of the same dimensions.
+type Parcel struct {
+	Dimensions ProductDimensions
+	Quantity   int
+}
+
+type ShippingOption struct {
+	// ID is the code of the service, which checkout takes as the chosen
+	// option
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Price   float64 `json:"price"`
+	MinDays int     `json:"minDays"`
+	MaxDays int     `json:"maxDays"`
+	// Free is set when the subtotal reached the free shipping threshold
+	Free bool `json:"free"`
+}
+
+// ShippingRate prices a service for parcels up to MaxWeightGrams sent to a
+// zone: a CEP range, a state, or anywhere when neither is set. The most
+// specific zone matching an address wins.
+type ShippingRate struct {
+	ID          int    `json:"id"`
+	Service     string `json:"service"`
+	ServiceName string `json:"serviceName"`
+	// State is the two letter code of a state
+	State *string `json:"state"`
+	// PostalCodeFrom and PostalCodeTo bound a range of CEPs, written as
+	// their 8 digits
+	PostalCodeFrom *string `json:"postalCodeFrom"`
+	PostalCodeTo   *string `json:"postalCodeTo"`
+	MaxWeightGrams int     `json:"maxWeightGrams"`
+	Price          float64 `json:"price"`
+	MinDays        int     `json:"minDays"`
+	MaxDays        int     `json:"maxDays"`
+	// FreeAbove is the subtotal from which the service is free, nil when it
+	// never is
+	FreeAbove *float64  `json:"freeAbove"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateShippingRatePayload struct {
+	Service        string   `json:"service" validate:"required,max=30"`
+	ServiceName    string   `json:"serviceName" validate:"required,max=100"`
+	State          *string  `json:"state,omitempty" validate:"omitempty,len=2,alpha"`
+	PostalCodeFrom *string  `json:"postalCodeFrom,omitempty" validate:"required_with=PostalCodeTo,omitempty,len=8,numeric"`
+	PostalCodeTo   *string  `json:"postalCodeTo,omitempty" validate:"required_with=PostalCodeFrom,omitempty,len=8,numeric"`
+	MaxWeightGrams int      `json:"maxWeightGrams" validate:"required,gt=0"`
+	Price          float64  `json:"price" validate:"gte=0"`
+	MinDays        int      `json:"minDays" validate:"required,gt=0"`
+	MaxDays        int      `json:"maxDays" validate:"required,gtefield=MinDays"`
+	FreeAbove      *float64 `json:"freeAbove,omitempty" validate:"omitempty,gte=0"`
+}
@@ -0,0 +1,19 @@
+package types
+
+// UnitOfWork runs fn with stores bound to a single database transaction.
+// The transaction commits when fn returns nil and rolls back otherwise.
+type UnitOfWork interface {
+	Do(fn func(stores *TxStores) error) error
+}
+
+type TxStores struct {
+	Orders        OrderStore
+	Carts         CartStore
+	Products      ProductStore
+	Variants      VariantStore
+	Notifications NotificationStore
+	Pix           PixStore
+	Reservations  ReservationStore
+	Coupons       CouponStore
+	Shipments     ShipmentStore
+}
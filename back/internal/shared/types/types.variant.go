@@ -0,0 +1,117 @@
+package types
+
+// VariantStore keeps the options a product comes in, such as size or color,
+// and the variants sold for combinations of their values. A variant has its
+// own SKU, stock and images, and may override the price of the product.
+type VariantStore interface {
+	GetOptions(productID int) ([]*ProductOption, error)
+	CreateOption(productID int, payload CreateProductOptionPayload) (*ProductOption, error)
+	DeleteOption(productID int, optionID int) error
+	// GetVariants returns the variants of productID, none when the product
+	// is sold without variants.
+	GetVariants(productID int) ([]*ProductVariant, error)
+	GetVariant(productID int, variantID int) (*ProductVariant, error)
+	GetVariantBySKU(sku string) (*ProductVariant, error)
+	CreateVariant(productID int, payload CreateVariantPayload) (*ProductVariant, error)
+	UpdateVariant(productID int, variantID int, payload UpdateVariantPayload) (*ProductVariant, error)
+	// DeleteVariant hides the variant and takes it out of every cart.
+	DeleteVariant(productID int, variantID int) error
+	UpdateVariantStock(variantID int, quantityChange int) error
+}
+
+type VariantService interface {
+	GetProductVariants(productID int) (*ProductVariants, error)
+	// CreateOption adds an option with its values to a product that has no
+	// variants yet.
+	CreateOption(productID int, payload CreateProductOptionPayload) (*ProductOption, error)
+	DeleteOption(productID int, optionID int) error
+	// CreateVariant takes one value of each option of the product, in a
+	// combination no other variant has.
+	CreateVariant(productID int, payload CreateVariantPayload) (*ProductVariant, error)
+	UpdateVariant(productID int, variantID int, payload UpdateVariantPayload) (*ProductVariant, error)
+	DeleteVariant(productID int, variantID int) error
+}
+
+type ProductOption struct {
+	ID        int                   `json:"id"`
+	ProductID int                   `json:"productId"`
+	Name      string                `json:"name"`
+	SortOrder int                   `json:"sortOrder"`
+	Values    []*ProductOptionValue `json:"values"`
+}
+
+type ProductOptionValue struct {
+	ID        int    `json:"id"`
+	OptionID  int    `json:"optionId"`
+	Value     string `json:"value"`
+	SortOrder int    `json:"sortOrder"`
+	// Available is set when a variant with the value is in stock
+	Available bool `json:"available"`
+}
+
+type ProductVariant struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"productId"`
+	SKU       string `json:"sku"`
+	// PriceOverride replaces the base price of the product, nil when the
+	// variant sells at it
+	PriceOverride *float64 `json:"priceOverride"`
+	StockQuantity int      `json:"stockQuantity"`
+	// AvailableQuantity is StockQuantity minus the units reserved by carts
+	AvailableQuantity int `json:"availableQuantity"`
+	// OptionValueIDs hold one value of each option of the product
+	OptionValueIDs []int `json:"optionValueIds"`
+	// Label names the values of the variant in the order of the options,
+	// such as "M / Blue"
+	Label   string         `json:"label"`
+	Images  []VariantImage `json:"images"`
+	Version int            `json:"-"`
+}
+
+type VariantImage struct {
+	ID        int    `json:"id"`
+	VariantID int    `json:"variantId"`
+	ImageUrl  string `json:"imageUrl"`
+	SortOrder int    `json:"sortOrder"`
+}
+
+// ProductVariants is the option matrix of a product: its options and the
+// variants sold for their values.
+type ProductVariants struct {
+	Options  []*ProductOption  `json:"options"`
+	Variants []*ProductVariant `json:"variants"`
+}
+
+// VariantDetails is a variant priced for the product page.
+type VariantDetails struct {
+	*ProductVariant
+	Price     float64 `json:"price"`
+	BasePrice float64 `json:"basePrice"`
+	InStock   bool    `json:"inStock"`
+	// Pricing explains Price, the price of one unit
+	Pricing *PriceBreakdown `json:"pricing"`
+}
+
+type CreateProductOptionPayload struct {
+	Name      string   `json:"name" validate:"required,max=50"`
+	SortOrder int      `json:"sortOrder"`
+	Values    []string `json:"values" validate:"required,min=1,dive,required,max=50"`
+}
+
+type CreateVariantPayload struct {
+	SKU string `json:"sku" validate:"required,max=64"`
+	// Price overrides the base price of the product when given
+	Price          *float64       `json:"price,omitempty" validate:"omitempty,gt=0"`
+	StockQuantity  int            `json:"stockQuantity" validate:"min=0"`
+	OptionValueIDs []int          `json:"optionValueIds" validate:"required,min=1"`
+	Images         []ImagePayload `json:"images,omitempty" validate:"omitempty,dive"`
+}
+
+type UpdateVariantPayload struct {
+	SKU *string `json:"sku,omitempty" validate:"omitempty,min=1,max=64"`
+	// Price of zero removes the override
+	Price         *float64 `json:"price,omitempty" validate:"omitempty,min=0"`
+	StockQuantity *int     `json:"stockQuantity,omitempty" validate:"omitempty,min=0"`
+	// Images replace the images of the variant when given
+	Images []ImagePayload `json:"images,omitempty" validate:"omitempty,dive"`
+}
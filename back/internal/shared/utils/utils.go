@@ -2,12 +2,18 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
@@ -105,6 +111,11 @@ func FormatValidationError(errs validator.ValidationErrors) error {
 }
 
 func getJsonTag(err validator.FieldError) string {
+	// Type is the type of the failing field itself, which only has fields to
+	// look up when it is a struct
+	if err.Type().Kind() != reflect.Struct {
+		return ""
+	}
 	if field, ok := err.Type().FieldByName(err.Field()); ok {
 		if jsonTag := field.Tag.Get("json"); jsonTag != "" {
 			// remove json tag
@@ -117,3 +128,72 @@ func getJsonTag(err validator.FieldError) string {
 func ParseInt(s string) (int, error) {
 	return strconv.Atoi(s)
 }
+
+// ParsePageRequest reads the cursor and limit query params of a paginated
+// list. The limit defaults to types.DefaultPageLimit and is capped at
+// types.MaxPageLimit.
+func ParsePageRequest(r *http.Request) (types.PageRequest, error) {
+	page := types.PageRequest{Limit: types.DefaultPageLimit}
+	query := r.URL.Query()
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := types.DecodeCursor(cursor)
+		if err != nil {
+			return page, apperrors.NewValidationError("cursor", "invalid cursor")
+		}
+		page.After = after
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return page, apperrors.NewValidationError("limit", "limit must be a positive number")
+		}
+		page.Limit = min(n, types.MaxPageLimit)
+	}
+
+	return page, nil
+}
+
+// WriteServiceError answers with the status carried by an AppError and falls
+// back to a 500 with the given message for any other error.
+func WriteServiceError(w http.ResponseWriter, err error, message string) {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		WriteJson(w, appErr.StatusCode(), map[string]interface{}{
+			"error":   message,
+			"details": appErr.Details,
+		})
+		return
+	}
+
+	WriteJson(w, http.StatusInternalServerError, map[string]string{"error": message})
+}
+
+// ParseDateParam reads the query param name as a date, which stands for its
+// first moment, or the first moment of the next day when it ends a range. An
+// RFC 3339 time is taken as it is. A missing param gives nil.
+func ParseDateParam(query url.Values, name string, endOfRange bool) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfRange {
+			date = date.AddDate(0, 0, 1)
+		}
+		return &date, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, apperrors.NewValidationError(name, name+" must be a date or an RFC 3339 time")
+	}
+	return &date, nil
+}
+
+// RoundCents rounds an amount of money to the cent.
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
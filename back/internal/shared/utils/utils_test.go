@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/apperrors"
+	"github.com/nobregas/ecommerce-mobile-back/internal/shared/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -252,3 +256,129 @@ type TestValidationStruct struct {
 	Password string `json:"password" validate:"required,min=6"`
 	Age      int    `json:"age" validate:"required,min=18,max=120"`
 }
+
+func TestParsePageRequest(t *testing.T) {
+	createdAt := time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC)
+	cursor := types.Cursor{ID: 42, Time: &createdAt}
+
+	tests := []struct {
+		name          string
+		query         string
+		expected      types.PageRequest
+		expectedError error
+	}{
+		{
+			name:     "Defaults",
+			expected: types.PageRequest{Limit: types.DefaultPageLimit},
+		},
+		{
+			name:     "Cursor and limit",
+			query:    "?cursor=" + cursor.Encode() + "&limit=5",
+			expected: types.PageRequest{After: &cursor, Limit: 5},
+		},
+		{
+			name:     "Limit above the maximum",
+			query:    "?limit=1000",
+			expected: types.PageRequest{Limit: types.MaxPageLimit},
+		},
+		{
+			name:          "Invalid limit",
+			query:         "?limit=0",
+			expectedError: apperrors.NewValidationError("limit", "limit must be a positive number"),
+		},
+		{
+			name:          "Invalid cursor",
+			query:         "?cursor=not-a-cursor",
+			expectedError: apperrors.NewValidationError("cursor", "invalid cursor"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test"+tt.query, nil)
+
+			page, err := ParsePageRequest(req)
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected.Limit, page.Limit)
+			if tt.expected.After == nil {
+				assert.Nil(t, page.After)
+			} else {
+				assert.Equal(t, tt.expected.After.ID, page.After.ID)
+				assert.True(t, tt.expected.After.Time.Equal(*page.After.Time))
+			}
+		})
+	}
+}
+
+func TestWriteServiceError(t *testing.T) {
+	t.Run("AppError keeps its status and details", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		WriteServiceError(rr, fmt.Errorf("wrapped: %w", apperrors.NewValidationError("items", "cart is empty")), "Failed to checkout")
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, "Failed to checkout", body["error"])
+		assert.Equal(t, "cart is empty", body["details"].(map[string]interface{})["reason"])
+	})
+
+	t.Run("Other errors are a 500", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+
+		WriteServiceError(rr, fmt.Errorf("connection refused"), "Failed to checkout")
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.JSONEq(t, `{"error":"Failed to checkout"}`, rr.Body.String())
+	})
+}
+
+func TestParseDateParam(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		endOfRange bool
+		expected   *time.Time
+		expectErr  bool
+	}{
+		{name: "Missing", query: ""},
+		{name: "Date", query: "from=2025-05-10", expected: ptrTime(time.Date(2025, 5, 10, 0, 0, 0, 0, time.UTC))},
+		{name: "Date ending a range", query: "from=2025-05-10", endOfRange: true, expected: ptrTime(time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC))},
+		{name: "RFC 3339 time", query: "from=2025-05-10T15:04:05Z", endOfRange: true, expected: ptrTime(time.Date(2025, 5, 10, 15, 4, 5, 0, time.UTC))},
+		{name: "Invalid", query: "from=10/05/2025", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+
+			date, err := ParseDateParam(query, "from", tt.endOfRange)
+			if tt.expectErr {
+				assert.Equal(t, apperrors.NewValidationError("from", "from must be a date or an RFC 3339 time"), err)
+				return
+			}
+
+			assert.NoError(t, err)
+			if tt.expected == nil {
+				assert.Nil(t, date)
+			} else {
+				assert.True(t, tt.expected.Equal(*date), "got %v", date)
+			}
+		})
+	}
+}
+
+func TestRoundCents(t *testing.T) {
+	assert.Equal(t, 10.0, RoundCents(9.999))
+	assert.Equal(t, 0.3, RoundCents(0.1+0.2))
+	assert.Equal(t, -1.24, RoundCents(-1.235))
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
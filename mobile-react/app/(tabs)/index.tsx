@@ -1,5 +1,5 @@
-import React, { useState, useEffect, useCallback } from 'react';
-import { ActivityIndicator, ScrollView, StyleSheet, View, FlatList, TouchableOpacity } from 'react-native';
+import React, { useState, useEffect, useCallback, useRef } from 'react';
+import { ActivityIndicator, ScrollView, StyleSheet, View, FlatList, TouchableOpacity, NativeScrollEvent, NativeSyntheticEvent } from 'react-native';
 import { Stack, useRouter } from 'expo-router';
 import Header from '@/components/Header';
 import ProductList from '@/components/ProductList';
@@ -18,6 +18,11 @@ const HomeScreen = () => {
   const [saleProducts, setSaleProducts] = useState<ProductType[]>([]);
   const [categories, setCategories] = useState<Category[]>([]);
   const [loading, setLoading] = useState<boolean>(true);
+  // nextCursor is null once the last page of products is loaded
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [loadingMore, setLoadingMore] = useState<boolean>(false);
+  // scroll events keep coming while a page loads, the ref lets only one through
+  const loadingMoreRef = useRef(false);
   const { fetchCartItems } = useCartStore();
   const router = useRouter();
 
@@ -34,7 +39,8 @@ const HomeScreen = () => {
       ]);
 
       setCategories(categoryData);
-      setProducts(productData);
+      setProducts(productData.items);
+      setNextCursor(productData.nextCursor);
     } catch (error) {
       if (__DEV__) {
         console.log(error);
@@ -44,6 +50,34 @@ const HomeScreen = () => {
     }
   };
 
+  const fetchMoreProducts = async () => {
+    if (!nextCursor || loadingMoreRef.current) return;
+
+    loadingMoreRef.current = true;
+    try {
+      setLoadingMore(true);
+      const page = await productService.getAllProducts(nextCursor);
+      setProducts((current) => [...current, ...page.items]);
+      setNextCursor(page.nextCursor);
+    } catch (error) {
+      if (__DEV__) {
+        console.log(error);
+      }
+    } finally {
+      loadingMoreRef.current = false;
+      setLoadingMore(false);
+    }
+  };
+
+  // the product list sits in a ScrollView, so the next page loads when the
+  // scroll gets close to its bottom
+  const handleScroll = ({ nativeEvent }: NativeSyntheticEvent<NativeScrollEvent>) => {
+    const { layoutMeasurement, contentOffset, contentSize } = nativeEvent;
+    if (layoutMeasurement.height + contentOffset.y >= contentSize.height - 300) {
+      fetchMoreProducts();
+    }
+  };
+
   const handleSupportChat = () => {
     router.push("/support/chat");
   };
@@ -63,13 +97,14 @@ const HomeScreen = () => {
   return (
     <>
       <Stack.Screen options={{ headerShown: true, header: () => <Header /> }} />
-      <ScrollView>
+      <ScrollView onScroll={handleScroll} scrollEventThrottle={400}>
         {renderCategories()}
         {renderFlashSale()}
         <View style={styles.bannerWrapper}>
           <Image source={require("@/assets/images/sale-banner.jpg")} style={styles.banner} />
         </View>
         {renderProductList()}
+        {loadingMore && <ActivityIndicator style={styles.loadingMore} />}
       </ScrollView>
 
       {/* Floating Chat Support Button */}
@@ -90,6 +125,9 @@ const styles = StyleSheet.create({
     justifyContent: 'center',
     alignItems: 'center',
   },
+  loadingMore: {
+    marginVertical: 20,
+  },
   bannerWrapper: {
     marginHorizontal: 20,
     marginBottom: 10,
@@ -1,5 +1,5 @@
-import { FlatList, StyleSheet, Text, View } from 'react-native'
-import React, { useEffect, useState } from 'react'
+import { ActivityIndicator, FlatList, StyleSheet, Text, View } from 'react-native'
+import React, { useEffect, useRef, useState } from 'react'
 import { useHeaderHeight } from '@react-navigation/elements'
 import { Stack } from 'expo-router'
 import { Ionicons } from '@expo/vector-icons'
@@ -12,6 +12,11 @@ const NotificationsScreen = (props: Props) => {
   const headerHeight = useHeaderHeight()
 
   const [notifications, setNotifications] = useState<Notification[]>([])
+  // nextCursor is null once the last page is loaded
+  const [nextCursor, setNextCursor] = useState<string | null>(null)
+  const [loadingMore, setLoadingMore] = useState(false)
+  // onEndReached may fire again while a page loads, the ref lets only one through
+  const loadingMoreRef = useRef(false)
 
   useEffect(() => {
     fetchNotifications()
@@ -19,15 +24,34 @@ const NotificationsScreen = (props: Props) => {
 
   const fetchNotifications = async () => {
       try {
-        const categoriesData = await notificationService.getNotifications()
+        const page = await notificationService.getNotifications()
   
-        setNotifications(categoriesData)
+        setNotifications(page.items)
+        setNextCursor(page.nextCursor)
   
       } catch (error) {
         console.log(error)
       }
     }
 
+  const fetchMoreNotifications = async () => {
+      if (!nextCursor || loadingMoreRef.current) return
+
+      loadingMoreRef.current = true
+      try {
+        setLoadingMore(true)
+        const page = await notificationService.getNotifications(nextCursor)
+
+        setNotifications((current) => [...current, ...page.items])
+        setNextCursor(page.nextCursor)
+      } catch (error) {
+        console.log(error)
+      } finally {
+        loadingMoreRef.current = false
+        setLoadingMore(false)
+      }
+    }
+
   return (
     <>
       <Stack.Screen
@@ -37,6 +61,9 @@ const NotificationsScreen = (props: Props) => {
         <FlatList
           data={notifications}
           keyExtractor={(item) => item.id.toString()}
+          onEndReached={fetchMoreNotifications}
+          onEndReachedThreshold={0.5}
+          ListFooterComponent={loadingMore ? <ActivityIndicator style={styles.loadingMore} /> : null}
           renderItem={({ item, index }) => (
             <Animated.View 
             style={styles.notificationWrapper} 
@@ -97,5 +124,8 @@ const styles = StyleSheet.create({
     marginTop: 5,
     lineHeight: 20
   },
+  loadingMore: {
+    marginVertical: 10
+  },
   
 })
\ No newline at end of file
@@ -18,7 +18,7 @@ const StatusColors = {
 const OrdersScreen = () => {
   const router = useRouter()
   const headerHeight = useHeaderHeight()
-  const { fetchOrders, orders, isLoading, error, clearError } = useOrderStore()
+  const { fetchOrders, fetchMoreOrders, orders, isLoading, isLoadingMore, error, clearError } = useOrderStore()
 
   useEffect(() => {
     fetchOrders()
@@ -118,6 +118,9 @@ const OrdersScreen = () => {
           <FlatList
             data={orders}
             keyExtractor={(item) => item.id.toString()}
+            onEndReached={fetchMoreOrders}
+            onEndReachedThreshold={0.5}
+            ListFooterComponent={isLoadingMore ? <ActivityIndicator style={styles.loadingMore} color={Colors.primary} /> : null}
             renderItem={({ item, index }) => (
               <Animated.View entering={FadeInDown.delay(100 * index).duration(400)}>
                 <TouchableOpacity
@@ -169,6 +172,9 @@ const styles = StyleSheet.create({
     fontSize: 16,
     color: Colors.gray,
   },
+  loadingMore: {
+    marginVertical: 16,
+  },
   errorContainer: {
     flex: 1,
     justifyContent: 'center',
@@ -149,8 +149,8 @@ If you don't know the answer to a specific product question, politely ask for mo
     }
 
     try {
-      // Get products
-      contextData.products = await productService.getAllProducts();
+      // Get products, the first page is enough context
+      contextData.products = (await productService.getAllProducts()).items;
     } catch (error) {
       console.log("Could not get products:", error);
     }
@@ -170,8 +170,8 @@ If you don't know the answer to a specific product question, politely ask for mo
     }
 
     try {
-      // Get orders
-      contextData.orders = await orderService.getOrders();
+      // Get orders, the most recent page is enough context
+      contextData.orders = (await orderService.getOrders()).items;
     } catch (error) {
       console.log("Could not get orders:", error);
     }
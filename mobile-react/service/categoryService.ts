@@ -1,4 +1,4 @@
-import api from "./apiClient";
+import { fetchAllPages } from "./pagination";
 
 export interface Category {
     id: number;
@@ -10,10 +10,8 @@ export interface Category {
 class CategoryService {
     async getAllCategories(): Promise<Category[]> {
         try {
-            const response = await api.get("/category");
-            console.log("get All categories", response.status)
-
-            return response.data
+            // every category shows up at once, so all the pages are fetched
+            return await fetchAllPages<Category>("/category");
         } catch (error) {
             throw this.handleError(error);
         }
@@ -1,4 +1,4 @@
-import api from "./apiClient";
+import { fetchPage, Page } from "./pagination";
 
 import { formatDateTime } from "@/utils/sharedFunctions";
 
@@ -12,13 +12,16 @@ export interface Notification {
 }
 
 class NotificationService {
-  async getNotifications(): Promise<Notification[]> {
+  async getNotifications(cursor?: string | null): Promise<Page<Notification>> {
     try {
-      const response = await api.get(`/notification/my`);
-      return response.data.map((notification: Notification) => ({
-        ...notification,
-        createdAt: formatDateTime(notification.createdAt)
-      }));
+      const page = await fetchPage<Notification>(`/notification/my`, cursor);
+      return {
+        ...page,
+        items: page.items.map((notification) => ({
+          ...notification,
+          createdAt: formatDateTime(notification.createdAt)
+        }))
+      };
     } catch (error) {
       throw this.handleError(error);
     }
@@ -1,4 +1,5 @@
 import api from "./apiClient";
+import { fetchPage, Page } from "./pagination";
 import { formatDateTime } from "@/utils/sharedFunctions";
 
 export enum OrderStatus {
@@ -57,30 +58,36 @@ class OrderService {
         }
     }
 
-    async getOrders(): Promise<OrderHistory[]> {
+    async getOrders(cursor?: string | null): Promise<Page<OrderHistory>> {
         try {
-            const response = await api.get('/orders');
-            return response.data.map((order: OrderHistory) => ({
-                ...order,
-                createdAt: formatDateTime(order.createdAt),
-                updatedAt: formatDateTime(order.updatedAt)
-            }));
+            const page = await fetchPage<OrderHistory>('/orders', cursor);
+            return {
+                ...page,
+                items: page.items.map((order) => ({
+                    ...order,
+                    createdAt: formatDateTime(order.createdAt),
+                    updatedAt: formatDateTime(order.updatedAt)
+                }))
+            };
         } catch (error) {
             throw this.handleError(error);
         }
     }
 
-    async getOrdersWithItems(): Promise<OrderWithItems[]> {
+    async getOrdersWithItems(cursor?: string | null): Promise<Page<OrderWithItems>> {
         try {
-            const response = await api.get('/orders?withItems=true');
-            return response.data.map((orderWithItems: OrderWithItems) => ({
-                ...orderWithItems,
-                order: {
-                    ...orderWithItems.order,
-                    createdAt: formatDateTime(orderWithItems.order.createdAt),
-                    updatedAt: formatDateTime(orderWithItems.order.updatedAt)
-                }
-            }));
+            const page = await fetchPage<OrderWithItems>('/orders?withItems=true', cursor);
+            return {
+                ...page,
+                items: page.items.map((orderWithItems) => ({
+                    ...orderWithItems,
+                    order: {
+                        ...orderWithItems.order,
+                        createdAt: formatDateTime(orderWithItems.order.createdAt),
+                        updatedAt: formatDateTime(orderWithItems.order.updatedAt)
+                    }
+                }))
+            };
         } catch (error) {
             throw this.handleError(error);
         }
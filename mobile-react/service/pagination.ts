@@ -0,0 +1,29 @@
+import api from "./apiClient";
+
+// Page is what the API returns for a list: the items of one page and the
+// cursor of the next, null on the last page.
+export interface Page<T> {
+    items: T[];
+    nextCursor: string | null;
+}
+
+export async function fetchPage<T>(url: string, cursor?: string | null): Promise<Page<T>> {
+    const response = await api.get(url, { params: cursor ? { cursor } : undefined });
+    return {
+        items: response.data.items,
+        nextCursor: response.data.nextCursor ?? null
+    };
+}
+
+// fetchAllPages follows nextCursor until the last page. Only for lists that
+// are short by nature, the others load a page at a time as they scroll.
+export async function fetchAllPages<T>(url: string): Promise<T[]> {
+    const items: T[] = [];
+    let cursor: string | null = null;
+    do {
+        const page: Page<T> = await fetchPage<T>(url, cursor);
+        items.push(...page.items);
+        cursor = page.nextCursor;
+    } while (cursor);
+    return items;
+}
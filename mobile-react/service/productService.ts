@@ -1,4 +1,5 @@
 import api from "./apiClient";
+import { fetchPage, Page } from "./pagination";
 
 export interface ProductDetailsType {
     id: number;
@@ -38,15 +39,16 @@ export interface ProductDetailsType {
         }
       }
     
-      async getAllProducts(): Promise<SimpleProductObject[]> {
+      async getAllProducts(cursor?: string | null): Promise<Page<SimpleProductObject>> {
         try {
-          const response = await api.get("/product/all/details");
-          console.log("get All products", response.status)
-
-          return response.data.map((item: any) => ({
-            ...item,
-            image: item.image.imageUrl
-          }));
+          const page = await fetchPage<any>("/product/all/details", cursor);
+          return {
+            ...page,
+            items: page.items.map((item) => ({
+              ...item,
+              image: item.image.imageUrl
+            }))
+          };
         } catch (error) {
           throw this.handleError(error);
         }
@@ -3,11 +3,15 @@ import orderService, { CreateOrderPayload, OrderHistory, OrderStatus, OrderWithI
 
 interface OrderStore {
   orders: OrderHistory[];
+  // nextCursor is null once the last page of orders is loaded
+  nextCursor: string | null;
   orderDetails: OrderWithItems | null;
   isLoading: boolean;
+  isLoadingMore: boolean;
   error: string | null;
   
   fetchOrders: () => Promise<void>;
+  fetchMoreOrders: () => Promise<void>;
   fetchOrderWithItems: (orderId: number) => Promise<void>;
   createOrder: (payload: CreateOrderPayload) => Promise<OrderHistory>;
   updateOrderStatus: (orderId: number, status: OrderStatus) => Promise<void>;
@@ -16,21 +20,44 @@ interface OrderStore {
 
 export const useOrderStore = create<OrderStore>((set, get) => ({
   orders: [],
+  nextCursor: null,
   orderDetails: null,
   isLoading: false,
+  isLoadingMore: false,
   error: null,
 
   fetchOrders: async () => {
     try {
       set({ isLoading: true, error: null });
-      const orders = await orderService.getOrders();
-      set({ orders, isLoading: false });
+      const page = await orderService.getOrders();
+      set({ orders: page.items, nextCursor: page.nextCursor, isLoading: false });
     } catch (error) {
       set({ error: error instanceof Error ? error.message : 'Error fetching orders', isLoading: false });
       console.error('Error fetching orders:', error);
     }
   },
 
+  fetchMoreOrders: async () => {
+    const { nextCursor, isLoading, isLoadingMore } = get();
+    if (!nextCursor || isLoading || isLoadingMore) {
+      return;
+    }
+
+    try {
+      set({ isLoadingMore: true });
+      const page = await orderService.getOrders(nextCursor);
+      set(state => ({
+        orders: [...state.orders, ...page.items],
+        nextCursor: page.nextCursor,
+        isLoadingMore: false
+      }));
+    } catch (error) {
+      // the orders already loaded stay on screen, scrolling again retries
+      set({ isLoadingMore: false });
+      console.error('Error fetching more orders:', error);
+    }
+  },
+
   fetchOrderWithItems: async (orderId: number) => {
     try {
       set({ isLoading: true, error: null });